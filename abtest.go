@@ -0,0 +1,80 @@
+// abtest.go: A/B パラメータ比較モード。
+// 2つのパラメータセットを読み込み、`coastpad ctl flip` で瞬時に切り替える。
+// 各コーストで使われたセットはラベル（A/B）のみログに出すため、
+// どちらのプリセットか意識せずに摩擦感をブラインド比較できる。
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// abLabels は A/B セットの表示ラベル。
+var abLabels = [2]string{"A", "B"}
+
+// abState は A/B 比較モードの状態を表す。
+type abState struct {
+	enabled bool
+	sets    [2]coastParams
+	active  int // 使用中のセット（0=A, 1=B）
+}
+
+// parseABPresets は "presetA,presetB" 形式の指定を2つのパラメータセットに変換する。
+func parseABPresets(spec string) ([2]coastParams, error) {
+	var sets [2]coastParams
+	names := strings.Split(spec, ",")
+	if len(names) != 2 {
+		return sets, fmt.Errorf("A/B spec must be two presets separated by a comma, got %q", spec)
+	}
+	for i, name := range names {
+		p, err := lookupPreset(strings.TrimSpace(name))
+		if err != nil {
+			return sets, err
+		}
+		sets[i] = p
+	}
+	return sets, nil
+}
+
+// EnableAB は A/B 比較モードを有効にし、セット A を適用する。
+func (a *App) EnableAB(sets [2]coastParams) {
+	a.call(func() {
+		a.ab = abState{enabled: true, sets: sets}
+		a.params = sets[0]
+		a.preset = ""
+	})
+}
+
+// FlipAB は使用するセットを切り替え、新しいセットのラベルを返す。
+func (a *App) FlipAB() (label string, err error) {
+	a.call(func() {
+		if !a.ab.enabled {
+			err = fmt.Errorf("A/B mode is not enabled (start with --ab=presetA,presetB)")
+			return
+		}
+		a.ab.active ^= 1
+		a.params = a.ab.sets[a.ab.active]
+		a.preset = ""
+		label = abLabels[a.ab.active]
+	})
+	return label, err
+}
+
+// abLabel は使用中のセットのラベルを返す。A/B モードでなければ空文字を返す。
+// アクター goroutine から呼ぶこと。
+func (a *App) abLabel() string {
+	if !a.ab.enabled {
+		return ""
+	}
+	return abLabels[a.ab.active]
+}
+
+// ctlFlip は制御コマンド `flip` を処理する。
+func ctlFlip(a *App, _ []string) (any, error) {
+	label, err := a.FlipAB()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("[ab] switched to set %s\n", label)
+	return map[string]string{"active": label}, nil
+}
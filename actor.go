@@ -0,0 +1,246 @@
+// actor.go: 状態機械のアクター。
+// App の状態（タッチ・ドラッグ・コースト・スクロール・パラメータ）は Run の goroutine だけが所有し、
+// コールバックや制御ソケットからは型付きメッセージを inbox に送って処理を依頼する。
+// 各メッセージは「状態遷移（prepareXxx）→ アクション構造体 → 副作用の実行（executeXxx）」の順に
+// 1つずつ処理されるため、ロックなしで遷移が直列化される。
+package main
+
+import "time"
+
+// inboxSize は inbox のバッファ数。タッチフレーム（~100Hz）がコーストフレームの実行中に溜まっても
+// コールバックを待たせない程度の大きさ。
+const inboxSize = 64
+
+// touchFrameMsg はマルチタッチコールバックからのタッチフレーム。
+type touchFrameMsg struct {
+	device      uintptr // フレームを送ったデバイス
+	fingerCount int
+	x, y        float64 // カーソル位置
+	padX, padY  float64 // パッド上の指の正規化座標
+	size        float64 // 接触楕円の長軸の平均（不明なら 0）
+	timestamp   float64
+}
+
+// mouseDownMsg は EventTap からのマウスダウン。reply に解放すべき保留マウスアップを返す。
+type mouseDownMsg struct {
+	attrs   dragAttrs      // マウスダウンのクリック回数と修飾キー
+	menuBar bool           // メニューバーの項目の並べ替えの始まりか（menubar.go）
+	source  eventSourceRef // マウスダウンと同じ状態 ID のイベントソース（作れなければ 0。所有権はアクターに移る）
+	reply   chan mouseDownAction
+}
+
+// otherMouseDownMsg は EventTap からの左以外のボタン（右・その他）のマウスダウン。
+// reply に解放すべき保留マウスアップを返す。
+type otherMouseDownMsg struct {
+	reply chan mouseDownAction
+}
+
+// mouseUpMsg は EventTap からのマウスアップ。reply にイベントを消費するかを返す。
+type mouseUpMsg struct {
+	event eventRef
+	reply chan bool
+}
+
+// scrollWheelMsg は EventTap からの段階的なホイール入力（行単位）。
+type scrollWheelMsg struct {
+	linesX, linesY int
+}
+
+// scrollPhaseMsg は EventTap からのスクロールフェーズ付きスクロールイベント。
+type scrollPhaseMsg struct{}
+
+// callMsg はアクター上で任意の関数を実行する（制御コマンド等の状態の読み書き用）。
+type callMsg struct {
+	f    func()
+	done chan struct{}
+}
+
+// send はメッセージを inbox に送る。アクターが終了済みなら送らずに false を返す。
+func (a *App) send(msg any) bool {
+	select {
+	case a.inbox <- msg:
+		return true
+	case <-a.actorDone:
+		return false
+	}
+}
+
+// await はアクターからの返信 reply を待つ。返信せずにアクターが終了した場合は ok=false を返す。
+// 終了間際に inbox へ入ったメッセージは処理されないため、EventTap のコールバックが返信を待ち続けて
+// RunLoop（と、その終了を待つ Stop）を止めないようにする。
+func await[T any](a *App, reply <-chan T) (v T, ok bool) {
+	select {
+	case v = <-reply:
+		return v, true
+	case <-a.actorDone:
+		// 終了直前に返信していればそれを使う
+		select {
+		case v = <-reply:
+			return v, true
+		default:
+			return v, false
+		}
+	}
+}
+
+// call は f をアクター上で実行し、完了を待つ。
+// アクターの開始前（起動時の設定）と終了後は、状態を所有する goroutine が他にいないため直接実行する。
+func (a *App) call(f func()) {
+	if a.inbox == nil {
+		f()
+		return
+	}
+	done := make(chan struct{})
+	if a.send(callMsg{f: f, done: done}) {
+		if _, ok := await(a, done); ok {
+			return
+		}
+	}
+	f()
+}
+
+// Run はアクターのループを実行する。Stop() が呼ばれるまでブロックする。
+// inbox のメッセージと ticker（コースト・スクロールのフレーム）を1つずつ処理する。
+//
+// 通常の慣性: mouseMoved イベントで相対的にカーソルを移動する。
+// ドラッグ慣性: mouseDragged イベントを発行してドラッグセッションを延長する。
+// ドラッグ慣性中は mouseUp を保留しているため、OS からはドラッグ継続中に見える。
+// これにより、ウィンドウ移動とリサイズの両方が慣性で動作する。
+//
+// 省電力モード（power.go）では ticker の間隔を延ばし、動きのない間は ticker を止める。
+func (a *App) Run() {
+	defer close(a.actorDone)
+
+	interval := a.frameInterval()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	idle := false // ticker を止めているか
+
+	dp := newDragPoster()
+	defer dp.close()
+
+	t1 := monotonicSeconds()
+
+	for {
+		var tick <-chan time.Time
+		if !idle {
+			tick = ticker.C
+		}
+		select {
+		case <-a.stop:
+			// 終了: 保留中のマウスアップを発行してボタンが押されたままにならないようにする。
+			// 以降のマウスアップは send が失敗するため、傍受されずにそのまま通る。
+			pending := a.pendingMouseUp
+			a.pendingMouseUp = 0
+			releasePendingMouseUp(pending)
+			a.mouseUpGuard.close()
+			if a.coastLog != nil {
+				a.coastLog.close()
+			}
+			return
+		case msg := <-a.inbox:
+			a.handleMessage(msg, dp)
+		case <-tick:
+			t2 := monotonicSeconds()
+			dt := t2 - t1
+			t1 = t2
+			if a.vx != 0 || a.vy != 0 || a.holdingDrop() {
+				a.record(recordedEvent{T: t2, Kind: recFrame})
+			}
+			a.runCoastFrame(t2, dp)
+			if a.smoothScroll {
+				executeScrollFrame(a.prepareScrollFrame(dt))
+			}
+		}
+		releasePendingMouseUp(a.validateDragState())
+		a.mouseUpGuard.set(a.pendingMouseUp != 0)
+		a.finishCoastRecord()
+
+		switch want := a.frameInterval(); {
+		case a.framesIdle():
+			if !idle {
+				ticker.Stop()
+				idle = true
+			}
+		case idle || want != interval:
+			// 再開時はスクロールの経過時間が止めていた間を含まないようにする
+			if idle {
+				t1 = monotonicSeconds()
+			}
+			ticker.Reset(want)
+			interval = want
+			idle = false
+		}
+	}
+}
+
+// handleMessage は inbox のメッセージを1つ処理する。
+func (a *App) handleMessage(msg any, dp *dragPoster) {
+	a.recordMessage(msg)
+	switch m := msg.(type) {
+	case touchFrameMsg:
+		releasePendingMouseUp(a.noteLocalInput(m.fingerCount))
+		if a.suspended || !a.arbitrateTouch(m.device, m.fingerCount) {
+			return
+		}
+		action := a.prepareTouchFrame(m.fingerCount, m.x, m.y, m.padX, m.padY, m.size, m.timestamp)
+		a.executeTouchFrame(action)
+		if action.coastStarted {
+			// リリース直後: ticker を待たずに最初のコーストフレームを発行し、
+			// 指を離してから動き出すまでの停止（最大でループの間隔）をなくす
+			now := monotonicSeconds()
+			a.record(recordedEvent{T: now, Kind: recFrame})
+			a.runCoastFrame(now, dp)
+		}
+	case mouseDownMsg:
+		m.reply <- a.prepareMouseDown(m.attrs, m.menuBar)
+		dp.beginSession(m.source)
+		a.queryDragWindow()
+	case otherMouseDownMsg:
+		m.reply <- a.prepareOtherMouseDown()
+	case mouseUpMsg:
+		held := a.prepareMouseUp(m.event)
+		if held && a.pendingMouseUp == m.event && !dp.hasSession() {
+			// 起動前に始まったドラッグ等でマウスダウンを見ていなければ、保留したマウスアップのソースに揃える
+			dp.beginSession(eventSourceFromEvent(m.event))
+		}
+		m.reply <- held
+	case scrollWheelMsg:
+		a.addScrollVelocity(m.linesX, m.linesY)
+	case dragWindowMsg:
+		a.applyDragWindow(m.seq, m.excluded)
+	case snapTargetMsg:
+		a.applySnapTarget(m.x, m.y)
+	case springTargetMsg:
+		a.applySpringTarget(m.target)
+	case focusMsg:
+		releasePendingMouseUp(a.applyFocus(m.name))
+	case gameMsg:
+		a.gameActive = m.active
+		releasePendingMouseUp(a.updateSuspend())
+	case sessionMsg:
+		a.sessionInactive = !m.active
+		releasePendingMouseUp(a.updateSuspend())
+	case missionControlMsg:
+		a.missionControl = m.active
+		releasePendingMouseUp(a.updateSuspend())
+	case permissionMsg:
+		a.untrusted = !m.trusted
+		releasePendingMouseUp(a.updateSuspend())
+	case remoteMsg:
+		releasePendingMouseUp(a.applyRemote(m.shared))
+	case powerMsg:
+		a.applyPower(m)
+	case thermalMsg:
+		a.applyThermal(m.state)
+	case keyDownMsg:
+		a.lastKeyDown = m.timestamp
+	case scrollPhaseMsg:
+		if a.isTouched {
+			a.touchScrolled = true
+		}
+	case callMsg:
+		m.f()
+		close(m.done)
+	}
+}
@@ -0,0 +1,338 @@
+// actor_test.go: 状態機械の遷移のテスト。
+// デバイスや EventTap を開かない App に、タッチフレーム（prepareTouchFrame）・マウスボタン（handleMessage）・
+// コーストフレーム（prepareCoastFrame）を順に与え、返されたアクションと dragPhase を確かめる。
+// 副作用（executeXxx）は実行しないため、カーソルは動かない。
+package main
+
+import "testing"
+
+// testScreen はテストのコーストが画面端にぶつからない広さのディスプレイ。
+var testScreen = displayRect{minX: -1e6, minY: -1e6, maxX: 1e6, maxY: 1e6, scale: 1}
+
+// testFrameInterval はテストのタッチフレームの間隔（秒）。
+const testFrameInterval = 0.01
+
+// scenario はデバイスを開かずに状態遷移だけを実行する App と、タッチの時刻を持つ。
+type scenario struct {
+	t   *testing.T
+	a   *App
+	dp  *dragPoster
+	now float64 // 最後のタッチフレームの timestamp
+}
+
+// newScenario はテスト用の App を作る。
+// マウスダウンでの文字の選択の問い合わせ（アクセシビリティ API）はしない。
+func newScenario(t *testing.T) *scenario {
+	t.Helper()
+	a := NewApp()
+	a.textDragCoast = true
+	dp := &dragPoster{}
+	t.Cleanup(func() {
+		if a.pendingMouseUp != 0 {
+			releaseEvent(a.pendingMouseUp)
+		}
+		dp.close()
+		if a.dragStateInvalid {
+			t.Errorf("illegal drag phase transition")
+		}
+	})
+	return &scenario{t: t, a: a, dp: dp}
+}
+
+// touchMove は fingers 本の指で (x, y) から1フレームごとに (dx, dy) ずつ n フレーム動かし、最後の位置を返す。
+func (s *scenario) touchMove(fingers int, x, y, dx, dy float64, n int) (float64, float64, touchAction) {
+	var action touchAction
+	for range n {
+		s.now += testFrameInterval
+		action = s.a.prepareTouchFrame(fingers, x, y, 0.5, 0.5, 0, s.now)
+		x += dx
+		y += dy
+	}
+	return x, y, action
+}
+
+// touchRelease は (x, y) で指を離す。コーストが始まれば、画面端にぶつからないようディスプレイを差し替える。
+func (s *scenario) touchRelease(x, y float64) touchAction {
+	s.now += testFrameInterval
+	action := s.a.prepareTouchFrame(0, x, y, 0.5, 0.5, 0, s.now)
+	s.a.screens = []displayRect{testScreen}
+	s.a.coastScreenIdx = 0
+	return action
+}
+
+// flick は fingers 本の指で (x, y) から右下へ 1000 px/s 程度で動かして離す。
+func (s *scenario) flick(fingers int, x, y float64) touchAction {
+	x, y, _ = s.touchMove(fingers, x, y, 10, 5, 5)
+	return s.touchRelease(x, y)
+}
+
+// runCoast はコーストが止まるまでコーストフレームを進め、止まったフレームのアクションを返す。
+func (s *scenario) runCoast() coastAction {
+	s.t.Helper()
+	a := s.a
+	now := a.coastT
+	for range 1000 {
+		now += a.loopInterval.Seconds()
+		if action := a.prepareCoastFrame(now); action.stopped {
+			return action
+		}
+	}
+	s.t.Fatalf("coast did not stop (v = %.1f, %.1f)", a.vx, a.vy)
+	return coastAction{}
+}
+
+// mouseDown はマウスダウンをアクターのメッセージとして処理する。
+func (s *scenario) mouseDown() mouseDownAction {
+	reply := make(chan mouseDownAction, 1)
+	s.a.handleMessage(mouseDownMsg{attrs: dragAttrs{clickState: 1}, reply: reply}, s.dp)
+	return <-reply
+}
+
+// mouseUp は (x, y) のマウスアップをアクターのメッセージとして処理し、消費されたかとイベントを返す。
+// イベントの参照はテストの終了時に解放する（保留された分は App が別に持つ）。
+func (s *scenario) mouseUp(x, y float64) (bool, eventRef) {
+	s.t.Helper()
+	event := newMouseUpEvent(x, y)
+	if event == 0 {
+		s.t.Fatal("failed to create a mouse-up event")
+	}
+	s.t.Cleanup(func() { releaseEvent(event) })
+	reply := make(chan bool, 1)
+	s.a.handleMessage(mouseUpMsg{event: event, reply: reply}, s.dp)
+	return <-reply, event
+}
+
+// startDragCoast は2本指ドラッグを投げてドラッグ慣性を始め、保留されたマウスアップを返す。
+func (s *scenario) startDragCoast() eventRef {
+	s.t.Helper()
+	a := s.a
+	s.mouseDown()
+	action := s.flick(2, 500, 500)
+	if !action.coastStarted || a.dragPhase != dragPhaseCoasting {
+		s.t.Fatalf("drag flick: coastStarted = %v, phase = %s", action.coastStarted, a.dragPhase)
+	}
+	held, event := s.mouseUp(a.coastX, a.coastY)
+	if !held || a.pendingMouseUp != event {
+		s.t.Fatalf("mouse-up during a drag coast was not held")
+	}
+	return event
+}
+
+func TestTransitions(t *testing.T) {
+	tests := []struct {
+		name           string
+		run            func(t *testing.T, s *scenario, a *App)
+		wantPhase      dragPhase
+		wantButtonDown bool
+		wantHeld       bool // マウスアップを保留しているか
+	}{
+		{
+			name: "cursor flick coasts and stops",
+			run: func(t *testing.T, s *scenario, a *App) {
+				action := s.flick(1, 500, 500)
+				if !action.coastStarted || a.vx <= 0 || a.vy <= 0 {
+					t.Fatalf("coastStarted = %v, v = (%.1f, %.1f)", action.coastStarted, a.vx, a.vy)
+				}
+				if stop := s.runCoast(); !stop.hasMove || stop.pending != 0 {
+					t.Errorf("stop frame: hasMove = %v, pending = %v", stop.hasMove, stop.pending)
+				}
+			},
+			wantPhase: dragPhaseNone,
+		},
+		{
+			name: "slow release does not coast",
+			run: func(t *testing.T, s *scenario, a *App) {
+				a.params.MinFlickSpeed = 2000
+				if action := s.flick(1, 500, 500); action.coastStarted || a.vx != 0 || a.vy != 0 {
+					t.Errorf("coastStarted = %v, v = (%.1f, %.1f)", action.coastStarted, a.vx, a.vy)
+				}
+			},
+			wantPhase: dragPhaseNone,
+		},
+		{
+			name: "click passes the mouse-up through",
+			run: func(t *testing.T, s *scenario, a *App) {
+				if action := s.mouseDown(); action.pending != 0 || action.swallow {
+					t.Errorf("mouse-down action = %+v", action)
+				}
+				if held, _ := s.mouseUp(500, 500); held {
+					t.Error("mouse-up was held")
+				}
+			},
+			wantPhase: dragPhaseNone,
+		},
+		{
+			name: "drag flick starts a drag coast holding the mouse-up",
+			run: func(t *testing.T, s *scenario, a *App) {
+				s.startDragCoast()
+			},
+			wantPhase:      dragPhaseCoasting,
+			wantButtonDown: true,
+			wantHeld:       true,
+		},
+		{
+			name: "drag coast releases the held mouse-up at rest",
+			run: func(t *testing.T, s *scenario, a *App) {
+				event := s.startDragCoast()
+				stop := s.runCoast()
+				if !stop.coastEnded || stop.pending != event {
+					t.Fatalf("stop frame: coastEnded = %v, pending = %v (want %v)", stop.coastEnded, stop.pending, event)
+				}
+				releaseEvent(stop.pending)
+			},
+			wantPhase: dragPhaseNone,
+		},
+		{
+			name: "one finger during a drag coast waits for a decision",
+			run: func(t *testing.T, s *scenario, a *App) {
+				s.startDragCoast()
+				if _, _, action := s.touchMove(1, a.coastX, a.coastY, 0, 0, 1); action.needWarp {
+					t.Error("one-finger touch warped the cursor")
+				}
+			},
+			wantPhase:      dragPhasePendingDecision,
+			wantButtonDown: true,
+			wantHeld:       true,
+		},
+		{
+			name: "moving one finger while pending ends the drag",
+			run: func(t *testing.T, s *scenario, a *App) {
+				event := s.startDragCoast()
+				x, y := a.coastX, a.coastY
+				s.touchMove(1, x, y, 0, 0, 1)
+				_, _, action := s.touchMove(1, x+20, y, 0, 0, 1)
+				if !action.needMouseUpOnly || action.pending != event {
+					t.Fatalf("needMouseUpOnly = %v, pending = %v (want %v)", action.needMouseUpOnly, action.pending, event)
+				}
+				releaseEvent(action.pending)
+			},
+			wantPhase: dragPhaseNone,
+		},
+		{
+			name: "two fingers during a drag coast follow the drag",
+			run: func(t *testing.T, s *scenario, a *App) {
+				s.startDragCoast()
+				x, y := a.coastX, a.coastY
+				_, _, action := s.touchMove(2, x+50, y+50, 0, 0, 1)
+				if !action.needWarp || action.warpX != x || action.warpY != y {
+					t.Errorf("needWarp = %v, warp = (%.1f, %.1f), want (%.1f, %.1f)", action.needWarp, action.warpX, action.warpY, x, y)
+				}
+			},
+			wantPhase:      dragPhaseFollowing,
+			wantButtonDown: true,
+			wantHeld:       true,
+		},
+		{
+			name: "followed drag thrown again coasts",
+			run: func(t *testing.T, s *scenario, a *App) {
+				s.startDragCoast()
+				if action := s.flick(2, a.coastX, a.coastY); !action.coastStarted {
+					t.Error("second throw did not coast")
+				}
+			},
+			wantPhase:      dragPhaseCoasting,
+			wantButtonDown: true,
+			wantHeld:       true,
+		},
+		{
+			name: "mouse-down during a drag coast releases the held mouse-up",
+			run: func(t *testing.T, s *scenario, a *App) {
+				event := s.startDragCoast()
+				action := s.mouseDown()
+				if action.pending != event || action.discard {
+					t.Fatalf("pending = %v (want %v), discard = %v", action.pending, event, action.discard)
+				}
+				releaseEvent(action.pending)
+			},
+			wantPhase:      dragPhaseNone,
+			wantButtonDown: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newScenario(t)
+			a := s.a
+			tt.run(t, s, a)
+			if a.dragPhase != tt.wantPhase {
+				t.Errorf("dragPhase = %s, want %s", a.dragPhase, tt.wantPhase)
+			}
+			if a.isLeftButtonDown != tt.wantButtonDown {
+				t.Errorf("isLeftButtonDown = %v, want %v", a.isLeftButtonDown, tt.wantButtonDown)
+			}
+			if held := a.pendingMouseUp != 0; held != tt.wantHeld {
+				t.Errorf("holding mouse-up = %v, want %v", held, tt.wantHeld)
+			}
+		})
+	}
+}
+
+// otherMouseDown は左以外のボタンのマウスダウンをアクターのメッセージとして処理する。
+func (s *scenario) otherMouseDown() mouseDownAction {
+	reply := make(chan mouseDownAction, 1)
+	s.a.handleMessage(otherMouseDownMsg{reply: reply}, s.dp)
+	return <-reply
+}
+
+func TestOtherMouseDown(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup は左ボタンのドラッグを進め、右クリックで解放されるべきマウスアップ（なければ 0）を返す
+		setup          func(s *scenario) eventRef
+		wantButtonDown bool
+	}{
+		{
+			name:  "during a drag coast",
+			setup: func(s *scenario) eventRef { return s.startDragCoast() },
+		},
+		{
+			name: "during a multi-finger hold",
+			setup: func(s *scenario) eventRef {
+				s.mouseDown()
+				x, y, _ := s.touchMove(2, 500, 500, 10, 0, 3)
+				held, event := s.mouseUp(x, y)
+				if !held {
+					s.t.Fatal("mouse-up during a multi-finger drag was not held")
+				}
+				return event
+			},
+		},
+		{
+			name: "with the left button still down",
+			setup: func(s *scenario) eventRef {
+				s.mouseDown()
+				s.touchMove(2, 500, 500, 10, 0, 3)
+				return 0
+			},
+			wantButtonDown: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newScenario(t)
+			a := s.a
+			want := tt.setup(s)
+			action := s.otherMouseDown()
+			if action.pending != want {
+				t.Errorf("pending = %v, want %v", action.pending, want)
+			}
+			if action.pending != 0 {
+				releaseEvent(action.pending)
+			}
+			if a.pendingMouseUp != 0 {
+				t.Errorf("pendingMouseUp = %v after the other button", a.pendingMouseUp)
+			}
+			if a.dragPhase != dragPhaseNone {
+				t.Errorf("dragPhase = %s, want %s", a.dragPhase, dragPhaseNone)
+			}
+			if a.isLeftButtonDown != tt.wantButtonDown {
+				t.Errorf("isLeftButtonDown = %v, want %v", a.isLeftButtonDown, tt.wantButtonDown)
+			}
+			if tt.wantButtonDown && !a.wasMultiFingerDrag {
+				t.Error("the ongoing multi-finger drag was reset")
+			}
+			if a.vx != 0 || a.vy != 0 {
+				t.Errorf("coast not stopped: v = (%.1f, %.1f)", a.vx, a.vy)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+// alert.go: 致命的な障害の通知センターへの通知（--notify）。
+// launchd や `coastpad start` で動かしていると標準エラー出力は誰も見ないため、
+// EventTap が回復できない・タッチデバイスがなくなった等、慣性が効かなくなる障害は通知でも知らせる。
+// UNUserNotificationCenter はアプリバンドルでないプロセスからは使えないため、osascript で通知する。
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// alertCooldown は同じ種類の通知を再び出すまでの間隔。回復と失敗を繰り返す場合に通知が溢れないようにする。
+const alertCooldown = 10 * time.Minute
+
+// 通知の種類
+const (
+	alertEventTap   = "eventtap"   // EventTap を再作成できない（アクセシビリティの権限の取り消し等）
+	alertNoDevice   = "device"     // タッチデバイスがすべてなくなった
+	alertPermission = "permission" // アクセシビリティの権限が取り消された（permission.go）
+)
+
+// alertNotifier は通知の有効・無効と、種類ごとの最後の通知時刻を保持する。
+// ウォッチドッグやデバイス更新の goroutine から呼ばれるため mu で保護する。
+type alertNotifier struct {
+	enabled bool // 起動時に決定
+	mu      sync.Mutex
+	last    map[string]time.Time
+}
+
+// alert は種類 kind の障害を通知センターに知らせる。無効時と alertCooldown 以内の再通知は何もしない。
+// 通知の表示は待たない。
+func (n *alertNotifier) alert(kind, message string) {
+	if !n.enabled {
+		return
+	}
+	n.mu.Lock()
+	now := time.Now()
+	if t, ok := n.last[kind]; ok && now.Sub(t) < alertCooldown {
+		n.mu.Unlock()
+		return
+	}
+	if n.last == nil {
+		n.last = make(map[string]time.Time)
+	}
+	n.last[kind] = now
+	n.mu.Unlock()
+
+	script := fmt.Sprintf("display notification %s with title %s", appleScriptString(message), appleScriptString("coastpad"))
+	go func() {
+		if out, err := exec.Command("osascript", "-e", script).CombinedOutput(); err != nil {
+			fmt.Fprintf(os.Stderr, "[notify] failed to post notification: %v: %s\n", err, strings.TrimSpace(string(out)))
+		}
+	}()
+}
+
+// appleScriptString は s を AppleScript の文字列リテラルにする。
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
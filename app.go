@@ -3,20 +3,43 @@ package main
 
 import (
 	"fmt"
+	"net/http"
+	"os"
 	"sync"
 	"time"
 )
 
 // 慣性パラメータ
 const (
-	decayRate     = 5.0                   // 減衰係数 (1/sec)
-	stopThreshold = 10.0                  // 停止閾値 (px/sec)
-	loopInterval  = 16 * time.Millisecond // ~60Hz
-	minTimeDelta  = 1e-9                  // ゼロ除算防御
+	defaultLoopInterval = 16 * time.Millisecond // ~60Hz
+	minTimeDelta        = 1e-9                  // ゼロ除算防御
+
+	// コーストループの間隔（--loop-interval）の範囲。
+	// 短すぎるとイベントの発行が WindowServer の処理を上回り、長すぎると動きがコマ送りになる。
+	minLoopInterval = 4 * time.Millisecond  // 250Hz
+	maxLoopInterval = 50 * time.Millisecond // 20Hz
+
+	// コーストフレームの経過時間の上限（秒）。これを超える・負の値は
+	// タイムスタンプの異常とみなし、ループの間隔で代用する。
+	maxCoastFrameDelta = 0.1
+
+	// コーストフレームの経過時間の平滑化。ticker の配信が負荷で揺らぐと、1フレームの移動量が
+	// 揺らいで速度がぶれて見えるため、経過時間を直近の平均の ±frameDeltaJitter に収める。
+	frameDeltaSmoothing = 0.1 // 平均（指数移動平均）の更新の重み
+	frameDeltaJitter    = 0.5 // 平均からの許容幅（比率）
+
+	// EventTap の生存確認間隔
+	eventTapWatchdogInterval = 2 * time.Second
+
+	// デバイス変更通知のデバウンス時間。Bluetooth 再接続時の通知の連発をまとめる。
+	deviceRefreshDebounce = 500 * time.Millisecond
 
 	// ドラッグ追従判定の移動閾値（px）。コースト中に1本指で再タッチした後、
 	// この閾値を超える移動があればドラッグを終了する。
 	dragFollowMovementThreshold = 3.0
+
+	// 判定保留中に1本指を静止させたままドラッグを終了するまでの時間のデフォルト値
+	defaultPendingDecisionTimeout = 1500 * time.Millisecond
 )
 
 // dragPhase はドラッグ慣性の状態フェーズを表す。
@@ -30,11 +53,75 @@ const (
 	dragPhasePendingDecision                  // コースト後1本指タッチ、判定保留中
 )
 
+// String は状態表示用のフェーズ名を返す。
+func (p dragPhase) String() string {
+	switch p {
+	case dragPhaseNone:
+		return "none"
+	case dragPhaseCoasting:
+		return "coasting"
+	case dragPhaseFollowing:
+		return "following"
+	case dragPhasePendingDecision:
+		return "pending-decision"
+	default:
+		return fmt.Sprintf("dragPhase(%d)", int(p))
+	}
+}
+
+// parseDragPhase は String のフェーズ名をフェーズに戻す（スナップショットの再生用）。
+func parseDragPhase(s string) (dragPhase, bool) {
+	for p := dragPhaseNone; p <= dragPhasePendingDecision; p++ {
+		if p.String() == s {
+			return p, true
+		}
+	}
+	return dragPhaseNone, false
+}
+
+// coastMode は慣性を適用する対象を表す。
+type coastMode int
+
+const (
+	modeBoth   coastMode = iota // カーソル慣性とドラッグ慣性の両方
+	modeCursor                  // カーソル慣性のみ（マウスボタンを傍受しない）
+	modeDrag                    // ドラッグ慣性のみ
+)
+
+// parseCoastMode はフラグの値から coastMode を返す。
+func parseCoastMode(s string) (coastMode, error) {
+	switch s {
+	case "both":
+		return modeBoth, nil
+	case "cursor":
+		return modeCursor, nil
+	case "drag":
+		return modeDrag, nil
+	}
+	return modeBoth, fmt.Errorf("unknown mode %q (available: both, cursor, drag)", s)
+}
+
+// String はフラグ・状態表示用のモード名を返す。
+func (m coastMode) String() string {
+	switch m {
+	case modeCursor:
+		return "cursor"
+	case modeDrag:
+		return "drag"
+	default:
+		return "both"
+	}
+}
+
 // displayRect はディスプレイの矩形範囲を表す（ピクセル座標、両端含む）。
 type displayRect struct {
 	minX, minY, maxX, maxY float64
+	scale                  float64 // バッキングスケール（1 ポイントあたりの物理ピクセル数）
 }
 
+// historySize はカーソル位置の履歴の点数。速度は直近2点、曲がり具合（curve.go）は全点から求める。
+const historySize = 4
+
 // cursorRecord はある時点のカーソル位置を保持する。
 type cursorRecord struct {
 	x, y      float64
@@ -42,12 +129,93 @@ type cursorRecord struct {
 }
 
 // App はタッチイベントの監視と慣性移動ループを管理する。
+// 状態のフィールドは Run（アクター）の goroutine だけが読み書きする（actor.go 参照）。
+// 起動時に決定するフィールドは Open 前に設定し、以降は変更しない。
 type App struct {
-	mu        sync.Mutex
-	history   [2]cursorRecord // 直近2点の記録（速度算出用）
+	history   [historySize]cursorRecord // 直近の記録（速度・曲がり具合の算出用）
 	histLen   int
 	isTouched bool
 	vx, vy    float64 // 慣性速度 (px/sec)
+	omega     float64 // コーストの角速度 (rad/sec、curve.go。0 なら直進)
+	curveGain float64 // リリース時の角速度をコーストに引き継ぐ割合（0 なら無効、起動時に決定）
+
+	// 連続したフリックの加速（flickboost.go）
+	flickBoost                   float64       // 同じ方向に続けたフリックの初速の割り増し（0 なら無効、起動時に決定）
+	flickBoostWindow             time.Duration // 前のコーストの最後のフレームから割り増しを付ける期間（起動時に決定）
+	lastCoastAt                  float64       // 最後のコーストフレームの時刻（monotonicSeconds、0 ならまだない）
+	lastCoastDirX, lastCoastDirY float64       // 最後に開始したコーストの方向（単位ベクトル）
+
+	// ウインドウごとのドラッグ慣性の除外（dragexclude.go）
+	dragExclusions []dragExclusion // 除外するウインドウの条件（nil なら無効、起動時に決定）
+	dragSeq        uint64          // マウスダウンごとに増やす番号（古い検索結果を捨てるため）
+	dragExcluded   bool            // 現在のドラッグが除外したウインドウのもの（か文字の選択・メニューバーの並べ替え）か
+	textDragCoast  bool            // 文字の選択のドラッグにも慣性を付けるか（起動時に決定、textselect.go）
+
+	// ドラッグ慣性をタイトルバーのドラッグに限る（titlebar.go）
+	dragTitlebarOnly bool // タイトルバーだと確かめられたドラッグにだけ慣性を付けるか（起動時に決定）
+
+	// シェイクによるポインタの拡大の防止（shake.go）
+	shakeGuard     bool // 有効か（起動時に決定）
+	shakeReversals int  // 続けて向きを折り返したコーストの数
+
+	// フリックの意図の判定（flickintent.go）
+	flickIntent float64 // コーストを始めるフリックらしさのしきい値（0〜1、0 なら無効、起動時に決定）
+	touchStartT float64 // 現在のタッチの開始時刻（タッチの timestamp）
+	contactPeak float64 // 現在のタッチの接触の大きさのピーク（不明なら 0）
+	contactLast float64 // 最新のフレームの接触の大きさ
+
+	// 複数指のタップでの急停止（brake.go）
+	brakeFingers int  // 急停止する指の本数（0 なら無効、起動時に決定）
+	brakeArmed   bool // 現在のタッチが急停止できるか（開始時にコースト中だったか）
+	braking      bool // 現在のタッチで急停止したか（リリースでコーストを始めない）
+
+	// フリックの方向の量子化（direction.go）
+	quantizeDirections int     // 揃える方向の数（0 なら無効・4・8、起動時に決定）
+	quantizeTolerance  float64 // 揃える角度の許容差（度、起動時に決定）
+
+	// パッド端フリック判定
+	padX, padY float64 // 最後にタッチしていたパッド上の位置（正規化座標）
+	edgeOnly   bool    // パッド端でのリリースでのみ慣性を発生させるか（起動時に決定）
+	edgeMargin float64 // パッド端とみなす範囲（正規化座標、起動時に決定）
+
+	// スクロールジェスチャー検出（2本指スクロールのリリースを慣性と誤認しないため）
+	maxFingers    int  // 現在のタッチ中の最大指数
+	touchScrolled bool // 現在のタッチ中にスクロールフェーズ付きイベントを観測したか
+
+	// バッテリー駆動中の省電力モード（power.go）
+	batterySaverBelow int           // この残量（%）未満のバッテリー駆動で省電力にする（0 で無効、起動時に決定）
+	powerSaveInterval time.Duration // 省電力モードのコーストループの間隔（起動時に決定）
+	onBattery         bool          // バッテリー駆動中か
+	batteryPercent    int           // バッテリーの残量（%）
+	powerSave         bool          // 省電力モード中か
+
+	// 熱の状態への適応（thermal.go）
+	thermalAdapt     bool // 有効か（起動時に決定）
+	thermalState     int  // 熱の状態（NSProcessInfoThermalState）
+	thermalThrottled bool // serious 以上で負荷を下げているか
+
+	// アクセシビリティのポインタの大きさへの対応（largecursor.go）
+	largeCursor bool    // 有効か（起動時に決定）
+	cursorScale float64 // 最後のリリース時のポインタの大きさ（1〜4）
+
+	// 基準の軌跡の速さ（com.apple.trackpad.scaling、0 で正規化しない、起動時に決定。trackingspeed.go）
+	trackingSpeedRef float64
+
+	// 4本指の横スワイプによる摩擦の調整（swipefriction.go）
+	swipeFriction            bool    // 有効か（起動時に決定）
+	swipeTracking            bool    // 現在のタッチで4本指のスワイプを追跡中か
+	swipeStartX, swipeStartY float64 // 4本指になったときのパッド上の位置（正規化座標）
+	swipeEndX, swipeEndY     float64 // 最後に4本指だったときのパッド上の位置
+
+	// 指の本数ごとの速度の倍率（fingergain.go）
+	fingerGains    map[int]float64 // 指の本数 → 速度の倍率（nil なら無効、起動時に決定）
+	releaseFingers int             // 最後にタッチしていたフレームの指の本数（リリース直前の本数）
+
+	// 複数デバイスの調停（arbitrateTouch）
+	deviceFingers map[uintptr]int // タッチ中のデバイスごとの指の本数
+	activeDevice  uintptr         // フレームをエンジンに渡しているデバイス
+	// コースト中に他のデバイスのタッチを無視するか（起動時に決定）
+	ignoreOtherDevices bool
 
 	// ドラッグ慣性サポート
 	// ドラッグ中に指を離すと OS がマウスアップを発行するが、これを EventTap で傍受・保留し、
@@ -58,112 +226,390 @@ type App struct {
 	// ドラッグ追従モードへ移行する。pendingMouseUp を保持したまま、カーソル移動を
 	// mouseDragged に変換してウィンドウを追従させる。
 	// 1本指で移動が検出された場合はドラッグを終了する。
-	isLeftButtonDown   bool      // マウスダウン中か（EventTap で追跡）
-	dragPhase          dragPhase // ドラッグ慣性の状態フェーズ
-	wasMultiFingerDrag bool      // 現在のドラッグが複数指で開始されたか
-	coastX, coastY     float64   // コースト中のカーソル位置追跡
-	accumX, accumY     float64   // ドラッグイベント用の端数デルタ蓄積
-	pendingMouseUp     eventRef  // 保留中のマウスアップ（CFRetain 済み）
+	isLeftButtonDown   bool            // マウスダウン中か（EventTap で追跡）
+	dragAttrs          dragAttrs       // 現在のドラッグのクリック回数と修飾キー（マウスダウンで取得、合成イベントに引き継ぐ）
+	dragPhase          dragPhase       // ドラッグ慣性の状態フェーズ（setDragPhase で遷移させる）
+	dragStateInvalid   bool            // 許可されていない遷移が発生したか（validateDragState でリセット）
+	wasMultiFingerDrag bool            // 現在のドラッグが複数指で開始されたか
+	coastX, coastY     float64         // コースト中のカーソル位置追跡
+	coastT             float64         // コースト位置の時刻（monotonicSeconds / タッチの timestamp と同じ時間軸）
+	frameDeltaAvg      float64         // コーストフレームの経過時間の平均（秒、コースト開始時は 0。smoothFrameDelta）
+	traj               coastTrajectory // コーストの減衰軌跡（trajectory.go）
+	pendingSince       float64         // 判定保留に入ったタッチのタイムスタンプ
+	pendingTimeout     float64         // 判定保留のタイムアウト（秒、0 で無効、起動時に決定）
+	accumX, accumY     float64         // ドラッグイベント用の端数デルタ蓄積
+	pendingMouseUp     eventRef        // 保留中のマウスアップ（CFRetain 済み）
+	pendingUpPolicy    string          // 追従中の新しいマウスダウンでの保留中のマウスアップの扱い（drag.go、起動時に決定）
 
 	// 画面バウンドキャッシュ（コースト開始時に取得、clampToScreen で使用）
 	screens        []displayRect
-	coastScreenIdx int // コースト中カーソルが最後にいたディスプレイのインデックス
+	coastScreenIdx int           // コースト中カーソルが最後にいたディスプレイのインデックス
+	bars           []displayRect // メニューバーと Dock の領域（barAssist 有効時のみ）
+	barAssist      bool          // メニューバー・Dock 上で追加減速するか（起動時に決定）
+
+	hotEdges     map[screenEdge]string // 端ごとのホットエッジのアクション名（起動時に決定）
+	hotEdgeSpeed float64               // ホットエッジを発動する速さ (px/sec、起動時に決定)
+	hotEdgeFired bool                  // 現在のコーストでホットエッジを発動したか
+
+	// ドラッグ慣性での操作スペースの切り替え（spaceswitch.go）
+	dragSpaceDwell   float64    // 端で保持してからスペースを切り替えるまでの時間（秒、0 で無効、起動時に決定）
+	edgeHold         screenEdge // 保持中の端（edgeNone なら保持していない）
+	edgeHoldSince    float64    // 保持の開始（切り替え後は切り替え）の時刻
+	edgeHoldSwitched bool       // スペース切り替えのキー操作を送ったか
+	edgeHoldVX       float64    // 保持中に維持する端に向かう速度
+
+	// ドラッグ慣性でのスプリングローディング（spring.go）
+	springDwell  float64 // 停止後にフォルダ等の上で保持する時間（秒、0 で無効、起動時に決定）
+	springHold   bool    // 停止位置でドロップを保留中か
+	springUntil  float64 // 保留の期限
+	springJitter int     // 往復ドラッグの現在のオフセット (0 / 1 px)
+
+	// ドラッグ慣性の停止後の静定待ち（dropsettle.go）
+	dropSettle   float64 // カーソルが止まってからドロップまでの時間（秒、0 で無効、起動時に決定）
+	dropSettling bool    // 静定待ちでドロップを保留中か
+	settleUntil  float64 // 保留の期限
+	dragMovedAt  float64 // ドラッグ慣性で最後にカーソルを動かした時刻
+
+	// タイピング中のカーソル慣性の抑制（typing.go）
+	typingSuppress float64 // キー入力後にカーソル慣性を抑制する時間（秒、0 で無効、起動時に決定）
+	lastKeyDown    float64 // 最後のキー入力の時刻（monotonicSeconds）
+
+	// 一時停止（pause.go）。ユーザーの操作・全画面ゲームの検出（game.go）・セッションの切り替え（session.go）で一時停止する
+	paused          bool // ユーザーが一時停止したか（制御コマンド pause / resume）
+	gameDetect      bool // 全画面ゲームの間は一時停止するか（起動時に決定）
+	gameActive      bool // 全画面ゲームが最前面か
+	mcDetect        bool // Mission Control の表示中は一時停止するか（起動時に決定）
+	missionControl  bool // Mission Control・App Exposé の表示中か
+	sessionInactive bool // セッションがコンソールにないか（ファストユーザスイッチ・ログイン画面）
+	untrusted       bool // アクセシビリティの権限が取り消されているか（permission.go）
+	suspended       bool // 一時停止中か（paused || gameActive || missionControl || focusPaused || sessionInactive || untrusted || remoteControlled。タッチフレームを無視する）
+	cursorOff       bool // カーソル慣性をオフにしているか（制御コマンド cursor on|off）
+	dragOff         bool // ドラッグ慣性をオフにしているか（制御コマンド drag on|off）
+
+	// 画面共有での遠隔操作の検出（remote.go）
+	remoteDetect     bool    // 遠隔操作されている間は一時停止するか（起動時に決定）
+	remoteShared     bool    // 画面を共有中か
+	remoteControlled bool    // 遠隔操作中とみなしているか（共有中で手元の操作が途切れている）
+	remoteLocalAt    float64 // 共有中の最後の手元のタッチの時刻
+
+	// 集中モードごとの設定（focus.go）
+	focusProfiles map[string]focusSetting // 集中モード名ごとの設定（nil なら無効、起動時に決定）
+	focus         string                  // 現在の集中モード名（オフなら空）
+	focusMode     coastMode               // 集中モードによる動作モードの制限（modeBoth なら制限なし）
+	focusPaused   bool                    // 集中モードによる一時停止
+	focusSaved    *presetStatus           // 集中モードのプリセット適用前の設定（適用中のみ）
+
+	// 投げた先のクリック（clickthrough.go）
+	clickThrough        bool    // コースト中のタップのクリックをコースト位置へ移すか（起動時に決定）
+	clickThroughArmed   bool    // コースト中に始まったタッチで、次のマウスダウンを移すか
+	clickThroughX       float64 // クリックの移動先（タッチした時点のコースト位置）
+	clickThroughY       float64
+	clickThroughSince   float64 // 準備したタッチのタイムスタンプ
+	clickThroughUpUntil float64 // 対になるマウスアップを移す期限（移したマウスダウンがなければ 0）
+
+	// コースト停止直後のクリックの無視（deadtime.go）
+	deadTime        float64 // 速いコーストの停止後にクリックを無視する時間（秒、0 で無効、起動時に決定）
+	coastStartSpeed float64 // 現在のコーストの開始時の速さ (px/sec)
+	deadUntil       float64 // クリックを無視する期限（monotonicSeconds、0 なら無効）
+	deadX, deadY    float64 // コーストの停止位置
+	swallowMouseUp  bool    // 無視したマウスダウンの対になるマウスアップを消費するか
+
+	// ゴミ箱へのドロップの確認（safedrop.go）
+	safeDrop    bool // ゴミ箱の上で止まったらタップでの確認までドロップを保留するか（起動時に決定）
+	dropConfirm bool // ドロップの確認待ちか
+
+	// ステージマネージャとの互換モード（stagemanager.go）
+	stageManagerCompat bool       // ステージマネージャの有効中はドラッグ慣性を抑えるか（起動時に決定）
+	stage              stageStrip // ドラッグ慣性の開始時に確認したステージマネージャの状態
+
+	loopInterval  time.Duration  // コースト・スクロールのフレームの間隔（起動時に決定）
+	frictionZones []frictionZone // 摩擦を変える画面上の領域（frictionzone.go、起動時に決定）
+
+	params coastParams // 慣性の物理パラメータ（プリセット・制御ソケットで変更可能）
+	preset string      // 最後に適用したプリセット名（個別変更後は空）
+	ab     abState     // A/B 比較モード
 
-	// EventTap（CGEventTap の管理）
-	eventTapRef     machPortRef   // タイムアウト再有効化用
-	eventTapRunLoop runLoopRef    // 停止時の CFRunLoopStop 用
-	eventTapDone    chan struct{} // RunLoop goroutine の終了通知
+	stats    coastStats    // セッション中の使用統計
+	recorder eventRecorder // スナップショット用の直近のイベントの記録（snapshot.go）
 
-	notifier     *DeviceNotifier
-	touchDevices *TouchDevices
-	stopOnce     sync.Once
-	stop         chan struct{}
+	// コーストの履歴の記録（coastlog.go）
+	coastLog       *coastLogger // 記録の書き込み先（無効時は nil、起動時に決定）
+	coastRec       coastRecord  // 記録中のコースト
+	coastRecording bool         // コーストを記録中か
+	coastEnd       string       // 記録中のコーストの終了の理由（分かった経路で設定する）
+
+	// 捕まえ方からの摩擦の学習（learnfriction.go）
+	adaptiveFriction bool            // 学習するか（起動時に決定）
+	learned          learnedFriction // 学習した減衰率と統計
+
+	mouseUpGuard *mouseUpGuard // 異常終了時にマウスアップを発行するガードプロセス（無効時は nil、mouseupguard.go）
+
+	mode         coastMode   // 慣性を適用する対象（起動時に決定）
+	smoothScroll bool        // 外付けマウスのスクロール平滑化を行うか（起動時に決定）
+	scroll       scrollState // スクロール慣性の状態
+
+	// 他のイベント傍受ツールとの共存（起動時に決定）
+	karabinerCompat bool   // Karabiner-Elements 互換モード（EventTap を末尾に挿入し、仮想デバイスを除外する）
+	conservativeTap bool   // 控えめモード（mouseDown を監視専用の tap で見て、合成イベントに印を付ける。coexist.go）
+	tapLocation     string // EventTap の挿入位置（tapplacement.go）
+	tapOptions      string // EventTap のオプション（tapplacement.go）
+
+	snapEnabled   bool // 吸着モード（実験的）を使うか（起動時に決定）
+	snapRequested bool // 現在のコーストで吸着先を探したか
+
+	hudEnabled bool // HUD オーバーレイを使うか（起動時に決定）
+	hudShown   bool // HUD に軌跡を表示中か
+
+	haptics *hapticFeedback // ドラッグ慣性終了時の触覚フィードバック（無効時は nil、起動時に決定）
+	sounds  soundFeedback   // サウンドフィードバック（起動時に決定）
+	hooks   shellHooks      // コーストのイベントで実行するシェルフック（起動時に決定）
+	// リリース時にコーストを変更・取り消すスクリプト（無効時は nil、起動時に決定）
+	releaseFilter *releaseFilter
+
+	// EventTap（CGEventTap の管理）。ウォッチドッグからの再作成とコールバックが並行するため tapMu で保護する
+	tapMu             sync.Mutex
+	eventTapRef       machPortRef   // タイムアウト再有効化用
+	eventTapListenRef machPortRef   // 控えめモードの監視専用の tap（mouseDown・キー入力、無効時は 0）
+	eventTapRunLoop   runLoopRef    // 停止時の CFRunLoopStop 用
+	eventTapDone      chan struct{} // RunLoop goroutine の終了通知
+	eventTapPaused    bool          // 一時停止中か（ウォッチドッグが再有効化しない）
+	watchdogDone      chan struct{} // EventTap ウォッチドッグの終了通知
+	gameWatchDone     chan struct{} // 全画面ゲームの監視の終了通知（無効時は nil）
+
+	// EventTap と保留中のマウスアップを持つ補助プロセス（taphelper.go）
+	useTapHelper bool              // 補助プロセスで傍受するか（起動時に決定）
+	tapHelper    *tapHelperProcess // エンジン側で補助プロセスを監視する（使わなければ nil）
+	tapEngine    *tapEngineLink    // 補助プロセス側でマウスボタン等をエンジンに問い合わせる（エンジンでは nil）
+
+	// 状態機械の現在時刻（通常は monotonicSeconds。replay-snapshot は記録時刻に差し替える）
+	now func() float64
+
+	alerts alertNotifier // 致命的な障害の通知（alert.go）
+
+	notifier          deviceWatcher
+	frontApp          *FrontAppNotifier // 最前面のアプリの監視（開始できなければ nil）
+	touchBackend      string            // タッチバックエンドの名前（起動時に決定）
+	touchDevices      TouchDevices
+	deviceRefresh     chan struct{} // デバイス更新要求（デバウンス用、バッファ1）
+	deviceRefreshDone chan struct{} // デバイス更新 goroutine の終了通知
+	control           *controlServer
+	tuneUI            *http.Server // ライブ調整 UI（--tune-ui、無効時は nil。tuneui.go）
+	startedAt         time.Time
+	inbox             chan any      // アクターへのメッセージ（Open で作成）
+	actorDone         chan struct{} // アクター（Run）の終了通知
+	stopOnce          sync.Once
+	stop              chan struct{}
+}
+
+// checkLoopInterval はコーストループの間隔が範囲内かを確認する。
+func checkLoopInterval(d time.Duration) error {
+	if d < minLoopInterval || d > maxLoopInterval {
+		return fmt.Errorf("loop interval %v out of range (%v to %v)", d, minLoopInterval, maxLoopInterval)
+	}
+	return nil
 }
 
 // NewApp は App を初期化して返す。
 func NewApp() *App {
 	return &App{
-		stop: make(chan struct{}),
+		pendingTimeout:    defaultPendingDecisionTimeout.Seconds(),
+		pendingUpPolicy:   pendingUpDiscard,
+		touchBackend:      touchBackendAuto,
+		loopInterval:      defaultLoopInterval,
+		powerSaveInterval: defaultPowerSaveInterval,
+		params:            presets[defaultPresetName],
+		preset:            defaultPresetName,
+		deviceFingers:     make(map[uintptr]int),
+		stop:              make(chan struct{}),
+		deviceRefresh:     make(chan struct{}, 1),
+		now:               monotonicSeconds,
 	}
 }
 
 // Open はタッチデバイスを検出し、コールバック・EventTap・デバイス通知を登録する。
 func (a *App) Open() error {
+	a.startedAt = time.Now()
+
+	// コールバックの登録前に inbox を用意する。Run が始まるまでのメッセージは inbox に溜まる。
+	a.inbox = make(chan any, inboxSize)
+	a.actorDone = make(chan struct{})
+
 	// タッチデバイスの初期検出とコールバック登録
-	a.touchDevices = NewTouchDevices()
-	a.touchDevices.RefreshDevices()
+	touchDevices, err := openTouchDevices(a.touchBackend, a.karabinerCompat)
+	if err != nil {
+		return fmt.Errorf("failed to open touch devices: %w", err)
+	}
+	a.touchDevices = touchDevices
+	if a.needsMainLoop() {
+		// NSEvent バックエンドは印のない相対移動を物理的な移動（タッチ）と区別できない
+		relativeCursorMoves = false
+	}
 
-	if err := a.startEventTap(); err != nil {
+	if a.useTapHelper {
+		a.tapHelper, err = startTapHelper(a)
+		tapHelperLink = a.tapHelper
+	} else {
+		err = a.startEventTap()
+	}
+	if err != nil {
 		a.touchDevices.StopAll()
 		return fmt.Errorf("failed to start event tap: %w", err)
 	}
 
-	// IOKit デバイス変更通知の開始。
-	// touchDevices 初期化完了後に開始することで、onDeviceChanged から
+	// デバイス更新 goroutine と IOKit デバイス変更通知の開始。
+	// touchDevices 初期化完了後に開始することで、デバイス更新 goroutine から
 	// a.touchDevices へのデータ競合を防ぐ。goroutine 生成が happens-before を
-	// 確立するため、通知コールバックから a.touchDevices が確実に可視になる。
+	// 確立するため、a.touchDevices が確実に可視になる。
+	a.deviceRefreshDone = make(chan struct{})
+	go a.runDeviceRefresh()
+
+	// 通知を開始できない環境ではポーリングにフォールバックする。
 	notifier, err := StartDeviceNotifier()
 	if err != nil {
-		a.stopEventTap()
-		a.touchDevices.StopAll()
-		return fmt.Errorf("failed to start device notifier: %w", err)
+		fmt.Fprintf(os.Stderr, "[device] notifier unavailable (%v), falling back to polling\n", err)
+		a.notifier = StartDevicePoller(a.onDeviceChanged)
+	} else {
+		a.notifier = notifier
+	}
+
+	// 最前面のアプリはリリースフィルタのためにキャッシュする。監視できなければ都度問い合わせる
+	if a.releaseFilter != nil {
+		if fn, err := StartFrontAppNotifier(); err != nil {
+			fmt.Fprintf(os.Stderr, "[frontapp] %v, querying the frontmost app on each release\n", err)
+		} else {
+			a.frontApp = fn
+		}
+	}
+
+	a.watchdogDone = make(chan struct{})
+	if a.tapHelper != nil {
+		// EventTap の生存は補助プロセスが確認する
+		close(a.watchdogDone)
+	} else {
+		go a.watchEventTap()
+	}
+
+	if a.gameDetect {
+		a.gameWatchDone = make(chan struct{})
+		go a.watchGame()
+	}
+
+	// 反映はアクター経由のため、終了時には待たない（a.stop で終了する）
+	if a.mcDetect {
+		go a.watchMissionControl()
+	}
+	go a.watchDefaults()
+	go a.watchSession()
+	go a.watchPermission()
+	if a.remoteDetect {
+		go a.watchRemote()
+	}
+	if a.batterySaverBelow > 0 {
+		go a.watchPower()
+	}
+	if a.thermalAdapt {
+		if err := a.startThermalObserver(); err != nil {
+			fmt.Fprintf(os.Stderr, "[thermal] %v\n", err)
+			a.thermalAdapt = false
+		}
+	}
+	if a.focusProfiles != nil {
+		go a.watchFocus()
+	}
+
+	// 制御ソケットは補助機能のため、開始できなくても動作を継続する
+	if path, err := defaultControlSocketPath(); err == nil {
+		if cs, err := startControlServer(a, path); err != nil {
+			fmt.Fprintf(os.Stderr, "[control] failed to start control socket: %v\n", err)
+		} else {
+			a.control = cs
+		}
 	}
-	a.notifier = notifier
 
 	return nil
 }
 
-// Stop はデバイス監視と慣性ループを停止する。
+// Stop はデバイス監視と慣性ループを停止する。Open の後は Run を呼んでいること（Run の終了を待つため）。
+//
+// 停止は入力元 → アクター → 出力先の順に行う。新しいタッチや制御コマンドを止めてから、
+// 実行中のフレームを終えて保留中のマウスアップを発行した Run の終了を待ち、
+// その後で Run が使うハプティクス・EventTap を閉じる。EventTap を止めた後に
+// イベントが発行されることはなく、保留中のマウスアップは EventTap を通って（消費されずに）届く。
 func (a *App) Stop() {
 	a.stopOnce.Do(func() {
 		close(a.stop)
-		// notifier.Stop は RunLoop goroutine の終了を待つため、
+		if a.control != nil {
+			a.control.close()
+		}
+		a.stopTuneUI()
+		// notifier.Stop は RunLoop（またはポーリング）goroutine の終了を待つため、
 		// 完了後は onDeviceChanged が呼ばれないことが保証される。
-		// この順序により touchDevices.StopAll 後の RefreshDevices 呼び出しを防ぐ。
+		// さらにデバイス更新 goroutine の終了を待つことで、
+		// touchDevices.StopAll 後の RefreshDevices 呼び出しを防ぐ。
 		a.notifier.Stop()
+		<-a.deviceRefreshDone
 		a.touchDevices.StopAll()
-		a.stopEventTap()
-
-		a.mu.Lock()
-		pending := a.pendingMouseUp
-		a.pendingMouseUp = 0
-		a.mu.Unlock()
-		releasePendingMouseUp(pending)
+		// アクターの終了を待つ。以降、状態を所有する goroutine はなく、CGEventPost も行われない
+		<-a.actorDone
+		if a.thermalAdapt {
+			stopThermalObserver()
+		}
+		if a.frontApp != nil {
+			a.frontApp.Stop()
+		}
+		if a.haptics != nil {
+			a.haptics.close()
+		}
+		if a.releaseFilter != nil {
+			a.releaseFilter.close()
+		}
+		// ウォッチドッグやゲームの監視が EventTap を操作中の可能性があるため、終了を待ってから停止する
+		if a.gameWatchDone != nil {
+			<-a.gameWatchDone
+		}
+		<-a.watchdogDone
+		if a.tapHelper != nil {
+			// アクターの終了時に発行した保留中のマウスアップは、補助プロセスが受け取り済み
+			a.tapHelper.stopTapHelper()
+		} else {
+			a.stopEventTap()
+		}
 	})
 }
 
-// onDeviceChanged は IOKit 通知から呼ばれ、デバイスリストを更新する。
-// Open で touchDevices 初期化後に notifier を開始するため、
-// この時点で a.touchDevices は必ず有効。
+// onDeviceChanged は IOKit 通知（またはポーリング）から呼ばれ、デバイスリストの更新を要求する。
+// 更新はデバイス更新 goroutine でデバウンスしてから行う。
 func (a *App) onDeviceChanged() {
-	a.touchDevices.RefreshDevices()
+	select {
+	case a.deviceRefresh <- struct{}{}:
+	default: // 既に要求済み
+	}
 }
 
-// Run は慣性移動ループを実行する。Stop() が呼ばれるまでブロックする。
-//
-// 通常の慣性: setMouseLocation で絶対座標にカーソルを移動する。
-// ドラッグ慣性: mouseDragged イベントを発行してドラッグセッションを延長する。
-// ドラッグ慣性中は mouseUp を保留しているため、OS からはドラッグ継続中に見える。
-// これにより、ウィンドウ移動とリサイズの両方が慣性で動作する。
-func (a *App) Run() {
-	ticker := time.NewTicker(loopInterval)
-	defer ticker.Stop()
-
-	dp := newDragPoster()
-	defer dp.close()
+// runDeviceRefresh はデバイス更新要求をデバウンスし、デバイスリストを更新する。
+// Bluetooth トラックパッドの再接続中は接続・切断通知が連発するため、
+// 通知が deviceRefreshDebounce の間途切れてから1回だけ RefreshDevices を呼ぶ。
+// a.stop が閉じられるまでブロックする。終了時に deviceRefreshDone を閉じる。
+func (a *App) runDeviceRefresh() {
+	defer close(a.deviceRefreshDone)
 
-	t1 := time.Now()
+	timer := time.NewTimer(deviceRefreshDebounce)
+	timer.Stop()
+	defer timer.Stop()
 
 	for {
 		select {
 		case <-a.stop:
 			return
-		case <-ticker.C:
-			t2 := time.Now()
-			dt := t2.Sub(t1).Seconds()
-			t1 = t2
-			action := a.prepareCoastFrame(dt)
-			a.executeCoastFrame(action, dp)
+		case <-a.deviceRefresh:
+			timer.Reset(deviceRefreshDebounce)
+		case <-timer.C:
+			before := a.touchDevices.Count()
+			a.touchDevices.RefreshDevices()
+			if before > 0 && a.touchDevices.Count() == 0 {
+				fmt.Fprintln(os.Stderr, "[device] no touch devices left")
+				a.alerts.alert(alertNoDevice, "All trackpads are gone. Coasting resumes when one is connected.")
+			}
 		}
 	}
 }
@@ -0,0 +1,44 @@
+// bars.go: メニューバー・Dock への着地補助。
+// コーストの軌道がメニューバーや Dock に入ったら追加で減速し、
+// 画面端で止まらずに狙ったアイコンを通り過ぎてしまうのを防ぐ。
+package main
+
+/*
+#cgo LDFLAGS: -framework Cocoa
+#include "bars.h"
+*/
+import "C"
+import "math"
+
+// バー領域内で速度に追加で掛ける減衰率 (1/sec)。通常の DecayRate に加算する。
+const barAssistDecayRate = 20.0
+
+// barRects はメニューバーと Dock が占める領域を返す。
+func barRects() []displayRect {
+	var rects [32]C.BarRect
+	n := int(C.bar_rects(&rects[0], C.int(len(rects))))
+	bars := make([]displayRect, n)
+	for i, r := range rects[:n] {
+		bars[i] = displayRect{
+			minX:  float64(r.x),
+			minY:  float64(r.y),
+			maxX:  float64(r.x+r.w) - 1,
+			maxY:  float64(r.y+r.h) - 1,
+			scale: 1,
+		}
+	}
+	return bars
+}
+
+// applyBarAssist はコースト位置がメニューバーか Dock の上にあれば速度を追加で減衰させる。
+// アクター goroutine から呼ぶこと。
+func (a *App) applyBarAssist(dt float64) {
+	for _, b := range a.bars {
+		if a.coastX >= b.minX && a.coastX <= b.maxX && a.coastY >= b.minY && a.coastY <= b.maxY {
+			factor := math.Exp(-barAssistDecayRate * dt)
+			a.vx *= factor
+			a.vy *= factor
+			return
+		}
+	}
+}
@@ -0,0 +1,18 @@
+// bars.h: メニューバーと Dock の領域の取得（NSScreen）。
+#ifndef BARS_H
+#define BARS_H
+
+// 画面上の矩形（CG のグローバル座標、原点は左上）。
+typedef struct {
+    double x, y, w, h;
+} BarRect;
+
+// 各スクリーンの frame と visibleFrame の差（メニューバー・Dock が占める領域）を
+// out に最大 max 個書き込み、書き込んだ数を返す。
+int bar_rects(BarRect *out, int max);
+
+// (x, y)（CG のグローバル座標）がメニューバーの上なら 1 を返す。
+// メニューバーを自動的に隠している場合は、表示されたときの領域で判定する。
+int menu_bar_contains(double x, double y);
+
+#endif
@@ -0,0 +1,68 @@
+// bars.m: NSScreen の frame と visibleFrame からメニューバーと Dock の領域を求める。
+#import <Cocoa/Cocoa.h>
+#include "bars.h"
+
+// add は幅・高さのある矩形を Cocoa 座標（原点は左下）から CG 座標に変換して追加する。
+static int add(BarRect *out, int n, int max, NSRect r, CGFloat mainHeight) {
+    if (n >= max || r.size.width <= 0 || r.size.height <= 0) {
+        return n;
+    }
+    out[n] = (BarRect){
+        r.origin.x,
+        mainHeight - (r.origin.y + r.size.height),
+        r.size.width,
+        r.size.height,
+    };
+    return n + 1;
+}
+
+int bar_rects(BarRect *out, int max) {
+    @autoreleasepool {
+        NSArray<NSScreen *> *screens = [NSScreen screens];
+        if (screens.count == 0) {
+            return 0;
+        }
+        // Cocoa 座標の原点は最初のスクリーン（メニューバーのあるスクリーン）の左下
+        CGFloat mainHeight = NSMaxY(screens[0].frame);
+
+        int n = 0;
+        for (NSScreen *screen in screens) {
+            NSRect f = screen.frame;
+            NSRect v = screen.visibleFrame;
+            // 上（メニューバー）・下・左・右（Dock）の各辺の差
+            n = add(out, n, max, NSMakeRect(f.origin.x, NSMaxY(v), f.size.width, NSMaxY(f) - NSMaxY(v)), mainHeight);
+            n = add(out, n, max, NSMakeRect(f.origin.x, f.origin.y, f.size.width, v.origin.y - f.origin.y), mainHeight);
+            n = add(out, n, max, NSMakeRect(f.origin.x, v.origin.y, v.origin.x - f.origin.x, v.size.height), mainHeight);
+            n = add(out, n, max, NSMakeRect(NSMaxX(v), v.origin.y, NSMaxX(f) - NSMaxX(v), v.size.height), mainHeight);
+        }
+        return n;
+    }
+}
+
+int menu_bar_contains(double x, double y) {
+    @autoreleasepool {
+        NSArray<NSScreen *> *screens = [NSScreen screens];
+        if (screens.count == 0) {
+            return 0;
+        }
+        CGFloat mainHeight = NSMaxY(screens[0].frame);
+        for (NSScreen *screen in screens) {
+            NSRect f = screen.frame;
+            // CG 座標でのスクリーンの上端
+            CGFloat top = mainHeight - NSMaxY(f);
+            if (x < f.origin.x || x >= NSMaxX(f) || y < top || y >= top + f.size.height) {
+                continue;
+            }
+            CGFloat height = NSMaxY(f) - NSMaxY(screen.visibleFrame);
+            if (height <= 0) {
+                // メニューバーを自動的に隠している: 表示されたときの高さ（ノッチのある画面ではノッチの高さ）
+                height = [[NSStatusBar systemStatusBar] thickness];
+                if (@available(macOS 12.0, *)) {
+                    height = MAX(height, screen.safeAreaInsets.top);
+                }
+            }
+            return y < top + height;
+        }
+        return 0;
+    }
+}
@@ -0,0 +1,177 @@
+// bench_test.go: ホットパスのベンチマーク（go test -bench .）と割り当ての予算（TestHotPathAllocs）。
+// コーストフレーム（60〜120Hz）とタッチフレーム（~100Hz/デバイス）の状態遷移は毎フレーム走るため、
+// 所要時間とメモリ割り当てを計測する。デバイスや EventTap を開かない App で状態遷移（prepareXxx）だけを
+// 実行するため、トラックパッドがなくても・他の coastpad の実行中でも計測できる。
+//
+// 毎フレームの割り当ては GC を誘発してフレームの間隔を乱すため、ホットパスは割り当てなしを保つ。
+// 履歴のリングバッファやデバイスごとのマップなどを追加するときは、TestHotPathAllocs で割り当てが
+// 増えていないか確かめること。
+package main
+
+import "testing"
+
+// benchScreen はベンチマークの App に設定するディスプレイ。
+var benchScreen = displayRect{minX: 0, minY: 0, maxX: 2559, maxY: 1599, scale: 2}
+
+// newBenchApp はデバイスを開かずに状態遷移だけを実行できる App を作る。
+// HUD の停止点の予測もフレームごとに走るため有効にしておく。
+func newBenchApp() *App {
+	a := NewApp()
+	a.screens = []displayRect{benchScreen}
+	a.hudEnabled = true
+	return a
+}
+
+// startBenchCoast は画面中央から右下へのコーストを開始した状態にする。
+func startBenchCoast(a *App, now float64) {
+	a.coastX, a.coastY = 1280, 800
+	a.vx, a.vy = 1200, 500
+	a.coastT = now
+	a.coastScreenIdx = 0
+}
+
+// 以下の setupXxx は App を準備し、ホットパスを1フレーム分実行する関数を返す。
+
+func setupCoastFrame() func() {
+	a := newBenchApp()
+	now := 1.0
+	startBenchCoast(a, now)
+	return func() {
+		now += a.loopInterval.Seconds()
+		a.prepareCoastFrame(now)
+		if a.vx == 0 && a.vy == 0 {
+			startBenchCoast(a, now)
+		}
+	}
+}
+
+func setupDragCoastFrame() func() {
+	a := newBenchApp()
+	now := 1.0
+	start := func() {
+		startBenchCoast(a, now)
+		a.isLeftButtonDown = true
+		a.dragPhase = dragPhaseCoasting
+	}
+	start()
+	return func() {
+		now += a.loopInterval.Seconds()
+		a.prepareCoastFrame(now)
+		if a.vx == 0 && a.vy == 0 {
+			start()
+		}
+	}
+}
+
+func setupTouchFrame() func() {
+	a := newBenchApp()
+	t := 1.0
+	x := 0.0
+	return func() {
+		t += 0.008
+		x += 4
+		if x > benchScreen.maxX {
+			x = 0
+		}
+		a.prepareTouchFrame(1, x, 800, 0.5, 0.5, 0, t)
+	}
+}
+
+func setupReleaseVelocity() func() {
+	a := newBenchApp()
+	a.recordCursor(100, 100, 1.000)
+	a.recordCursor(112, 105, 1.008)
+	return func() {
+		a.calcReleaseVelocity()
+	}
+}
+
+func setupTrajectory() func() {
+	p := presets[defaultPresetName]
+	tr := newCoastTrajectory(1280, 800, 1200, 500, p.DecayRate, 0, p.StopThreshold)
+	return func() {
+		tr.advance(0.016)
+		if tr.vx == 0 && tr.vy == 0 {
+			tr = newCoastTrajectory(1280, 800, 1200, 500, p.DecayRate, 0, p.StopThreshold)
+		}
+	}
+}
+
+// setupTouchMessage はタッチフレームを inbox から受け取ったときの処理全体（handleMessage）を1フレーム分実行する。
+// 常に動いているイベントの記録（snapshot.go の recordMessage・record）も含む。
+// 記録のバッファは1世代（snapshotWindow）分に育つまで append で伸び、以降は世代を切り替えても再利用する。
+// 世代の切り替えごとの captureState も snapshotWindow に1回なので、1フレームあたりの平均は 0 に収まる。
+func setupTouchMessage() func() {
+	a := newBenchApp()
+	t := 1.0
+	x := 0.0
+	return func() {
+		t += 0.008
+		x += 4
+		if x > benchScreen.maxX {
+			x = 0
+		}
+		// 指を動かし続けるだけのフレームはコーストを始めないため、dragPoster は使われない
+		a.handleMessage(touchFrameMsg{device: 1, fingerCount: 1, x: x, y: 800, padX: 0.5, padY: 0.5, timestamp: t}, nil)
+	}
+}
+
+// hotPath は割り当てを確かめるホットパスを表す。maxAllocs は1フレームあたりの割り当ての予算。
+type hotPath struct {
+	name      string
+	setup     func() func()
+	maxAllocs float64
+}
+
+var hotPaths = []hotPath{
+	{"prepareCoastFrame", setupCoastFrame, 0},
+	{"prepareCoastFrame/drag", setupDragCoastFrame, 0},
+	{"prepareTouchFrame", setupTouchFrame, 0},
+	{"handleMessage/touchFrame", setupTouchMessage, 0},
+	{"calcReleaseVelocity", setupReleaseVelocity, 0},
+	{"coastTrajectory.advance", setupTrajectory, 0},
+}
+
+// allocCheckRuns は割り当ての確認で各ホットパスを実行する回数。
+// コーストの停止・再開始を何度か含むだけの回数にする。
+const allocCheckRuns = 2000
+
+func TestHotPathAllocs(t *testing.T) {
+	for _, h := range hotPaths {
+		t.Run(h.name, func(t *testing.T) {
+			if allocs := testing.AllocsPerRun(allocCheckRuns, h.setup()); allocs > h.maxAllocs {
+				t.Errorf("%.0f allocs/op, budget %.0f", allocs, h.maxAllocs)
+			}
+		})
+	}
+}
+
+// benchmarkHotPath は setup の返す1フレーム分の処理を計測する。
+func benchmarkHotPath(b *testing.B, setup func() func()) {
+	step := setup()
+	b.ReportAllocs()
+	for b.Loop() {
+		step()
+	}
+}
+
+func BenchmarkPrepareCoastFrame(b *testing.B) {
+	b.Run("cursor", func(b *testing.B) { benchmarkHotPath(b, setupCoastFrame) })
+	b.Run("drag", func(b *testing.B) { benchmarkHotPath(b, setupDragCoastFrame) })
+}
+
+func BenchmarkPrepareTouchFrame(b *testing.B) {
+	benchmarkHotPath(b, setupTouchFrame)
+}
+
+func BenchmarkCalcReleaseVelocity(b *testing.B) {
+	benchmarkHotPath(b, setupReleaseVelocity)
+}
+
+func BenchmarkHandleTouchMessage(b *testing.B) {
+	benchmarkHotPath(b, setupTouchMessage)
+}
+
+func BenchmarkCoastTrajectoryAdvance(b *testing.B) {
+	benchmarkHotPath(b, setupTrajectory)
+}
@@ -0,0 +1,46 @@
+// brake.go: 複数指のタップでの急停止（--brake-fingers）。
+// コースト中に指定した本数の指でパッドに触れると、カーソル慣性もドラッグ慣性もその場で止め、
+// 保留中のマウスアップを現在位置で発行する。1本指で正確に触れて止めるより速い非常停止。
+// 指は1本ずつ触れるため、タッチの開始時にコースト中（ドラッグの保持中）だったかを覚えておき、
+// そのタッチの間に本数が揃ったら止める。止めたタッチのリリースではコーストを始めない。
+package main
+
+import "fmt"
+
+// armBrake はタッチの開始時に、このタッチで急停止できるか（コースト中か）を記録する。
+// 速度をゼロにする前に呼ぶこと。アクター goroutine から呼ぶこと。
+func (a *App) armBrake() {
+	a.brakeArmed = a.brakeFingers > 0 && (a.vx != 0 || a.vy != 0 || a.dragPhase != dragPhaseNone)
+	a.braking = false
+}
+
+// checkBrake は指の本数が揃っていれば急停止し、そのアクションを返す。
+// ドラッグを保持していれば、コースト位置（追従中は指に合わせた位置）でマウスアップを発行する。
+// アクター goroutine から呼ぶこと。
+func (a *App) checkBrake(fingerCount int) (touchAction, bool) {
+	var action touchAction
+	if !a.brakeArmed || fingerCount < a.brakeFingers {
+		return action, false
+	}
+	a.brakeArmed = false
+	a.braking = true
+	if a.dragPhase != dragPhaseNone {
+		action.releaseX = a.coastX
+		action.releaseY = a.coastY
+		action.needMouseUpOnly = true
+		a.queueHook(&action.hooks, hookDragEnd)
+	}
+	action.pending = a.resetCoasting()
+	a.histLen = 0
+	fmt.Printf("[brake] %d-finger tap, coast stopped\n", fingerCount)
+	return action, true
+}
+
+// releaseBrake は急停止したタッチのリリースでコーストを始めないようにする。
+// アクター goroutine から呼ぶこと。
+func (a *App) releaseBrake() {
+	if a.braking {
+		a.vx, a.vy = 0, 0
+		a.braking = false
+	}
+}
@@ -0,0 +1,114 @@
+// capability.go: MultitouchSupport の機能の検出。
+// MultitouchSupport はプライベートフレームワークで、macOS のリリースによって関数がなくなったり
+// 動作が変わったりする。リンクせずに dlopen で開いて関数を dlsym で解決し（multitouch.c）、
+// 解決できなかった関数は起動時に検出して、その関数を使う機能を無効にする。
+// タッチの受信は関数があっても動かないことがあるため、デバイスに実際にコールバックを登録して確かめる。
+package main
+
+/*
+#include <stdlib.h>
+#include "multitouch.h"
+*/
+import "C"
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// mtCapabilities は MultitouchSupport で使える機能を表す。
+type mtCapabilities struct {
+	touch    bool     // タッチの受信（デバイスの列挙・コールバックの登録・開始と停止）
+	deviceID bool     // デバイス ID の取得（デバイスの増減のポーリング・触覚フィードバック）
+	haptics  bool     // アクチュエータによる触覚フィードバック
+	started  bool     // 検出時にデバイスのタッチの受信を開始できたか（デバイスがなければ false）
+	missing  []string // 見つからなかった関数
+}
+
+// 機能ごとに必要な関数
+var (
+	mtTouchSymbols = []string{
+		"MTDeviceCreateList",
+		"MTRegisterContactFrameCallback",
+		"MTUnregisterContactFrameCallback",
+		"MTDeviceStart",
+		"MTDeviceStop",
+	}
+	mtDeviceIDSymbols = []string{"MTDeviceGetDeviceID"}
+	mtHapticSymbols   = []string{
+		"MTActuatorCreateFromDeviceID",
+		"MTActuatorOpen",
+		"MTActuatorClose",
+		"MTActuatorActuate",
+	}
+)
+
+// multitouchCaps は起動時に1回だけ検出した機能を返す。
+var multitouchCaps = sync.OnceValue(probeMultitouch)
+
+// probeMultitouch は関数の有無を確かめ、タッチの受信が使えれば最初のデバイスで受信を開始・停止してみる。
+func probeMultitouch() mtCapabilities {
+	var caps mtCapabilities
+	has := func(symbols []string) bool {
+		ok := true
+		for _, name := range symbols {
+			cname := C.CString(name)
+			found := C.mt_has_symbol(cname) != 0
+			C.free(unsafe.Pointer(cname))
+			if !found {
+				caps.missing = append(caps.missing, name)
+				ok = false
+			}
+		}
+		return ok
+	}
+	caps.touch = has(mtTouchSymbols)
+	caps.deviceID = has(mtDeviceIDSymbols)
+	caps.haptics = caps.deviceID && has(mtHapticSymbols)
+	if caps.touch {
+		caps.started = probeTouchRegistration()
+	}
+	return caps
+}
+
+// probeTouchRegistration は最初のデバイスにコールバックを登録して受信を開始し、すぐに解除する。
+// 開始できたかを返す。デバイスがなければ false。
+func probeTouchRegistration() bool {
+	list := C.mt_device_create_list()
+	if list == 0 {
+		return false
+	}
+	defer C.CFRelease(C.CFTypeRef(list))
+	if C.CFArrayGetCount(list) == 0 {
+		return false
+	}
+	dev := C.MTDeviceRef(C.CFArrayGetValueAtIndex(list, 0))
+	C.mt_register_contact_frame_callback(dev, C.MTContactCallbackFunction(C.bridge_touch_callback))
+	ok := C.mt_device_start(dev, 0) == 0
+	unregisterTouchCallback(MTDeviceRef(dev))
+	return ok
+}
+
+// describe は機能の一覧を「名前: yes/no」の形で返す。
+func (c mtCapabilities) describe() string {
+	yesNo := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+	return fmt.Sprintf("touch: %s, touch start: %s, device ID: %s, haptics: %s",
+		yesNo(c.touch), yesNo(c.started), yesNo(c.deviceID), yesNo(c.haptics))
+}
+
+// reportMultitouchCaps は使えない機能があれば、起動時に理由とともに表示する。
+func reportMultitouchCaps() {
+	caps := multitouchCaps()
+	if len(caps.missing) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "[multitouch] missing on macOS %s: %s\n", macOSVersion(), strings.Join(caps.missing, ", "))
+	fmt.Fprintf(os.Stderr, "[multitouch] %s\n", caps.describe())
+}
@@ -0,0 +1,57 @@
+// clickthrough.go: 投げた先のクリック（クリックスルー）。
+// カーソルの慣性中にトラックパッドをタップするとタッチでコーストは止まるが、クリックは
+// 指を離した時点のカーソル位置に届くため、タップ中の指のずれで投げた先の隣をクリックしてしまう。
+// コースト中に始まったタッチのクリックを、タッチした時点のコースト位置へ移す。
+package main
+
+import "math"
+
+// clickThroughWindow はコースト中のタッチから、クリックスルーの対象とするマウスダウン・マウスアップまでの猶予（秒）。
+// タップのクリックは指を離した後に届くため、タップの長さより少し長くする。
+const clickThroughWindow = 0.5
+
+// armClickThrough はコースト中（ドラッグ慣性を除く）に始まったタッチで、コースト位置へのクリックを準備する。
+// タッチでコーストが止まる前（速度をゼロにする前）に呼ぶこと。
+// アクター goroutine から呼ぶこと。
+func (a *App) armClickThrough(timestamp float64) {
+	a.clickThroughArmed = false
+	if !a.clickThrough || a.dragPhase != dragPhaseNone || (a.vx == 0 && a.vy == 0) {
+		return
+	}
+	a.clickThroughArmed = true
+	a.clickThroughX = math.RoundToEven(a.coastX)
+	a.clickThroughY = math.RoundToEven(a.coastY)
+	a.clickThroughSince = timestamp
+}
+
+// trackClickThrough はタッチ中のカーソル位置を見て、指を動かした（タップではない）ならクリックスルーをやめる。
+// アクター goroutine から呼ぶこと。
+func (a *App) trackClickThrough(x, y float64) {
+	if a.clickThroughArmed && math.Hypot(x-a.clickThroughX, y-a.clickThroughY) > dragFollowMovementThreshold {
+		a.clickThroughArmed = false
+	}
+}
+
+// takeClickThrough はマウスダウンをコースト位置へ移すかを判定し、移す先を返す。
+// 準備は1回のマウスダウンで使い切る。移した場合は、対になるマウスアップも同じ位置へ移す（clickThroughUpUntil）。
+// アクター goroutine から呼ぶこと。
+func (a *App) takeClickThrough(now float64) (x, y float64, ok bool) {
+	if !a.clickThroughArmed {
+		return 0, 0, false
+	}
+	a.clickThroughArmed = false
+	if now-a.clickThroughSince > clickThroughWindow {
+		return 0, 0, false
+	}
+	a.clickThroughUpUntil = now + clickThroughWindow
+	return a.clickThroughX, a.clickThroughY, true
+}
+
+// takeClickThroughUp はマウスアップをクリックスルーの位置へ移すかを返す。
+// 押したまま動かした（猶予を過ぎた）マウスアップは移さない。
+// アクター goroutine から呼ぶこと。
+func (a *App) takeClickThroughUp(now float64) bool {
+	until := a.clickThroughUpUntil
+	a.clickThroughUpUntil = 0
+	return now <= until
+}
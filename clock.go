@@ -0,0 +1,25 @@
+// clock.go: マルチタッチのタイムスタンプと同じ時間軸の単調時計。
+// MultitouchSupport のタイムスタンプは mach_absolute_time を秒に換算した値のため、
+// コーストループも同じ時計を使うことで、リリースから最初のコーストフレームまでの
+// 経過時間を正確に求められる。
+package main
+
+/*
+#include <mach/mach_time.h>
+
+static double mach_time_seconds(void) {
+    static mach_timebase_info_data_t tb;
+    if (tb.denom == 0) {
+        mach_timebase_info(&tb);
+    }
+    return (double)mach_absolute_time() * tb.numer / tb.denom / 1e9;
+}
+*/
+import "C"
+
+// monotonicSeconds は mach_absolute_time ベースの現在時刻（秒）を返す。
+// タッチコールバックの timestamp と比較できる。
+// 状態機械は App.now を通して読む（スナップショットの再生で差し替えるため。snapshot.go）。
+func monotonicSeconds() float64 {
+	return float64(C.mach_time_seconds())
+}
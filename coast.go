@@ -2,92 +2,230 @@
 // ~60Hz ループの1フレーム分の慣性計算と実行。
 package main
 
-import "math"
+import (
+	"fmt"
+	"math"
+	"os"
+)
 
 // coastAction はコーストループの1フレームで実行するアクションを表す。
-// prepareCoastFrame が mutex 内で準備し、executeCoastFrame が mutex 外で実行する。
+// prepareCoastFrame が状態遷移とともに準備し、executeCoastFrame が副作用（cgo 呼び出し）を実行する。
 type coastAction struct {
-	moveX, moveY   float64  // 通常の慣性移動先（絶対座標）
-	hasMove        bool     // 通常の慣性フレームか
-	dragX, dragY   float64  // ドラッグ慣性のカーソル位置
-	dragDx, dragDy int      // ドラッグイベントの整数デルタ
-	isDragCoasting bool     // ドラッグ慣性フレームか
-	coastEnded     bool     // コーストが今フレームで終了したか
-	pending        eventRef // 終了時に解放するマウスアップ
+	moveDx, moveDy int         // 通常の慣性の相対移動量
+	hasMove        bool        // 通常の慣性フレームか
+	dragX, dragY   float64     // ドラッグ慣性のカーソル位置
+	dragDx, dragDy int         // ドラッグイベントの整数デルタ
+	isDragCoasting bool        // ドラッグ慣性フレームか
+	coastEnded     bool        // ドラッグ慣性が今フレームで終了したか
+	abandoned      bool        // ドラッグ慣性をカーソルを戻さずに終了するか（mouseUp を dragX/dragY で発行）
+	stopped        bool        // コースト（通常・ドラッグ）が今フレームで自然停止したか
+	hotEdge        string      // 実行するホットエッジのアクション名
+	pending        eventRef    // 終了時に解放するマウスアップ
+	hud            hudFrame    // HUD の表示内容
+	hooks          []hookEvent // 実行するシェルフック
 }
 
-// prepareCoastFrame は mutex 内でコーストの1フレーム分の状態を計算する。
-func (a *App) prepareCoastFrame(dt float64) coastAction {
-	a.mu.Lock()
-	defer a.mu.Unlock()
-
+// prepareCoastFrame はコーストの1フレーム分の状態を計算する。アクター goroutine から呼ぶこと。
+// now は monotonicSeconds の現在時刻。経過時間は前回のコースト位置の時刻 coastT から求めるため、
+// 最初のフレームはリリース直前のタッチのタイムスタンプから計算され、指からの引き継ぎが途切れない。
+func (a *App) prepareCoastFrame(now float64) coastAction {
 	var action coastAction
+	if a.springHold {
+		return a.prepareSpringHold(now)
+	}
+	if a.dropSettling {
+		return a.prepareDropSettle(now)
+	}
 	if a.vx == 0 && a.vy == 0 {
+		action.hud = a.currentHUDFrame()
 		return action
 	}
 
+	dt := now - a.coastT
+	if dt <= 0 || dt > maxCoastFrameDelta {
+		dt = a.loopInterval.Seconds()
+	}
+	dt = a.smoothFrameDelta(dt)
+	a.coastT = now
+	a.lastCoastAt = now
+
+	// 位置と速度を減衰軌跡に沿って進める（trajectory.go）
+	prevX, prevY := a.coastX, a.coastY
+	a.advanceCoast(dt)
+	var edge screenEdge
+	var speed float64
 	if a.dragPhase == dragPhaseCoasting {
-		// 位置を更新し、画面端でクランプする
-		prevX, prevY := a.coastX, a.coastY
-		a.coastX += a.vx * dt
-		a.coastY += a.vy * dt
-		a.clampToScreen()
+		// 画面端でクランプする
+		a.avoidStageStrip(prevX)
+		edge, speed = a.clampToScreen()
+		a.startEdgeHold(edge, speed, now)
 
 		// 実際の移動量（クランプ後）から整数デルタを抽出する
 		action.dragDx, action.dragDy = a.extractIntegerDelta(a.coastX-prevX, a.coastY-prevY)
+		if action.dragDx != 0 || action.dragDy != 0 {
+			a.dragMovedAt = now
+		}
 
 		action.dragX = a.coastX
 		action.dragY = a.coastY
 		action.isDragCoasting = true
 	} else {
-		// 通常コースト: 位置を更新し画面端でクランプする
-		a.coastX += a.vx * dt
-		a.coastY += a.vy * dt
-		a.clampToScreen()
-		action.moveX = a.coastX
-		action.moveY = a.coastY
+		// 通常コースト: 画面端でクランプする。
+		// 位置は float の coastX/coastY で追跡し続け、発行時のみ偶数丸めで整数化する。
+		// 端数は coastX/coastY に残るため、減衰の終盤でも誤差が蓄積せず階段状にならない。
+		// 発行は丸めた位置の差分（相対移動）で行い、同時に動かされた物理マウスの移動を上書きしない。
+		edge, speed = a.clampToScreen()
+		action.hotEdge = a.prepareHotEdge(edge, speed)
+		if a.barAssist {
+			a.applyBarAssist(dt)
+		}
+		action.moveDx = int(math.RoundToEven(a.coastX) - math.RoundToEven(prevX))
+		action.moveDy = int(math.RoundToEven(a.coastY) - math.RoundToEven(prevY))
 		action.hasMove = true
 	}
+	if edge != edgeNone {
+		// 画面端にぶつかったら曲げるのをやめ、端に沿って直進させる
+		a.omega = 0
+	}
+	a.stats.addDistance(math.Hypot(a.coastX-prevX, a.coastY-prevY))
 
-	a.applyDecay(dt)
+	if a.edgeHold != edgeNone {
+		// 端での保持中（スペース切り替え待ち）は減衰させず、終わったらドロップする
+		var done bool
+		action.hotEdge, done = a.advanceEdgeHold(now)
+		if !done {
+			action.hud = a.currentHUDFrame()
+			return action
+		}
+		a.vx, a.vy = 0, 0
+	}
 	if a.vx == 0 && a.vy == 0 {
-		// 自然停止: 最終位置にカーソルを同期してからマウスアップを解放する
+		action.stopped = true
+		a.coastEnd = coastEndDecayed
+		if edge != edgeNone || a.edgeHold != edgeNone {
+			a.coastEnd = coastEndClamped
+		}
+		a.queueHook(&action.hooks, hookCoastEnd)
+		// 自然停止: 最終位置にカーソルを同期してからマウスアップを解放する。
+		// スプリングローディング・安全なドロップが有効なら、停止位置の問い合わせが終わるまでドロップを保留する。
+		// 静定待ち（dropsettle.go）が有効なら、カーソルが止まって一定時間経つまで保留する。
 		if a.dragPhase == dragPhaseCoasting {
+			if a.startSpringHold(now) || a.startDropSettle(now) {
+				action.hud = a.currentHUDFrame()
+				return action
+			}
 			action.dragX = a.coastX
 			action.dragY = a.coastY
 			action.coastEnded = true
+			a.queueHook(&action.hooks, hookDragEnd)
+		} else {
+			a.startDeadTime(now)
 		}
 		action.pending = a.resetCoasting()
 	}
+	action.hud = a.currentHUDFrame()
 
 	return action
 }
 
+// runCoastFrame はコーストの1フレームを計算・実行し、カーソルがリンク先のデバイスへ
+// 移っていればコーストを終了する。アクター goroutine から呼ぶこと。
+func (a *App) runCoastFrame(now float64, dp *dragPoster) {
+	if x, y, ok := a.executeCoastFrame(a.prepareCoastFrame(now), dp); ok {
+		a.executeCoastFrame(a.prepareLinkedDeviceExit(x, y), dp)
+	}
+	if x, y, ok := a.prepareSnapQuery(); ok {
+		a.querySnapTarget(x, y)
+	}
+}
+
 // executeCoastFrame はコーストアクションに基づき cgo 呼び出しを実行する。
-func (a *App) executeCoastFrame(action coastAction, dp *dragPoster) {
+// 戻り値はイベント発行前に観測したカーソル位置（発行しなかった場合は ok=false）。
+func (a *App) executeCoastFrame(action coastAction, dp *dragPoster) (x, y float64, ok bool) {
 	if action.isDragCoasting {
-		dp.post(action.dragX, action.dragY, action.dragDx, action.dragDy)
+		x, y, ok = dp.post(action.dragX, action.dragY, action.dragDx, action.dragDy, a.dragAttrs)
 	} else if action.hasMove {
-		setMouseLocation(action.moveX, action.moveY)
+		x, y, ok = postMouseMovedBy(action.moveDx, action.moveDy)
+	}
+	if action.hotEdge != "" {
+		runHotEdgeAction(action.hotEdge)
+	}
+	if action.abandoned {
+		if action.pending != 0 {
+			a.dragReleaseFeedback()
+		}
+		releasePendingMouseUpAt(action.pending, action.dragX, action.dragY)
+		action.pending = 0 // 発行済み
 	}
 	if action.coastEnded {
+		if action.pending != 0 {
+			a.dragReleaseFeedback()
+		}
 		endDragSession(action.pending, action.dragX, action.dragY)
 		action.pending = 0 // 発行済み
 	}
 	releasePendingMouseUp(action.pending)
+	postHUDFrame(action.hud)
+	if action.stopped {
+		playSound(a.sounds.coastEnd)
+	}
+	a.runHooks(action.hooks)
+	return x, y, ok
+}
+
+// prepareLinkedDeviceExit は観測したカーソル位置がローカルのディスプレイ外にあれば、
+// コーストを終了するアクションを返す。
+// Sidecar / ユニバーサルコントロールでカーソルが iPad や別の Mac に移ると、CG 座標は
+// ローカルのディスプレイの外を指し、合成ドラッグも届かなくなる。存在しない画面端に
+// クランプし続けるのではなく、保留中の mouseUp をその場で解放してコーストを終える。
+// カーソルはリンク先にあるため、ワープで呼び戻さない。
+// アクター goroutine から呼ぶこと。
+func (a *App) prepareLinkedDeviceExit(x, y float64) coastAction {
+	var action coastAction
+	if (a.vx == 0 && a.vy == 0) || a.onLocalDisplay(x, y) {
+		return action
+	}
+	fmt.Fprintln(os.Stderr, "[coast] cursor moved to a linked device, ending coast")
+	if a.dragPhase == dragPhaseCoasting {
+		action.dragX = a.coastX
+		action.dragY = a.coastY
+		action.abandoned = true
+		a.queueHook(&action.hooks, hookDragEnd)
+	}
+	action.pending = a.resetCoasting()
+	action.hud = a.currentHUDFrame()
+	return action
+}
+
+// onLocalDisplay は座標がキャッシュ済みのローカルのディスプレイ内にあるかを返す。
+// 実際のカーソルは端のピクセル内の端数位置も取るため、右端・下端は1px 分広く判定する。
+// アクター goroutine から呼ぶこと。
+func (a *App) onLocalDisplay(x, y float64) bool {
+	for _, s := range a.screens {
+		if x >= s.minX && x < s.maxX+1 && y >= s.minY && y < s.maxY+1 {
+			return true
+		}
+	}
+	return false
 }
 
 // clampToScreen はコースト中のカーソル位置をディスプレイ内にクランプする。
 // いずれかのディスプレイ矩形内にあれば coastScreenIdx を更新して終了。
+// バッキングスケールの異なるディスプレイへ移った場合は、画面上の見かけの速さ（物理ピクセル/秒）が
+// 変わらないよう速度を補正する。
 // どのディスプレイにも属さない場合、最後にいたディスプレイの端にクランプし、
-// クランプで変化した軸の速度をゼロにする。
-// mu をロックした状態で呼ぶこと。
-func (a *App) clampToScreen() {
+// クランプで変化した軸の速度をゼロにする。ぶつかった端と、その時点の端に向かう速さを返す。
+// アクター goroutine から呼ぶこと。
+func (a *App) clampToScreen() (edge screenEdge, speed float64) {
 	for i, s := range a.screens {
 		if a.coastX >= s.minX && a.coastX <= s.maxX &&
 			a.coastY >= s.minY && a.coastY <= s.maxY {
+			if prev := a.screens[a.coastScreenIdx]; i != a.coastScreenIdx && prev.scale != s.scale {
+				a.vx *= prev.scale / s.scale
+				a.vy *= prev.scale / s.scale
+			}
 			a.coastScreenIdx = i
-			return
+			return edgeNone, 0
 		}
 	}
 
@@ -97,22 +235,37 @@ func (a *App) clampToScreen() {
 	cy := math.Max(s.minY, math.Min(a.coastY, s.maxY))
 
 	if cx != a.coastX {
+		edge, speed = edgeRight, a.vx
+		if a.coastX < s.minX {
+			edge, speed = edgeLeft, -a.vx
+		}
 		a.coastX = cx
 		a.vx = 0
 	}
 	if cy != a.coastY {
+		// 上下の端に速く向かっていた場合はそちらを優先する
+		if vy := math.Abs(a.vy); vy > speed {
+			edge, speed = edgeBottom, a.vy
+			if a.coastY < s.minY {
+				edge, speed = edgeTop, -a.vy
+			}
+		}
 		a.coastY = cy
 		a.vy = 0
 	}
+	return edge, speed
 }
 
 // cacheScreenBounds は画面バウンドを取得してキャッシュする。
 // コースト開始時に1回だけ呼ぶ。
-// mu をロックした状態で呼ぶこと。
+// アクター goroutine から呼ぶこと。
 // screenBounds() は CGGetActiveDisplayList を呼ぶ cgo 呼び出しだが、
-// 単純なクエリでありコールバックやブロッキングのリスクがないため mutex 内で安全に呼べる。
+// 単純なクエリでありコールバックやブロッキングのリスクがないため、状態遷移中に呼んでも安全。
 func (a *App) cacheScreenBounds() {
 	a.screens = screenBounds()
+	if a.barAssist {
+		a.bars = barRects()
+	}
 	a.coastScreenIdx = 0
 	for i, s := range a.screens {
 		if a.coastX >= s.minX && a.coastX <= s.maxX &&
@@ -124,7 +277,7 @@ func (a *App) cacheScreenBounds() {
 }
 
 // extractIntegerDelta は端数デルタを蓄積し、整数部を抽出して返す。
-// mu をロックした状態で呼ぶこと。
+// アクター goroutine から呼ぶこと。
 func (a *App) extractIntegerDelta(dx, dy float64) (int, int) {
 	a.accumX += dx
 	a.accumY += dy
@@ -134,15 +287,27 @@ func (a *App) extractIntegerDelta(dx, dy float64) (int, int) {
 	return ix, iy
 }
 
-// applyDecay は慣性速度に指数減衰を適用する。
-// mu をロックした状態で呼ぶこと。
-func (a *App) applyDecay(dt float64) {
-	factor := math.Exp(-decayRate * dt)
-	a.vx *= factor
-	a.vy *= factor
+// predictStop は現在の速度から、指数減衰で停止するまでの移動先を閉形式で予測する。
+// 速さ |v(t)| = |v0|e^(-kt) が停止閾値 th を下回る時刻 T = ln(|v0|/th)/k までの移動量は
+// v0(1 - e^(-kT))/k = v0(1 - th/|v0|)/k となる（predictCoast）。画面端でのクランプは考慮しない。
+// アクター goroutine から呼ぶこと。
+func (a *App) predictStop() (x, y float64) {
+	p := a.predictCoast()
+	return p.StopX, p.StopY
+}
 
-	if math.Sqrt(a.vx*a.vx+a.vy*a.vy) < stopThreshold {
-		a.vx = 0
-		a.vy = 0
+// smoothFrameDelta はコーストフレームの経過時間を直近の平均の ±frameDeltaJitter に収めて返す。
+// 負荷で ticker が遅れたフレームでも移動量が跳ねず、見た目の速さが一定に保たれる。
+// 切り捨てた時間の分だけコーストは実時間より遅れるが、減衰も同じ時間で計算するため、
+// 止まる位置は変わらない。コースト最初のフレーム（リリース直前のタッチからの経過時間）は平滑化しない。
+// アクター goroutine から呼ぶこと。
+func (a *App) smoothFrameDelta(dt float64) float64 {
+	if a.frameDeltaAvg == 0 {
+		a.frameDeltaAvg = a.loopInterval.Seconds()
+		return dt
 	}
+	avg := a.frameDeltaAvg
+	dt = max(avg*(1-frameDeltaJitter), min(dt, avg*(1+frameDeltaJitter)))
+	a.frameDeltaAvg += (dt - avg) * frameDeltaSmoothing
+	return dt
 }
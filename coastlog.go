@@ -0,0 +1,162 @@
+// coastlog.go: コーストの履歴の記録。
+// 摩擦を調整するユーザーが実際の使い方をオフラインで分析できるように、コーストごとに
+// リリース時の速度・所要時間・移動距離・終了の理由を1行ずつファイルに追記する。
+// 拡張子が .csv なら CSV（新規ファイルにはヘッダー行を付ける）、それ以外は JSON Lines で書く。
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// コーストの終了の理由
+const (
+	coastEndDecayed   = "decayed"   // 減衰して自然停止した
+	coastEndClamped   = "clamped"   // 画面端にぶつかって止まった
+	coastEndCaught    = "caught"    // 再タッチで止めた
+	coastEndCancelled = "cancelled" // それ以外（マウスダウン・一時停止・リリースフィルタ等）で打ち切られた
+)
+
+// coastLogBuffer は書き込み待ちの記録の数。書き込みが詰まったら記録を捨てる（コーストを止めない）。
+const coastLogBuffer = 64
+
+// coastRecord は1回のコーストの記録を表す。
+type coastRecord struct {
+	Start    time.Time `json:"start"`
+	Drag     bool      `json:"drag"` // ドラッグ慣性か
+	VX       float64   `json:"vx"`   // リリース時の速度 (px/sec)
+	VY       float64   `json:"vy"`
+	Speed    float64   `json:"speed"`
+	Duration float64   `json:"duration"` // 所要時間（秒）
+	Distance float64   `json:"distance"` // 移動距離 (px)
+	End      string    `json:"end"`      // 終了の理由
+	Preset   string    `json:"preset,omitempty"`
+
+	t0 float64 // 開始時刻（monotonicSeconds）
+}
+
+// coastRecordHeader は CSV のヘッダー行。
+var coastRecordHeader = []string{"start", "drag", "vx", "vy", "speed", "duration", "distance", "end", "preset"}
+
+// csvRow は記録を CSV の1行にする。
+func (r *coastRecord) csvRow() []string {
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', 3, 64) }
+	return []string{r.Start.Format(time.RFC3339Nano), strconv.FormatBool(r.Drag),
+		f(r.VX), f(r.VY), f(r.Speed), f(r.Duration), f(r.Distance), r.End, r.Preset}
+}
+
+// coastLogger はコーストの記録をファイルに追記する。書き込みは専用 goroutine で行う。
+type coastLogger struct {
+	f       *os.File
+	csv     *csv.Writer // CSV で書くか（nil なら JSON Lines）
+	records chan coastRecord
+	done    chan struct{}
+}
+
+// openCoastLog は記録ファイルを追記モードで開き、書き込みを開始する。
+func openCoastLog(path string) (*coastLogger, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	l := &coastLogger{f: f, records: make(chan coastRecord, coastLogBuffer), done: make(chan struct{})}
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		l.csv = csv.NewWriter(f)
+		if info, err := f.Stat(); err == nil && info.Size() == 0 {
+			l.csv.Write(coastRecordHeader)
+			l.csv.Flush()
+		}
+	}
+	go l.run()
+	return l, nil
+}
+
+// log は記録を書き込み待ちに追加する。書き込みが詰まっていれば捨てる。
+func (l *coastLogger) log(r coastRecord) {
+	select {
+	case l.records <- r:
+	default:
+	}
+}
+
+// run は記録を1行ずつ書き込む。close まで実行する。
+func (l *coastLogger) run() {
+	defer close(l.done)
+	failed := false
+	for r := range l.records {
+		var err error
+		if l.csv != nil {
+			l.csv.Write(r.csvRow())
+			l.csv.Flush()
+			err = l.csv.Error()
+		} else {
+			var data []byte
+			if data, err = json.Marshal(r); err == nil {
+				_, err = l.f.Write(append(data, '\n'))
+			}
+		}
+		if err != nil && !failed {
+			fmt.Fprintf(os.Stderr, "[coastlog] write failed: %v\n", err)
+			failed = true
+		}
+	}
+}
+
+// close は書き込み待ちの記録を書き終えてからファイルを閉じる。
+func (l *coastLogger) close() {
+	close(l.records)
+	<-l.done
+	l.f.Close()
+}
+
+// startCoastRecord はコーストの開始を記録する。アクター goroutine から呼ぶこと。
+func (a *App) startCoastRecord() {
+	if a.coastLog == nil && !a.adaptiveFriction {
+		return
+	}
+	a.coastRec = coastRecord{
+		Start:  time.Now(),
+		Drag:   a.dragPhase == dragPhaseCoasting,
+		VX:     a.vx,
+		VY:     a.vy,
+		Speed:  math.Hypot(a.vx, a.vy),
+		Preset: a.preset,
+		t0:     a.coastT,
+	}
+	a.coastRecording = true
+	a.coastEnd = ""
+}
+
+// finishCoastRecord は記録中のコーストが止まっていれば、終了の理由を付けて書き込む。
+// コーストを止める経路は多いため、アクターのループで1メッセージ（フレーム）ごとに確認する。
+// 理由が分かる経路（自然停止・画面端・再タッチ）は coastEnd に設定し、それ以外は打ち切りとする。
+// 摩擦の学習（learnfriction.go）にも同じ記録を渡す。
+// アクター goroutine から呼ぶこと。
+func (a *App) finishCoastRecord() {
+	if !a.coastRecording || a.vx != 0 || a.vy != 0 {
+		return
+	}
+	r := a.coastRec
+	r.End = a.coastEnd
+	if r.End == "" {
+		r.End = coastEndCancelled
+	}
+	r.Duration = max(a.now()-r.t0, 0)
+	r.Distance = a.stats.current
+	if a.coastLog != nil {
+		a.coastLog.log(r)
+	}
+	a.learnFriction(r)
+	a.coastRecording = false
+	a.coastEnd = ""
+}
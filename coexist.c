@@ -0,0 +1,61 @@
+// coexist.c: CGGetEventTapList で他のプロセスの EventTap を列挙する（doctor では自分の tap も）。
+#include <libproc.h>
+#include <stdlib.h>
+#include <unistd.h>
+#include "coexist.h"
+
+int coexist_list_taps(CGEventMask mask, pid_t *pids, char *names, int nameLen, int max) {
+    uint32_t count = 0;
+    if (CGGetEventTapList(0, NULL, &count) != kCGErrorSuccess || count == 0) {
+        return 0;
+    }
+    CGEventTapInformation *taps = calloc(count, sizeof(CGEventTapInformation));
+    if (taps == NULL) {
+        return 0;
+    }
+    int n = 0;
+    if (CGGetEventTapList(count, taps, &count) == kCGErrorSuccess) {
+        pid_t self = getpid();
+        for (uint32_t i = 0; i < count && n < max; i++) {
+            if (!taps[i].enabled || taps[i].tappingProcess == self || (taps[i].eventsOfInterest & mask) == 0) {
+                continue;
+            }
+            pids[n] = taps[i].tappingProcess;
+            char *name = names + n * nameLen;
+            if (proc_name(pids[n], name, nameLen) <= 0) {
+                name[0] = '\0';
+            }
+            n++;
+        }
+    }
+    free(taps);
+    return n;
+}
+
+int coexist_list_all_taps(coexist_tap_info *out, int max) {
+    uint32_t count = 0;
+    if (CGGetEventTapList(0, NULL, &count) != kCGErrorSuccess || count == 0) {
+        return 0;
+    }
+    CGEventTapInformation *taps = calloc(count, sizeof(CGEventTapInformation));
+    if (taps == NULL) {
+        return 0;
+    }
+    int n = 0;
+    if (CGGetEventTapList(count, taps, &count) == kCGErrorSuccess) {
+        for (uint32_t i = 0; i < count && n < max; i++) {
+            coexist_tap_info *t = &out[n];
+            t->pid = taps[i].tappingProcess;
+            if (proc_name(t->pid, t->name, sizeof(t->name)) <= 0) {
+                t->name[0] = '\0';
+            }
+            t->location = taps[i].tapPoint;
+            t->options = taps[i].options;
+            t->enabled = taps[i].enabled;
+            t->mask = taps[i].eventsOfInterest;
+            n++;
+        }
+    }
+    free(taps);
+    return n;
+}
@@ -0,0 +1,82 @@
+// coexist.go: 他のイベント傍受ユーティリティとの共存。
+// BetterTouchTool・Mos などもマウスボタンやスクロールを EventTap で傍受・書き換えるため、
+// 同じイベントを奪い合うとドラッグが切れたりスクロールが二重になったりする。
+// 起動時に他のプロセスの EventTap を列挙して既知のユーティリティを警告し、
+// --conservative で傍受を控えめにする（mouseDown は監視専用の tap で見て、合成イベントに印を付ける）。
+package main
+
+/*
+#cgo LDFLAGS: -framework CoreGraphics
+#include "coexist.h"
+*/
+import "C"
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// maxTapConflicts は列挙する他のプロセスの EventTap の最大数。
+const maxTapConflicts = 32
+
+// knownTapUtilities は既知のイベント傍受ユーティリティ（プロセス名の小文字）と、併用時の注意点。
+var knownTapUtilities = map[string]string{
+	"bettertouchtool": "trackpad gestures and click remapping can swallow or reorder the mouse-ups coastpad holds during drag coasts",
+	"mos":             "smooths scrolling too; disable --smooth-scroll or Mos for external mice",
+	"linearmouse":     "smooths and reverses scrolling too; disable --smooth-scroll or LinearMouse scrolling",
+	"steermouse":      "remaps buttons and may post its own button events",
+	"smoothscroll":    "smooths scrolling too; disable --smooth-scroll or SmoothScroll",
+	"scroll reverser": "rewrites scroll events and may fight --smooth-scroll",
+	"hammerspoon":     "eventtap scripts may consume or re-post mouse buttons",
+	"swish":           "trackpad gestures may consume the same touches",
+}
+
+// tapConflict は他のプロセスの EventTap を表す。
+type tapConflict struct {
+	pid    int
+	name   string
+	hazard string // 既知のユーティリティの注意点（不明なら空）
+}
+
+// findTapConflicts はマウスボタン・スクロールを傍受している他のプロセスの EventTap を返す。
+func findTapConflicts() []tapConflict {
+	mask := C.CGEventMask((1 << C.kCGEventLeftMouseDown) | (1 << C.kCGEventLeftMouseUp) |
+		(1 << C.kCGEventLeftMouseDragged) | (1 << C.kCGEventScrollWheel))
+	const nameLen = 64
+	var pids [maxTapConflicts]C.pid_t
+	var names [maxTapConflicts * nameLen]C.char
+	n := int(C.coexist_list_taps(mask, &pids[0], &names[0], nameLen, maxTapConflicts))
+
+	var conflicts []tapConflict
+	seen := make(map[int]bool)
+	for i := range n {
+		pid := int(pids[i])
+		if seen[pid] {
+			continue // 1つのプロセスが複数の tap を持つことがある
+		}
+		seen[pid] = true
+		name := C.GoString(&names[i*nameLen])
+		conflicts = append(conflicts, tapConflict{
+			pid:    pid,
+			name:   name,
+			hazard: knownTapUtilities[strings.ToLower(name)],
+		})
+	}
+	return conflicts
+}
+
+// warnTapConflicts は既知のイベント傍受ユーティリティが動いていれば注意点を警告する。
+// 控えめモードでなければ --conservative を勧める。
+func warnTapConflicts(conservative bool) {
+	warned := false
+	for _, c := range findTapConflicts() {
+		if c.hazard == "" {
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "[coexist] %s (pid %d) also taps mouse events: %s\n", c.name, c.pid, c.hazard)
+		warned = true
+	}
+	if warned && !conservative {
+		fmt.Fprintln(os.Stderr, "[coexist] if drags or clicks misbehave, try --conservative")
+	}
+}
@@ -0,0 +1,26 @@
+// coexist.h: 他のプロセスの CGEventTap の列挙。
+#ifndef COEXIST_H
+#define COEXIST_H
+
+#include <CoreGraphics/CoreGraphics.h>
+
+// 他のプロセスの有効な EventTap のうち、mask のイベントを傍受するものについて、
+// プロセス ID を pids に、プロセス名を names（1件あたり nameLen バイト）に書き込む。
+// 書き込んだ件数（最大 max）を返す。取得できなければ 0 を返す。
+int coexist_list_taps(CGEventMask mask, pid_t *pids, char *names, int nameLen, int max);
+
+// EventTap の情報（診断用）。
+typedef struct {
+    pid_t pid;
+    char name[64];
+    uint32_t location; // CGEventTapLocation
+    uint32_t options;  // CGEventTapOptions
+    int enabled;
+    CGEventMask mask;
+} coexist_tap_info;
+
+// 自分を含む全てのプロセスの EventTap を、CGGetEventTapList の返す順に taps に書き込む。
+// 書き込んだ件数（最大 max）を返す。取得できなければ 0 を返す。
+int coexist_list_all_taps(coexist_tap_info *taps, int max);
+
+#endif
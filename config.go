@@ -0,0 +1,274 @@
+// config.go: 設定の書き出しと読み込み。
+// `coastpad config export` で実効的な設定を1つの JSON ファイルに書き出し、
+// `coastpad config import` で defaults ドメインに取り込む。調整した「滑り心地」を共有できる。
+// `coastpad config check` は取り込む前にファイルを検証し、問題点と取り込んだ場合の実効的な設定を表示する。
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// configFileVersion は設定ファイルの形式のバージョン。
+const configFileVersion = 1
+
+// configFile は書き出す設定ファイルの内容を表す。
+type configFile struct {
+	Version int         `json:"version"`
+	Preset  string      `json:"preset,omitempty"` // 個別変更後は空
+	Params  coastParams `json:"params"`
+}
+
+// configIssue は設定ファイルの検証で見つかった問題を表す。
+type configIssue struct {
+	warning bool   // 取り込めるが意図と違う動作になりうるもの（false なら取り込めない）
+	key     string // 問題のあるキー（params.decay_rate のようなパス、ファイル全体なら空）
+	msg     string
+}
+
+func (i configIssue) String() string {
+	level := "error"
+	if i.warning {
+		level = "warning"
+	}
+	if i.key == "" {
+		return level + ": " + i.msg
+	}
+	return fmt.Sprintf("%s: %s: %s", level, i.key, i.msg)
+}
+
+// runConfigCommand は `coastpad config export|import|check [file]` を実行する。
+// file を省略した場合は標準出力・標準入力を使う。
+func runConfigCommand(args []string) error {
+	const usage = "usage: coastpad config export|import|check [file]"
+	if len(args) < 1 || len(args) > 2 {
+		return errors.New(usage)
+	}
+	path := ""
+	if len(args) == 2 {
+		path = args[1]
+	}
+	switch args[0] {
+	case "export":
+		return exportConfig(path)
+	case "import":
+		return importConfig(path)
+	case "check":
+		return checkConfigCommand(path)
+	}
+	return errors.New(usage)
+}
+
+// exportConfig は実効的な設定を path（空なら標準出力）に書き出す。
+// coastpad が実行中ならその時点の値を、そうでなければ defaults ドメインの値を書き出す。
+func exportConfig(path string) error {
+	var st presetStatus
+	if result, err := sendControl("preset"); err == nil {
+		if err := json.Unmarshal(result, &st); err != nil {
+			return err
+		}
+	} else if st, err = effectiveDefaults(); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(configFile{Version: configFileVersion, Preset: st.Preset, Params: st.Params}, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	if path == "" {
+		_, err = os.Stdout.Write(data)
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// readConfigInput は path（空なら標準入力）の内容を読み込む。
+func readConfigInput(path string) ([]byte, error) {
+	if path == "" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// checkConfig は設定ファイルの内容をスキーマに照らして検証する。
+// 最初の問題で止めずに、未知のキー・型の誤り・欠けているパラメータ・範囲外の値・矛盾する指定をすべて返す。
+// 問題があっても、読み取れた範囲で取り込んだ場合の実効的な設定を返す。
+func checkConfig(data []byte) (configFile, []configIssue) {
+	var issues []configIssue
+	fail := func(key, format string, args ...any) {
+		issues = append(issues, configIssue{key: key, msg: fmt.Sprintf(format, args...)})
+	}
+	warn := func(key, format string, args ...any) {
+		issues = append(issues, configIssue{warning: true, key: key, msg: fmt.Sprintf(format, args...)})
+	}
+
+	cfg := configFile{Version: configFileVersion, Params: presets[defaultPresetName]}
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(data, &top); err != nil {
+		fail("", "parse config: %v", err)
+		return cfg, issues
+	}
+	for _, key := range sortedKeys(top) {
+		switch key {
+		case "version", "preset", "params":
+		default:
+			fail(key, "unknown key (available: version, preset, params)")
+		}
+	}
+
+	if raw, ok := top["version"]; !ok {
+		fail("version", "missing")
+	} else if err := json.Unmarshal(raw, &cfg.Version); err != nil {
+		fail("version", "must be an integer")
+	} else if cfg.Version != configFileVersion {
+		fail("version", "unsupported version %d (expected %d)", cfg.Version, configFileVersion)
+	}
+
+	base := presets[defaultPresetName]
+	if raw, ok := top["preset"]; ok {
+		if err := json.Unmarshal(raw, &cfg.Preset); err != nil {
+			fail("preset", "must be a string")
+		} else if cfg.Preset != "" {
+			if p, err := lookupPreset(cfg.Preset); err != nil {
+				fail("preset", "%v", err)
+				cfg.Preset = ""
+			} else {
+				base = p
+			}
+		}
+	}
+
+	// 読み取れなかったパラメータはプリセット（なければ default）の値で補って実効的な設定を示す
+	cfg.Params = base
+	var params map[string]json.RawMessage
+	if raw, ok := top["params"]; !ok {
+		fail("params", "missing")
+	} else if err := json.Unmarshal(raw, &params); err != nil {
+		fail("params", "must be an object")
+	}
+	for _, key := range sortedKeys(params) {
+		if _, ok := paramRanges[key]; !ok {
+			fail("params."+key, "unknown parameter (available: %s)", strings.Join(paramNames(), ", "))
+		}
+	}
+	for _, name := range paramNames() {
+		key := "params." + name
+		raw, ok := params[name]
+		if !ok {
+			if params != nil {
+				fail(key, "missing")
+			}
+			continue
+		}
+		var v float64
+		if err := json.Unmarshal(raw, &v); err != nil {
+			fail(key, "must be a number")
+			continue
+		}
+		if err := cfg.Params.set(name, v); err != nil {
+			r := paramRanges[name]
+			fail(key, "%g is out of range (must be between %g and %g)", v, r.min, r.max)
+		}
+	}
+
+	// 矛盾する指定
+	if cfg.Preset != "" {
+		var differ []string
+		p := presets[cfg.Preset]
+		for _, name := range paramNames() {
+			f, _ := cfg.Params.field(name)
+			if g, _ := p.field(name); *f != *g {
+				differ = append(differ, name)
+			}
+		}
+		if len(differ) > 0 {
+			warn("preset", "params override preset %q (%s differ), so the preset name is dropped", cfg.Preset, strings.Join(differ, ", "))
+			cfg.Preset = ""
+		}
+	}
+	if p := cfg.Params; p.MinFlickSpeed > 0 && p.MinFlickSpeed < p.StopThreshold {
+		warn("params.min_flick_speed", "%g is below stop_threshold %g, so releases between them start a coast that stops on the first frame", p.MinFlickSpeed, p.StopThreshold)
+	}
+	return cfg, issues
+}
+
+// sortedKeys は m のキーをソートして返す（問題の表示順を安定させるため）。
+func sortedKeys(m map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// configErrors は issues のうち取り込めない問題の数を返す。
+func configErrors(issues []configIssue) int {
+	n := 0
+	for _, i := range issues {
+		if !i.warning {
+			n++
+		}
+	}
+	return n
+}
+
+// checkConfigCommand は path（空なら標準入力）の設定を検証し、問題点と実効的な設定を表示する。
+// 取り込めない問題があればエラーを返す（終了コードで検証の結果を判定できるように）。
+func checkConfigCommand(path string) error {
+	data, err := readConfigInput(path)
+	if err != nil {
+		return err
+	}
+	cfg, issues := checkConfig(data)
+	for _, i := range issues {
+		fmt.Println(i)
+	}
+	if len(issues) == 0 {
+		fmt.Println("OK")
+	}
+	out, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nEffective config:\n%s\n", out)
+	if n := configErrors(issues); n > 0 {
+		return fmt.Errorf("%d problem(s) in config", n)
+	}
+	return nil
+}
+
+// importConfig は path（空なら標準入力）の設定を検証し、defaults ドメインに書き込む。
+// 取り込めない問題があれば何も書き込まない。警告は表示して取り込む。
+// 実行中の coastpad は defaults ドメインの監視で自動的に反映する。
+func importConfig(path string) error {
+	data, err := readConfigInput(path)
+	if err != nil {
+		return err
+	}
+	cfg, issues := checkConfig(data)
+	for _, i := range issues {
+		fmt.Fprintln(os.Stderr, i)
+	}
+	if n := configErrors(issues); n > 0 {
+		return fmt.Errorf("%d problem(s) in config; see `coastpad config check`", n)
+	}
+
+	s := defaultsSettings{preset: cfg.Preset, params: make(map[string]float64)}
+	for _, name := range paramNames() {
+		f, _ := cfg.Params.field(name)
+		s.params[name] = *f
+	}
+
+	if err := writeDefaults(s); err != nil {
+		return err
+	}
+	fmt.Printf("Imported config into defaults domain %s\n", defaultsDomain)
+	return nil
+}
@@ -0,0 +1,214 @@
+// control.go: 制御ソケット（Unix ドメインソケット）。
+// 実行中の coastpad に外部からコマンドを送り、状態の取得や設定の変更を行う。
+//
+// プロトコル: クライアントは1行のコマンド（空白区切りの単語列）を送り、
+// サーバーは1行の JSON レスポンス（controlResponse）を返して接続を閉じる。
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// controlTimeout は制御ソケットの1リクエストあたりの読み書きタイムアウト。
+const controlTimeout = 2 * time.Second
+
+// controlResponse は制御コマンドのレスポンスを表す。
+type controlResponse struct {
+	OK     bool            `json:"ok"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// controlHandler は制御コマンドの処理関数。戻り値は JSON にエンコードして返す。
+type controlHandler func(a *App, args []string) (any, error)
+
+// controlCommands は制御コマンドの一覧。
+var controlCommands = map[string]controlHandler{
+	"status":   func(a *App, _ []string) (any, error) { return a.Status(), nil },
+	"stats":    func(a *App, _ []string) (any, error) { return a.Stats(), nil },
+	"preset":   ctlPreset,
+	"set":      ctlSet,
+	"flip":     ctlFlip,
+	"hud":      ctlHUD,
+	"pause":    ctlPause(true),
+	"resume":   ctlPause(false),
+	"cursor":   ctlCoastToggle(false),
+	"drag":     ctlCoastToggle(true),
+	"get":      ctlGet,
+	"predict":  ctlPredict,
+	"snapshot": ctlSnapshot,
+}
+
+// controlServer は制御ソケットの待ち受けを管理する。
+type controlServer struct {
+	ln   net.Listener
+	path string // 終了時に削除するソケットファイル（launchd が所有する場合は空）
+	wg   sync.WaitGroup
+}
+
+// defaultControlSocketPath は制御ソケットのデフォルトパスを返す。
+func defaultControlSocketPath() (string, error) {
+	return appDataPath("control.sock")
+}
+
+// startControlServer は制御ソケットの待ち受けを開始する。
+// launchd から起動された場合は、launchd が待ち受けているソケットを引き継ぐ（launchd.go）。
+// そうでなければ、前回の異常終了で残ったソケットファイルは削除してから作り直す。
+func startControlServer(a *App, path string) (*controlServer, error) {
+	if ln, ok := launchdControlListener(); ok {
+		cs := &controlServer{ln: ln}
+		cs.wg.Add(1)
+		go cs.serve(a)
+		return cs, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	// 同一ユーザーのみ接続できるようにする
+	if err := os.Chmod(path, 0o600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+
+	cs := &controlServer{ln: ln, path: path}
+	cs.wg.Add(1)
+	go cs.serve(a)
+	return cs, nil
+}
+
+// serve は接続を受け付け、1接続1コマンドで処理する。
+func (cs *controlServer) serve(a *App) {
+	defer cs.wg.Done()
+	for {
+		conn, err := cs.ln.Accept()
+		if err != nil {
+			return // close() でリスナーが閉じられた
+		}
+		cs.wg.Add(1)
+		go func() {
+			defer cs.wg.Done()
+			defer conn.Close()
+			cs.handleConn(a, conn)
+		}()
+	}
+}
+
+func (cs *controlServer) handleConn(a *App, conn net.Conn) {
+	conn.SetDeadline(time.Now().Add(controlTimeout))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil && line == "" {
+		return
+	}
+	resp := dispatchControl(a, strings.Fields(line))
+	data, _ := json.Marshal(resp)
+	conn.Write(append(data, '\n'))
+}
+
+// dispatchControl はコマンドを実行してレスポンスを作る。
+func dispatchControl(a *App, words []string) controlResponse {
+	if len(words) == 0 {
+		return controlResponse{Error: "empty command"}
+	}
+	handler, ok := controlCommands[words[0]]
+	if !ok {
+		return controlResponse{Error: fmt.Sprintf("unknown command %q (available: %s)",
+			words[0], strings.Join(controlCommandNames(), ", "))}
+	}
+	result, err := handler(a, words[1:])
+	if err != nil {
+		return controlResponse{Error: err.Error()}
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return controlResponse{Error: err.Error()}
+	}
+	return controlResponse{OK: true, Result: data}
+}
+
+// controlCommandNames は制御コマンド名をソートして返す。
+func controlCommandNames() []string {
+	names := make([]string, 0, len(controlCommands))
+	for name := range controlCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// close は待ち受けを停止し、処理中の接続の完了を待ってからソケットファイルを削除する。
+// launchd のソケットは次の起動のために残す。
+func (cs *controlServer) close() {
+	cs.ln.Close()
+	cs.wg.Wait()
+	if cs.path != "" {
+		os.Remove(cs.path)
+	}
+}
+
+// --- クライアント ---
+
+// sendControl は実行中の coastpad に制御コマンドを送り、結果を返す。
+func sendControl(words ...string) (json.RawMessage, error) {
+	path, err := defaultControlSocketPath()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialTimeout("unix", path, controlTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("coastpad is not running: %w", err)
+	}
+	defer conn.Close()
+	// launchd が待ち受けている場合は、接続で coastpad が起動するまで応答が遅れる
+	conn.SetDeadline(time.Now().Add(controlTimeout + daemonStartTimeout))
+
+	if _, err := fmt.Fprintln(conn, strings.Join(words, " ")); err != nil {
+		return nil, err
+	}
+	var resp controlResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if !resp.OK {
+		return nil, errors.New(resp.Error)
+	}
+	return resp.Result, nil
+}
+
+// runCtlCommand は `coastpad ctl <command> [args...]` を実行し、結果の JSON を表示する。
+func runCtlCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: coastpad ctl <command> [args...] (commands: %s)",
+			strings.Join(controlCommandNames(), ", "))
+	}
+	result, err := sendControl(args...)
+	if err != nil {
+		return err
+	}
+	var out any
+	if err := json.Unmarshal(result, &out); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
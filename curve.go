@@ -0,0 +1,52 @@
+// curve.go: 曲がるコースト（--curve）。
+// 指が弧を描きながら離れたときに、直線ではなくその弧を緩やかに続けるコーストにする。
+// リリース直前の数点のカーソル位置から進行方向の変化の速さ（角速度）を求め、
+// 減衰軌跡（trajectory.go）の速度をその角速度で回転させる。
+package main
+
+import "math"
+
+const (
+	// minTurnSegment は角速度の算出に使う移動の最小の長さ (px)。短い移動の向きはノイズが大きい。
+	minTurnSegment = 2.0
+	// minCoastOmega は曲げる角速度の下限 (rad/sec)。直線のフリックのわずかな揺れでは曲げない。
+	minCoastOmega = 0.5
+	// maxCoastOmega は曲げる角速度の上限 (rad/sec)。
+	maxCoastOmega = 4.0
+)
+
+// releaseTurnRate は履歴の最初と最後の移動の向きの差から、リリース時の角速度 (rad/sec) を求める。
+// 3点に満たない・移動が短すぎる場合は 0 を返す。
+// アクター goroutine から呼ぶこと。
+func (a *App) releaseTurnRate() float64 {
+	n := a.histLen
+	if n < 3 {
+		return 0
+	}
+	h0, h1 := a.history[0], a.history[1]
+	h2, h3 := a.history[n-2], a.history[n-1]
+	if math.Hypot(h1.x-h0.x, h1.y-h0.y) < minTurnSegment || math.Hypot(h3.x-h2.x, h3.y-h2.y) < minTurnSegment {
+		return 0
+	}
+	// 各移動の中点の時刻の差で割る
+	dt := (h2.timestamp + h3.timestamp - h0.timestamp - h1.timestamp) / 2
+	if dt < minTimeDelta {
+		return 0
+	}
+	turn := math.Atan2(h3.y-h2.y, h3.x-h2.x) - math.Atan2(h1.y-h0.y, h1.x-h0.x)
+	turn = math.Remainder(turn, 2*math.Pi) // -π〜π に正規化する
+	return turn / dt
+}
+
+// releaseOmega はコーストに適用する角速度を返す。無効・下限未満なら 0。
+// アクター goroutine から呼ぶこと。
+func (a *App) releaseOmega() float64 {
+	if a.curveGain == 0 {
+		return 0
+	}
+	w := a.releaseTurnRate() * a.curveGain
+	if math.Abs(w) < minCoastOmega {
+		return 0
+	}
+	return math.Copysign(math.Min(math.Abs(w), maxCoastOmega), w)
+}
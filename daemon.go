@@ -0,0 +1,249 @@
+// daemon.go: バックグラウンド実行（start/stop/status サブコマンド）と PID ファイル管理。
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// デーモン起動・停止の待機設定
+const (
+	daemonStartTimeout = 5 * time.Second
+	daemonStopTimeout  = 5 * time.Second
+	daemonPollInterval = 100 * time.Millisecond
+)
+
+// appStatus は実行中の coastpad の状態を表す（制御コマンド status の結果）。
+type appStatus struct {
+	PID            int        `json:"pid"`
+	Uptime         string     `json:"uptime"`
+	Mode           string     `json:"mode"`
+	Devices        int        `json:"devices"`
+	Touching       bool       `json:"touching"`
+	Coasting       bool       `json:"coasting"`
+	DragPhase      string     `json:"drag_phase"`
+	Paused         bool       `json:"paused"`            // ユーザーが一時停止しているか
+	CursorOff      bool       `json:"cursor_off"`        // カーソル慣性をオフにしているか
+	DragOff        bool       `json:"drag_off"`          // ドラッグ慣性をオフにしているか
+	Game           bool       `json:"game"`              // 全画面ゲームの検出で一時停止しているか
+	MissionControl bool       `json:"mission_control"`   // Mission Control の表示中のため一時停止しているか
+	Focus          string     `json:"focus,omitempty"`   // 現在の集中モード（--focus 有効時のみ）
+	Away           bool       `json:"away"`              // セッションがコンソールにないため一時停止しているか
+	Untrusted      bool       `json:"untrusted"`         // アクセシビリティの権限がないため一時停止しているか
+	Remote         bool       `json:"remote"`            // 画面共有で遠隔操作されているため一時停止しているか
+	Power          string     `json:"power"`             // 電源のモード（normal / saving）
+	Thermal        string     `json:"thermal,omitempty"` // 熱の状態（--thermal-adapt 有効時のみ）
+	Battery        int        `json:"battery,omitempty"` // バッテリーの残量（%、--battery-saver 有効時のみ）
+	Stats          coastStats `json:"stats"`
+}
+
+// Status は現在の状態を返す。
+func (a *App) Status() (s appStatus) {
+	devices := a.touchDevices.Count()
+
+	a.call(func() {
+		s = appStatus{
+			PID:            os.Getpid(),
+			Uptime:         time.Since(a.startedAt).Round(time.Second).String(),
+			Mode:           a.mode.String(),
+			Devices:        devices,
+			Touching:       a.isTouched,
+			Coasting:       a.vx != 0 || a.vy != 0,
+			DragPhase:      a.dragPhase.String(),
+			Paused:         a.paused,
+			CursorOff:      a.cursorOff,
+			DragOff:        a.dragOff,
+			Game:           a.gameActive,
+			MissionControl: a.missionControl,
+			Focus:          a.focus,
+			Away:           a.sessionInactive,
+			Untrusted:      a.untrusted,
+			Remote:         a.remoteControlled,
+			Power:          a.powerMode(),
+			Thermal:        a.thermalStatus(),
+			Battery:        a.batteryPercent,
+			Stats:          a.stats,
+		}
+	})
+	return s
+}
+
+// print は状態を人間向けの形式で出力する。
+func (s *appStatus) print() {
+	fmt.Printf("coastpad is running (pid %d, uptime %s)\n", s.PID, s.Uptime)
+	fmt.Printf("Mode:           %s\n", s.Mode)
+	fmt.Printf("Touch devices:  %d\n", s.Devices)
+	fmt.Printf("Touching:       %t\n", s.Touching)
+	fmt.Printf("Coasting:       %t\n", s.Coasting)
+	fmt.Printf("Drag phase:     %s\n", s.DragPhase)
+	fmt.Printf("Paused:         %t\n", s.Paused)
+	if s.CursorOff {
+		fmt.Println("Cursor coast:   off")
+	}
+	if s.DragOff {
+		fmt.Println("Drag coast:     off")
+	}
+	fmt.Printf("Game:           %t\n", s.Game)
+	fmt.Printf("Mission Ctrl:   %t\n", s.MissionControl)
+	fmt.Printf("Away:           %t\n", s.Away)
+	fmt.Printf("Untrusted:      %t\n", s.Untrusted)
+	fmt.Printf("Remote:         %t\n", s.Remote)
+	fmt.Printf("Power:          %s\n", s.Power)
+	if s.Thermal != "" {
+		fmt.Printf("Thermal:        %s\n", s.Thermal)
+	}
+	if s.Focus != "" {
+		fmt.Printf("Focus:          %s\n", s.Focus)
+	}
+	s.Stats.print(os.Stdout)
+}
+
+// --- PID ファイル ---
+
+// defaultPIDPath は PID ファイルのパスを返す。
+func defaultPIDPath() (string, error) {
+	return appDataPath("coastpad.pid")
+}
+
+// readPID は PID ファイルを読み、そのプロセスが生存していれば PID を返す。
+func readPID() (int, bool) {
+	path, err := defaultPIDPath()
+	if err != nil {
+		return 0, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, false
+	}
+	return pid, processAlive(pid)
+}
+
+// processAlive はプロセスが生存しているかを返す（シグナル 0 で確認）。
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// writePIDFile は PID ファイルを作成し、削除用の関数を返す。
+// 既に別の coastpad が実行中ならエラーを返す。
+func writePIDFile() (remove func(), err error) {
+	if pid, alive := readPID(); alive && pid != os.Getpid() {
+		return nil, fmt.Errorf("coastpad is already running (pid %d)", pid)
+	}
+	path, err := defaultPIDPath()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, []byte(strconv.Itoa(os.Getpid())+"\n"), 0o644); err != nil {
+		return nil, err
+	}
+	return func() { os.Remove(path) }, nil
+}
+
+// --- サブコマンド ---
+
+// runStartCommand は `coastpad start [flags...]` を実行する。
+// 自身をセッションから切り離して再起動し、制御ソケットが応答するまで待つ。
+// flags はそのままバックグラウンドプロセスに渡す。
+func runStartCommand(args []string) error {
+	if pid, alive := readPID(); alive {
+		return fmt.Errorf("coastpad is already running (pid %d)", pid)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	logPath, err := appDataPath("coastpad.log")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(logPath), 0o755); err != nil {
+		return err
+	}
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(exe, args...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	deadline := time.After(daemonStartTimeout)
+	for {
+		select {
+		case err := <-exited:
+			return fmt.Errorf("coastpad exited during startup (%v), see %s", err, logPath)
+		case <-deadline:
+			return fmt.Errorf("coastpad did not respond within %s, see %s", daemonStartTimeout, logPath)
+		case <-time.After(daemonPollInterval):
+			if _, err := sendControl("status"); err == nil {
+				fmt.Printf("coastpad started (pid %d), log: %s\n", cmd.Process.Pid, logPath)
+				return nil
+			}
+		}
+	}
+}
+
+// runStopCommand は `coastpad stop` を実行する。SIGTERM を送り、終了を待つ。
+func runStopCommand() error {
+	pid, alive := readPID()
+	if !alive {
+		return errors.New("coastpad is not running")
+	}
+	if err := syscall.Kill(pid, syscall.SIGTERM); err != nil {
+		return err
+	}
+	deadline := time.Now().Add(daemonStopTimeout)
+	for processAlive(pid) {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("coastpad (pid %d) did not stop within %s", pid, daemonStopTimeout)
+		}
+		time.Sleep(daemonPollInterval)
+	}
+	fmt.Printf("coastpad stopped (pid %d)\n", pid)
+	return nil
+}
+
+// runStatusCommand は `coastpad status` を実行する。
+// 実行中なら制御ソケット経由で状態を取得して表示する。
+func runStatusCommand() error {
+	pid, alive := readPID()
+	if !alive {
+		return errors.New("coastpad is not running")
+	}
+	result, err := sendControl("status")
+	if err != nil {
+		return fmt.Errorf("coastpad is running (pid %d) but not responding: %w", pid, err)
+	}
+	var status appStatus
+	if err := json.Unmarshal(result, &status); err != nil {
+		return err
+	}
+	status.print()
+	return nil
+}
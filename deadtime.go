@@ -0,0 +1,51 @@
+// deadtime.go: コースト停止直後のクリックの無視（デッドタイム）。
+// 速いコーストが止まった直後は、カーソルが着地した先を意図せずタップしてしまいやすい。
+// 速いコーストの自然停止から一定時間は、指を意図的に動かしてからでなければクリックを無視する。
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// deadTimeMinSpeed はデッドタイムを設けるコーストの開始時の速さ（px/sec）。
+	deadTimeMinSpeed = 1500.0
+
+	// deadTimeMovement はデッドタイムを解除する意図的な移動とみなす、停止位置からの距離（px）。
+	deadTimeMovement = 10.0
+)
+
+// startDeadTime はコーストの自然停止でデッドタイムを開始する。開始時の速さが遅いコーストでは開始しない。
+// アクター goroutine から呼ぶこと。
+func (a *App) startDeadTime(now float64) {
+	if a.deadTime <= 0 || a.coastStartSpeed < deadTimeMinSpeed {
+		return
+	}
+	a.deadUntil = now + a.deadTime
+	a.deadX, a.deadY = a.coastX, a.coastY
+}
+
+// trackDeadTime はタッチ中のカーソル位置を見て、停止位置から意図的に動かしたらデッドタイムを解除する。
+// アクター goroutine から呼ぶこと。
+func (a *App) trackDeadTime(x, y float64) {
+	if a.deadUntil != 0 && math.Hypot(x-a.deadX, y-a.deadY) > deadTimeMovement {
+		a.deadUntil = 0
+	}
+}
+
+// inDeadTime はマウスダウンをデッドタイムとして無視するかを返す。
+// 無視した場合は、対になるマウスアップも無視する（swallowMouseUp）。
+// アクター goroutine から呼ぶこと。
+func (a *App) inDeadTime(now float64) bool {
+	if a.deadUntil == 0 {
+		return false
+	}
+	if now >= a.deadUntil {
+		a.deadUntil = 0
+		return false
+	}
+	a.swallowMouseUp = true
+	fmt.Println("[deadtime] ignored a click right after a coast stopped")
+	return true
+}
@@ -0,0 +1,103 @@
+// defaults.c: CFPreferences の読み書きヘルパー。
+#include <CoreFoundation/CoreFoundation.h>
+#include "defaults.h"
+
+// copy_value は domain の key の値を返す（呼び出し側が CFRelease すること）。値がなければ NULL。
+static CFPropertyListRef copy_value(const char *domain, const char *key) {
+    CFStringRef d = CFStringCreateWithCString(NULL, domain, kCFStringEncodingUTF8);
+    CFStringRef k = CFStringCreateWithCString(NULL, key, kCFStringEncodingUTF8);
+    CFPropertyListRef value = CFPreferencesCopyAppValue(k, d);
+    CFRelease(k);
+    CFRelease(d);
+    return value;
+}
+
+void defaults_sync(const char *domain) {
+    CFStringRef d = CFStringCreateWithCString(NULL, domain, kCFStringEncodingUTF8);
+    CFPreferencesAppSynchronize(d);
+    CFRelease(d);
+}
+
+int defaults_get_double(const char *domain, const char *key, double *out) {
+    CFPropertyListRef value = copy_value(domain, key);
+    if (value == NULL) {
+        return 0;
+    }
+    int ok = CFGetTypeID(value) == CFNumberGetTypeID() &&
+             CFNumberGetValue((CFNumberRef)value, kCFNumberDoubleType, out);
+    CFRelease(value);
+    return ok;
+}
+
+int defaults_get_global_double(const char *key, double *out) {
+    CFPreferencesAppSynchronize(kCFPreferencesAnyApplication);
+    CFStringRef k = CFStringCreateWithCString(NULL, key, kCFStringEncodingUTF8);
+    CFPropertyListRef value = CFPreferencesCopyAppValue(k, kCFPreferencesAnyApplication);
+    CFRelease(k);
+    if (value == NULL) {
+        return 0;
+    }
+    int ok = CFGetTypeID(value) == CFNumberGetTypeID() &&
+             CFNumberGetValue((CFNumberRef)value, kCFNumberDoubleType, out);
+    CFRelease(value);
+    return ok;
+}
+
+int defaults_get_bool(const char *domain, const char *key, int *out) {
+    CFPropertyListRef value = copy_value(domain, key);
+    if (value == NULL) {
+        return 0;
+    }
+    int ok = 1;
+    if (CFGetTypeID(value) == CFBooleanGetTypeID()) {
+        *out = CFBooleanGetValue((CFBooleanRef)value);
+    } else if (CFGetTypeID(value) == CFNumberGetTypeID()) {
+        int n = 0;
+        ok = CFNumberGetValue((CFNumberRef)value, kCFNumberIntType, &n);
+        *out = n != 0;
+    } else {
+        ok = 0;
+    }
+    CFRelease(value);
+    return ok;
+}
+
+void defaults_set_double(const char *domain, const char *key, double value) {
+    CFStringRef d = CFStringCreateWithCString(NULL, domain, kCFStringEncodingUTF8);
+    CFStringRef k = CFStringCreateWithCString(NULL, key, kCFStringEncodingUTF8);
+    CFNumberRef n = CFNumberCreate(NULL, kCFNumberDoubleType, &value);
+    CFPreferencesSetAppValue(k, n, d);
+    CFRelease(n);
+    CFRelease(k);
+    CFRelease(d);
+}
+
+void defaults_set_string(const char *domain, const char *key, const char *value) {
+    CFStringRef d = CFStringCreateWithCString(NULL, domain, kCFStringEncodingUTF8);
+    CFStringRef k = CFStringCreateWithCString(NULL, key, kCFStringEncodingUTF8);
+    CFStringRef v = value != NULL ? CFStringCreateWithCString(NULL, value, kCFStringEncodingUTF8) : NULL;
+    CFPreferencesSetAppValue(k, v, d);
+    if (v != NULL) {
+        CFRelease(v);
+    }
+    CFRelease(k);
+    CFRelease(d);
+}
+
+int defaults_flush(const char *domain) {
+    CFStringRef d = CFStringCreateWithCString(NULL, domain, kCFStringEncodingUTF8);
+    int ok = CFPreferencesAppSynchronize(d);
+    CFRelease(d);
+    return ok;
+}
+
+int defaults_get_string(const char *domain, const char *key, char *buf, int len) {
+    CFPropertyListRef value = copy_value(domain, key);
+    if (value == NULL) {
+        return 0;
+    }
+    int ok = CFGetTypeID(value) == CFStringGetTypeID() &&
+             CFStringGetCString((CFStringRef)value, buf, len, kCFStringEncodingUTF8);
+    CFRelease(value);
+    return ok;
+}
@@ -0,0 +1,159 @@
+// defaults.go: defaults ドメイン（CFPreferences）での設定の保存。
+// `defaults write com.nobmurakita.coastpad decay_rate -float 4` のように設定でき、
+// 実行中の coastpad は定期的にドメインを読み直して、再起動なしで変更を反映する。
+package main
+
+/*
+#cgo LDFLAGS: -framework CoreFoundation
+#include <stdlib.h>
+#include "defaults.h"
+*/
+import "C"
+import (
+	"fmt"
+	"maps"
+	"os"
+	"time"
+	"unsafe"
+)
+
+// defaultsDomain は設定を保存する defaults ドメイン。
+const defaultsDomain = "com.nobmurakita.coastpad"
+
+// defaultsPollInterval は defaults ドメインを読み直す間隔。
+// defaults write は通知を送らないため、変更はポーリングで検出する。
+const defaultsPollInterval = 2 * time.Second
+
+// defaultsPresetKey はプリセット名のキー。パラメータはパラメータ名（decay_rate 等）をキーにする。
+const defaultsPresetKey = "preset"
+
+// defaultsSettings は defaults ドメインから読み込んだ設定を表す。値のないキーは含まない。
+type defaultsSettings struct {
+	preset string
+	params map[string]float64
+}
+
+// readDefaults は defaults ドメインを読み直して設定を返す。
+func readDefaults() defaultsSettings {
+	domain := C.CString(defaultsDomain)
+	defer C.free(unsafe.Pointer(domain))
+	C.defaults_sync(domain)
+
+	s := defaultsSettings{params: make(map[string]float64)}
+	var buf [64]C.char
+	key := C.CString(defaultsPresetKey)
+	if C.defaults_get_string(domain, key, &buf[0], C.int(len(buf))) != 0 {
+		s.preset = C.GoString(&buf[0])
+	}
+	C.free(unsafe.Pointer(key))
+	for _, name := range paramNames() {
+		key := C.CString(name)
+		var v C.double
+		if C.defaults_get_double(domain, key, &v) != 0 {
+			s.params[name] = float64(v)
+		}
+		C.free(unsafe.Pointer(key))
+	}
+	return s
+}
+
+// writeDefaults は設定を defaults ドメインに書き込む。preset が空ならプリセットのキーを削除する。
+// 実行中の coastpad は次のポーリングで変更を反映する。
+func writeDefaults(s defaultsSettings) error {
+	domain := C.CString(defaultsDomain)
+	defer C.free(unsafe.Pointer(domain))
+
+	key := C.CString(defaultsPresetKey)
+	if s.preset == "" {
+		C.defaults_set_string(domain, key, nil)
+	} else {
+		value := C.CString(s.preset)
+		C.defaults_set_string(domain, key, value)
+		C.free(unsafe.Pointer(value))
+	}
+	C.free(unsafe.Pointer(key))
+	for name, v := range s.params {
+		key := C.CString(name)
+		C.defaults_set_double(domain, key, C.double(v))
+		C.free(unsafe.Pointer(key))
+	}
+	if C.defaults_flush(domain) == 0 {
+		return fmt.Errorf("failed to write defaults domain %s", defaultsDomain)
+	}
+	return nil
+}
+
+// effectiveDefaults は defaults ドメインの設定を適用した結果のプリセット名とパラメータを返す
+// （coastpad を起動したときに使われる値）。
+func effectiveDefaults() (presetStatus, error) {
+	s := readDefaults()
+	st := presetStatus{Preset: defaultPresetName, Params: presets[defaultPresetName]}
+	if s.preset != "" {
+		p, err := lookupPreset(s.preset)
+		if err != nil {
+			return st, err
+		}
+		st = presetStatus{Preset: s.preset, Params: p}
+	}
+	for name, v := range s.params {
+		if err := st.Params.set(name, v); err != nil {
+			return st, err
+		}
+		st.Preset = ""
+	}
+	return st, nil
+}
+
+// applyDefaults は前回読み込んだ設定 prev から変わった値を反映する。
+// プリセットが変わった場合は、プリセットを適用してからパラメータの個別指定をすべて掛け直す。
+// 不正な値は警告して無視する（defaults write の書き間違いで停止しないように）。
+func (a *App) applyDefaults(prev, cur defaultsSettings) {
+	presetChanged := cur.preset != prev.preset && cur.preset != ""
+	if presetChanged {
+		if err := a.ApplyPreset(cur.preset); err != nil {
+			fmt.Fprintf(os.Stderr, "[defaults] %v\n", err)
+		} else {
+			fmt.Printf("[defaults] preset %s\n", cur.preset)
+		}
+	}
+	for _, name := range paramNames() {
+		v, ok := cur.params[name]
+		if !ok {
+			continue
+		}
+		if old, had := prev.params[name]; had && old == v && !presetChanged {
+			continue
+		}
+		if err := a.SetParam(name, v); err != nil {
+			fmt.Fprintf(os.Stderr, "[defaults] %v\n", err)
+		} else {
+			fmt.Printf("[defaults] %s = %g\n", name, v)
+		}
+	}
+}
+
+// watchDefaults は defaults ドメインを定期的に読み直し、変更があれば反映する。
+// 最初に1回読み込んで反映するため、defaults の値は起動時のフラグより優先される。
+// a.stop が閉じられるまでブロックする。
+func (a *App) watchDefaults() {
+	var prev defaultsSettings
+	apply := func() {
+		cur := readDefaults()
+		if cur.preset != prev.preset || !maps.Equal(cur.params, prev.params) {
+			a.applyDefaults(prev, cur)
+			prev = cur
+		}
+	}
+	apply()
+
+	ticker := time.NewTicker(defaultsPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+			apply()
+		}
+	}
+}
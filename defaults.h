@@ -0,0 +1,30 @@
+// defaults.h: CFPreferences（defaults ドメイン）での設定の読み書き。
+#ifndef DEFAULTS_H
+#define DEFAULTS_H
+
+// ドメインの値をディスクから読み直す（defaults write による変更を反映する）。
+void defaults_sync(const char *domain);
+
+// key の数値を out に書き込む。値がないか数値でなければ 0 を返す。
+int defaults_get_double(const char *domain, const char *key, double *out);
+
+// 全アプリ共通のドメイン（defaults -g）の key の数値を、ディスクから読み直してから out に書き込む。
+// 値がないか数値でなければ 0 を返す。
+int defaults_get_global_double(const char *key, double *out);
+
+// key の真偽値を out に書き込む（数値は 0 以外を真とする）。値がないか真偽値・数値でなければ 0 を返す。
+int defaults_get_bool(const char *domain, const char *key, int *out);
+
+// key の文字列を buf に書き込む。値がないか文字列でなければ 0 を返す。
+int defaults_get_string(const char *domain, const char *key, char *buf, int len);
+
+// key に数値を設定する。
+void defaults_set_double(const char *domain, const char *key, double value);
+
+// key に文字列を設定する。value が NULL なら key を削除する。
+void defaults_set_string(const char *domain, const char *key, const char *value);
+
+// 設定した値をディスクに書き出す。失敗したら 0 を返す。
+int defaults_flush(const char *domain);
+
+#endif
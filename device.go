@@ -10,11 +10,19 @@ package main
 import "C"
 import (
 	"fmt"
+	"maps"
 	"runtime"
 	"sync"
+	"time"
 	"unsafe"
 )
 
+// deviceWatcher はタッチデバイスの接続・切断の監視を表す。
+// IOKit 通知（DeviceNotifier）と、そのフォールバックのポーリング（DevicePoller）がある。
+type deviceWatcher interface {
+	Stop()
+}
+
 // DeviceNotifier は IOKit 通知でタッチデバイスの接続・切断を検出する。
 type DeviceNotifier struct {
 	mu         sync.Mutex
@@ -158,3 +166,54 @@ func goIOKitDeviceChanged(iterator C.uint) {
 	}
 	app.onDeviceChanged()
 }
+
+// --- ポーリングによるフォールバック ---
+
+// devicePollInterval はデバイスリストのポーリング間隔。
+const devicePollInterval = 3 * time.Second
+
+// DevicePoller は IOKit 通知が使えない環境（サンドボックス等）向けに、
+// MTDeviceCreateList を定期的に呼んでデバイスの増減を検出する。
+type DevicePoller struct {
+	stop chan struct{}
+	done chan struct{}
+}
+
+// StartDevicePoller はデバイスリストのポーリングを開始する。
+// デバイス ID の集合が変化したら onChange を呼ぶ。
+func StartDevicePoller(onChange func()) *DevicePoller {
+	dp := &DevicePoller{
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	go dp.run(onChange)
+	return dp
+}
+
+func (dp *DevicePoller) run(onChange func()) {
+	defer close(dp.done)
+
+	ticker := time.NewTicker(devicePollInterval)
+	defer ticker.Stop()
+
+	last := listDeviceIDs()
+	for {
+		select {
+		case <-dp.stop:
+			return
+		case <-ticker.C:
+			ids := listDeviceIDs()
+			if !maps.Equal(ids, last) {
+				last = ids
+				onChange()
+			}
+		}
+	}
+}
+
+// Stop はポーリングを停止する。ポーリング goroutine の終了を待つため、
+// 戻った後は onChange が呼ばれないことが保証される。
+func (dp *DevicePoller) Stop() {
+	close(dp.stop)
+	<-dp.done
+}
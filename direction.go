@@ -0,0 +1,53 @@
+// direction.go: フリックの方向の量子化（--quantize-direction）。
+// リリース時の方向が上下左右（8方向なら斜めも）に近ければ、その方向にぴったり揃える。
+// 横に並んだ複数のモニタの間で、カーソルを真横に投げやすくする。
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// defaultDirectionTolerance は方向を揃える角度の許容差のデフォルト（度）。
+const defaultDirectionTolerance = 10.0
+
+// checkDirectionQuantize は方向の数と許容差を検証する。
+// 方向の数は 0（無効）・4・8。許容差は隣の方向との中間（4方向なら 45°、8方向なら 22.5°）未満。
+func checkDirectionQuantize(directions int, tolerance float64) error {
+	switch directions {
+	case 0:
+		return nil
+	case 4, 8:
+	default:
+		return fmt.Errorf("--quantize-direction must be 0, 4 or 8 (got %d)", directions)
+	}
+	if half := 180.0 / float64(directions); tolerance <= 0 || tolerance >= half {
+		return fmt.Errorf("--quantize-tolerance must be between 0 and %g degrees (got %g)", half, tolerance)
+	}
+	return nil
+}
+
+// quantizeDirection はリリース時の速度の方向が最寄りの方向から許容差以内なら、速さを保ったまま揃える。
+// 揃えた場合は直進させる（曲がるコーストの角速度を 0 にする）。
+// アクター goroutine から呼ぶこと。
+func (a *App) quantizeDirection() {
+	if a.quantizeDirections == 0 || (a.vx == 0 && a.vy == 0) {
+		return
+	}
+	step := 2 * math.Pi / float64(a.quantizeDirections)
+	angle := math.Atan2(a.vy, a.vx)
+	nearest := math.Round(angle/step) * step
+	if math.Abs(angle-nearest) > a.quantizeTolerance*math.Pi/180 {
+		return
+	}
+	speed := math.Hypot(a.vx, a.vy)
+	a.vx, a.vy = speed*math.Cos(nearest), speed*math.Sin(nearest)
+	// 揃えた方向の成分の丸め誤差（cos(π/2) ≈ 6e-17 等）を消して、真横・真上下に進める
+	if math.Abs(a.vx) < 1e-9*speed {
+		a.vx = 0
+	}
+	if math.Abs(a.vy) < 1e-9*speed {
+		a.vy = 0
+	}
+	a.omega = 0
+}
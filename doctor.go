@@ -0,0 +1,102 @@
+// doctor.go: 動作環境の診断（`coastpad doctor`）。
+// アクセシビリティの権限と、実行中の coastpad の EventTap の実際の位置・オプションを、
+// マウスイベントを傍受している他のプロセスの tap と並べて表示する。
+// 他のユーティリティやリモートデスクトップのツールと合わないときに、tap の位置（tapplacement.go）を
+// 変えるかどうかの判断に使う。
+package main
+
+/*
+#cgo LDFLAGS: -framework CoreGraphics
+#include "coexist.h"
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maxDoctorTaps は表示する EventTap の最大数。
+const maxDoctorTaps = 64
+
+// eventTapInfo は EventTap の情報を表す。
+type eventTapInfo struct {
+	pid      int
+	name     string
+	location string
+	options  string
+	enabled  bool
+	mouse    bool // マウスボタン・スクロールを傍受しているか
+}
+
+// listEventTaps は全てのプロセスの EventTap を返す。
+func listEventTaps() []eventTapInfo {
+	mouseMask := C.CGEventMask((1 << C.kCGEventLeftMouseDown) | (1 << C.kCGEventLeftMouseUp) |
+		(1 << C.kCGEventLeftMouseDragged) | (1 << C.kCGEventScrollWheel))
+	var infos [maxDoctorTaps]C.coexist_tap_info
+	n := int(C.coexist_list_all_taps(&infos[0], maxDoctorTaps))
+	taps := make([]eventTapInfo, 0, n)
+	for _, t := range infos[:n] {
+		taps = append(taps, eventTapInfo{
+			pid:      int(t.pid),
+			name:     C.GoString(&t.name[0]),
+			location: tapLocationName(uint32(t.location)),
+			options:  tapOptionsName(uint32(t.options)),
+			enabled:  t.enabled != 0,
+			mouse:    t.mask&mouseMask != 0,
+		})
+	}
+	return taps
+}
+
+// runDoctorCommand は `coastpad doctor` を実行する。
+func runDoctorCommand(args []string) error {
+	if len(args) != 0 {
+		return errors.New("usage: coastpad doctor")
+	}
+	if accessibilityTrusted() {
+		fmt.Println("Accessibility:  granted")
+	} else {
+		fmt.Println("Accessibility:  not granted (System Settings > Privacy & Security > Accessibility)")
+	}
+
+	pid, alive := readPID()
+	if alive {
+		fmt.Printf("coastpad:       running (pid %d)\n", pid)
+	} else {
+		fmt.Println("coastpad:       not running")
+	}
+
+	taps := listEventTaps()
+	own := 0
+	fmt.Println("Event taps on mouse events:")
+	for _, t := range taps {
+		mine := alive && t.pid == pid
+		if !t.mouse && !mine {
+			continue
+		}
+		var notes []string
+		if mine {
+			notes = append(notes, "coastpad")
+			own++
+		}
+		if !t.enabled {
+			notes = append(notes, "disabled")
+		}
+		note := ""
+		if len(notes) > 0 {
+			note = " [" + strings.Join(notes, ", ") + "]"
+		}
+		fmt.Printf("  %-20s pid %-6d %-9s %s%s\n", t.name, t.pid, t.location, t.options, note)
+	}
+	if alive && own == 0 {
+		fmt.Println("coastpad has no event tap; check the accessibility permission and restart it")
+	}
+	for _, t := range taps {
+		if alive && t.pid == pid && !t.enabled {
+			fmt.Println("coastpad's event tap is disabled; it is re-created by the watchdog, restart coastpad if this persists")
+			break
+		}
+	}
+	return nil
+}
@@ -2,66 +2,220 @@
 // CGEventTap コールバックから呼ばれるマウスボタンイベント処理。
 package main
 
+import (
+	"fmt"
+	"os"
+)
+
+// ドラッグ追従中の新しいマウスダウンで、保留中のマウスアップをどう扱うか（--pending-mouseup）。
+// 破棄すると新しいドラッグセッションを壊さないが、ボタンが離されたことを知らされないまま
+// 次のマウスダウンを受け取ると混乱するアプリもあるため、先に発行することもできる。
+const (
+	pendingUpDiscard = "discard" // 発行せずに破棄する
+	pendingUpPost    = "post"    // マウスダウンの前に発行してから、マウスダウンを通す
+)
+
+// mouseDownAction はマウスダウンで実行するアクションを表す。
+type mouseDownAction struct {
+	pending      eventRef // 保留中だったマウスアップ
+	discard      bool     // pending を発行せずに破棄するか
+	swallow      bool     // マウスダウンを消費するか（コースト停止直後のデッドタイム。deadtime.go）
+	clickX       float64  // クリックスルーの位置（clickThrough のときのみ）
+	clickY       float64
+	clickThrough bool // マウスダウンをコースト位置へ移すか（clickthrough.go）
+}
+
 // onMouseDown は EventTap からのマウスダウンで呼ばれる。
-func (a *App) onMouseDown() {
-	a.mu.Lock()
-	var pending eventRef
-	var discard bool
+// 状態遷移はアクター（補助プロセスではパイプの先のエンジン。taphelper.go）に任せ、
+// 返された保留中のマウスアップを proxy 経由で発行し、このマウスダウンより前に届くようにする
+// （proxy はコールバック中のみ有効なため、ここで発行する）。
+// マウスダウンを消費した場合は true を返す。
+func (a *App) onMouseDown(proxy tapProxy, event eventRef) (suppressed bool) {
+	var action mouseDownAction
+	var ok bool
+	if a.tapEngine != nil {
+		action, ok = a.tapEngine.mouseDown(event)
+	} else {
+		action, ok = a.askMouseDown(event)
+	}
+	if !ok {
+		return false
+	}
+	if action.swallow {
+		return true
+	}
+	if action.clickThrough {
+		setEventLocation(event, action.clickX, action.clickY)
+	}
+
+	switch {
+	case action.discard:
+		releaseEvent(action.pending)
+	case a.conservativeTap:
+		// 監視専用の tap ではマウスダウンより前に挿入できないため、通常どおり発行する
+		releasePendingMouseUp(action.pending)
+	default:
+		releasePendingMouseUpViaTap(proxy, action.pending, event)
+	}
+	return false
+}
+
+// askMouseDown はマウスダウンの状態遷移をアクターに任せ、その結果を返す。
+func (a *App) askMouseDown(event eventRef) (mouseDownAction, bool) {
+	reply := make(chan mouseDownAction, 1)
+	attrs := eventDragAttrs(event)
+	x, y := eventLocation(event)
+	source := eventSourceFromEvent(event)
+	if !a.send(mouseDownMsg{attrs: attrs, menuBar: isMenuBarReorder(attrs, x, y), source: source, reply: reply}) {
+		releaseEventSource(source)
+		return mouseDownAction{}, false
+	}
+	return await(a, reply)
+}
+
+// prepareMouseDown はマウスダウンの状態遷移を行う。
+// attrs はマウスダウンのクリック回数と修飾キーで、このドラッグの合成イベントと保留するマウスアップに引き継ぐ
+// （ダブルクリックしてのドラッグによる単語単位の選択や、Option を押したままのコピーを慣性中も維持するため）。
+// menuBar はメニューバーの項目の並べ替えの始まりか（menubar.go）で、そのドラッグには慣性を付けない。
+// アクター goroutine から呼ぶこと。
+func (a *App) prepareMouseDown(attrs dragAttrs, menuBar bool) mouseDownAction {
+	var action mouseDownAction
+	now := a.now()
+	if a.inDeadTime(now) {
+		action.swallow = true
+		return action
+	}
+	action.clickX, action.clickY, action.clickThrough = a.takeClickThrough(now)
 	if a.dragPhase == dragPhaseCoasting {
-		pending = a.resetCoasting()
+		action.pending = a.resetCoasting()
 	} else if a.pendingMouseUp != 0 {
 		// ドラッグ追従中に新しい mouseDown が発生（3本指ドラッグ再開等）。
-		// 保留中の古い mouseUp は Post せずに破棄する。
+		// 保留中の古い mouseUp はデフォルトでは Post せずに破棄する。
 		// Post すると新しいドラッグセッションを壊す可能性がある。
-		pending = a.pendingMouseUp
+		action.pending = a.pendingMouseUp
 		a.pendingMouseUp = 0
-		a.dragPhase = dragPhaseNone
+		a.setDragPhase(dragPhaseNone)
 		a.wasMultiFingerDrag = false
 		a.accumX = 0
 		a.accumY = 0
-		discard = true
+		action.discard = a.pendingUpPolicy != pendingUpPost
+		if action.discard {
+			fmt.Fprintln(os.Stderr, "[drag] new mouse-down while following a drag, discarding the held mouse-up")
+		} else {
+			fmt.Fprintln(os.Stderr, "[drag] new mouse-down while following a drag, posting the held mouse-up first")
+		}
 	}
 	a.isLeftButtonDown = true
-	a.mu.Unlock()
+	attrs.clickState = max(attrs.clickState, 1)
+	a.dragAttrs = attrs
+	// ウインドウの除外（dragexclude.go）は新しいドラッグごとに調べ直す
+	a.dragSeq++
+	a.dragExcluded = menuBar || a.dragTitlebarOnly
+	if menuBar {
+		fmt.Fprintln(os.Stderr, "[drag] Command-drag on the menu bar, no drag inertia")
+	}
+	return action
+}
 
-	if discard {
-		releaseEvent(pending)
+// onOtherMouseDown は EventTap からの左以外のボタン（右・その他）のマウスダウンで呼ばれる。
+// ドラッグ慣性中（ウィンドウが飛んでいる最中の右クリック等）なら慣性を止め、
+// 保留中のマウスアップをこのマウスダウンより前に発行する。
+// 左ボタンが押されたままの間に別のボタンのイベントが届くと、アプリが組み合わせ（コード）として
+// 解釈してしまうため、左ボタンのドラッグを先に終わらせる。
+func (a *App) onOtherMouseDown(proxy tapProxy, event eventRef) {
+	var action mouseDownAction
+	var ok bool
+	if a.tapEngine != nil {
+		action, ok = a.tapEngine.otherMouseDown()
 	} else {
-		releasePendingMouseUp(pending)
+		action, ok = a.askOtherMouseDown()
+	}
+	if !ok {
+		return
+	}
+
+	if a.conservativeTap {
+		releasePendingMouseUp(action.pending)
+	} else {
+		releasePendingMouseUpViaTap(proxy, action.pending, event)
 	}
 }
 
+// askOtherMouseDown は左以外のボタンのマウスダウンの状態遷移をアクターに任せ、その結果を返す。
+func (a *App) askOtherMouseDown() (mouseDownAction, bool) {
+	reply := make(chan mouseDownAction, 1)
+	if !a.send(otherMouseDownMsg{reply: reply}) {
+		return mouseDownAction{}, false
+	}
+	return await(a, reply)
+}
+
+// prepareOtherMouseDown は左以外のボタンのマウスダウンの状態遷移を行う。
+// コースト中なら慣性を止める。複数指ドラッグのリリース待ちでマウスアップを保留していれば、
+// 物理的には左ボタンが離されているため、破棄せずに発行する。
+// 実際に左ボタンを押したまま他のボタンを押した場合は何もしない。
+// アクター goroutine から呼ぶこと。
+func (a *App) prepareOtherMouseDown() mouseDownAction {
+	var action mouseDownAction
+	if a.dragPhase == dragPhaseCoasting || a.pendingMouseUp != 0 {
+		action.pending = a.resetCoasting()
+	}
+	return action
+}
+
 // handleMouseUp は EventTap からのマウスアップを処理する。
-// マウスアップを消費した場合は true を返す。
+// マウスアップを消費した場合は true を返す。判定はアクター（補助プロセスではエンジン）に任せ、結果を待つ。
+func (a *App) handleMouseUp(event eventRef) (suppressed bool) {
+	if a.tapEngine != nil {
+		return a.tapEngine.mouseUp(event)
+	}
+	reply := make(chan bool, 1)
+	if !a.send(mouseUpMsg{event: event, reply: reply}) {
+		return false
+	}
+	suppressed, _ = await(a, reply)
+	return suppressed
+}
+
+// prepareMouseUp はマウスアップの状態遷移を行い、イベントを消費するかを返す。
 //
 // ドラッグ慣性中: mouseUp を保留してドラッグセッションを維持する。
 // 複数指ドラッグ中かつタッチ中: onTouchFrame のリリース判定を待つため一時保留する。
 // 1本指操作では mouseUp を保留しない（押し込み解除後の移動をドラッグにしない）。
-func (a *App) handleMouseUp(event eventRef) (suppressed bool) {
-	a.mu.Lock()
-
-	if a.dragPhase == dragPhaseCoasting || (a.isLeftButtonDown && a.isTouched && a.wasMultiFingerDrag) {
+// --drag-titlebar-only でタイトルバーだと確かめられていないドラッグでも保留しない（titlebar.go）。
+// アクター goroutine から呼ぶこと。コールバックが返答を待っている間に呼ばれるため、event は有効。
+func (a *App) prepareMouseUp(event eventRef) bool {
+	if a.swallowMouseUp {
+		// デッドタイムで無視したマウスダウンの対
+		a.swallowMouseUp = false
+		return true
+	}
+	if a.takeClickThroughUp(a.now()) {
+		setEventLocation(event, a.clickThroughX, a.clickThroughY)
+	}
+	if a.dragPhase == dragPhaseCoasting || (a.isLeftButtonDown && a.isTouched && a.wasMultiFingerDrag && !a.holdsUnconfirmedDrag()) {
 		retainEvent(event)
-		old := a.pendingMouseUp
-		a.pendingMouseUp = event
-		a.mu.Unlock()
-		// 解放は mutex 外で実行する
-		if old != 0 {
-			releaseEvent(old)
+		applyDragAttrs(event, a.dragAttrs)
+		if a.pendingMouseUp != 0 {
+			releaseEvent(a.pendingMouseUp)
 		}
+		a.pendingMouseUp = event
 		return true
 	}
 
 	a.isLeftButtonDown = false
-	a.mu.Unlock()
 	return false
 }
 
 // resetCoasting はコースト状態をリセットし、保留中のマウスアップイベントを返す。
-// 返されたイベントは呼び出し側が mutex 外で releasePendingMouseUp すること。
-// mu をロックした状態で呼ぶこと。
+// 返されたイベントは呼び出し側が releasePendingMouseUp すること。
+// アクター goroutine から呼ぶこと。
 func (a *App) resetCoasting() eventRef {
-	a.dragPhase = dragPhaseNone
+	a.setDragPhase(dragPhaseNone)
+	a.edgeHold = edgeNone
+	a.springHold = false
+	a.dropSettling = false
+	a.dropConfirm = false
 	a.wasMultiFingerDrag = false
 	a.vx = 0
 	a.vy = 0
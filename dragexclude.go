@@ -0,0 +1,138 @@
+// dragexclude.go: ウインドウごとのドラッグ慣性の除外（--drag-exclude）。
+// カラーピッカーやシート、並べ替えのできるリストなど、投げると具合の悪いウインドウがある。
+// ドラッグの開始時（マウスダウン）にカーソルの下のウインドウのタイトルとロールを調べ、
+// 除外に一致すればそのドラッグでは慣性を付けない（カーソル慣性はそのまま）。
+// 文字の選択のドラッグ（textselect.go）も同じ仕組みで除外し、
+// タイトルバー以外のドラッグの除外（titlebar.go）も同じ経路で結果を送る。
+package main
+
+/*
+#cgo LDFLAGS: -framework ApplicationServices
+#include "snap.h"
+*/
+import "C"
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// dragExclusion はドラッグ慣性を除外するウインドウの条件。title か role のどちらかを持つ。
+type dragExclusion struct {
+	title *regexp.Regexp // タイトルのパターン（大文字・小文字を区別しない）
+	role  string         // ウインドウのロールまたはサブロール（AXSheet、AXFloatingWindow 等）
+}
+
+// dragWindowMsg はドラッグの開始時のウインドウの検索結果。seq はどのマウスダウンに対する結果か。
+type dragWindowMsg struct {
+	seq      uint64
+	excluded bool
+}
+
+// windowInfo はカーソルの下のウインドウの属性。
+type windowInfo struct {
+	title, role, subrole string
+}
+
+// parseDragExclusions は "role:AXSheet,title:Colors*" 形式の除外の一覧を解析する。
+// タイトルのパターンでは * が任意の文字列、? が任意の1文字に一致する。
+func parseDragExclusions(spec string) ([]dragExclusion, error) {
+	var rules []dragExclusion
+	for _, entry := range strings.Split(spec, ",") {
+		kind, value, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("invalid drag exclusion %q (expected title:pattern or role:AXRole)", entry)
+		}
+		switch kind {
+		case "title":
+			pattern := regexp.QuoteMeta(value)
+			pattern = strings.NewReplacer(`\*`, `.*`, `\?`, `.`).Replace(pattern)
+			rules = append(rules, dragExclusion{title: regexp.MustCompile("(?i)^" + pattern + "$")})
+		case "role":
+			rules = append(rules, dragExclusion{role: value})
+		default:
+			return nil, fmt.Errorf("invalid drag exclusion %q (expected title:pattern or role:AXRole)", entry)
+		}
+	}
+	return rules, nil
+}
+
+// matches はウインドウが条件に一致するかを返す。
+func (r dragExclusion) matches(w windowInfo) bool {
+	if r.title != nil {
+		return r.title.MatchString(w.title)
+	}
+	return r.role == w.role || r.role == w.subrole
+}
+
+// windowAt は (x, y) にある UI 要素を含むウインドウの属性を返す。
+// アクセシビリティ API はアプリとの IPC を伴うため、アクターの外で呼ぶこと。
+func windowAt(x, y float64) (w windowInfo, ok bool) {
+	var title [256]C.char
+	var role, subrole [64]C.char
+	if C.snap_window_at(C.double(x), C.double(y), &title[0], C.int(len(title)),
+		&role[0], C.int(len(role)), &subrole[0], C.int(len(subrole))) == 0 {
+		return w, false
+	}
+	return windowInfo{title: C.GoString(&title[0]), role: C.GoString(&role[0]), subrole: C.GoString(&subrole[0])}, true
+}
+
+// excludedWindow は (x, y) のウインドウが除外の条件のいずれかに一致するかを返す。
+// アクセシビリティ API はアプリとの IPC を伴うため、アクターの外で呼ぶこと。
+func excludedWindow(x, y float64, rules []dragExclusion) bool {
+	if rules == nil {
+		return false
+	}
+	w, ok := windowAt(x, y)
+	if !ok {
+		return false
+	}
+	for _, r := range rules {
+		if r.matches(w) {
+			fmt.Fprintf(os.Stderr, "[drag] drag inertia excluded for window %q (%s %s)\n", w.title, w.role, w.subrole)
+			return true
+		}
+	}
+	return false
+}
+
+// queryDragWindow はマウスダウンの後、カーソルの下のウインドウと文字の選択（textselect.go）を
+// 別 goroutine で調べ、除外するなら結果をアクターに送る。
+// --drag-titlebar-only ではマウスダウンの時点で除外しておき、タイトルバーの上で、
+// ほかの除外にも一致しなければ除外を解く結果を送る（titlebar.go）。
+// 結果はドラッグの途中で届くが、除外はリリース時に判定するため間に合う。
+// アクター goroutine から呼ぶこと。
+func (a *App) queryDragWindow() {
+	checkText := !a.textDragCoast
+	titlebarOnly := a.dragTitlebarOnly
+	if (a.dragExclusions == nil && !checkText && !titlebarOnly) || !a.isLeftButtonDown {
+		return
+	}
+	seq := a.dragSeq
+	rules := a.dragExclusions
+	go func() {
+		x, y, ok := getMouseLocation()
+		if !ok {
+			return
+		}
+		if titlebarOnly {
+			// タイトルバーの上なら文字の選択ではないため、文字の選択は調べない
+			if isTitlebarAt(x, y) {
+				a.send(dragWindowMsg{seq: seq, excluded: excludedWindow(x, y, rules)})
+			}
+			return
+		}
+		if excludedWindow(x, y, rules) || (checkText && isTextSelectionAt(x, y)) {
+			a.send(dragWindowMsg{seq: seq, excluded: true})
+		}
+	}()
+}
+
+// applyDragWindow は検索結果を反映する。別のマウスダウンに対する古い結果は捨てる。
+// アクター goroutine から呼ぶこと。
+func (a *App) applyDragWindow(seq uint64, excluded bool) {
+	if seq == a.dragSeq {
+		a.dragExcluded = excluded
+	}
+}
@@ -0,0 +1,47 @@
+// dropsettle.go: ドラッグ慣性の停止後の静定待ち（--drop-settle）。
+// 速く投げたファイルは、減衰の終盤でまだ動いているうちに mouseUp を解放すると、
+// 通過中のフォルダやウインドウに落ちることがある。速度が完全にゼロになり、
+// カーソルが指定時間止まっていたのを確かめてから mouseUp を解放する。
+// 静定待ちの間は何も発行せず、ドラッグフェーズを維持するため、タッチすれば掴み直せる。
+package main
+
+// startDropSettle はドラッグ慣性の停止時に、最後にカーソルが動いてから dropSettle が
+// 経つまでドロップを保留する。保留を始めた場合は true を返す。
+// アクター goroutine から呼ぶこと。
+func (a *App) startDropSettle(now float64) bool {
+	if a.dropSettle <= 0 {
+		return false
+	}
+	until := a.dragMovedAt + a.dropSettle
+	if now >= until {
+		return false
+	}
+	a.dropSettling = true
+	a.settleUntil = until
+	return true
+}
+
+// prepareDropSettle は静定待ちの1フレームを計算する。期限が来たらドラッグを終了する。
+// アクター goroutine から呼ぶこと。
+func (a *App) prepareDropSettle(now float64) coastAction {
+	var action coastAction
+	if now < a.settleUntil {
+		action.hud = a.currentHUDFrame()
+		return action
+	}
+	a.dropSettling = false
+	action.dragX = a.coastX
+	action.dragY = a.coastY
+	action.coastEnded = true
+	a.queueHook(&action.hooks, hookDragEnd)
+	action.pending = a.resetCoasting()
+	action.hud = a.currentHUDFrame()
+	return action
+}
+
+// holdingDrop は停止位置でドロップを保留中（スプリングローディングか静定待ち）かを返す。
+// 保留中は速度がゼロでもコーストフレームを回し続ける。
+// アクター goroutine から呼ぶこと。
+func (a *App) holdingDrop() bool {
+	return a.springHold || a.dropSettling
+}
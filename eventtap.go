@@ -9,7 +9,9 @@ package main
 import "C"
 import (
 	"fmt"
+	"os"
 	"runtime"
+	"time"
 	"unsafe"
 )
 
@@ -22,69 +24,222 @@ type runLoopRef = C.CFRunLoopRef
 
 // startEventTap は CGEventTap を作成し、専用スレッドで RunLoop を回す。
 func (a *App) startEventTap() error {
-	mask := C.CGEventMask((1 << C.kCGEventLeftMouseDown) | (1 << C.kCGEventLeftMouseUp))
-	tap := C.CGEventTapCreate(
-		C.kCGSessionEventTap,
-		C.kCGHeadInsertEventTap,
-		C.kCGEventTapOptionDefault,
-		mask,
-		C.CGEventTapCallBack(C.bridge_event_tap_callback),
-		nil,
-	)
-	if tap == 0 {
-		return fmt.Errorf("CGEventTapCreate failed (accessibility permission required)")
+	// スクロールはジェスチャー検出のため常に監視する（平滑化が無効なら素通しする）
+	mask := C.CGEventMask(1 << C.kCGEventScrollWheel)
+	options := C.CGEventTapOptions(C.kCGEventTapOptionDefault)
+	if a.mode != modeCursor {
+		mask |= (1 << C.kCGEventLeftMouseDown) | (1 << C.kCGEventLeftMouseUp) |
+			(1 << C.kCGEventRightMouseDown) | (1 << C.kCGEventOtherMouseDown)
+	} else if !a.smoothScroll {
+		// カーソル慣性のみのモードではイベントを書き換えないため、監視専用の tap にする
+		options = C.kCGEventTapOptionListenOnly
 	}
-	a.eventTapRef = tap
-
-	source := C.CFMachPortCreateRunLoopSource(C.kCFAllocatorDefault, tap, 0)
-	if source == 0 {
-		C.CFRelease(C.CFTypeRef(tap))
-		a.eventTapRef = 0
-		return fmt.Errorf("CFMachPortCreateRunLoopSource failed")
+	if a.typingSuppress > 0 {
+		mask |= 1 << C.kCGEventKeyDown
+	}
+	options = a.eventTapOptions(options)
+	// 控えめモードでは、書き換える必要のない mouseDown とキー入力を監視専用の tap で見る
+	// （他のユーティリティの tap と同じイベントを奪い合わない）
+	var listenMask C.CGEventMask
+	if a.conservativeTap {
+		listenMask = mask & ((1 << C.kCGEventLeftMouseDown) | (1 << C.kCGEventRightMouseDown) |
+			(1 << C.kCGEventOtherMouseDown) | (1 << C.kCGEventKeyDown))
+		mask &^= listenMask
+	}
+	// Karabiner 互換モードでは、他の tap がイベントを書き換え・並べ替えた後の
+	// 最終的なボタンイベントを見るように末尾に挿入する
+	place := C.CGEventTapPlacement(C.kCGHeadInsertEventTap)
+	if a.karabinerCompat {
+		place = C.kCGTailAppendEventTap
+	}
+	location := a.eventTapLocation()
+	tap, source, err := createEventTap(location, place, options, mask)
+	if err != nil {
+		return err
 	}
+	var listenTap machPortRef
+	var listenSource C.CFRunLoopSourceRef
+	if listenMask != 0 {
+		if listenTap, listenSource, err = createEventTap(location, place, C.kCGEventTapOptionListenOnly, listenMask); err != nil {
+			C.CFRelease(C.CFTypeRef(source))
+			C.CFRelease(C.CFTypeRef(tap))
+			return err
+		}
+	}
+
+	// ウォッチドッグからの再作成時はコールバックと並行するため、tapMu 内で設定する
+	done := make(chan struct{})
+	a.tapMu.Lock()
+	a.eventTapRef = tap
+	a.eventTapListenRef = listenTap
+	a.eventTapDone = done
+	a.tapMu.Unlock()
 
 	// 専用 goroutine で RunLoop を回す（OS スレッドに固定）
 	started := make(chan struct{})
-	a.eventTapDone = make(chan struct{})
 	go func() {
 		runtime.LockOSThread()
 		rl := C.CFRunLoopGetCurrent()
-		a.mu.Lock()
+		a.tapMu.Lock()
 		a.eventTapRunLoop = rl
-		a.mu.Unlock()
+		a.tapMu.Unlock()
 
 		// CFRunLoopAddSource は内部で source を CFRetain するので、ここで CFRelease して参照を手放す
 		C.CFRunLoopAddSource(rl, source, C.kCFRunLoopCommonModes)
 		C.CFRelease(C.CFTypeRef(source))
+		if listenSource != 0 {
+			C.CFRunLoopAddSource(rl, listenSource, C.kCFRunLoopCommonModes)
+			C.CFRelease(C.CFTypeRef(listenSource))
+		}
 		close(started)
 		C.CFRunLoopRun()
-		close(a.eventTapDone)
+		close(done)
 	}()
 	<-started
 
 	return nil
 }
 
+// createEventTap は location（通常はセッションレベル。tapplacement.go）の CGEventTap と、その RunLoop ソースを作成する。
+func createEventTap(location C.CGEventTapLocation, place C.CGEventTapPlacement, options C.CGEventTapOptions, mask C.CGEventMask) (machPortRef, C.CFRunLoopSourceRef, error) {
+	tap := C.CGEventTapCreate(
+		location,
+		place,
+		options,
+		mask,
+		C.CGEventTapCallBack(C.bridge_event_tap_callback),
+		nil,
+	)
+	if tap == 0 {
+		return 0, 0, fmt.Errorf("CGEventTapCreate failed (accessibility permission required)")
+	}
+
+	source := C.CFMachPortCreateRunLoopSource(C.kCFAllocatorDefault, tap, 0)
+	if source == 0 {
+		C.CFRelease(C.CFTypeRef(tap))
+		return 0, 0, fmt.Errorf("CFMachPortCreateRunLoopSource failed")
+	}
+	return tap, source, nil
+}
+
+// eventTaps は作成済みの EventTap（控えめモードでは監視専用の tap を含む）を返す。tapMu を保持して呼ぶこと。
+func (a *App) eventTaps() []machPortRef {
+	var taps []machPortRef
+	for _, tap := range []machPortRef{a.eventTapRef, a.eventTapListenRef} {
+		if tap != 0 {
+			taps = append(taps, tap)
+		}
+	}
+	return taps
+}
+
 // reEnableEventTap はタイムアウトで無効化された EventTap を再有効化する。
 func (a *App) reEnableEventTap() {
-	a.mu.Lock()
-	tap := a.eventTapRef
-	a.mu.Unlock()
-	if tap != 0 {
+	a.tapMu.Lock()
+	taps := a.eventTaps()
+	a.tapMu.Unlock()
+	for _, tap := range taps {
 		C.CGEventTapEnable(tap, C.bool(true))
 	}
 }
 
+// pauseEventTap は EventTap を一時停止（または再開）する。
+// --tap-helper では補助プロセスの EventTap を一時停止する。
+// 一時停止中はウォッチドッグが無効化された tap を再有効化・再作成しない。
+func (a *App) pauseEventTap(paused bool) {
+	if a.tapHelper != nil {
+		a.tapHelper.pause(paused)
+		return
+	}
+	a.tapMu.Lock()
+	a.eventTapPaused = paused
+	taps := a.eventTaps()
+	a.tapMu.Unlock()
+	for _, tap := range taps {
+		C.CGEventTapEnable(tap, C.bool(!paused))
+	}
+}
+
+// watchEventTap は EventTap の生存を定期的に確認し、死んでいれば再作成する。
+// kCGEventTapDisabledByTimeout はコールバックで再有効化されるが、
+// kCGEventTapDisabledByUserInput による無効化や、権限変更による mach port の無効化は
+// コールバックでは回復できないため、ここで検出する。
+// a.stop が閉じられるまでブロックする。終了時に watchdogDone を閉じる。
+func (a *App) watchEventTap() {
+	defer close(a.watchdogDone)
+
+	ticker := time.NewTicker(eventTapWatchdogInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+			a.checkEventTap()
+		}
+	}
+}
+
+// checkEventTap は EventTap が有効か確認し、無効なら再有効化、それでも駄目なら再作成する。
+// 控えめモードの監視専用の tap も同様に確認し、どちらかが回復できなければ両方を作り直す。
+func (a *App) checkEventTap() {
+	a.tapMu.Lock()
+	taps := a.eventTaps()
+	paused := a.eventTapPaused
+	a.tapMu.Unlock()
+
+	if paused {
+		return
+	}
+	alive := len(taps) > 0
+	for _, tap := range taps {
+		if !a.checkOneEventTap(tap) {
+			alive = false
+		}
+	}
+	if alive {
+		return
+	}
+
+	// tap が破棄されている → 作り直す
+	a.stopEventTap()
+	if err := a.startEventTap(); err != nil {
+		fmt.Fprintf(os.Stderr, "[eventtap] tap is dead, recreate failed: %v\n", err)
+		a.alerts.alert(alertEventTap, "Mouse event interception stopped and could not be restarted. Check the Accessibility permission in System Settings.")
+		return
+	}
+	fmt.Fprintln(os.Stderr, "[eventtap] tap was dead, recreated")
+}
+
+// checkOneEventTap は tap が有効かを確認し、無効化されているだけなら再有効化を試みる。
+// 有効（または再有効化できた）なら true を返す。
+func (a *App) checkOneEventTap(tap machPortRef) bool {
+	if C.CFMachPortIsValid(tap) == 0 {
+		return false
+	}
+	if C.CGEventTapIsEnabled(tap) {
+		return true
+	}
+	C.CGEventTapEnable(tap, C.bool(true))
+	if C.CGEventTapIsEnabled(tap) {
+		fmt.Fprintln(os.Stderr, "[eventtap] tap was disabled, re-enabled")
+		return true
+	}
+	return false
+}
+
 // stopEventTap は EventTap の RunLoop を停止し、リソースを解放する。
 // RunLoop goroutine の終了を待ってから tap を解放する。
 func (a *App) stopEventTap() {
-	a.mu.Lock()
+	a.tapMu.Lock()
 	rl := a.eventTapRunLoop
-	tap := a.eventTapRef
+	taps := a.eventTaps()
 	done := a.eventTapDone
 	a.eventTapRunLoop = 0
 	a.eventTapRef = 0
-	a.mu.Unlock()
+	a.eventTapListenRef = 0
+	a.tapMu.Unlock()
 
 	if rl != 0 {
 		C.CFRunLoopStop(rl)
@@ -92,7 +247,7 @@ func (a *App) stopEventTap() {
 			<-done // RunLoop goroutine の終了を待つ
 		}
 	}
-	if tap != 0 {
+	for _, tap := range taps {
 		C.CGEventTapEnable(tap, C.bool(false))
 		C.CFRelease(C.CFTypeRef(tap))
 	}
@@ -101,7 +256,6 @@ func (a *App) stopEventTap() {
 //export goEventTapCallback
 func goEventTapCallback(proxy C.CGEventTapProxy, eventType C.CGEventType,
 	event C.CGEventRef, userInfo unsafe.Pointer) C.CGEventRef {
-	_ = proxy
 	_ = userInfo
 
 	if app == nil {
@@ -110,14 +264,51 @@ func goEventTapCallback(proxy C.CGEventTapProxy, eventType C.CGEventType,
 
 	switch eventType {
 	case C.kCGEventLeftMouseDown:
-		app.onMouseDown()
+		if app.onMouseDown(proxy, event) {
+			return 0
+		}
+	case C.kCGEventRightMouseDown, C.kCGEventOtherMouseDown:
+		app.onOtherMouseDown(proxy, event)
 	case C.kCGEventLeftMouseUp:
 		if app.handleMouseUp(event) {
 			return 0 // nil を返すとイベントが消費される
 		}
+	case C.kCGEventScrollWheel:
+		if handleScrollWheel(event) {
+			return 0
+		}
+	case C.kCGEventKeyDown:
+		// ホットエッジ等で自分が送ったキー操作はタイピングとみなさない
+		if C.CGEventGetIntegerValueField(event, C.kCGEventSourceUserData) != syntheticEventMarker {
+			app.onKeyDown()
+		}
 	case C.kCGEventTapDisabledByTimeout:
 		app.reEnableEventTap()
 	}
 
 	return event
 }
+
+// handleScrollWheel はスクロールイベントを処理する。消費した場合は true を返す。
+// スクロールフェーズ付きのイベント（トラックパッドのスクロールジェスチャー）はタッチに記録し、
+// 平滑化が有効なら段階的なホイールスクロールを傍受してスクロール慣性に変換する。
+// トラックパッド等の連続スクロールと、自分が発行した合成スクロールはそのまま通す。
+func handleScrollWheel(event C.CGEventRef) bool {
+	if C.CGEventGetIntegerValueField(event, C.kCGEventSourceUserData) == syntheticEventMarker {
+		return false
+	}
+	if C.CGEventGetIntegerValueField(event, C.kCGScrollWheelEventScrollPhase) != 0 {
+		app.onScrollPhase()
+		return false
+	}
+	if !app.smoothScroll || C.CGEventGetIntegerValueField(event, C.kCGScrollWheelEventIsContinuous) != 0 {
+		return false
+	}
+	linesY := int(C.CGEventGetIntegerValueField(event, C.kCGScrollWheelEventDeltaAxis1))
+	linesX := int(C.CGEventGetIntegerValueField(event, C.kCGScrollWheelEventDeltaAxis2))
+	if linesX == 0 && linesY == 0 {
+		return false
+	}
+	app.onScrollWheel(linesX, linesY)
+	return true
+}
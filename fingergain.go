@@ -0,0 +1,40 @@
+// fingergain.go: 指の本数ごとの速度の倍率（--finger-gain）。
+// リリース直前にパッドに触れていた指の本数で初速を変える（例: 3本指ドラッグのフリックは遠くまで飛ばす）。
+// 2本指の移動はスクロールとして扱われることが多く、その場合はそもそも慣性を発生させない（isScrollGesture）。
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseFingerGains は "2:2,3:1.5" の形式の指定を、指の本数 → 速度の倍率の表にする。
+func parseFingerGains(spec string) (map[int]float64, error) {
+	gains := make(map[int]float64)
+	for _, item := range strings.Split(spec, ",") {
+		countStr, gainStr, ok := strings.Cut(strings.TrimSpace(item), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid finger gain %q (expected fingers:gain)", item)
+		}
+		count, err := strconv.Atoi(countStr)
+		if err != nil || count < 1 || count > 5 {
+			return nil, fmt.Errorf("invalid finger count %q (expected 1 to 5)", countStr)
+		}
+		gain, err := strconv.ParseFloat(gainStr, 64)
+		if err != nil || gain < 0 {
+			return nil, fmt.Errorf("invalid gain %q for %d fingers (expected a number >= 0)", gainStr, count)
+		}
+		gains[count] = gain
+	}
+	return gains, nil
+}
+
+// applyFingerGain はリリース直前の指の本数の倍率を速度に掛ける。表にない本数はそのまま。
+// アクター goroutine から呼ぶこと。
+func (a *App) applyFingerGain() {
+	if gain, ok := a.fingerGains[a.releaseFingers]; ok {
+		a.vx *= gain
+		a.vy *= gain
+	}
+}
@@ -0,0 +1,42 @@
+// flickboost.go: 連続したフリックの加速（--flick-boost）。
+// コーストの直後（またはコースト中に捕まえて）同じ方向にもう一度フリックすると、初速に割り増しを付ける。
+// 繰り返しのスワイプが独立した投げではなく、はずみ車を回し足しているように感じられる。
+package main
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// defaultFlickBoostWindow は前のコーストの最後のフレームから割り増しを付ける期間のデフォルト。
+	defaultFlickBoostWindow = 500 * time.Millisecond
+	// flickBoostMaxAngle は前のコーストと同じ方向とみなす角度の差の上限（度）。
+	flickBoostMaxAngle = 30.0
+)
+
+// applyFlickBoost はリリース時の速度が前のコーストとほぼ同じ方向で、前のコーストの最後のフレームから
+// flickBoostWindow 以内なら、速度に (1 + flickBoost) を掛ける。now はリリースの時刻。
+// アクター goroutine から呼ぶこと。
+func (a *App) applyFlickBoost(now float64) {
+	if a.flickBoost == 0 || a.lastCoastAt == 0 || (a.vx == 0 && a.vy == 0) {
+		return
+	}
+	if now-a.lastCoastAt > a.flickBoostWindow.Seconds() {
+		return
+	}
+	speed := math.Hypot(a.vx, a.vy)
+	cos := (a.vx*a.lastCoastDirX + a.vy*a.lastCoastDirY) / speed
+	if cos < math.Cos(flickBoostMaxAngle*math.Pi/180) {
+		return
+	}
+	a.vx *= 1 + a.flickBoost
+	a.vy *= 1 + a.flickBoost
+}
+
+// noteCoastDirection はコーストの開始時に方向を記録する（次のフリックの割り増しの判定用）。
+// アクター goroutine から呼ぶこと。
+func (a *App) noteCoastDirection() {
+	speed := math.Hypot(a.vx, a.vy)
+	a.lastCoastDirX, a.lastCoastDirY = a.vx/speed, a.vy/speed
+}
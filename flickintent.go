@@ -0,0 +1,94 @@
+// flickintent.go: フリックの意図の判定（--flick-intent）。
+// 速度のしきい値を超えても、指を離すときのぶれ（指先が転がってカーソルが少し動く）で
+// 意図しないコーストが始まることがある。リリース時の速度・接触時間・軌跡のまっすぐさ・
+// 接触の大きさの変化から意図したフリックらしさを 0〜1 で求め、しきい値に届かなければコーストを始めない。
+// 調整できるように、判定は毎回ログに出す。
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// intentSpeedFull はフリックらしさが最大になる速度（MinFlickSpeed に対する倍率）。
+	intentSpeedFull = 3.0
+	// intentMinDuration はこれより短い接触を意図したフリックとみなしにくくする時間（秒）。
+	// かすめただけのタッチや、タップの指のずれを除く。
+	intentMinDuration = 0.08
+	// intentShrinkFull は離す直前の接触の大きさがピークに対してこの割合以上なら、ぶれとみなさない。
+	// 指を離すときは接触が急に小さくなりながらカーソルが動く。
+	intentShrinkFull = 0.7
+)
+
+// 各特徴の重み（接触の大きさが分からないバックエンドでは残りで按分する）
+const (
+	intentWeightSpeed    = 0.35
+	intentWeightDuration = 0.15
+	intentWeightStraight = 0.3
+	intentWeightContact  = 0.2
+)
+
+// startFlickIntent はタッチの開始を記録する。アクター goroutine から呼ぶこと。
+func (a *App) startFlickIntent(timestamp float64) {
+	a.touchStartT = timestamp
+	a.contactPeak = 0
+	a.contactLast = 0
+}
+
+// trackContactSize はタッチ中の接触の大きさのピークと最新の値を記録する。
+// アクター goroutine から呼ぶこと。
+func (a *App) trackContactSize(size float64) {
+	a.contactPeak = max(a.contactPeak, size)
+	a.contactLast = size
+}
+
+// pathStraightness はカーソル履歴の始点と終点の距離を、経路の長さで割った値（0〜1）を返す。
+// 履歴が動いていなければ 1 を返す。アクター goroutine から呼ぶこと。
+func (a *App) pathStraightness() float64 {
+	if a.histLen < 2 {
+		return 1
+	}
+	var path float64
+	for i := 1; i < a.histLen; i++ {
+		path += math.Hypot(a.history[i].x-a.history[i-1].x, a.history[i].y-a.history[i-1].y)
+	}
+	if path == 0 {
+		return 1
+	}
+	first, last := a.history[0], a.history[a.histLen-1]
+	return math.Min(math.Hypot(last.x-first.x, last.y-first.y)/path, 1)
+}
+
+// classifyFlick はリリース時の特徴からフリックらしさを求め、しきい値に届かなければ速度をゼロにする。
+// 履歴を使うため、リリース時に履歴を消す前に呼ぶこと。速度がフリックのしきい値未満なら何もしない。
+// アクター goroutine から呼ぶこと。
+func (a *App) classifyFlick() {
+	speed := math.Hypot(a.vx, a.vy)
+	if a.flickIntent <= 0 || speed < a.params.MinFlickSpeed || a.params.MinFlickSpeed <= 0 {
+		return
+	}
+	speedScore := math.Min(speed/(a.params.MinFlickSpeed*intentSpeedFull), 1)
+	duration := a.coastT - a.touchStartT
+	durationScore := math.Min(math.Max(duration, 0)/intentMinDuration, 1)
+	straight := a.pathStraightness()
+
+	score := intentWeightSpeed*speedScore + intentWeightDuration*durationScore + intentWeightStraight*straight
+	weights := intentWeightSpeed + intentWeightDuration + intentWeightStraight
+	contact := "-"
+	if a.contactPeak > 0 {
+		ratio := a.contactLast / a.contactPeak
+		score += intentWeightContact * math.Min(ratio/intentShrinkFull, 1)
+		weights += intentWeightContact
+		contact = fmt.Sprintf("%.2f", ratio)
+	}
+	score /= weights
+
+	verdict := "flick"
+	if score < a.flickIntent {
+		verdict = "lift-off wobble, no coast"
+		a.vx, a.vy = 0, 0
+	}
+	fmt.Printf("[intent] score %.2f: speed %.0f px/s, contact %.2fs, straightness %.2f, contact size %s -> %s\n",
+		score, speed, duration, straight, contact, verdict)
+}
@@ -0,0 +1,174 @@
+// focus.go: 集中モードごとの設定。
+// 現在の集中モード（おやすみモード・仕事・プレゼンテーション等）を定期的に読み取り、
+// 集中モードごとに設定したプリセット・動作モード・一時停止を切り替える
+// （例: プレゼンテーション中はドラッグ慣性を無効にする）。
+//
+// 集中モードを取得する公開 API はないため、おやすみモードのデータベース
+// （~/Library/DoNotDisturb/DB）の JSON を読む。読むにはフルディスクアクセスが必要。
+// スケジュールで自動的にオンになった集中モードはここに記録されないため検出できない。
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// focusCheckInterval は集中モードを確認する間隔。
+const focusCheckInterval = 2 * time.Second
+
+// focusSetting は1つの集中モードに対する設定を表す。
+type focusSetting struct {
+	pause  bool      // 一時停止する
+	mode   coastMode // 慣性を適用する対象（modeBoth なら制限しない）
+	preset string    // 適用するプリセット（空なら変更しない）
+}
+
+// focusMsg は集中モードの変化（name は集中モードの名前、オフなら空）。
+type focusMsg struct {
+	name string
+}
+
+// parseFocusProfiles は "名前:設定[+設定...],..." 形式の集中モードごとの設定を解析する。
+// 設定は off（一時停止）、cursor / drag（動作モードの制限）、またはプリセット名。
+func parseFocusProfiles(spec string) (map[string]focusSetting, error) {
+	profiles := make(map[string]focusSetting)
+	for _, entry := range strings.Split(spec, ",") {
+		name, settings, ok := strings.Cut(entry, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || settings == "" {
+			return nil, fmt.Errorf("invalid focus setting %q (expected name:setting)", entry)
+		}
+		var s focusSetting
+		for _, v := range strings.Split(settings, "+") {
+			switch v = strings.TrimSpace(v); v {
+			case "off":
+				s.pause = true
+			case "cursor", "drag":
+				s.mode, _ = parseCoastMode(v)
+			default:
+				if _, err := lookupPreset(v); err != nil {
+					return nil, fmt.Errorf("invalid focus setting %q for %s (available: off, cursor, drag, %s)",
+						v, name, strings.Join(presetNames(), ", "))
+				}
+				s.preset = v
+			}
+		}
+		profiles[name] = s
+	}
+	return profiles, nil
+}
+
+// currentFocus は有効な集中モードの名前を返す。集中モードがオフなら空文字を返す。
+func currentFocus() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	db := filepath.Join(home, "Library", "DoNotDisturb", "DB")
+
+	var assertions struct {
+		Data []struct {
+			StoreAssertionRecords []struct {
+				AssertionDetails struct {
+					ModeIdentifier string `json:"assertionDetailsModeIdentifier"`
+				} `json:"assertionDetails"`
+			} `json:"storeAssertionRecords"`
+		} `json:"data"`
+	}
+	if err := readJSONFile(filepath.Join(db, "Assertions.json"), &assertions); err != nil {
+		return "", err
+	}
+	id := ""
+	for _, d := range assertions.Data {
+		for _, r := range d.StoreAssertionRecords {
+			id = r.AssertionDetails.ModeIdentifier
+		}
+	}
+	if id == "" {
+		return "", nil
+	}
+
+	var configs struct {
+		Data []struct {
+			ModeConfigurations map[string]struct {
+				Mode struct {
+					Name string `json:"name"`
+				} `json:"mode"`
+			} `json:"modeConfigurations"`
+		} `json:"data"`
+	}
+	if err := readJSONFile(filepath.Join(db, "ModeConfigurations.json"), &configs); err != nil {
+		return "", err
+	}
+	for _, d := range configs.Data {
+		if c, ok := d.ModeConfigurations[id]; ok && c.Mode.Name != "" {
+			return c.Mode.Name, nil
+		}
+	}
+	return id, nil
+}
+
+// readJSONFile は JSON ファイルを読み込んで v にデコードする。
+func readJSONFile(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// watchFocus は集中モードを定期的に確認し、変化したらアクターに通知する。
+// 読み取りに失敗した場合（フルディスクアクセスがない等）は1回だけ警告し、集中モードはオフとみなす。
+// a.stop が閉じられるまでブロックする。
+func (a *App) watchFocus() {
+	ticker := time.NewTicker(focusCheckInterval)
+	defer ticker.Stop()
+
+	last, warned := "", false
+	for {
+		name, err := currentFocus()
+		if err != nil && !warned {
+			fmt.Fprintf(os.Stderr, "[focus] cannot read the current Focus (Full Disk Access required?): %v\n", err)
+			warned = true
+		}
+		if name != last {
+			last = name
+			a.send(focusMsg{name: name})
+		}
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// applyFocus は集中モードに対応する設定を適用する。
+// プリセットを切り替える場合は元のパラメータを保存し、集中モードを抜けたら戻す。
+// 一時停止が変わった場合は保留中のマウスアップを返す（呼び出し側が releasePendingMouseUp すること）。
+// アクター goroutine から呼ぶこと。
+func (a *App) applyFocus(name string) eventRef {
+	if name == "" {
+		fmt.Println("[focus] focus off")
+	} else {
+		fmt.Printf("[focus] focus %s\n", name)
+	}
+	a.focus = name
+	s := a.focusProfiles[name]
+
+	if a.focusSaved != nil {
+		a.params, a.preset = a.focusSaved.Params, a.focusSaved.Preset
+		a.focusSaved = nil
+	}
+	if s.preset != "" {
+		a.focusSaved = &presetStatus{Preset: a.preset, Params: a.params}
+		a.params, a.preset = presets[s.preset], s.preset
+	}
+	a.focusMode = s.mode
+	a.focusPaused = s.pause
+	return a.updateSuspend()
+}
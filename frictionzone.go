@@ -0,0 +1,71 @@
+// frictionzone.go: 画面上の領域ごとの摩擦（--friction-zones）。
+// 設定ファイルで画面上の矩形と摩擦の倍率を指定し、コースト位置がその中にある間は減衰率に倍率を掛ける。
+// Dock の上で摩擦を増やして止まりやすくしたり、2台のモニタの間を摩擦なし（倍率 0）で滑らせたりできる。
+// 倍率はコーストフレームごとにフレーム開始時のコースト位置で決め、変わったらその状態から軌跡を引き直す。
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// frictionZone は摩擦を変える画面上の領域を表す。座標はグローバルなディスプレイ座標（pt、左上原点）。
+type frictionZone struct {
+	Name     string  `json:"name,omitempty"` // ログ・エラー用の名前
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	Width    float64 `json:"width"`
+	Height   float64 `json:"height"`
+	Friction float64 `json:"friction"` // 減衰率の倍率（0 で摩擦なし、1 で通常）
+}
+
+// contains は (x, y) が領域の中にあるかを返す。
+func (z *frictionZone) contains(x, y float64) bool {
+	return x >= z.X && x < z.X+z.Width && y >= z.Y && y < z.Y+z.Height
+}
+
+// loadFrictionZones は摩擦の領域の設定ファイル（JSON の配列）を読み込んで検証する。
+func loadFrictionZones(path string) ([]frictionZone, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var zones []frictionZone
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&zones); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if len(zones) == 0 {
+		return nil, errors.New("no friction zones defined")
+	}
+	for i, z := range zones {
+		name := z.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		if z.Width <= 0 || z.Height <= 0 {
+			return nil, fmt.Errorf("friction zone %s: width and height must be positive", name)
+		}
+		if z.Friction < 0 {
+			return nil, fmt.Errorf("friction zone %s: friction must be >= 0", name)
+		}
+	}
+	return zones, nil
+}
+
+// zoneDecayRate はコースト位置での減衰率を返す。領域が重なる場合は先に定義したものを使う。
+// ポインタの大きさによる倍率（largecursor.go）も掛ける。
+// アクター goroutine から呼ぶこと。
+func (a *App) zoneDecayRate() float64 {
+	k := a.params.DecayRate * a.largeCursorFactor()
+	for i := range a.frictionZones {
+		if z := &a.frictionZones[i]; z.contains(a.coastX, a.coastY) {
+			return k * z.Friction
+		}
+	}
+	return k
+}
@@ -0,0 +1,98 @@
+// frontapp.go: 最前面のアプリの取得。
+// タッチのたびに NSWorkspace に問い合わせないように、アプリの切り替え通知で
+// 最前面のアプリのバンドル ID をキャッシュする（FrontAppNotifier）。
+package main
+
+/*
+#cgo LDFLAGS: -framework Cocoa
+#include "frontapp.h"
+*/
+import "C"
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// frontmostCache は切り替え通知で更新する最前面のアプリのバンドル ID（監視中のみ有効）。
+var frontmostCache atomic.Pointer[string]
+
+// frontmostBundleID は最前面のアプリのバンドル ID を返す。取得できなければ空文字を返す。
+func frontmostBundleID() string {
+	var buf [256]C.char
+	if C.frontmost_bundle_id(&buf[0], C.int(len(buf))) == 0 {
+		return ""
+	}
+	return C.GoString(&buf[0])
+}
+
+// cachedFrontmostBundleID は最前面のアプリのバンドル ID を返す。
+// 切り替え通知を監視中ならキャッシュを返し、そうでなければ直接問い合わせる。
+func cachedFrontmostBundleID() string {
+	if id := frontmostCache.Load(); id != nil {
+		return *id
+	}
+	return frontmostBundleID()
+}
+
+// FrontAppNotifier はアプリの切り替え通知を監視し、最前面のアプリをキャッシュする。
+// DeviceNotifier と同様に、専用 goroutine（OS スレッドに固定）の RunLoop で通知を受け取る。
+type FrontAppNotifier struct {
+	mu      sync.Mutex
+	runLoop C.CFRunLoopRef
+	done    chan struct{}
+}
+
+// StartFrontAppNotifier はアプリの切り替え通知の監視を開始する。
+func StartFrontAppNotifier() (*FrontAppNotifier, error) {
+	fn := &FrontAppNotifier{done: make(chan struct{})}
+
+	started := make(chan bool)
+	go func() {
+		runtime.LockOSThread()
+		defer close(fn.done)
+
+		if C.frontapp_observe_start() == 0 {
+			started <- false
+			return
+		}
+		defer C.frontapp_observe_stop()
+
+		fn.mu.Lock()
+		fn.runLoop = C.CFRunLoopGetCurrent()
+		fn.mu.Unlock()
+
+		// 監視の開始前に切り替わっていても取りこぼさないよう、現在の値で初期化する
+		id := frontmostBundleID()
+		frontmostCache.Store(&id)
+		started <- true
+		C.CFRunLoopRun()
+	}()
+	if !<-started {
+		return nil, errors.New("failed to observe application activation")
+	}
+	return fn, nil
+}
+
+// Stop は RunLoop を停止して監視を終了する。以降は最前面のアプリを直接問い合わせる。
+func (fn *FrontAppNotifier) Stop() {
+	fn.mu.Lock()
+	rl := fn.runLoop
+	fn.runLoop = 0
+	fn.mu.Unlock()
+
+	if rl != 0 {
+		C.CFRunLoopStop(rl)
+		<-fn.done
+	}
+	frontmostCache.Store(nil)
+}
+
+// goFrontAppChanged は frontapp_observe_start の通知ブロック (Objective-C) から呼ばれる cgo export 関数。
+//
+//export goFrontAppChanged
+func goFrontAppChanged(bundleID *C.char) {
+	id := C.GoString(bundleID)
+	frontmostCache.Store(&id)
+}
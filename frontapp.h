@@ -0,0 +1,17 @@
+// frontapp.h: 最前面のアプリの取得と切り替えの監視（NSWorkspace）。
+#ifndef FRONTAPP_H
+#define FRONTAPP_H
+
+#include <CoreFoundation/CoreFoundation.h>
+
+// 最前面のアプリのバンドル ID を buf に書き込む。取得できなければ 0 を返す。
+int frontmost_bundle_id(char *buf, int len);
+
+// アプリの切り替え通知（NSWorkspaceDidActivateApplicationNotification）の監視を開始する。
+// 通知は呼び出したスレッドの RunLoop で受け取り、goFrontAppChanged に渡す。失敗すると 0 を返す。
+int frontapp_observe_start(void);
+
+// アプリの切り替え通知の監視を停止する。
+void frontapp_observe_stop(void);
+
+#endif
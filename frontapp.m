@@ -0,0 +1,47 @@
+// frontapp.m: 最前面のアプリのバンドル ID の取得と、アプリの切り替え通知の監視。
+#import <Cocoa/Cocoa.h>
+#include "frontapp.h"
+#include "_cgo_export.h"
+
+int frontmost_bundle_id(char *buf, int len) {
+    @autoreleasepool {
+        NSString *bundleID = [NSWorkspace sharedWorkspace].frontmostApplication.bundleIdentifier;
+        if (bundleID == nil) {
+            return 0;
+        }
+        return [bundleID getCString:buf maxLength:len encoding:NSUTF8StringEncoding];
+    }
+}
+
+static id observer = nil;
+
+int frontapp_observe_start(void) {
+    @autoreleasepool {
+        NSNotificationCenter *nc = [NSWorkspace sharedWorkspace].notificationCenter;
+        // queue:nil のため、ブロックは通知を投稿したスレッドの RunLoop で実行される
+        observer = [nc addObserverForName:NSWorkspaceDidActivateApplicationNotification
+                                   object:nil
+                                    queue:nil
+                               usingBlock:^(NSNotification *note) {
+            NSRunningApplication *app = note.userInfo[NSWorkspaceApplicationKey];
+            NSString *bundleID = app.bundleIdentifier;
+            goFrontAppChanged((char *)(bundleID != nil ? bundleID.UTF8String : ""));
+        }];
+        if (observer == nil) {
+            return 0;
+        }
+        [observer retain];
+        // 入力ソースのない RunLoop は CFRunLoopRun で即座に戻るため、ダミーのポートを登録する
+        [[NSRunLoop currentRunLoop] addPort:[NSMachPort port] forMode:NSDefaultRunLoopMode];
+        return 1;
+    }
+}
+
+void frontapp_observe_stop(void) {
+    if (observer == nil) {
+        return;
+    }
+    [[NSWorkspace sharedWorkspace].notificationCenter removeObserver:observer];
+    [observer release];
+    observer = nil;
+}
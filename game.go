@@ -0,0 +1,56 @@
+// game.go: 全画面ゲームの検出。
+// FPS などのゲームはカーソル移動をカメラの回転として扱うため、慣性が乗ると視点が暴れる。
+// 最前面のアプリが全画面のゲームの間はイベントの傍受と合成イベントの発行を止める。
+package main
+
+/*
+#cgo LDFLAGS: -framework Cocoa
+#include "game.h"
+*/
+import "C"
+import (
+	"fmt"
+	"time"
+)
+
+// gameCheckInterval は全画面ゲームかを確認する間隔。
+const gameCheckInterval = 2 * time.Second
+
+// gameMsg は全画面ゲームの検出結果の変化。
+type gameMsg struct {
+	active bool
+}
+
+// isGameFrontmost はディスプレイがキャプチャされているか、最前面のアプリが全画面のゲームかを返す。
+func isGameFrontmost() bool {
+	return C.game_is_frontmost() != 0
+}
+
+// watchGame は最前面のアプリを定期的に確認し、全画面のゲームの間は一時停止する。
+// a.stop が閉じられるまでブロックする。終了時に gameWatchDone を閉じる。
+func (a *App) watchGame() {
+	defer close(a.gameWatchDone)
+
+	ticker := time.NewTicker(gameCheckInterval)
+	defer ticker.Stop()
+
+	active := false
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+			game := isGameFrontmost()
+			if game == active {
+				continue
+			}
+			active = game
+			if active {
+				fmt.Println("[game] full-screen game detected, suspending")
+			} else {
+				fmt.Println("[game] full-screen game left, resuming")
+			}
+			a.send(gameMsg{active: active})
+		}
+	}
+}
@@ -0,0 +1,8 @@
+// game.h: 全画面ゲームの検出（CGDisplayIsCaptured / 提示オプション）。
+#ifndef GAME_H
+#define GAME_H
+
+// ディスプレイがキャプチャされているか、最前面のアプリが全画面表示のゲームなら 1 を返す。
+int game_is_frontmost(void);
+
+#endif
@@ -0,0 +1,33 @@
+// game.m: 最前面のアプリが全画面のゲームかを判定する。
+#import <Cocoa/Cocoa.h>
+#include "game.h"
+
+// is_game_app はアプリの Info.plist のカテゴリがゲームなら 1 を返す。
+// ゲームのカテゴリは public.app-category.games と、そのサブカテゴリ（public.app-category.action-games 等）。
+static int is_game_app(NSRunningApplication *app) {
+    if (app.bundleURL == nil) {
+        return 0;
+    }
+    NSBundle *bundle = [NSBundle bundleWithURL:app.bundleURL];
+    id category = bundle.infoDictionary[@"LSApplicationCategoryType"];
+    if (![category isKindOfClass:[NSString class]]) {
+        return 0;
+    }
+    return [category hasPrefix:@"public.app-category."] && [category hasSuffix:@"games"];
+}
+
+int game_is_frontmost(void) {
+    @autoreleasepool {
+        // CGDisplayCapture で画面を占有するゲームは、カテゴリに関係なく対象にする
+        if (CGDisplayIsCaptured(CGMainDisplayID())) {
+            return 1;
+        }
+        // 最前面のアプリの提示オプション（全画面表示、またはメニューバーと Dock を隠している）
+        NSApplicationPresentationOptions opts = [NSApp currentSystemPresentationOptions];
+        NSApplicationPresentationOptions hidden = NSApplicationPresentationHideMenuBar | NSApplicationPresentationHideDock;
+        if (!(opts & NSApplicationPresentationFullScreen) && (opts & hidden) != hidden) {
+            return 0;
+        }
+        return is_game_app([NSWorkspace sharedWorkspace].frontmostApplication);
+    }
+}
@@ -0,0 +1,141 @@
+// gesture.go: NSEvent のグローバルモニタによるタッチの近似（最終手段のバックエンド）。
+// MultitouchSupport と IOHIDEventSystemClient のどちらも使えない環境でもカーソル慣性だけは
+// 使えるように、カーソル移動とジェスチャーのイベントからタッチの有無を推定する。
+//
+// 指の接触は取れないため、カーソル移動が続いている間を1本指のタッチ、
+// スクロール・拡大縮小等のジェスチャー中を2本指のタッチとみなし、
+// イベントが gestureIdleTimeout 途切れたらリリースとする。速度はエンジンがカーソル位置から求める。
+// パッド上の位置は分からないため --edge-only は働かない。外付けマウスの移動もタッチとみなされる。
+// グローバルモニタはメインスレッドで NSApplication の RunLoop が回っている必要がある（main.go）。
+package main
+
+/*
+#cgo LDFLAGS: -framework Cocoa
+#include "gesture.h"
+*/
+import "C"
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// gestureIdleTimeout はイベントが途切れてからリリースとみなすまでの時間（秒）。
+	// mouseMoved は移動中 ~8ms 間隔で届くため、その数フレーム分。
+	gestureIdleTimeout = 0.05
+
+	// gestureCheckInterval はリリースを判定する間隔。
+	gestureCheckInterval = 10 * time.Millisecond
+
+	// gestureDevice は近似したタッチのデバイス ID（arbitrateTouch 用）。
+	// MultitouchSupport のデバイス参照（ポインタ値）とは重ならない。
+	gestureDevice uintptr = 1
+)
+
+// activeGestureDevices はイベントを受け取る GestureTouchDevices（監視中のみ）。
+var activeGestureDevices atomic.Pointer[GestureTouchDevices]
+
+// GestureTouchDevices は NSEvent のグローバルモニタからタッチフレームを近似する。
+type GestureTouchDevices struct {
+	mu        sync.Mutex
+	fingers   int     // 近似した指の本数（0 ならタッチなし）
+	lastEvent float64 // 最後のイベントの時刻（monotonicSeconds）
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewGestureTouchDevices はグローバルモニタを登録し、リリースの判定を開始する。
+func NewGestureTouchDevices() *GestureTouchDevices {
+	gd := &GestureTouchDevices{stop: make(chan struct{}), done: make(chan struct{})}
+	activeGestureDevices.Store(gd)
+	C.gesture_monitor_start(syntheticEventMarker)
+	go gd.watchIdle()
+	return gd
+}
+
+// RefreshDevices は何もしない（グローバルモニタはデバイスに依存しない）。
+func (gd *GestureTouchDevices) RefreshDevices() {}
+
+// Count は近似のデバイスを1台として返す。
+func (gd *GestureTouchDevices) Count() int {
+	return 1
+}
+
+// StopAll はグローバルモニタを解除し、リリースの判定を停止する。
+func (gd *GestureTouchDevices) StopAll() {
+	if !activeGestureDevices.CompareAndSwap(gd, nil) {
+		return
+	}
+	C.gesture_monitor_stop()
+	close(gd.stop)
+	<-gd.done
+}
+
+// onEvent はグローバルモニタのイベントからタッチフレームを作って送る。
+func (gd *GestureTouchDevices) onEvent(kind C.int) {
+	now := monotonicSeconds()
+	gd.mu.Lock()
+	switch kind {
+	case C.GESTURE_EVENT_MOVE:
+		gd.fingers = max(gd.fingers, 1)
+	case C.GESTURE_EVENT_MULTI:
+		gd.fingers = 2
+	}
+	gd.lastEvent = now
+	n := gd.fingers
+	gd.mu.Unlock()
+
+	// ジェスチャーの終了はリリースの判定（watchIdle）に任せる
+	if kind != C.GESTURE_EVENT_END && app != nil {
+		app.onTouchFrame(gestureDevice, n, 0.5, 0.5, 0, now)
+	}
+}
+
+// watchIdle はイベントが gestureIdleTimeout 途切れたらリリースのフレームを送る。
+// StopAll まで実行する。
+func (gd *GestureTouchDevices) watchIdle() {
+	defer close(gd.done)
+
+	ticker := time.NewTicker(gestureCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-gd.stop:
+			return
+		case <-ticker.C:
+			now := monotonicSeconds()
+			gd.mu.Lock()
+			released := gd.fingers > 0 && now-gd.lastEvent >= gestureIdleTimeout
+			if released {
+				gd.fingers = 0
+			}
+			gd.mu.Unlock()
+			if released && app != nil {
+				app.onTouchFrame(gestureDevice, 0, 0, 0, 0, now)
+			}
+		}
+	}
+}
+
+// runGestureMainLoop はメインスレッドで NSApplication の RunLoop を回す。stopGestureMainLoop まで戻らない。
+// HUD を使わないときに、グローバルモニタのイベントを受け取るために使う。main goroutine から呼ぶこと。
+func runGestureMainLoop() {
+	C.gesture_run()
+}
+
+// stopGestureMainLoop は runGestureMainLoop の RunLoop を停止する。
+func stopGestureMainLoop() {
+	C.gesture_stop()
+}
+
+// goGestureEvent はグローバルモニタ (Objective-C) から呼ばれる cgo export 関数。
+//
+//export goGestureEvent
+func goGestureEvent(kind C.int) {
+	if gd := activeGestureDevices.Load(); gd != nil {
+		gd.onEvent(kind)
+	}
+}
@@ -0,0 +1,26 @@
+// gesture.h: NSEvent のグローバルモニタによるタッチの近似（Cocoa）。
+#ifndef GESTURE_H
+#define GESTURE_H
+
+#include <stdint.h>
+
+// gesture_event の kind
+#define GESTURE_EVENT_MOVE 1   // カーソル移動（mouseMoved / mouseDragged）
+#define GESTURE_EVENT_MULTI 2  // 複数指の操作（スクロールジェスチャー・拡大縮小・回転等）
+#define GESTURE_EVENT_END 3    // 複数指の操作の終了
+
+// グローバルモニタを登録する。登録はメインスレッドで行い、イベントは goGestureEvent に渡す。
+// イベントが届くには、メインスレッドで NSApplication の RunLoop が回っている必要がある。
+// marker の付いた合成イベント（kCGEventSourceUserData）は無視する。
+void gesture_monitor_start(int64_t marker);
+
+// グローバルモニタを解除する。任意のスレッドから呼べる。
+void gesture_monitor_stop(void);
+
+// メインスレッドで NSApplication の RunLoop を回す（ウィンドウは作らない）。gesture_stop が呼ばれるまで戻らない。
+void gesture_run(void);
+
+// gesture_run の RunLoop を停止する。任意のスレッドから呼べる。
+void gesture_stop(void);
+
+#endif
@@ -0,0 +1,80 @@
+// gesture.m: NSEvent のグローバルモニタで、カーソル移動とジェスチャーのイベントを Go に中継する。
+#import <Cocoa/Cocoa.h>
+#include "gesture.h"
+#include "_cgo_export.h"
+
+static id monitor = nil;
+
+void gesture_monitor_start(int64_t marker) {
+    dispatch_async(dispatch_get_main_queue(), ^{
+        if (monitor != nil) {
+            return;
+        }
+        NSEventMask mask = NSEventMaskMouseMoved | NSEventMaskLeftMouseDragged | NSEventMaskScrollWheel |
+                           NSEventMaskMagnify | NSEventMaskRotate | NSEventMaskSwipe |
+                           NSEventMaskBeginGesture | NSEventMaskEndGesture;
+        monitor = [NSEvent addGlobalMonitorForEventsMatchingMask:mask handler:^(NSEvent *event) {
+            // コーストで発行した合成イベントはタッチとみなさない
+            if (CGEventGetIntegerValueField(event.CGEvent, kCGEventSourceUserData) == marker) {
+                return;
+            }
+            switch (event.type) {
+            case NSEventTypeMouseMoved:
+            case NSEventTypeLeftMouseDragged:
+                goGestureEvent(GESTURE_EVENT_MOVE);
+                break;
+            case NSEventTypeScrollWheel:
+                // フェーズのないスクロールはマウスホイールのため、タッチとして扱わない
+                if (event.phase == NSEventPhaseEnded || event.phase == NSEventPhaseCancelled) {
+                    goGestureEvent(GESTURE_EVENT_END);
+                } else if (event.phase != NSEventPhaseNone) {
+                    goGestureEvent(GESTURE_EVENT_MULTI);
+                }
+                break;
+            case NSEventTypeEndGesture:
+                goGestureEvent(GESTURE_EVENT_END);
+                break;
+            default:
+                goGestureEvent(GESTURE_EVENT_MULTI);
+                break;
+            }
+        }];
+        [monitor retain];
+    });
+}
+
+void gesture_monitor_stop(void) {
+    dispatch_async(dispatch_get_main_queue(), ^{
+        if (monitor == nil) {
+            return;
+        }
+        [NSEvent removeMonitor:monitor];
+        [monitor release];
+        monitor = nil;
+    });
+}
+
+void gesture_run(void) {
+    @autoreleasepool {
+        [NSApplication sharedApplication];
+        [NSApp setActivationPolicy:NSApplicationActivationPolicyAccessory];
+        [NSApp run];
+    }
+}
+
+void gesture_stop(void) {
+    dispatch_async(dispatch_get_main_queue(), ^{
+        [NSApp stop:nil];
+        // stop: は次のイベント処理後に効くため、ダミーイベントで RunLoop を起こす
+        NSEvent *event = [NSEvent otherEventWithType:NSEventTypeApplicationDefined
+                                            location:NSZeroPoint
+                                       modifierFlags:0
+                                           timestamp:0
+                                        windowNumber:0
+                                             context:nil
+                                             subtype:0
+                                               data1:0
+                                               data2:0];
+        [NSApp postEvent:event atStart:YES];
+    });
+}
@@ -0,0 +1,91 @@
+// haptic.go: Force Touch トラックパッドの触覚フィードバック。
+// ドラッグ慣性の終了時（保留していた mouseUp の発行時）に軽いクリック感を与え、
+// 「仮想的に押されていたボタン」が離されたことを指で確認できるようにする。
+package main
+
+/*
+#include "multitouch.h"
+*/
+import "C"
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// 触覚フィードバックのパラメータ（MTActuatorActuate の引数）。
+// actuationID 6 は弱いクリック。残りの引数は慣例値。
+const (
+	hapticActuationID = 6
+	hapticUnknown1    = 0
+	hapticUnknown2    = 0.0
+	hapticUnknown3    = 2.0
+)
+
+// hapticFeedback は触覚フィードバック用のアクチュエータを管理する。
+// アクチュエータはデバイスごとに初回使用時に開き、close まで保持する。
+type hapticFeedback struct {
+	mu        sync.Mutex
+	lastDev   MTDeviceRef                // 最後にタッチがあったデバイス
+	lastID    uint64                     // lastDev のデバイス ID
+	actuators map[uint64]C.MTActuatorRef // デバイス ID → 開いたアクチュエータ
+}
+
+func newHapticFeedback() *hapticFeedback {
+	return &hapticFeedback{actuators: make(map[uint64]C.MTActuatorRef)}
+}
+
+// noteDevice はタッチのあったデバイスを記録する。タッチコールバックから呼ばれる。
+// デバイスが変わったときだけデバイス ID を取得する。
+func (h *hapticFeedback) noteDevice(dev MTDeviceRef) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if dev == h.lastDev {
+		return
+	}
+	var id C.uint64_t
+	if C.mt_device_get_device_id(C.MTDeviceRef(dev), &id) != 0 {
+		return
+	}
+	h.lastDev = dev
+	h.lastID = uint64(id)
+}
+
+// tick は最後にタッチのあったデバイスで触覚フィードバックを1回発生させる。
+// Force Touch 非対応のデバイスでは何もしない。
+func (h *hapticFeedback) tick() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.lastDev == nil {
+		return
+	}
+	act, ok := h.actuators[h.lastID]
+	if !ok {
+		act = C.mt_actuator_create_from_device_id(C.uint64_t(h.lastID))
+		if act != 0 && C.mt_actuator_open(act) != 0 {
+			C.CFRelease(C.CFTypeRef(act))
+			act = 0
+		}
+		if act == 0 {
+			fmt.Fprintf(os.Stderr, "[haptic] no actuator for device %#x\n", h.lastID)
+		}
+		// 失敗も記録して、非対応デバイスで毎回生成を試みないようにする
+		h.actuators[h.lastID] = act
+	}
+	if act != 0 {
+		C.mt_actuator_actuate(act, hapticActuationID, hapticUnknown1, hapticUnknown2, hapticUnknown3)
+	}
+}
+
+// close は開いている全アクチュエータを閉じる。
+func (h *hapticFeedback) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for id, act := range h.actuators {
+		if act != 0 {
+			C.mt_actuator_close(act)
+			C.CFRelease(C.CFTypeRef(act))
+		}
+		delete(h.actuators, id)
+	}
+}
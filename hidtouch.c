@@ -0,0 +1,110 @@
+// hidtouch.c: IOHIDEventSystemClient のデジタイザイベントを
+// タッチフレーム（指の本数・重心・時刻）にまとめて Go の goHIDTouchCallback に中継する。
+#include <mach/mach_time.h>
+#include "hidtouch.h"
+#include "_cgo_export.h"
+
+// IOHIDEventTypes.h（プライベート）の定義
+#define kIOHIDEventTypeDigitizer 11
+#define hid_digitizer_field(n) ((kIOHIDEventTypeDigitizer << 16) | (n))
+#define kIOHIDEventFieldDigitizerX hid_digitizer_field(0)
+#define kIOHIDEventFieldDigitizerY hid_digitizer_field(1)
+#define kIOHIDEventFieldDigitizerTouch hid_digitizer_field(9)
+
+// HID Usage Tables: Digitizer ページの TouchPad
+#define kHIDPage_Digitizer 0x0D
+#define kHIDUsage_Dig_TouchPad 0x05
+
+static CFNumberRef cf_int(int v) {
+    return CFNumberCreate(kCFAllocatorDefault, kCFNumberIntType, &v);
+}
+
+IOHIDEventSystemClientRef hid_touch_client_create(void) {
+    IOHIDEventSystemClientRef client = IOHIDEventSystemClientCreate(kCFAllocatorDefault);
+    if (client == NULL) {
+        return NULL;
+    }
+    CFNumberRef page = cf_int(kHIDPage_Digitizer);
+    CFNumberRef usage = cf_int(kHIDUsage_Dig_TouchPad);
+    const void *keys[] = {CFSTR("PrimaryUsagePage"), CFSTR("PrimaryUsage")};
+    const void *values[] = {page, usage};
+    CFDictionaryRef match = CFDictionaryCreate(kCFAllocatorDefault, keys, values, 2,
+                                               &kCFTypeDictionaryKeyCallBacks, &kCFTypeDictionaryValueCallBacks);
+    IOHIDEventSystemClientSetMatching(client, match);
+    CFRelease(match);
+    CFRelease(page);
+    CFRelease(usage);
+    return client;
+}
+
+// property_contains はサービスの文字列プロパティが needle を含むかを返す。
+static int property_contains(IOHIDServiceClientRef service, CFStringRef key, CFStringRef needle) {
+    CFTypeRef value = IOHIDServiceClientCopyProperty(service, key);
+    if (value == NULL) {
+        return 0;
+    }
+    int found = CFGetTypeID(value) == CFStringGetTypeID() &&
+                CFStringFind((CFStringRef)value, needle, kCFCompareCaseInsensitive).location != kCFNotFound;
+    CFRelease(value);
+    return found;
+}
+
+int hid_service_is_virtual(IOHIDServiceClientRef service) {
+    // Karabiner-DriverKit-VirtualHIDDevice は製品名に Karabiner、製造元に pqrs.org を持つ
+    return property_contains(service, CFSTR("Product"), CFSTR("Karabiner")) ||
+           property_contains(service, CFSTR("Manufacturer"), CFSTR("pqrs.org"));
+}
+
+static int ignore_virtual = 0;
+
+void hid_touch_set_ignore_virtual(int ignore) {
+    ignore_virtual = ignore;
+}
+
+// mach_ticks_seconds は mach_absolute_time の値を秒に換算する（clock.go の monotonicSeconds と同じ時間軸）。
+static double mach_ticks_seconds(uint64_t ticks) {
+    static mach_timebase_info_data_t tb;
+    if (tb.denom == 0) {
+        mach_timebase_info(&tb);
+    }
+    return (double)ticks * tb.numer / tb.denom / 1e9;
+}
+
+void bridge_hid_touch_callback(void *target, void *refcon, IOHIDServiceClientRef sender, IOHIDEventRef event) {
+    if (IOHIDEventGetType(event) != kIOHIDEventTypeDigitizer) {
+        return;
+    }
+    if (ignore_virtual) {
+        // 判定はプロパティの取得を伴うため、直前の送信元の結果を使い回す（コールバックは1スレッドから呼ばれる）
+        static uint64_t last_sender = 0;
+        static int last_virtual = 0;
+        uint64_t id = IOHIDEventGetSenderID(event);
+        if (id != last_sender) {
+            last_sender = id;
+            last_virtual = hid_service_is_virtual(sender);
+        }
+        if (last_virtual) {
+            return;
+        }
+    }
+    // 親イベントはデバイス全体、子イベントが指1本ずつを表す
+    int n = 0;
+    double x = 0, y = 0;
+    CFArrayRef children = IOHIDEventGetChildren(event);
+    CFIndex count = children != NULL ? CFArrayGetCount(children) : 0;
+    for (CFIndex i = 0; i < count; i++) {
+        IOHIDEventRef finger = (IOHIDEventRef)CFArrayGetValueAtIndex(children, i);
+        if (IOHIDEventGetIntegerValue(finger, kIOHIDEventFieldDigitizerTouch) == 0) {
+            continue;
+        }
+        n++;
+        x += IOHIDEventGetFloatValue(finger, kIOHIDEventFieldDigitizerX);
+        y += IOHIDEventGetFloatValue(finger, kIOHIDEventFieldDigitizerY);
+    }
+    if (n > 0) {
+        x /= n;
+        // デジタイザの Y は上が原点のため、MultitouchSupport と同じ左下原点に揃える
+        y = 1 - y / n;
+    }
+    goHIDTouchCallback(IOHIDEventGetSenderID(event), n, x, y, mach_ticks_seconds(IOHIDEventGetTimeStamp(event)));
+}
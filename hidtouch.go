@@ -0,0 +1,131 @@
+// hidtouch.go: IOHIDEventSystemClient によるタッチイベントの受信（HID バックエンド）。
+// MultitouchSupport は macOS のリリースごとに動かなくなることがあるプライベートフレームワークのため、
+// HID イベントシステムのデジタイザイベントからタッチフレームを作る代替のバックエンドを用意する。
+// IOHIDEventSystemClient もプライベート API だが、MultitouchSupport とは独立して動く。
+package main
+
+/*
+#cgo LDFLAGS: -framework CoreFoundation -framework IOKit
+#include "hidtouch.h"
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// HIDTouchDevices は IOHIDEventSystemClient でトラックパッドのタッチを受信する。
+// クライアントがデバイスの接続・切断に追従するため、デバイスごとの登録は不要。
+type HIDTouchDevices struct {
+	mu      sync.Mutex
+	client  C.IOHIDEventSystemClientRef
+	runLoop C.CFRunLoopRef
+	done    chan struct{}
+	count   int // 最後に確認したデバイス数（ログ用）
+
+	ignoreVirtual bool // Karabiner-Elements の仮想デバイスを除外するか
+}
+
+// NewHIDTouchDevices はクライアントを作成し、専用 goroutine の RunLoop でイベントの受信を開始する。
+// ignoreVirtual が true なら Karabiner-Elements の仮想デバイスを除外する。
+func NewHIDTouchDevices(ignoreVirtual bool) (*HIDTouchDevices, error) {
+	client := C.hid_touch_client_create()
+	if client == nil {
+		return nil, errors.New("IOHIDEventSystemClientCreate failed")
+	}
+	hd := &HIDTouchDevices{client: client, ignoreVirtual: ignoreVirtual, done: make(chan struct{})}
+	if ignoreVirtual {
+		C.hid_touch_set_ignore_virtual(1)
+	}
+
+	// 専用ゴルーチンで RunLoop を回す（OS スレッドに固定）
+	started := make(chan struct{})
+	go func() {
+		runtime.LockOSThread()
+		rl := C.CFRunLoopGetCurrent()
+		hd.mu.Lock()
+		hd.runLoop = rl
+		hd.mu.Unlock()
+
+		C.IOHIDEventSystemClientScheduleWithRunLoop(client, rl, C.kCFRunLoopDefaultMode)
+		C.IOHIDEventSystemClientRegisterEventCallback(client, C.IOHIDEventSystemClientEventCallback(C.bridge_hid_touch_callback), nil, nil)
+		close(started)
+		C.CFRunLoopRun()
+		C.IOHIDEventSystemClientUnregisterEventCallback(client, C.IOHIDEventSystemClientEventCallback(C.bridge_hid_touch_callback), nil, nil)
+		C.IOHIDEventSystemClientUnscheduleWithRunLoop(client, rl, C.kCFRunLoopDefaultMode)
+		close(hd.done)
+	}()
+	<-started
+
+	return hd, nil
+}
+
+// RefreshDevices はデバイス数を確認してログに出す。受信の登録はクライアントが追従するため何もしない。
+func (hd *HIDTouchDevices) RefreshDevices() {
+	active := hd.Count()
+	hd.mu.Lock()
+	prev := hd.count
+	hd.count = active
+	hd.mu.Unlock()
+	if active != prev {
+		fmt.Printf("Touch devices (HID): %d → %d\n", prev, active)
+	}
+}
+
+// Count は受信対象のデバイス（デジタイザのサービス）の数を返す。
+func (hd *HIDTouchDevices) Count() int {
+	hd.mu.Lock()
+	defer hd.mu.Unlock()
+	if hd.client == nil {
+		return 0
+	}
+	services := C.IOHIDEventSystemClientCopyServices(hd.client)
+	if services == 0 {
+		return 0
+	}
+	defer C.CFRelease(C.CFTypeRef(services))
+	n := int(C.CFArrayGetCount(services))
+	if !hd.ignoreVirtual {
+		return n
+	}
+	count := 0
+	for i := range n {
+		service := C.IOHIDServiceClientRef(C.CFArrayGetValueAtIndex(services, C.CFIndex(i)))
+		if C.hid_service_is_virtual(service) == 0 {
+			count++
+		}
+	}
+	return count
+}
+
+// StopAll は RunLoop を停止してイベントの受信を終了し、クライアントを解放する。
+func (hd *HIDTouchDevices) StopAll() {
+	hd.mu.Lock()
+	rl := hd.runLoop
+	hd.runLoop = 0
+	hd.mu.Unlock()
+
+	if rl == 0 {
+		return
+	}
+	C.CFRunLoopStop(rl)
+	<-hd.done
+
+	hd.mu.Lock()
+	C.CFRelease(C.CFTypeRef(hd.client))
+	hd.client = nil
+	hd.mu.Unlock()
+}
+
+// goHIDTouchCallback は bridge_hid_touch_callback (C) から呼ばれる cgo export 関数。
+// デバイスの識別には HID サービスの送信元 ID を使う。
+//
+//export goHIDTouchCallback
+func goHIDTouchCallback(sender C.uint64_t, fingerCount C.int, padX, padY, timestamp C.double) {
+	if app == nil {
+		return
+	}
+	app.onTouchFrame(uintptr(sender), int(fingerCount), float64(padX), float64(padY), 0, float64(timestamp))
+}
@@ -0,0 +1,40 @@
+// hidtouch.h: IOHIDEventSystemClient によるタッチイベントの受信（プライベート API）。
+#ifndef HIDTOUCH_H
+#define HIDTOUCH_H
+
+#include <CoreFoundation/CoreFoundation.h>
+
+typedef struct __IOHIDEventSystemClient *IOHIDEventSystemClientRef;
+typedef struct __IOHIDEvent *IOHIDEventRef;
+typedef void *IOHIDServiceClientRef;
+typedef void (*IOHIDEventSystemClientEventCallback)(void *target, void *refcon, IOHIDServiceClientRef sender, IOHIDEventRef event);
+
+// IOHIDEventSystemClient / IOHIDEvent extern 宣言
+extern IOHIDEventSystemClientRef IOHIDEventSystemClientCreate(CFAllocatorRef allocator);
+extern void IOHIDEventSystemClientSetMatching(IOHIDEventSystemClientRef client, CFDictionaryRef match);
+extern CFArrayRef IOHIDEventSystemClientCopyServices(IOHIDEventSystemClientRef client);
+extern void IOHIDEventSystemClientScheduleWithRunLoop(IOHIDEventSystemClientRef client, CFRunLoopRef runLoop, CFStringRef mode);
+extern void IOHIDEventSystemClientUnscheduleWithRunLoop(IOHIDEventSystemClientRef client, CFRunLoopRef runLoop, CFStringRef mode);
+extern void IOHIDEventSystemClientRegisterEventCallback(IOHIDEventSystemClientRef client, IOHIDEventSystemClientEventCallback callback, void *target, void *refcon);
+extern void IOHIDEventSystemClientUnregisterEventCallback(IOHIDEventSystemClientRef client, IOHIDEventSystemClientEventCallback callback, void *target, void *refcon);
+extern CFTypeRef IOHIDServiceClientCopyProperty(IOHIDServiceClientRef service, CFStringRef key);
+extern uint32_t IOHIDEventGetType(IOHIDEventRef event);
+extern CFArrayRef IOHIDEventGetChildren(IOHIDEventRef event);
+extern CFIndex IOHIDEventGetIntegerValue(IOHIDEventRef event, uint32_t field);
+extern double IOHIDEventGetFloatValue(IOHIDEventRef event, uint32_t field);
+extern uint64_t IOHIDEventGetTimeStamp(IOHIDEventRef event);
+extern uint64_t IOHIDEventGetSenderID(IOHIDEventRef event);
+
+// デジタイザ（トラックパッド）のサービスだけに絞り込んだクライアントを作成する。失敗すると NULL を返す。
+IOHIDEventSystemClientRef hid_touch_client_create(void);
+
+// サービスが Karabiner-Elements の仮想デバイスなら 1 を返す。
+int hid_service_is_virtual(IOHIDServiceClientRef service);
+
+// 仮想デバイスからのイベントを無視するかを設定する。イベントの受信開始前に呼ぶこと。
+void hid_touch_set_ignore_virtual(int ignore);
+
+// C→Go コールバックブリッジ。デジタイザイベントを指の本数と重心にまとめて goHIDTouchCallback に渡す。
+void bridge_hid_touch_callback(void *target, void *refcon, IOHIDServiceClientRef sender, IOHIDEventRef event);
+
+#endif
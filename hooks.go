@@ -0,0 +1,104 @@
+// hooks.go: コーストのイベントで実行するシェルフック。
+// Hammerspoon や yabai との連携、ログ記録などを、個別に機能を実装せずに
+// ユーザーのシェルコマンドで行えるようにする。
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// フックを実行するイベント名（COASTPAD_EVENT に渡す）
+const (
+	hookCoastStart = "coast-start" // コースト開始時
+	hookCoastEnd   = "coast-end"   // コーストの自然停止時
+	hookDragEnd    = "drag-end"    // ドラッグ慣性の mouseUp 解放時
+)
+
+// shellHooks はイベントごとに実行するシェルコマンドを保持する。空文字は実行しない。
+// 起動時に設定し、以後は変更しない。
+type shellHooks struct {
+	coastStart string
+	coastEnd   string
+	dragEnd    string
+}
+
+// command はイベント名に対応するコマンドを返す。
+func (h shellHooks) command(event string) string {
+	switch event {
+	case hookCoastStart:
+		return h.coastStart
+	case hookCoastEnd:
+		return h.coastEnd
+	case hookDragEnd:
+		return h.dragEnd
+	}
+	return ""
+}
+
+// hookEvent はフックに渡すイベントの内容を表す。
+type hookEvent struct {
+	event    string
+	vx, vy   float64 // 速度 (px/sec)。停止時は 0
+	distance float64 // 現在のコーストの移動距離 (px)
+	x, y     float64 // カーソル（ドラッグ慣性ではドロップ）位置
+	drag     bool    // ドラッグ慣性か
+}
+
+// queueHook はイベントのフックが設定されていれば、現在の状態から hookEvent を作って hooks に追加する。
+// アクター goroutine から呼ぶこと。
+func (a *App) queueHook(hooks *[]hookEvent, event string) {
+	if a.hooks.command(event) == "" {
+		return
+	}
+	*hooks = append(*hooks, hookEvent{
+		event:    event,
+		vx:       a.vx,
+		vy:       a.vy,
+		distance: a.stats.current,
+		x:        a.coastX,
+		y:        a.coastY,
+		drag:     a.dragPhase == dragPhaseCoasting,
+	})
+}
+
+// runHooks はフックのコマンドを /bin/sh で起動する。終了は待たない（コーストを止めないため）。
+// イベントの内容は環境変数 COASTPAD_* で渡す。
+func (a *App) runHooks(hooks []hookEvent) {
+	for _, h := range hooks {
+		cmd := exec.Command("/bin/sh", "-c", a.hooks.command(h.event))
+		drag := "0"
+		if h.drag {
+			drag = "1"
+		}
+		cmd.Env = append(os.Environ(),
+			"COASTPAD_EVENT="+h.event,
+			"COASTPAD_VX="+formatHookFloat(h.vx),
+			"COASTPAD_VY="+formatHookFloat(h.vy),
+			"COASTPAD_SPEED="+formatHookFloat(math.Hypot(h.vx, h.vy)),
+			"COASTPAD_DISTANCE="+formatHookFloat(h.distance),
+			"COASTPAD_X="+formatHookFloat(h.x),
+			"COASTPAD_Y="+formatHookFloat(h.y),
+			"COASTPAD_DRAG="+drag,
+		)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Start(); err != nil {
+			fmt.Fprintf(os.Stderr, "[hook] %s: %v\n", h.event, err)
+			continue
+		}
+		go func(event string) {
+			if err := cmd.Wait(); err != nil {
+				fmt.Fprintf(os.Stderr, "[hook] %s: %v\n", event, err)
+			}
+		}(h.event)
+	}
+}
+
+// formatHookFloat は環境変数に渡す数値を小数点以下1桁で整形する。
+func formatHookFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', 1, 64)
+}
@@ -0,0 +1,169 @@
+// hotedge.go: ホットエッジ。
+// コーストが画面端に勢いよくぶつかったときに、端ごとに設定したアクション
+// （Mission Control、操作スペースの切り替え、通知センターなど）を実行し、強く投げる操作をナビゲーションにする。
+package main
+
+/*
+#cgo LDFLAGS: -framework CoreGraphics -framework Cocoa -framework ApplicationServices
+#include <CoreGraphics/CoreGraphics.h>
+#include <stdlib.h>
+#include "menuextra.h"
+*/
+import "C"
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"unsafe"
+)
+
+// defaultHotEdgeSpeed はホットエッジを発動する、端にぶつかった時点の速さ (px/sec) のデフォルト値。
+const defaultHotEdgeSpeed = 1500.0
+
+// screenEdge は画面（デスクトップ全体）の端を表す。
+type screenEdge int
+
+const (
+	edgeNone screenEdge = iota
+	edgeLeft
+	edgeRight
+	edgeTop
+	edgeBottom
+)
+
+// screenEdgeNames はフラグで使う端の名前。
+var screenEdgeNames = map[string]screenEdge{
+	"left":   edgeLeft,
+	"right":  edgeRight,
+	"top":    edgeTop,
+	"bottom": edgeBottom,
+}
+
+// String は端の名前を返す（edgeNone なら空文字）。
+func (e screenEdge) String() string {
+	for name, edge := range screenEdgeNames {
+		if edge == e {
+			return name
+		}
+	}
+	return ""
+}
+
+// hotEdgeAction はホットエッジで送るキー操作（macOS のデフォルトのショートカット）。
+// デフォルトのショートカットのない操作は、代わりにメニューエクストラを押す。
+type hotEdgeAction struct {
+	keyCode   C.CGKeyCode
+	flags     C.CGEventFlags
+	menuExtra menuExtra // 押すメニューエクストラ（キー操作を送る場合は空）
+}
+
+// menuExtra はメニューバーのステータス項目を、持ち主のアプリと AXIdentifier で表す。
+type menuExtra struct {
+	bundleID, identifier string
+}
+
+// clockMenuExtra はメニューバーの時計。macOS 11 以降は押すと通知センターが開く。
+var clockMenuExtra = menuExtra{"com.apple.controlcenter", "com.apple.menuextra.clock"}
+
+// 矢印キーの仮想キーコード
+const (
+	keyLeftArrow  = 123
+	keyRightArrow = 124
+	keyDownArrow  = 125
+	keyUpArrow    = 126
+)
+
+// hotEdgeActions はホットエッジに割り当てられるアクションの一覧。
+var hotEdgeActions = map[string]hotEdgeAction{
+	"mission-control": {keyCode: keyUpArrow, flags: C.kCGEventFlagMaskControl},    // Ctrl+↑
+	"app-windows":     {keyCode: keyDownArrow, flags: C.kCGEventFlagMaskControl},  // Ctrl+↓（アプリケーションウインドウ）
+	"space-left":      {keyCode: keyLeftArrow, flags: C.kCGEventFlagMaskControl},  // Ctrl+←
+	"space-right":     {keyCode: keyRightArrow, flags: C.kCGEventFlagMaskControl}, // Ctrl+→
+	// 通知センターにはデフォルトのショートカットがないため、時計を押して開く
+	"notification-center": {menuExtra: clockMenuExtra},
+}
+
+// parseHotEdges はフラグの値（例: "left:space-left,right:space-right"）を解析する。
+func parseHotEdges(spec string) (map[screenEdge]string, error) {
+	edges := make(map[screenEdge]string)
+	for _, item := range strings.Split(spec, ",") {
+		edgeName, actionName, ok := strings.Cut(strings.TrimSpace(item), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid hot edge %q (expected edge:action)", item)
+		}
+		edge, ok := screenEdgeNames[edgeName]
+		if !ok {
+			return nil, fmt.Errorf("unknown edge %q (available: left, right, top, bottom)", edgeName)
+		}
+		if _, ok := hotEdgeActions[actionName]; !ok {
+			return nil, fmt.Errorf("unknown hot edge action %q (available: %s)", actionName, strings.Join(hotEdgeActionNames(), ", "))
+		}
+		edges[edge] = actionName
+	}
+	return edges, nil
+}
+
+// hotEdgeActionNames はアクション名をソートして返す。
+func hotEdgeActionNames() []string {
+	names := make([]string, 0, len(hotEdgeActions))
+	for name := range hotEdgeActions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// prepareHotEdge は画面端への衝突がホットエッジの条件を満たせば、実行するアクション名を返す。
+// 1回のコーストにつき1回だけ発動する。
+// アクター goroutine から呼ぶこと。
+func (a *App) prepareHotEdge(edge screenEdge, speed float64) string {
+	if edge == edgeNone || a.hotEdgeFired || speed < a.hotEdgeSpeed {
+		return ""
+	}
+	name, ok := a.hotEdges[edge]
+	if !ok {
+		return ""
+	}
+	a.hotEdgeFired = true
+	return name
+}
+
+// runHotEdgeAction はアクションのキー操作を発行する。
+func runHotEdgeAction(name string) {
+	action, ok := hotEdgeActions[name]
+	if !ok {
+		return
+	}
+	fmt.Printf("[hotedge] %s\n", name)
+	if action.menuExtra != (menuExtra{}) {
+		// アクセシビリティ API はアプリとの IPC を伴うため、コーストのフレームを止めないよう別 goroutine で押す
+		go func() {
+			if !pressMenuExtra(action.menuExtra) {
+				fmt.Fprintf(os.Stderr, "[hotedge] failed to press menu extra %s\n", action.menuExtra.identifier)
+			}
+		}()
+		return
+	}
+	for _, down := range []bool{true, false} {
+		event := C.CGEventCreateKeyboardEvent(0, action.keyCode, C.bool(down))
+		if event == 0 {
+			fmt.Fprintln(os.Stderr, "[hotedge] CGEventCreateKeyboardEvent failed")
+			return
+		}
+		C.CGEventSetFlags(event, action.flags)
+		C.CGEventSetIntegerValueField(event, C.kCGEventSourceUserData, syntheticEventMarker)
+		C.CGEventPost(syntheticPostLocation, event)
+		C.CFRelease(C.CFTypeRef(event))
+	}
+}
+
+// pressMenuExtra はメニューエクストラを押す。押せた場合は true を返す。
+// アクセシビリティ API はアプリとの IPC を伴うため、アクターの外で呼ぶこと。
+func pressMenuExtra(m menuExtra) bool {
+	bundleID := C.CString(m.bundleID)
+	defer C.free(unsafe.Pointer(bundleID))
+	identifier := C.CString(m.identifier)
+	defer C.free(unsafe.Pointer(identifier))
+	return C.press_menu_extra(bundleID, identifier) != 0
+}
@@ -0,0 +1,97 @@
+// hud.go: コースト軌跡オーバーレイ（HUD）の Go 側インターフェース。
+// クランプの確認やパラメータ調整のため、コースト中の現在位置と予測停止点を画面に表示する。
+// Cocoa はメインスレッドでの実行が必要なため、--hud 指定時は
+// メインスレッドで NSApplication の RunLoop を回し、慣性ループは別 goroutine で動かす。
+package main
+
+/*
+#cgo LDFLAGS: -framework Cocoa
+#include "hud.h"
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"runtime"
+)
+
+func init() {
+	// main goroutine をメインスレッドに固定する（Cocoa の要件）
+	runtime.LockOSThread()
+}
+
+// hudFrame はコーストフレームごとの HUD 表示内容を表す。
+type hudFrame struct {
+	x, y         float64 // 現在のコースト位置
+	stopX, stopY float64 // 予測停止点
+	active       bool    // 軌跡を表示するか（false なら消去）
+	update       bool    // このフレームで HUD を更新するか
+}
+
+// runHUDMainLoop はメインスレッドで HUD の RunLoop を回す。stopHUDMainLoop まで戻らない。
+// main goroutine から呼ぶこと。
+func runHUDMainLoop() {
+	C.hud_run()
+}
+
+// stopHUDMainLoop は HUD の RunLoop を停止する。
+func stopHUDMainLoop() {
+	C.hud_stop()
+}
+
+// postHUDFrame は HUD の表示を更新する。
+func postHUDFrame(f hudFrame) {
+	if !f.update {
+		return
+	}
+	active := 0
+	if f.active {
+		active = 1
+	}
+	C.hud_update(C.double(f.x), C.double(f.y), C.double(f.stopX), C.double(f.stopY), C.int(active))
+}
+
+// SetHUDVisible は HUD の表示・非表示を切り替える。
+func (a *App) SetHUDVisible(visible bool) error {
+	if !a.hudEnabled {
+		return errors.New("HUD is not enabled (start with --hud)")
+	}
+	v := 0
+	if visible {
+		v = 1
+	}
+	C.hud_set_visible(C.int(v))
+	return nil
+}
+
+// ctlHUD は制御コマンド `hud on|off` を処理する。
+func ctlHUD(a *App, args []string) (any, error) {
+	if len(args) != 1 || (args[0] != "on" && args[0] != "off") {
+		return nil, fmt.Errorf("usage: hud on|off")
+	}
+	visible := args[0] == "on"
+	if err := a.SetHUDVisible(visible); err != nil {
+		return nil, err
+	}
+	return map[string]bool{"visible": visible}, nil
+}
+
+// currentHUDFrame は現在のコースト状態から HUD 表示内容を作る。
+// HUD が有効でなければ更新なしを返す。コースト終了後は1回だけ消去を返す。
+// アクター goroutine から呼ぶこと。
+func (a *App) currentHUDFrame() hudFrame {
+	if !a.hudEnabled {
+		return hudFrame{}
+	}
+	// 熱で負荷を下げている間（thermal.go）は描画しない
+	if (a.vx == 0 && a.vy == 0) || a.thermalThrottled {
+		if !a.hudShown {
+			return hudFrame{}
+		}
+		a.hudShown = false
+		return hudFrame{update: true}
+	}
+	a.hudShown = true
+	sx, sy := a.predictStop()
+	return hudFrame{x: a.coastX, y: a.coastY, stopX: sx, stopY: sy, active: true, update: true}
+}
@@ -0,0 +1,19 @@
+// hud.h: コースト軌跡を表示するオーバーレイウィンドウ（Cocoa）。
+#ifndef HUD_H
+#define HUD_H
+
+// メインスレッドで NSApplication を起動し、オーバーレイウィンドウを作成して
+// RunLoop を回す。hud_stop が呼ばれるまで戻らない。
+void hud_run(void);
+
+// hud_run の RunLoop を停止する。任意のスレッドから呼べる。
+void hud_stop(void);
+
+// 表示内容を更新する（CG グローバル座標）。active が 0 なら軌跡を消す。
+// 任意のスレッドから呼べる（描画はメインスレッドで行う）。
+void hud_update(double x, double y, double stopX, double stopY, int active);
+
+// オーバーレイウィンドウの表示・非表示を切り替える。任意のスレッドから呼べる。
+void hud_set_visible(int visible);
+
+#endif
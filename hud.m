@@ -0,0 +1,120 @@
+// hud.m: コースト軌跡オーバーレイの Cocoa 実装。
+// 全ディスプレイを覆う透明・クリック透過のウィンドウに、
+// 現在のコースト位置から予測停止点までの線と停止点を描画する。
+#import <Cocoa/Cocoa.h>
+#include "hud.h"
+
+@interface HUDView : NSView
+@property NSPoint from;
+@property NSPoint to;
+@property BOOL active;
+@end
+
+@implementation HUDView
+- (void)drawRect:(NSRect)dirtyRect {
+    [[NSColor clearColor] set];
+    NSRectFill(dirtyRect);
+    if (!self.active) {
+        return;
+    }
+    [[NSColor colorWithRed:1.0 green:0.45 blue:0.0 alpha:0.85] set];
+    NSBezierPath *path = [NSBezierPath bezierPath];
+    [path moveToPoint:self.from];
+    [path lineToPoint:self.to];
+    [path setLineWidth:3.0];
+    [path stroke];
+    NSRect dot = NSMakeRect(self.to.x - 6, self.to.y - 6, 12, 12);
+    [[NSBezierPath bezierPathWithOvalInRect:dot] fill];
+}
+@end
+
+static NSWindow *hudWindow;
+static HUDView *hudView;
+
+// CG グローバル座標（メインディスプレイ左上原点、y 下向き）を
+// ウィンドウ内のビュー座標（左下原点、y 上向き）に変換する。
+static NSPoint hud_to_view(double x, double y) {
+    CGFloat mainHeight = NSMaxY([[NSScreen screens][0] frame]);
+    NSPoint p = NSMakePoint(x, mainHeight - y);
+    NSRect frame = [hudWindow frame];
+    return NSMakePoint(p.x - frame.origin.x, p.y - frame.origin.y);
+}
+
+// 全ディスプレイの和集合を返す。
+static NSRect hud_screens_union(void) {
+    NSRect r = NSZeroRect;
+    for (NSScreen *s in [NSScreen screens]) {
+        r = NSUnionRect(r, [s frame]);
+    }
+    return r;
+}
+
+void hud_run(void) {
+    @autoreleasepool {
+        [NSApplication sharedApplication];
+        [NSApp setActivationPolicy:NSApplicationActivationPolicyAccessory];
+
+        hudWindow = [[NSWindow alloc] initWithContentRect:hud_screens_union()
+                                                styleMask:NSWindowStyleMaskBorderless
+                                                  backing:NSBackingStoreBuffered
+                                                    defer:NO];
+        [hudWindow setOpaque:NO];
+        [hudWindow setBackgroundColor:[NSColor clearColor]];
+        [hudWindow setIgnoresMouseEvents:YES];
+        [hudWindow setHasShadow:NO];
+        [hudWindow setLevel:NSScreenSaverWindowLevel];
+        [hudWindow setCollectionBehavior:NSWindowCollectionBehaviorCanJoinAllSpaces |
+                                         NSWindowCollectionBehaviorStationary |
+                                         NSWindowCollectionBehaviorIgnoresCycle];
+
+        hudView = [[HUDView alloc] initWithFrame:[[hudWindow contentView] bounds]];
+        [hudWindow setContentView:hudView];
+        [hudWindow orderFrontRegardless];
+
+        [NSApp run];
+    }
+}
+
+void hud_stop(void) {
+    dispatch_async(dispatch_get_main_queue(), ^{
+        [NSApp stop:nil];
+        // stop: は次のイベント処理後に効くため、ダミーイベントで RunLoop を起こす
+        NSEvent *event = [NSEvent otherEventWithType:NSEventTypeApplicationDefined
+                                            location:NSZeroPoint
+                                       modifierFlags:0
+                                           timestamp:0
+                                        windowNumber:0
+                                             context:nil
+                                             subtype:0
+                                               data1:0
+                                               data2:0];
+        [NSApp postEvent:event atStart:YES];
+    });
+}
+
+void hud_update(double x, double y, double stopX, double stopY, int active) {
+    dispatch_async(dispatch_get_main_queue(), ^{
+        if (hudView == nil) {
+            return;
+        }
+        if (active) {
+            hudView.from = hud_to_view(x, y);
+            hudView.to = hud_to_view(stopX, stopY);
+        }
+        hudView.active = active != 0;
+        [hudView setNeedsDisplay:YES];
+    });
+}
+
+void hud_set_visible(int visible) {
+    dispatch_async(dispatch_get_main_queue(), ^{
+        if (hudWindow == nil) {
+            return;
+        }
+        if (visible) {
+            [hudWindow orderFrontRegardless];
+        } else {
+            [hudWindow orderOut:nil];
+        }
+    });
+}
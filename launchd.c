@@ -0,0 +1,19 @@
+#include <launch.h>
+#include <stdlib.h>
+#include <unistd.h>
+#include "launchd.h"
+
+int launchd_activate_socket(const char *name) {
+    int *fds = NULL;
+    size_t count = 0;
+    if (launch_activate_socket(name, &fds, &count) != 0 || count == 0) {
+        free(fds);
+        return -1;
+    }
+    int fd = fds[0];
+    for (size_t i = 1; i < count; i++) {
+        close(fds[i]);
+    }
+    free(fds);
+    return fd;
+}
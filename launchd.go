@@ -0,0 +1,163 @@
+// launchd.go: launchd のソケットアクティベーション（`coastpad launchd install`）。
+// LaunchAgent として登録すると launchd が制御ソケットを待ち受け、最初の `coastpad ctl` の接続で
+// coastpad を起動して待ち受け中のソケットを渡す。ログイン時に常駐させなくても、使うときに自動で起動する。
+package main
+
+/*
+#include <stdlib.h>
+#include "launchd.h"
+*/
+import "C"
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"unsafe"
+)
+
+const (
+	// launchdLabel は LaunchAgent のラベル（plist のファイル名にも使う）。
+	launchdLabel = "com.nobmurakita.coastpad"
+	// launchdSocketName はジョブ定義の Sockets での制御ソケットの名前。
+	launchdSocketName = "Control"
+)
+
+// launchdControlListener は launchd から渡された制御ソケットを返す。
+// launchd から起動されていなければ ok=false を返す。
+func launchdControlListener() (ln net.Listener, ok bool) {
+	name := C.CString(launchdSocketName)
+	defer C.free(unsafe.Pointer(name))
+	fd := C.launchd_activate_socket(name)
+	if fd < 0 {
+		return nil, false
+	}
+	f := os.NewFile(uintptr(fd), "launchd-control")
+	defer f.Close()
+	ln, err := net.FileListener(f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[control] invalid launchd socket: %v\n", err)
+		return nil, false
+	}
+	return ln, true
+}
+
+// launchAgentPath は LaunchAgent の plist のパスを返す。
+func launchAgentPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, "Library", "LaunchAgents", launchdLabel+".plist"), nil
+}
+
+// runLaunchdCommand は `coastpad launchd install [flags...]` / `coastpad launchd uninstall` を実行する。
+// flags は launchd が起動する coastpad にそのまま渡す。
+func runLaunchdCommand(args []string) error {
+	usage := errors.New("usage: coastpad launchd install [flags...] | coastpad launchd uninstall")
+	if len(args) == 0 {
+		return usage
+	}
+	switch {
+	case args[0] == "install":
+		return installLaunchAgent(args[1:])
+	case args[0] == "uninstall" && len(args) == 1:
+		return uninstallLaunchAgent()
+	}
+	return usage
+}
+
+// installLaunchAgent は制御ソケットで起動する LaunchAgent を書き出し、launchd に登録する。
+// KeepAlive は指定しないため、`coastpad stop` で止めた後は次の接続まで起動しない。
+func installLaunchAgent(flags []string) error {
+	if pid, alive := readPID(); alive {
+		return fmt.Errorf("coastpad is already running (pid %d), stop it first", pid)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	sockPath, err := defaultControlSocketPath()
+	if err != nil {
+		return err
+	}
+	logPath, err := appDataPath("coastpad.log")
+	if err != nil {
+		return err
+	}
+	plistPath, err := launchAgentPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(sockPath), 0o755); err != nil {
+		return err
+	}
+	// launchd がソケットファイルを作るため、前回の実行で残ったものは消しておく
+	os.Remove(sockPath)
+	if err := os.MkdirAll(filepath.Dir(plistPath), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(plistPath, []byte(launchAgentPlist(exe, flags, sockPath, logPath)), 0o644); err != nil {
+		return err
+	}
+
+	domain := fmt.Sprintf("gui/%d", os.Getuid())
+	// 登録済みなら入れ替える（未登録ならエラーになるが無視する）
+	exec.Command("launchctl", "bootout", domain+"/"+launchdLabel).Run()
+	if out, err := exec.Command("launchctl", "bootstrap", domain, plistPath).CombinedOutput(); err != nil {
+		return fmt.Errorf("launchctl bootstrap: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	fmt.Printf("Installed %s\ncoastpad starts on the first 'coastpad ctl' or 'coastpad status', log: %s\n", plistPath, logPath)
+	return nil
+}
+
+// uninstallLaunchAgent は LaunchAgent の登録を解除し、plist を削除する。
+func uninstallLaunchAgent() error {
+	plistPath, err := launchAgentPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(plistPath); err != nil {
+		return fmt.Errorf("LaunchAgent is not installed: %w", err)
+	}
+	exec.Command("launchctl", "bootout", fmt.Sprintf("gui/%d/%s", os.Getuid(), launchdLabel)).Run()
+	if err := os.Remove(plistPath); err != nil {
+		return err
+	}
+	fmt.Printf("Removed %s\n", plistPath)
+	return nil
+}
+
+// launchAgentPlist は LaunchAgent の plist を作る。
+// 制御ソケットは同一ユーザーのみ接続できるようにする（SockPathMode 0600）。
+func launchAgentPlist(exe string, flags []string, sockPath, logPath string) string {
+	esc := func(s string) string {
+		var b strings.Builder
+		xml.EscapeText(&b, []byte(s))
+		return b.String()
+	}
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+`)
+	fmt.Fprintf(&b, "\t<key>Label</key>\n\t<string>%s</string>\n", launchdLabel)
+	b.WriteString("\t<key>ProgramArguments</key>\n\t<array>\n")
+	for _, arg := range append([]string{exe}, flags...) {
+		fmt.Fprintf(&b, "\t\t<string>%s</string>\n", esc(arg))
+	}
+	b.WriteString("\t</array>\n")
+	fmt.Fprintf(&b, "\t<key>Sockets</key>\n\t<dict>\n\t\t<key>%s</key>\n\t\t<dict>\n", launchdSocketName)
+	fmt.Fprintf(&b, "\t\t\t<key>SockPathName</key>\n\t\t\t<string>%s</string>\n", esc(sockPath))
+	fmt.Fprintf(&b, "\t\t\t<key>SockPathMode</key>\n\t\t\t<integer>%d</integer>\n", 0o600)
+	b.WriteString("\t\t</dict>\n\t</dict>\n")
+	fmt.Fprintf(&b, "\t<key>StandardOutPath</key>\n\t<string>%s</string>\n", esc(logPath))
+	fmt.Fprintf(&b, "\t<key>StandardErrorPath</key>\n\t<string>%s</string>\n", esc(logPath))
+	b.WriteString("</dict>\n</plist>\n")
+	return b.String()
+}
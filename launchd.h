@@ -0,0 +1,9 @@
+// launchd.h: launchd のソケットアクティベーション。
+#ifndef LAUNCHD_H
+#define LAUNCHD_H
+
+// launchd のジョブ定義（Sockets）の name のソケットを受け取り、最初のファイルディスクリプタを返す。
+// launchd から起動されていない・ソケットがなければ -1 を返す。
+int launchd_activate_socket(const char *name);
+
+#endif
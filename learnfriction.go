@@ -0,0 +1,144 @@
+// learnfriction.go: 捕まえ方からの摩擦の学習（--adaptive-friction）。
+// カーソル慣性を再タッチで捕まえる（行き過ぎを止める）ことが多ければ減衰率を少し上げ、
+// ほとんど捕まえずに自然に止まるのに任せていれば少し下げる。
+// 捕まえた割合の移動平均が目標の範囲に収まるように、1回のコーストごとにわずかずつ動かす。
+// 学習した値は終了時に保存し、次の起動時にプリセットの上から適用する。
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// learnAlpha は捕まえた割合の移動平均の重み（1回のコーストの寄与）。
+	learnAlpha = 0.05
+	// learnWarmup は減衰率を動かし始めるまでのコーストの数。
+	learnWarmup = 20
+	// 捕まえた割合の目標の範囲。上回れば摩擦を増やし、下回れば減らす。
+	learnCatchHigh = 0.35
+	learnCatchLow  = 0.15
+	// learnStep は1回のコーストで減衰率に掛ける（割る）倍率。
+	learnStep = 1.01
+)
+
+// learnedFriction は学習した減衰率と、その根拠の統計を表す。
+type learnedFriction struct {
+	DecayRate float64 `json:"decay_rate"` // 学習した減衰率（0 なら未学習）
+	CatchRate float64 `json:"catch_rate"` // 再タッチで捕まえたコーストの割合の移動平均
+	Coasts    int     `json:"coasts"`     // 学習に使ったコーストの数
+}
+
+// defaultLearnedFrictionPath は学習した摩擦を保存するファイルのパスを返す。
+func defaultLearnedFrictionPath() (string, error) {
+	return appDataPath("learned-friction.json")
+}
+
+// loadLearnedFriction は学習した摩擦を読み込む。ファイルが存在しない場合はゼロ値を返す。
+func loadLearnedFriction(path string) (learnedFriction, error) {
+	var l learnedFriction
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return l, err
+	}
+	if err := json.Unmarshal(data, &l); err != nil {
+		return l, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return l, nil
+}
+
+// saveLearnedFriction は学習した摩擦を保存する。
+func saveLearnedFriction(path string, l learnedFriction) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// applyLearnedFriction は学習した減衰率を適用する。Open 前に呼ぶこと。
+func (a *App) applyLearnedFriction(l learnedFriction) {
+	a.learned = l
+	if l.DecayRate <= 0 {
+		return
+	}
+	r := paramRanges["decay_rate"]
+	a.params.DecayRate = math.Max(r.min, math.Min(l.DecayRate, r.max))
+	a.preset = ""
+	fmt.Printf("[learn] decay_rate %.2f (learned from %d coasts)\n", a.params.DecayRate, l.Coasts)
+}
+
+// learnFriction は終わったコーストの止まり方を学習に加え、必要なら減衰率を1段階動かす。
+// ドラッグ慣性は掴み直しのための再タッチが多いため、カーソル慣性だけを使う。
+// マウスダウン等で打ち切られたコーストは止まり方が分からないため使わない。
+// アクター goroutine から呼ぶこと。
+func (a *App) learnFriction(r coastRecord) {
+	if !a.adaptiveFriction || r.Drag {
+		return
+	}
+	var caught float64
+	switch r.End {
+	case coastEndCaught:
+		caught = 1
+	case coastEndDecayed, coastEndClamped:
+	default:
+		return
+	}
+	l := &a.learned
+	if l.Coasts == 0 {
+		l.CatchRate = caught
+	} else {
+		l.CatchRate += learnAlpha * (caught - l.CatchRate)
+	}
+	l.Coasts++
+	if l.Coasts < learnWarmup {
+		return
+	}
+
+	decay := a.params.DecayRate
+	switch {
+	case l.CatchRate > learnCatchHigh:
+		decay *= learnStep
+	case l.CatchRate < learnCatchLow:
+		decay /= learnStep
+	default:
+		return
+	}
+	rng := paramRanges["decay_rate"]
+	decay = math.Max(rng.min, math.Min(decay, rng.max))
+	if decay == a.params.DecayRate {
+		return
+	}
+	a.params.DecayRate = decay
+	a.preset = ""
+	l.DecayRate = decay
+}
+
+// saveLearnedFriction は学習した摩擦をデフォルトのファイルへ保存する。
+// アクターの終了後に呼ぶこと。
+func (a *App) saveLearnedFriction() error {
+	if a.learned.Coasts == 0 {
+		return nil
+	}
+	path, err := defaultLearnedFrictionPath()
+	if err != nil {
+		return err
+	}
+	if err := saveLearnedFriction(path, a.learned); err != nil {
+		return err
+	}
+	fmt.Printf("[learn] catch rate %.0f%% over %d coasts, decay_rate %.2f saved\n",
+		a.learned.CatchRate*100, a.learned.Coasts, a.params.DecayRate)
+	return nil
+}
@@ -3,22 +3,356 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 )
 
 var app *App
 
+// subcommands はサブコマンドの一覧。サブコマンドなし（フラグのみ）の場合はフォアグラウンドで実行する。
+var subcommands = map[string]func(args []string) error{
+	"stats":           func([]string) error { return runStatsCommand() },
+	"start":           runStartCommand,
+	"stop":            func([]string) error { return runStopCommand() },
+	"status":          func([]string) error { return runStatusCommand() },
+	"ctl":             runCtlCommand,
+	"config":          runConfigCommand,
+	"replay-snapshot": runReplaySnapshotCommand,
+	"selftest":        runSelftestCommand,
+	"version":         runVersionCommand,
+	"mouseup-guard":   runMouseUpGuardCommand,
+	"tap-helper":      runTapHelperCommand,
+	"launchd":         runLaunchdCommand,
+	"doctor":          runDoctorCommand,
+}
+
 func main() {
+	// サブコマンドの振り分け
+	if len(os.Args) > 1 {
+		if cmd, ok := subcommands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	saveStatsFlag := flag.Bool("save-stats", false, "accumulate usage stats into the stats file on exit")
+	pprofAddr := flag.String("pprof", "", "start a net/http/pprof server on the given address (e.g. :6060)")
+	presetFlag := flag.String("preset", defaultPresetName, "friction preset ("+strings.Join(presetNames(), ", ")+")")
+	tuneAddr := flag.String("tune-ui", "", "serve the live tuning web UI on the given loopback address (e.g. 127.0.0.1:7070)")
+	abSpec := flag.String("ab", "", "A/B comparison mode: two presets to switch between with 'coastpad ctl flip' (e.g. ice,carpet)")
+	hudFlag := flag.Bool("hud", false, "show an overlay with the coast vector and predicted stop point (toggle with 'coastpad ctl hud on|off')")
+	hapticFlag := flag.Bool("haptic", false, "haptic tick on Force Touch trackpads when a drag coast releases the mouse button")
+	soundStart := flag.String("sound-start", "", "system sound to play when a coast starts (e.g. Tink)")
+	soundEnd := flag.String("sound-end", "", "system sound to play when a coast comes to rest (e.g. Pop)")
+	soundDrag := flag.String("sound-drag-release", "", "system sound to play when a drag coast releases the mouse button (e.g. Bottle)")
+	hookStart := flag.String("hook-coast-start", "", "shell command to run when a coast starts (details in COASTPAD_* environment variables)")
+	hookEnd := flag.String("hook-coast-end", "", "shell command to run when a coast comes to rest")
+	hookDrag := flag.String("hook-drag-end", "", "shell command to run when a drag coast releases the mouse button")
+	releaseFilterCmd := flag.String("release-filter", "", "resident script that can adjust or cancel each coast at release (JSON lines on stdin/stdout)")
+	smoothScrollFlag := flag.Bool("smooth-scroll", false, "turn discrete mouse wheel clicks into smooth, decaying pixel scrolling")
+	touchBackend := flag.String("touch-backend", touchBackendAuto, "where to read touches from: "+strings.Join(touchBackendNames, ", ")+" (auto falls back to hid, then nsevent, when MultitouchSupport doesn't work)")
+	karabinerFlag := flag.Bool("karabiner", false, "Karabiner-Elements compatibility: tap button events after other taps, post synthetic events at the session level and ignore Karabiner's virtual devices")
+	tapLocation := flag.String("tap-location", tapLocationSession, "where to insert the event tap: session, hid (before session-level taps) or annotated (after events are routed to an app); see `coastpad doctor`")
+	tapOptions := flag.String("tap-options", tapOptionsAuto, "event tap options: auto, default (always filter) or listen-only (only with --mode cursor and no --smooth-scroll)")
+	tapHelperFlag := flag.Bool("tap-helper", false, "run the event tap in a separate helper process that holds a thrown drag's mouse-up and releases it if coastpad crashes")
+	conservativeFlag := flag.Bool("conservative", false, "coexist with other event-tap utilities (BetterTouchTool, Mos, ...): watch mouse-downs with a listen-only tap and mark all synthetic events")
+	modeFlag := flag.String("mode", "both", "what to coast: both, cursor (pointer only) or drag (dragged windows only)")
+	pendingUpPolicy := flag.String("pending-mouseup", pendingUpDiscard, "what to do with the held mouse-up when a new click arrives while following a thrown drag: discard, or post it before the click")
+	pendingTimeout := flag.Duration("pending-timeout", defaultPendingDecisionTimeout, "end a thrown drag when a single finger rests on the pad this long (0 disables)")
+	edgeOnly := flag.Bool("edge-only", false, "only coast when the finger lifts near an edge of the trackpad")
+	ignoreOtherDevices := flag.Bool("ignore-other-devices", false, "while coasting, ignore touches on trackpads other than the one that started the coast")
+	edgeMargin := flag.Float64("edge-margin", 0.1, "distance from the trackpad edge counted as 'near' for --edge-only (fraction of pad size)")
+	snapFlag := flag.Bool("snap", false, "experimental: bend the end of a coast onto the nearest clickable control")
+	barAssistFlag := flag.Bool("bar-assist", false, "slow coasts down over the menu bar and Dock so the cursor stops on them")
+	hotEdgeSpec := flag.String("hot-edges", "", "actions to run when a coast hits a screen edge hard, as edge:action pairs (e.g. left:space-left,right:space-right; actions: "+strings.Join(hotEdgeActionNames(), ", ")+")")
+	hotEdgeSpeed := flag.Float64("hot-edge-speed", defaultHotEdgeSpeed, "minimum speed (px/sec) at the edge that triggers a hot edge action")
+	dragSpaceDwell := flag.Duration("drag-space-dwell", 0, "hold a thrown window at the left/right screen edge this long, then switch Spaces with it (0 disables)")
+	springDwell := flag.Duration("spring-dwell", 0, "when a drag coast stops on a folder or Dock item, keep the drag alive this long so spring-loading opens it (0 disables)")
+	typingSuppress := flag.Duration("typing-suppress", 0, "don't start cursor coasts for this long after a key press, so brushing the trackpad while typing doesn't launch the cursor (0 disables)")
+	gameDetect := flag.Bool("game-detect", true, "suspend while a full-screen game is frontmost or a display is captured")
+	remoteDetect := flag.Bool("remote-detect", true, "suspend while the screen is shared and controlled remotely (Screen Sharing, Remote Desktop), resuming when the trackpad is touched locally")
+	mcDetect := flag.Bool("mission-control-detect", true, "suspend while Mission Control or App Exposé is shown")
+	focusSpec := flag.String("focus", "", "per-Focus settings as name:setting pairs, settings joined with + (e.g. Presentation:cursor,Gaming:off,Work:carpet; settings: off, cursor, drag or a preset)")
+	stageManagerFlag := flag.Bool("stage-manager", false, "while Stage Manager is on, shorten drag coasts and stop fast thrown windows before the strip so drops there aren't cancelled")
+	dropSettle := flag.Duration("drop-settle", 0, "after a drag coast stops, hold the drop until the cursor has been still this long (0 disables)")
+	safeDropFlag := flag.Bool("safe-drop", false, "when a drag coast stops on the Trash, hold the drop until it is confirmed with a tap")
+	clickThroughFlag := flag.Bool("click-through", false, "a tap while the cursor is coasting clicks where the cursor was thrown (needs mouse buttons, not --mode cursor)")
+	deadTime := flag.Duration("dead-time", 0, "ignore clicks for this long after a fast cursor coast stops, unless the finger moves first (e.g. 80ms; 0 disables; needs mouse buttons, not --mode cursor)")
+	loopInterval := flag.Duration("loop-interval", defaultLoopInterval, "interval of coast frames (e.g. 8ms for 120Hz displays, 33ms to save battery; "+minLoopInterval.String()+" to "+maxLoopInterval.String()+")")
+	thermalAdapt := flag.Bool("thermal-adapt", true, "under serious or critical thermal pressure, lower the loop rate and skip the HUD")
+	batterySaver := flag.Int("battery-saver", 0, "on battery below this percentage, lengthen the loop interval and stop the frame timer while idle (0 disables)")
+	batteryInterval := flag.Duration("battery-loop-interval", defaultPowerSaveInterval, "loop interval used by --battery-saver ("+minLoopInterval.String()+" to "+maxLoopInterval.String()+")")
+	curveGain := flag.Float64("curve", 0, "let coasts continue the arc the finger was drawing at release: 0 keeps them straight, 1 continues the measured turn rate")
+	quantizeDirections := flag.Int("quantize-direction", 0, "snap the coast direction to the nearest of 4 or 8 compass directions when the release is within --quantize-tolerance of it (0 disables)")
+	quantizeTolerance := flag.Float64("quantize-tolerance", defaultDirectionTolerance, "how close (degrees) the release direction must be to a compass direction for --quantize-direction")
+	flickBoost := flag.Float64("flick-boost", 0, "speed bonus for a flick in roughly the same direction right after the previous coast (e.g. 0.3 for +30%; 0 disables)")
+	flickBoostWindow := flag.Duration("flick-boost-window", defaultFlickBoostWindow, "how long after the previous coast's last frame a flick counts as successive for --flick-boost")
+	dragExcludeSpec := flag.String("drag-exclude", "", "windows whose drags never coast, by title pattern or accessibility role of the window under the cursor at mouse-down (e.g. role:AXSheet,title:Colors*)")
+	dragTitlebarOnly := flag.Bool("drag-titlebar-only", false, "only coast drags that start on a window's title bar (window throwing), leaving all other drags and their mouse-ups alone")
+	textDragCoast := flag.Bool("text-drag-coast", false, "also coast drags that select text (by default they never coast, since momentum selects too much)")
+	flickIntent := flag.Float64("flick-intent", 0, "only coast when a release scores at least this (0-1, e.g. 0.5) on speed, contact time, path straightness and contact size, so lift-off wobble doesn't coast; each decision is logged (0 disables)")
+	brakeFingers := flag.Int("brake-fingers", 0, "touching the pad with this many fingers (3-5) during a coast stops it at once and drops a thrown drag where it is (0 disables)")
+	shakeGuardFlag := flag.Bool("shake-guard", false, "don't start a coast that reverses direction again right after two quick back-and-forth coasts, so catch-and-rethrow doesn't trigger 'shake mouse pointer to locate'")
+	largeCursorFlag := flag.Bool("large-cursor", false, "with an enlarged accessibility pointer, coast slower and shorter in proportion to the pointer size")
+	trackingSpeedRef := flag.Float64("normalize-tracking-speed", 0, "scale release velocity as if the system trackpad tracking speed were this value (0-3, e.g. 1), so coasts feel the same at any tracking speed (0 disables)")
+	fingerGainSpec := flag.String("finger-gain", "", "coast speed multiplier by the number of fingers on the pad just before release, as fingers:gain pairs (e.g. 3:1.5)")
+	swipeFrictionFlag := flag.Bool("swipe-friction", false, "a four-finger swipe right/left raises/lowers decay_rate one step (set the system's full-screen app swipe to three fingers)")
+	frictionZonesPath := flag.String("friction-zones", "", "JSON file of screen rectangles with friction multipliers applied while the coast is inside them (e.g. more friction over the Dock)")
+	notifyFlag := flag.Bool("notify", true, "post a Notification Center alert when the event tap can't be restarted or all trackpads disappear")
+	mouseUpGuardFlag := flag.Bool("mouseup-guard", false, "run a second, long-lived helper process that releases the mouse button if coastpad is killed or crashes while holding a thrown drag (not in --mode cursor)")
+	adaptiveFriction := flag.Bool("adaptive-friction", false, "learn from how often you catch coasts and slowly adjust decay_rate toward fewer catches, keeping the learned value across restarts")
+	coastLogPath := flag.String("coast-log", "", "append a record per coast (release velocity, duration, distance, end reason) to this file; CSV if it ends in .csv, JSON lines otherwise")
+	flag.Parse()
+
+	mode, err := parseCoastMode(*modeFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := checkTapPlacement(*tapLocation, *tapOptions, mode, *smoothScrollFlag); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *pendingUpPolicy != pendingUpDiscard && *pendingUpPolicy != pendingUpPost {
+		fmt.Fprintf(os.Stderr, "Error: invalid --pending-mouseup %q (want discard or post)\n", *pendingUpPolicy)
+		os.Exit(1)
+	}
+	if err := checkLoopInterval(*loopInterval); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := checkLoopInterval(*batteryInterval); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --battery-loop-interval: %v\n", err)
+		os.Exit(1)
+	}
+	if *batterySaver < 0 || *batterySaver > 100 {
+		fmt.Fprintln(os.Stderr, "Error: --battery-saver must be between 0 and 100")
+		os.Exit(1)
+	}
+	if *curveGain < 0 {
+		fmt.Fprintln(os.Stderr, "Error: --curve must be >= 0")
+		os.Exit(1)
+	}
+	if *trackingSpeedRef < 0 || *trackingSpeedRef > 3 {
+		fmt.Fprintln(os.Stderr, "Error: --normalize-tracking-speed must be between 0 and 3")
+		os.Exit(1)
+	}
+	if *edgeMargin <= 0 || *edgeMargin >= 0.5 {
+		fmt.Fprintln(os.Stderr, "Error: --edge-margin must be greater than 0 and less than 0.5")
+		os.Exit(1)
+	}
+	if *flickIntent < 0 || *flickIntent > 1 {
+		fmt.Fprintln(os.Stderr, "Error: --flick-intent must be between 0 and 1")
+		os.Exit(1)
+	}
+	if *brakeFingers != 0 && (*brakeFingers < 3 || *brakeFingers > 5) {
+		fmt.Fprintln(os.Stderr, "Error: --brake-fingers must be 0 or between 3 and 5")
+		os.Exit(1)
+	}
+	if *flickBoost < 0 {
+		fmt.Fprintln(os.Stderr, "Error: --flick-boost must be >= 0")
+		os.Exit(1)
+	}
+	if err := checkDirectionQuantize(*quantizeDirections, *quantizeTolerance); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	var hotEdges map[screenEdge]string
+	if *hotEdgeSpec != "" {
+		if hotEdges, err = parseHotEdges(*hotEdgeSpec); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	var fingerGains map[int]float64
+	if *fingerGainSpec != "" {
+		if fingerGains, err = parseFingerGains(*fingerGainSpec); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	var dragExclusions []dragExclusion
+	if *dragExcludeSpec != "" {
+		if dragExclusions, err = parseDragExclusions(*dragExcludeSpec); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	var focusProfiles map[string]focusSetting
+	if *focusSpec != "" {
+		if focusProfiles, err = parseFocusProfiles(*focusSpec); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *pprofAddr != "" {
+		if err := startPprofServer(*pprofAddr); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to start pprof server: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	removePID, err := writePIDFile()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer removePID()
+
+	reportMultitouchCaps()
 	app = NewApp()
+	app.hudEnabled = *hudFlag
+	if *hapticFlag {
+		if multitouchCaps().haptics {
+			app.haptics = newHapticFeedback()
+		} else {
+			fmt.Fprintln(os.Stderr, "[haptic] the actuator API is unavailable on this macOS, --haptic is ignored")
+		}
+	}
+	app.mode = mode
+	app.loopInterval = *loopInterval
+	app.batterySaverBelow = *batterySaver
+	app.thermalAdapt = *thermalAdapt
+	app.powerSaveInterval = *batteryInterval
+	app.curveGain = *curveGain
+	app.quantizeDirections = *quantizeDirections
+	app.flickBoost = *flickBoost
+	app.fingerGains = fingerGains
+	app.trackingSpeedRef = *trackingSpeedRef
+	app.largeCursor = *largeCursorFlag
+	app.shakeGuard = *shakeGuardFlag
+	app.brakeFingers = *brakeFingers
+	app.flickIntent = *flickIntent
+	app.dragExclusions = dragExclusions
+	app.textDragCoast = *textDragCoast
+	app.dragTitlebarOnly = *dragTitlebarOnly
+	app.swipeFriction = *swipeFrictionFlag
+	app.flickBoostWindow = *flickBoostWindow
+	app.quantizeTolerance = *quantizeTolerance
+	app.touchBackend = *touchBackend
+	app.useTapHelper = *tapHelperFlag
+	app.alerts.enabled = *notifyFlag
+	if *karabinerFlag {
+		app.karabinerCompat = true
+		postAtSessionLevel()
+		relativeCursorMoves = false
+	}
+	app.tapLocation = *tapLocation
+	app.tapOptions = *tapOptions
+	if *conservativeFlag {
+		app.conservativeTap = true
+		annotateSynthetic = true
+		relativeCursorMoves = false
+	}
+	warnTapConflicts(*conservativeFlag)
+	app.pendingTimeout = pendingTimeout.Seconds()
+	app.pendingUpPolicy = *pendingUpPolicy
+	app.edgeOnly = *edgeOnly
+	app.edgeMargin = *edgeMargin
+	app.ignoreOtherDevices = *ignoreOtherDevices
+	app.smoothScroll = *smoothScrollFlag
+	app.snapEnabled = *snapFlag
+	app.barAssist = *barAssistFlag
+	app.hotEdges = hotEdges
+	app.hotEdgeSpeed = *hotEdgeSpeed
+	app.dragSpaceDwell = dragSpaceDwell.Seconds()
+	app.springDwell = springDwell.Seconds()
+	app.safeDrop = *safeDropFlag
+	app.dropSettle = dropSettle.Seconds()
+	app.stageManagerCompat = *stageManagerFlag
+	app.clickThrough = *clickThroughFlag
+	if app.clickThrough && mode == modeCursor {
+		fmt.Fprintln(os.Stderr, "[click-through] ignored with --mode cursor (mouse buttons are not intercepted)")
+		app.clickThrough = false
+	}
+	if app.clickThrough && app.conservativeTap {
+		fmt.Fprintln(os.Stderr, "[click-through] ignored with --conservative (mouse-downs are only listened to)")
+		app.clickThrough = false
+	}
+	app.deadTime = deadTime.Seconds()
+	if app.deadTime > 0 && (mode == modeCursor || app.conservativeTap) {
+		fmt.Fprintln(os.Stderr, "[dead-time] ignored with --mode cursor or --conservative (mouse-downs can't be swallowed)")
+		app.deadTime = 0
+	}
+	app.typingSuppress = typingSuppress.Seconds()
+	app.gameDetect = *gameDetect
+	app.mcDetect = *mcDetect
+	app.remoteDetect = *remoteDetect
+	app.focusProfiles = focusProfiles
+	app.sounds = soundFeedback{coastStart: *soundStart, coastEnd: *soundEnd, dragRelease: *soundDrag}
+	app.hooks = shellHooks{coastStart: *hookStart, coastEnd: *hookEnd, dragEnd: *hookDrag}
+	if *frictionZonesPath != "" {
+		if app.frictionZones, err = loadFrictionZones(*frictionZonesPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			removePID()
+			os.Exit(1)
+		}
+	}
+	if *coastLogPath != "" {
+		if app.coastLog, err = openCoastLog(*coastLogPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to open coast log: %v\n", err)
+			removePID()
+			os.Exit(1)
+		}
+	}
+	// --tap-helper では補助プロセスが保留中のマウスアップを持つため、ガードは要らない
+	if *mouseUpGuardFlag && mode != modeCursor && !app.useTapHelper {
+		if app.mouseUpGuard, err = startMouseUpGuard(); err != nil {
+			fmt.Fprintf(os.Stderr, "[guard] failed to start the mouse-up guard: %v\n", err)
+		}
+	}
+	if *releaseFilterCmd != "" {
+		if app.releaseFilter, err = startReleaseFilter(*releaseFilterCmd); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to start release filter: %v\n", err)
+			removePID()
+			os.Exit(1)
+		}
+	}
+	if err := app.ApplyPreset(*presetFlag); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		removePID()
+		os.Exit(1)
+	}
+	if *adaptiveFriction {
+		app.adaptiveFriction = true
+		if path, err := defaultLearnedFrictionPath(); err == nil {
+			l, err := loadLearnedFriction(path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "[learn] %v, starting over\n", err)
+			}
+			app.applyLearnedFriction(l)
+		}
+	}
+	if *abSpec != "" {
+		sets, err := parseABPresets(*abSpec)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			removePID()
+			os.Exit(1)
+		}
+		app.EnableAB(sets)
+	}
 
 	if err := app.Open(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		removePID()
 		os.Exit(1)
 	}
 
+	if *tuneAddr != "" {
+		if err := startTuneUI(app, *tuneAddr); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to start tuning UI: %v\n", err)
+		}
+	}
+
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
@@ -28,5 +362,37 @@ func main() {
 	}()
 
 	fmt.Println("CoastPad started. Press Ctrl+C to stop.")
-	app.Run()
+	if *hudFlag || app.needsMainLoop() {
+		// Cocoa の RunLoop はメインスレッドで回す必要があるため、慣性ループを別 goroutine で動かす。
+		// HUD の RunLoop はグローバルモニタのイベントも処理するため、HUD があればそちらを使う
+		runMainLoop, stopMainLoop := runHUDMainLoop, stopHUDMainLoop
+		if !*hudFlag {
+			runMainLoop, stopMainLoop = runGestureMainLoop, stopGestureMainLoop
+		}
+		runDone := make(chan struct{})
+		go func() {
+			app.Run()
+			stopMainLoop()
+			close(runDone)
+		}()
+		runMainLoop()
+		<-runDone
+	} else {
+		app.Run()
+	}
+
+	stats := app.Stats()
+	fmt.Println("Session stats:")
+	stats.print(os.Stdout)
+	if *saveStatsFlag {
+		if err := persistStats(stats); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to save stats: %v\n", err)
+		}
+	}
+	if app.adaptiveFriction {
+		// アクターは終了しているため、直接読める
+		if err := app.saveLearnedFriction(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: failed to save learned friction: %v\n", err)
+		}
+	}
 }
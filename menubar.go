@@ -0,0 +1,22 @@
+// menubar.go: メニューバーの項目の並べ替えでのドラッグ慣性の無効化。
+// メニューバーの右側の項目（メニューエクストラ）は Command を押したままドラッグして並べ替えるが、
+// 慣性を付けると項目が遠くへ飛んでしまう。メニューバーの上で Command を押したまま始めたドラッグでは、
+// そのドラッグに限ってドラッグ慣性を付けない（除外したウインドウのドラッグと同じ扱い。dragexclude.go）。
+package main
+
+/*
+#cgo LDFLAGS: -framework Cocoa
+#include <CoreGraphics/CoreGraphics.h>
+#include "bars.h"
+*/
+import "C"
+
+// isMenuBarReorder はマウスダウンがメニューバーの項目の並べ替え（Command を押したままのドラッグ）の
+// 始まりかを返す。Command を押していなければ画面を調べない。
+// EventTap コールバックから呼ぶ。
+func isMenuBarReorder(attrs dragAttrs, x, y float64) bool {
+	if attrs.flags&uint64(C.kCGEventFlagMaskCommand) == 0 {
+		return false
+	}
+	return C.menu_bar_contains(C.double(x), C.double(y)) != 0
+}
@@ -0,0 +1,9 @@
+// menuextra.h: メニューバーのステータス項目（メニューエクストラ）の操作（アクセシビリティ API）。
+#ifndef MENUEXTRA_H
+#define MENUEXTRA_H
+
+// bundle_id のアプリのメニューエクストラのうち、AXIdentifier が identifier のものを押す。
+// 押せた場合は 1 を返す。
+int press_menu_extra(const char *bundle_id, const char *identifier);
+
+#endif
@@ -0,0 +1,48 @@
+// menuextra.m: メニューバーのステータス項目（メニューエクストラ）の操作（アクセシビリティ API）。
+#import <Cocoa/Cocoa.h>
+#include "menuextra.h"
+
+// 応答しないアプリでコーストが止まらないよう、問い合わせのタイムアウトを短くする（秒）
+#define MENU_EXTRA_MESSAGING_TIMEOUT 0.2
+
+int press_menu_extra(const char *bundle_id, const char *identifier) {
+    @autoreleasepool {
+        NSArray<NSRunningApplication *> *apps =
+            [NSRunningApplication runningApplicationsWithBundleIdentifier:@(bundle_id)];
+        if (apps.count == 0) {
+            return 0;
+        }
+        AXUIElementRef app = AXUIElementCreateApplication(apps[0].processIdentifier);
+        if (app == NULL) {
+            return 0;
+        }
+        AXUIElementSetMessagingTimeout(app, MENU_EXTRA_MESSAGING_TIMEOUT);
+
+        int pressed = 0;
+        CFTypeRef bar = NULL;
+        if (AXUIElementCopyAttributeValue(app, CFSTR("AXExtrasMenuBar"), &bar) == kAXErrorSuccess && bar != NULL) {
+            CFArrayRef items = NULL;
+            if (AXUIElementCopyAttributeValue((AXUIElementRef)bar, kAXChildrenAttribute, (CFTypeRef *)&items) ==
+                    kAXErrorSuccess &&
+                items != NULL) {
+                CFStringRef want = (__bridge CFStringRef)@(identifier);
+                for (CFIndex i = 0; i < CFArrayGetCount(items) && !pressed; i++) {
+                    AXUIElementRef item = (AXUIElementRef)CFArrayGetValueAtIndex(items, i);
+                    CFTypeRef ident = NULL;
+                    if (AXUIElementCopyAttributeValue(item, kAXIdentifierAttribute, &ident) != kAXErrorSuccess ||
+                        ident == NULL) {
+                        continue;
+                    }
+                    if (CFEqual(ident, want)) {
+                        pressed = AXUIElementPerformAction(item, kAXPressAction) == kAXErrorSuccess;
+                    }
+                    CFRelease(ident);
+                }
+                CFRelease(items);
+            }
+            CFRelease(bar);
+        }
+        CFRelease(app);
+        return pressed;
+    }
+}
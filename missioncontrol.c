@@ -0,0 +1,51 @@
+// missioncontrol.c: Mission Control・App Exposé の表示中かを判定する。
+// 公開 API がないため、表示中に Dock が画面全体を覆うウインドウを専用のレイヤーに出すことを利用する。
+#include <ApplicationServices/ApplicationServices.h>
+#include "missioncontrol.h"
+
+// Mission Control・App Exposé の表示中に Dock が出すウインドウのレイヤー。
+// Dock 自体（レイヤー 20）や Launchpad とは異なる。
+#define MISSION_CONTROL_WINDOW_LAYER 18
+
+// covers_display はウインドウの矩形がいずれかのディスプレイ全体を覆っていれば 1 を返す。
+static int covers_display(CFDictionaryRef bounds) {
+    CGRect rect;
+    if (bounds == NULL || !CGRectMakeWithDictionaryRepresentation(bounds, &rect)) {
+        return 0;
+    }
+    CGDirectDisplayID displays[16];
+    uint32_t count = 0;
+    if (CGGetActiveDisplayList(16, displays, &count) != kCGErrorSuccess) {
+        return 0;
+    }
+    for (uint32_t i = 0; i < count; i++) {
+        if (CGRectContainsRect(rect, CGDisplayBounds(displays[i]))) {
+            return 1;
+        }
+    }
+    return 0;
+}
+
+int mission_control_is_active(void) {
+    CFArrayRef windows = CGWindowListCopyWindowInfo(kCGWindowListOptionOnScreenOnly | kCGWindowListExcludeDesktopElements, kCGNullWindowID);
+    if (windows == NULL) {
+        return 0;
+    }
+    int active = 0;
+    CFIndex n = CFArrayGetCount(windows);
+    for (CFIndex i = 0; i < n && !active; i++) {
+        CFDictionaryRef w = CFArrayGetValueAtIndex(windows, i);
+        CFStringRef owner = CFDictionaryGetValue(w, kCGWindowOwnerName);
+        if (owner == NULL || CFStringCompare(owner, CFSTR("Dock"), 0) != kCFCompareEqualTo) {
+            continue;
+        }
+        CFNumberRef layerRef = CFDictionaryGetValue(w, kCGWindowLayer);
+        int layer = 0;
+        if (layerRef == NULL || !CFNumberGetValue(layerRef, kCFNumberIntType, &layer) || layer != MISSION_CONTROL_WINDOW_LAYER) {
+            continue;
+        }
+        active = covers_display(CFDictionaryGetValue(w, kCGWindowBounds));
+    }
+    CFRelease(windows);
+    return active;
+}
@@ -0,0 +1,55 @@
+// missioncontrol.go: Mission Control・App Exposé の表示中の一時停止。
+// Mission Control の中でコーストすると、ウインドウの選択（ハイライト）が慣性で飛び回ってしまう。
+// 表示中はイベントの傍受と合成イベントの発行を止める（全画面ゲームと同じ一時停止）。
+package main
+
+/*
+#cgo LDFLAGS: -framework ApplicationServices
+#include "missioncontrol.h"
+*/
+import "C"
+import (
+	"fmt"
+	"time"
+)
+
+// missionControlCheckInterval は Mission Control の表示中かを確認する間隔。
+// 開いてすぐのフリックを止められるよう、全画面ゲーム（gameCheckInterval）より短くする。
+const missionControlCheckInterval = 250 * time.Millisecond
+
+// missionControlMsg は Mission Control の表示状態の変化。
+type missionControlMsg struct {
+	active bool
+}
+
+// isMissionControlActive は Mission Control または App Exposé の表示中かを返す。
+func isMissionControlActive() bool {
+	return C.mission_control_is_active() != 0
+}
+
+// watchMissionControl は Mission Control の表示状態を定期的に確認し、表示中は一時停止する。
+// a.stop が閉じられるまでブロックする。
+func (a *App) watchMissionControl() {
+	ticker := time.NewTicker(missionControlCheckInterval)
+	defer ticker.Stop()
+
+	active := false
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+			mc := isMissionControlActive()
+			if mc == active {
+				continue
+			}
+			active = mc
+			if active {
+				fmt.Println("[mission-control] shown, suspending")
+			} else {
+				fmt.Println("[mission-control] hidden, resuming")
+			}
+			a.send(missionControlMsg{active: active})
+		}
+	}
+}
@@ -0,0 +1,8 @@
+// missioncontrol.h: Mission Control・App Exposé の表示中かの判定（ウインドウリストのヒューリスティック）。
+#ifndef MISSIONCONTROL_H
+#define MISSIONCONTROL_H
+
+// Mission Control または App Exposé の表示中なら 1 を返す。
+int mission_control_is_active(void);
+
+#endif
@@ -0,0 +1,112 @@
+// mouse.c: コーストフレーム発行用の C ヘルパー。
+// 60〜120Hz のループで1フレームごとに「生成・フィールド設定・発行・解放」を
+// 個別の cgo 呼び出しで行うと遷移コストがかさむため、1回の cgo 呼び出しにまとめる。
+#include <dispatch/dispatch.h>
+#include <mach/mach_time.h>
+#include <stdatomic.h>
+#include <IOKit/hidsystem/IOHIDLib.h>
+#include <IOKit/hidsystem/event_status_driver.h>
+#include "mouse.h"
+
+// ドラッグの合成イベントとマウスアップのタイムスタンプは、元のイベントと同じ mach_absolute_time の
+// 時間軸にそろえる。生成時刻のままだと、傍受したマウスアップのタイムスタンプと前後して、
+// イベント間の時間からドラッグ速度を求めるアプリ（Qt、Chromium）が異常な速度を算出する。
+void stamp_event(CGEventRef event) {
+    static _Atomic uint64_t last = 0;
+    uint64_t now = mach_absolute_time();
+    uint64_t prev = atomic_load(&last);
+    uint64_t next;
+    do {
+        next = now > prev ? now : prev + 1;
+    } while (!atomic_compare_exchange_weak(&last, &prev, next));
+    CGEventSetTimestamp(event, next);
+}
+
+// get_cursor は現在のカーソル位置を *cursor に返す。取得できた場合は 1 を返す。
+static int get_cursor(CGPoint *cursor) {
+    CGEventRef current = CGEventCreate(NULL);
+    if (current == NULL) {
+        return 0;
+    }
+    *cursor = CGEventGetLocation(current);
+    CFRelease(current);
+    return 1;
+}
+
+// hid_system は IOHIDSystem への接続を返す（開けなければ 0）。最初の呼び出しで1回だけ開く。
+static io_connect_t hid_system(void) {
+    static io_connect_t conn = 0;
+    static dispatch_once_t once;
+    dispatch_once(&once, ^{
+#pragma clang diagnostic push
+#pragma clang diagnostic ignored "-Wdeprecated-declarations"
+        conn = NXOpenEventStatus();
+#pragma clang diagnostic pop
+    });
+    return conn;
+}
+
+int post_mouse_moved_relative(int64_t dx, int64_t dy, CGPoint *cursor, int *cursor_ok) {
+    io_connect_t conn = hid_system();
+    if (conn == 0) {
+        return 0;
+    }
+    // 発行前の位置は呼び出し側の判定（リンクしたデバイスへの移動等）のためだけに読む。
+    // 移動先はこの位置から計算しない
+    *cursor_ok = get_cursor(cursor);
+
+    NXEventData data = {0};
+    data.mouseMove.dx = (SInt32)dx;
+    data.mouseMove.dy = (SInt32)dy;
+    IOGPoint delta = {(SInt16)dx, (SInt16)dy};
+#pragma clang diagnostic push
+#pragma clang diagnostic ignored "-Wdeprecated-declarations"
+    kern_return_t kr = IOHIDPostEvent(conn, NX_MOUSEMOVED, delta, &data, kNXEventDataVersion, 0,
+                                      kIOHIDSetRelativeCursorPosition);
+#pragma clang diagnostic pop
+    return kr == KERN_SUCCESS;
+}
+
+int post_mouse_moved_by(CGEventTapLocation loc, int64_t dx, int64_t dy, int64_t marker, CGPoint *cursor) {
+    if (!get_cursor(cursor)) {
+        return 0;
+    }
+
+    CGPoint point = CGPointMake(cursor->x + dx, cursor->y + dy);
+    CGEventRef event = CGEventCreateMouseEvent(NULL, kCGEventMouseMoved, point, 0);
+    if (event == NULL) {
+        return 1;
+    }
+    CGEventSetIntegerValueField(event, kCGMouseEventDeltaX, dx);
+    CGEventSetIntegerValueField(event, kCGMouseEventDeltaY, dy);
+    CGEventSetIntegerValueField(event, kCGEventSourceUserData, marker);
+    CGEventPost(loc, event);
+    CFRelease(event);
+    return 1;
+}
+
+int post_drag_frame(CGEventTapLocation loc, CGEventSourceRef source,
+                    double x, double y, int64_t dx, int64_t dy, int64_t click_state, CGEventFlags flags,
+                    int64_t marker, CGPoint *cursor) {
+    int ok = get_cursor(cursor);
+    CGEventRef event = CGEventCreateMouseEvent(source, kCGEventLeftMouseDragged,
+                                               CGPointMake(x, y), kCGMouseButtonLeft);
+    if (event == NULL) {
+        return ok;
+    }
+    // delta を整数・浮動小数点の両方で設定（参照する側がアプリによって異なる）
+    CGEventSetIntegerValueField(event, kCGMouseEventDeltaX, dx);
+    CGEventSetIntegerValueField(event, kCGMouseEventDeltaY, dy);
+    CGEventSetDoubleValueField(event, kCGMouseEventDeltaX, (double)dx);
+    CGEventSetDoubleValueField(event, kCGMouseEventDeltaY, (double)dy);
+
+    // ドラッグ中のボタン状態・圧力と、マウスダウン時の修飾キーを設定
+    CGEventSetIntegerValueField(event, kCGMouseEventClickState, click_state);
+    CGEventSetFlags(event, flags);
+    CGEventSetDoubleValueField(event, kCGMouseEventPressure, 1.0);
+    CGEventSetIntegerValueField(event, kCGEventSourceUserData, marker);
+    stamp_event(event);
+    CGEventPost(loc, event);
+    CFRelease(event);
+    return ok;
+}
@@ -2,13 +2,14 @@
 package main
 
 /*
-#cgo LDFLAGS: -framework CoreGraphics
-#include <CoreGraphics/CoreGraphics.h>
+#cgo LDFLAGS: -framework CoreGraphics -framework IOKit
+#include "mouse.h"
 */
 import "C"
 import (
 	"fmt"
 	"os"
+	"unsafe"
 )
 
 // eventRef は CoreGraphics イベントの参照型。
@@ -25,6 +26,33 @@ func releaseEvent(event eventRef) {
 	C.CFRelease(C.CFTypeRef(event))
 }
 
+// tapProxy は EventTap コールバックに渡されるプロキシ。コールバックの実行中のみ有効。
+type tapProxy = C.CGEventTapProxy
+
+// syntheticPostLocation はコールバック外から合成イベントを挿入する位置。
+// 全ての合成イベントを同じ位置に挿入し、イベントストリーム内での順序を一定に保つ。
+// 起動時に決定し、以降は変更しない（postAtSessionLevel）。
+var syntheticPostLocation C.CGEventTapLocation = C.kCGHIDEventTap
+
+// postAtSessionLevel は合成イベントを HID レベルではなくセッションレベルに挿入するようにする。
+// HID ストリームを仮想デバイスで再発行するツール（Karabiner-Elements）に合成イベントを
+// 再処理させないために使う。Open 前に呼ぶこと。
+func postAtSessionLevel() {
+	syntheticPostLocation = C.kCGSessionEventTap
+}
+
+// annotateSynthetic が true なら、postEvent で発行する全てのイベント（解放するマウスアップを含む）に
+// syntheticEventMarker を付け、他のユーティリティが合成イベントを見分けられるようにする（起動時に決定）。
+var annotateSynthetic bool
+
+// postEvent は合成イベントを syntheticPostLocation に挿入する。
+func postEvent(event C.CGEventRef) {
+	if annotateSynthetic {
+		C.CGEventSetIntegerValueField(event, C.kCGEventSourceUserData, syntheticEventMarker)
+	}
+	C.CGEventPost(syntheticPostLocation, event)
+}
+
 // --- 基本カーソル操作 ---
 
 // getMouseLocation は現在のカーソル位置をスクリーン座標で返す。
@@ -39,16 +67,33 @@ func getMouseLocation() (x, y float64, ok bool) {
 	return float64(loc.x), float64(loc.y), true
 }
 
-// setMouseLocation はカーソルを指定座標に移動する。
-// CGEvent の生成に失敗した場合は何もしない。
-func setMouseLocation(x, y float64) {
-	point := C.CGPointMake(C.CGFloat(x), C.CGFloat(y))
-	event := C.CGEventCreateMouseEvent(0, C.kCGEventMouseMoved, point, 0)
-	if event == 0 {
-		return
+// relativeCursorMoves が true なら、通常の慣性のカーソル移動を IOHIDSystem への相対移動として発行する
+// （起動時に決定）。相対移動には合成イベントの印（syntheticEventMarker）を付けられず、
+// HID レベルに入るため、印で自分の移動を見分ける NSEvent バックエンド（gesture.go）と、
+// 合成イベントをセッションレベルに挿入する互換モード・印を付ける控えめモードでは使わない。
+var relativeCursorMoves = true
+
+// postMouseMovedBy はカーソルを現在位置から dx, dy だけ動かす。
+// relativeCursorMoves なら相対移動として発行し、移動先はウインドウサーバがその時点の位置から決めるため、
+// コースト中に物理マウスが動いてもその移動を上書きせず、慣性の移動と加算的に合成される。
+// 相対移動を使えない場合は、読み取った現在位置にデルタを加えた位置への mouseMoved イベントを発行する。
+// この場合は読み取りから発行までの間（1回の cgo 呼び出しの中）の物理的な移動が上書きされる競合が残る。
+// デルタフィールドも設定し、相対移動を参照するアプリにも同じ移動量を伝える。
+// 毎フレーム呼ばれるため、イベントの生成から解放までを1回の cgo 呼び出し（mouse.c）で行う。
+// 戻り値は発行前のカーソル位置（移動なし・取得失敗時は ok=false）。
+func postMouseMovedBy(dx, dy int) (x, y float64, ok bool) {
+	if dx == 0 && dy == 0 {
+		return 0, 0, false
 	}
-	defer C.CFRelease(C.CFTypeRef(event))
-	C.CGEventPost(C.kCGHIDEventTap, event)
+	var cursor C.CGPoint
+	if relativeCursorMoves {
+		var cursorOK C.int
+		if C.post_mouse_moved_relative(C.int64_t(dx), C.int64_t(dy), &cursor, &cursorOK) != 0 {
+			return float64(cursor.x), float64(cursor.y), cursorOK != 0
+		}
+	}
+	ok = C.post_mouse_moved_by(syntheticPostLocation, C.int64_t(dx), C.int64_t(dy), syntheticEventMarker, &cursor) != 0
+	return float64(cursor.x), float64(cursor.y), ok
 }
 
 // warpCursor はイベントを発行せずにカーソル位置を移動する。
@@ -67,12 +112,55 @@ func reassociateMouse() {
 
 // --- イベント操作 ---
 
+// newMouseUpEvent は (x, y) での左ボタンのマウスアップを作る（スナップショットの再生用、発行しない）。
+// 呼び出し側が releaseEvent すること。
+func newMouseUpEvent(x, y float64) C.CGEventRef {
+	return C.CGEventCreateMouseEvent(0, C.kCGEventLeftMouseUp, C.CGPointMake(C.CGFloat(x), C.CGFloat(y)), C.kCGMouseButtonLeft)
+}
+
+// eventData はイベントを CGEventCreateData で直列化する（補助プロセスとの受け渡し用。taphelper.go）。
+func eventData(event C.CGEventRef) []byte {
+	data := C.CGEventCreateData(C.kCFAllocatorDefault, event)
+	if data == 0 {
+		return nil
+	}
+	defer C.CFRelease(C.CFTypeRef(data))
+	return C.GoBytes(unsafe.Pointer(C.CFDataGetBytePtr(data)), C.int(C.CFDataGetLength(data)))
+}
+
+// eventFromData は eventData で直列化したイベントを復元する（失敗すると 0）。呼び出し側が releaseEvent すること。
+func eventFromData(b []byte) C.CGEventRef {
+	if len(b) == 0 {
+		return 0
+	}
+	data := C.CFDataCreate(C.kCFAllocatorDefault, (*C.UInt8)(unsafe.Pointer(&b[0])), C.CFIndex(len(b)))
+	if data == 0 {
+		return 0
+	}
+	defer C.CFRelease(C.CFTypeRef(data))
+	return C.CGEventCreateFromData(C.kCFAllocatorDefault, data)
+}
+
+// eventLocation は EventTap で傍受中のマウスイベントの位置を返す。
+func eventLocation(event C.CGEventRef) (x, y float64) {
+	loc := C.CGEventGetLocation(event)
+	return float64(loc.x), float64(loc.y)
+}
+
+// setEventLocation は EventTap で傍受中のマウスイベントの位置を書き換える。
+func setEventLocation(event C.CGEventRef, x, y float64) {
+	C.CGEventSetLocation(event, C.CGPointMake(C.CGFloat(x), C.CGFloat(y)))
+}
+
 // endDragSession は保留中のマウスアップを最終位置に修正して発行し、
 // カーソルをワープして関連付けを復元する。
 // mouseUp の発行をワープより先に行うのは、ワープが先だとドラッグセッション中に
 // カーソルジャンプが発生し、ウィンドウが二重に移動してしまうため。
-// mutex 外で呼ぶこと。
+// マウスアップを補助プロセスが持っていれば、ワープまで補助プロセスに任せて順序を保つ。
 func endDragSession(pending C.CGEventRef, x, y float64) {
+	if tapHelperLink.release(pending, "end", x, y) {
+		return
+	}
 	releasePendingMouseUpAt(pending, x, y)
 	warpCursor(x, y)
 	reassociateMouse()
@@ -80,11 +168,14 @@ func endDragSession(pending C.CGEventRef, x, y float64) {
 
 // releasePendingMouseUpAt は保留中のマウスアップの位置を更新してから発行・解放する。
 // コースト終了時に、元のマウスアップ位置（コースト前）をコースト最終位置に修正するために使う。
-// mutex 外で呼ぶこと。
 func releasePendingMouseUpAt(event C.CGEventRef, x, y float64) {
+	if tapHelperLink.release(event, "release-at", x, y) {
+		return
+	}
 	if event != 0 {
 		C.CGEventSetLocation(event, C.CGPointMake(C.CGFloat(x), C.CGFloat(y)))
-		C.CGEventPost(C.kCGHIDEventTap, event)
+		C.stamp_event(event)
+		postEvent(event)
 		C.CFRelease(C.CFTypeRef(event))
 	}
 }
@@ -93,7 +184,8 @@ func releasePendingMouseUpAt(event C.CGEventRef, x, y float64) {
 // ゼロデルタのドラッグイベントを発行してカーソルを移動するため、
 // CGWarpMouseCursorPosition のような入力抑制が発生しない。
 // ドラッグセッション中（mouseUp 保留中）にカーソル位置を修正するために使う。
-func syncCursorViaDrag(x, y float64) {
+// attrs はドラッグを開始したマウスダウンのクリック回数と修飾キー。
+func syncCursorViaDrag(x, y float64, attrs dragAttrs) {
 	point := C.CGPointMake(C.CGFloat(x), C.CGFloat(y))
 	event := C.CGEventCreateMouseEvent(0, C.kCGEventLeftMouseDragged, point, C.kCGMouseButtonLeft)
 	if event == 0 {
@@ -102,13 +194,16 @@ func syncCursorViaDrag(x, y float64) {
 	defer C.CFRelease(C.CFTypeRef(event))
 	C.CGEventSetIntegerValueField(event, C.kCGMouseEventDeltaX, 0)
 	C.CGEventSetIntegerValueField(event, C.kCGMouseEventDeltaY, 0)
-	C.CGEventPost(C.kCGHIDEventTap, event)
+	applyDragAttrs(event, attrs)
+	C.stamp_event(event)
+	postEvent(event)
 }
 
 // postSyntheticDrag はカーソル追従用の mouseDragged イベントを発行する。
 // OS が mouseUp 後の再タッチを mouseMoved として送る状況で、
 // ドラッグセッション維持中にウィンドウを追従させるために使う。
-func postSyntheticDrag(x, y float64, dx, dy int) {
+// attrs はドラッグを開始したマウスダウンのクリック回数と修飾キー。
+func postSyntheticDrag(x, y float64, dx, dy int, attrs dragAttrs) {
 	point := C.CGPointMake(C.CGFloat(x), C.CGFloat(y))
 	event := C.CGEventCreateMouseEvent(0, C.kCGEventLeftMouseDragged, point, C.kCGMouseButtonLeft)
 	if event == 0 {
@@ -117,25 +212,101 @@ func postSyntheticDrag(x, y float64, dx, dy int) {
 	defer C.CFRelease(C.CFTypeRef(event))
 	C.CGEventSetIntegerValueField(event, C.kCGMouseEventDeltaX, C.int64_t(dx))
 	C.CGEventSetIntegerValueField(event, C.kCGMouseEventDeltaY, C.int64_t(dy))
-	C.CGEventSetIntegerValueField(event, C.kCGMouseEventClickState, 1)
-	C.CGEventPost(C.kCGHIDEventTap, event)
+	applyDragAttrs(event, attrs)
+	C.stamp_event(event)
+	postEvent(event)
+}
+
+// dragAttrs はドラッグを開始したマウスダウンの属性。ドラッグの合成イベントと保留するマウスアップに引き継ぐ。
+type dragAttrs struct {
+	clickState int    // クリック回数（kCGMouseEventClickState。ダブルクリックしてのドラッグなら 2）
+	flags      uint64 // 修飾キー（CGEventFlags。Option を押したままのコピー等）
+}
+
+// eventDragAttrs はマウスイベントのクリック回数と修飾キーを返す。
+func eventDragAttrs(event C.CGEventRef) dragAttrs {
+	return dragAttrs{
+		clickState: int(C.CGEventGetIntegerValueField(event, C.kCGMouseEventClickState)),
+		flags:      uint64(C.CGEventGetFlags(event)),
+	}
+}
+
+// applyDragAttrs はマウスイベントにクリック回数と修飾キーを設定する。
+func applyDragAttrs(event C.CGEventRef, attrs dragAttrs) {
+	C.CGEventSetIntegerValueField(event, C.kCGMouseEventClickState, C.int64_t(attrs.clickState))
+	C.CGEventSetFlags(event, C.CGEventFlags(attrs.flags))
 }
 
 // releasePendingMouseUp は保留中のマウスアップを発行・解放する。
-// mutex 外で呼ぶこと。
+// タイムスタンプは発行時刻にし、直前のドラッグの合成イベントより後にする（stamp_event）。
+// 補助プロセス（--tap-helper）が持つマウスアップの複製なら、発行を補助プロセスに指示する（endDragSession・releasePendingMouseUpAt も同様）。
 func releasePendingMouseUp(event C.CGEventRef) {
+	if tapHelperLink.release(event, "release") {
+		return
+	}
 	if event != 0 {
-		C.CGEventPost(C.kCGHIDEventTap, event)
+		C.stamp_event(event)
+		postEvent(event)
 		C.CFRelease(C.CFTypeRef(event))
 	}
 }
 
+// releasePendingMouseUpViaTap は保留中のマウスアップを EventTap のプロキシ経由で発行・解放する。
+// EventTap コールバック内から使う。プロキシ経由の発行は処理中のイベントより先に
+// tap の位置からストリームに挿入されるため、負荷が高い状況でも
+// 傍受中のイベント（新しい mouseDown 等）との順序が入れ替わらない。
+// マウスアップは before（傍受中のイベント）より先に届くため、タイムスタンプも before の直前にする。
+func releasePendingMouseUpViaTap(proxy tapProxy, event, before C.CGEventRef) {
+	if event != 0 {
+		C.CGEventSetTimestamp(event, C.CGEventGetTimestamp(before)-1)
+		C.CGEventTapPostEvent(proxy, event)
+		C.CFRelease(C.CFTypeRef(event))
+	}
+}
+
+// syntheticEventMarker は coastpad が発行した合成イベントに付ける印（kCGEventSourceUserData）。
+// EventTap やグローバルモニタ（gesture.go）で自分の合成イベントを再度傍受しないために使う。
+const syntheticEventMarker = 0x434F4153 // "COAS"
+
+// postScrollPixels はピクセル単位の連続スクロールイベントを発行する。
+// トラックパッドと同様の連続（IsContinuous）スクロールとして扱わせる。
+func postScrollPixels(dx, dy int) {
+	event := C.CGEventCreateScrollWheelEvent2(0, C.kCGScrollEventUnitPixel, 2, C.int32_t(dy), C.int32_t(dx), 0)
+	if event == 0 {
+		return
+	}
+	defer C.CFRelease(C.CFTypeRef(event))
+	C.CGEventSetIntegerValueField(event, C.kCGScrollWheelEventIsContinuous, 1)
+	C.CGEventSetIntegerValueField(event, C.kCGEventSourceUserData, syntheticEventMarker)
+	postEvent(event)
+}
+
 // --- ドラッグ慣性用イベントソース ---
 
+// eventSourceRef は CGEventSourceRef の別名。
+type eventSourceRef = C.CGEventSourceRef
+
+// eventSourceFromEvent は event と同じ状態 ID のイベントソースを作る（作れなければ 0 を返す）。
+// 返されたソースは呼び出し側が releaseEventSource すること。
+func eventSourceFromEvent(event eventRef) eventSourceRef {
+	return C.CGEventCreateSourceFromEvent(event)
+}
+
+// releaseEventSource はイベントソースを解放する。0 なら何もしない。
+func releaseEventSource(source eventSourceRef) {
+	if source != 0 {
+		C.CFRelease(C.CFTypeRef(source))
+	}
+}
+
 // dragPoster はドラッグ慣性用の mouseDragged イベントを管理する。
 // CGEventSource を保持し、HID レベルのボタン状態を正しく反映する。
+// ドラッグセッションごとに、傍受したマウスダウン（見ていなければ保留したマウスアップ）から作ったソースに切り替える。
+// 合成の mouseDragged と、保留して後で発行するマウスアップのソースの状態 ID を揃え、
+// 状態 ID でボタンの状態を追うアプリにドラッグが途切れたように見えないようにする。
 type dragPoster struct {
-	source C.CGEventSourceRef
+	source  C.CGEventSourceRef // 実行中ずっと使う HID 状態のソース
+	session C.CGEventSourceRef // 現在のドラッグセッションのソース（なければ 0）
 }
 
 func newDragPoster() *dragPoster {
@@ -147,33 +318,42 @@ func newDragPoster() *dragPoster {
 }
 
 func (dp *dragPoster) close() {
-	if dp.source != 0 {
-		C.CFRelease(C.CFTypeRef(dp.source))
-		dp.source = 0
-	}
+	releaseEventSource(dp.source)
+	dp.source = 0
+	releaseEventSource(dp.session)
+	dp.session = 0
+}
+
+// beginSession はドラッグセッションのソースを source に切り替える。source の所有権は dp に移る。
+// source が 0 なら共通のソースに戻す。
+func (dp *dragPoster) beginSession(source eventSourceRef) {
+	releaseEventSource(dp.session)
+	dp.session = source
+}
+
+// hasSession はドラッグセッションのソースがあるかを返す。
+func (dp *dragPoster) hasSession() bool {
+	return dp.session != 0
 }
 
 // post は指定座標に kCGEventLeftMouseDragged イベントを発行する。
 // dx, dy は整数 delta。ウィンドウマネージャはこの delta でウィンドウを移動する。
+// ドラッグセッションのソースがあればそれを使う。
 // CGEventCreateMouseEvent は source に nil（0）を受け付けるため、
 // CGEventSourceCreate が失敗しても動作する。
-func (dp *dragPoster) post(x, y float64, dx, dy int) {
-	point := C.CGPointMake(C.CGFloat(x), C.CGFloat(y))
-	event := C.CGEventCreateMouseEvent(dp.source, C.kCGEventLeftMouseDragged, point, C.kCGMouseButtonLeft)
-	if event == 0 {
-		return
+// 毎フレーム呼ばれるため、イベントの生成から解放までを1回の cgo 呼び出し（mouse.c）で行う。
+// attrs はドラッグを開始したマウスダウンのクリック回数と修飾キー。
+// 戻り値は発行前のカーソル位置（取得失敗時は ok=false）。
+func (dp *dragPoster) post(x, y float64, dx, dy int, attrs dragAttrs) (cx, cy float64, ok bool) {
+	source := dp.source
+	if dp.session != 0 {
+		source = dp.session
 	}
-	defer C.CFRelease(C.CFTypeRef(event))
-	// delta を整数・浮動小数点の両方で設定（参照する側がアプリによって異なる）
-	C.CGEventSetIntegerValueField(event, C.kCGMouseEventDeltaX, C.int64_t(dx))
-	C.CGEventSetIntegerValueField(event, C.kCGMouseEventDeltaY, C.int64_t(dy))
-	C.CGEventSetDoubleValueField(event, C.kCGMouseEventDeltaX, C.double(dx))
-	C.CGEventSetDoubleValueField(event, C.kCGMouseEventDeltaY, C.double(dy))
-
-	// ドラッグ中のボタン状態と圧力を設定
-	C.CGEventSetIntegerValueField(event, C.kCGMouseEventClickState, 1)
-	C.CGEventSetDoubleValueField(event, C.kCGMouseEventPressure, 1.0)
-	C.CGEventPost(C.kCGHIDEventTap, event)
+	var cursor C.CGPoint
+	ok = C.post_drag_frame(syntheticPostLocation, source,
+		C.double(x), C.double(y), C.int64_t(dx), C.int64_t(dy), C.int64_t(attrs.clickState), C.CGEventFlags(attrs.flags),
+		syntheticEventMarker, &cursor) != 0
+	return float64(cursor.x), float64(cursor.y), ok
 }
 
 // --- ディスプレイ情報 ---
@@ -185,7 +365,7 @@ func screenBounds() []displayRect {
 	if C.CGGetActiveDisplayList(0, nil, &count) != 0 || count == 0 {
 		// ディスプレイ情報を取得できない場合の安全なフォールバック。
 		// 慣性カーソルがクランプされる範囲に使われるだけなので、実用上問題ない。
-		return []displayRect{{0, 0, 1919, 1079}}
+		return []displayRect{{0, 0, 1919, 1079, 1}}
 	}
 	// 最大16ディスプレイをサポート（macOS の実用上十分な上限）
 	if count > 16 {
@@ -193,18 +373,51 @@ func screenBounds() []displayRect {
 	}
 	var displays [16]C.CGDirectDisplayID
 	if C.CGGetActiveDisplayList(count, &displays[0], &count) != 0 {
-		return []displayRect{{0, 0, 1919, 1079}}
+		return []displayRect{{0, 0, 1919, 1079, 1}}
 	}
 
-	rects := make([]displayRect, count)
-	for i := C.uint32_t(0); i < count; i++ {
-		b := C.CGDisplayBounds(displays[i])
-		rects[i] = displayRect{
-			minX: float64(b.origin.x),
-			minY: float64(b.origin.y),
-			maxX: float64(b.origin.x+b.size.width) - 1,
-			maxY: float64(b.origin.y+b.size.height) - 1,
+	rects := make([]displayRect, 0, count)
+	for _, d := range displays[:count] {
+		// ミラーリング中はミラー先のディスプレイが同じ領域に重複して並ぶため、
+		// ミラーセットのプライマリ以外を除外する
+		if C.CGDisplayIsInMirrorSet(d) != 0 && C.CGDisplayPrimaryDisplay(d) != d {
+			continue
 		}
+		b := C.CGDisplayBounds(d)
+		rects = append(rects, displayRect{
+			minX:  float64(b.origin.x),
+			minY:  float64(b.origin.y),
+			maxX:  float64(b.origin.x+b.size.width) - 1,
+			maxY:  float64(b.origin.y+b.size.height) - 1,
+			scale: displayScale(d),
+		})
 	}
 	return rects
 }
+
+// displayScale はディスプレイのバッキングスケール（Retina なら 2）を返す。
+// 現在の表示モードのピクセル幅とポイント幅の比から求め、取得できなければ 1 を返す。
+func displayScale(d C.CGDirectDisplayID) float64 {
+	mode := C.CGDisplayCopyDisplayMode(d)
+	if mode == 0 {
+		return 1
+	}
+	defer C.CGDisplayModeRelease(mode)
+	w := C.CGDisplayModeGetWidth(mode)
+	if w == 0 {
+		return 1
+	}
+	return float64(C.CGDisplayModeGetPixelWidth(mode)) / float64(w)
+}
+
+// postMouseUpAtCursor は現在のカーソル位置で左ボタンのマウスアップを発行する（mouseupguard.go）。
+// 保留中のマウスアップを失ったまま本体が終了したとき、押されたままのボタンを離すために使う。
+func postMouseUpAtCursor() {
+	x, y, _ := getMouseLocation()
+	event := newMouseUpEvent(x, y)
+	if event == 0 {
+		return
+	}
+	C.CGEventPost(C.kCGHIDEventTap, event)
+	C.CFRelease(C.CFTypeRef(event))
+}
@@ -0,0 +1,30 @@
+// mouse.h: コーストフレーム発行用の C ヘルパー。
+#ifndef MOUSE_H
+#define MOUSE_H
+
+#include <CoreGraphics/CoreGraphics.h>
+
+// イベントのタイムスタンプを現在の mach_absolute_time に設定する。
+// 発行順に単調増加するよう、前回の値以下なら前回 + 1 にする。任意のスレッドから呼べる。
+void stamp_event(CGEventRef event);
+
+// カーソルを (dx, dy) だけ動かす相対移動を IOHIDSystem に発行する。移動先はウインドウサーバが
+// その時点のカーソル位置にデルタを加えて決めるため、読み取りと発行の間の物理的な移動を上書きしない。
+// 発行前のカーソル位置を *cursor に返し、取得できたかを *cursor_ok に返す。
+// IOHIDSystem を開けない・発行できなかった場合は 0 を返す（呼び出し側は post_mouse_moved_by を使う）。
+int post_mouse_moved_relative(int64_t dx, int64_t dy, CGPoint *cursor, int *cursor_ok);
+
+// 現在位置から (dx, dy) だけ動かす mouseMoved イベントを生成・発行・解放する。
+// 読み取った現在位置にデルタを加えた絶対位置で発行するため、読み取りと発行の間の物理的な移動は上書きされる。
+// 発行前のカーソル位置を *cursor に返す。位置を取得できた場合は 1 を返す。
+int post_mouse_moved_by(CGEventTapLocation loc, int64_t dx, int64_t dy, int64_t marker, CGPoint *cursor);
+
+// 指定位置に (dx, dy) のデルタを持つ mouseDragged イベントを生成・発行・解放する。
+// 発行前のカーソル位置を *cursor に返す。位置を取得できた場合は 1 を返す。
+// click_state はクリック回数（kCGMouseEventClickState）、flags は修飾キー、
+// marker は合成イベントの印（kCGEventSourceUserData）。
+int post_drag_frame(CGEventTapLocation loc, CGEventSourceRef source,
+                    double x, double y, int64_t dx, int64_t dy, int64_t click_state, CGEventFlags flags,
+                    int64_t marker, CGPoint *cursor);
+
+#endif
@@ -0,0 +1,113 @@
+// mouseupguard.go: 異常終了時のマウスアップの保証（--mouseup-guard）。
+// ドラッグ慣性中はマウスアップを保留しているため、その間に coastpad が SIGKILL やクラッシュで終了すると、
+// OS からは左ボタンが押されたままに見える（次にクリックするまでドラッグが続く）。
+// SIGKILL は捕捉できないため、別プロセス（`coastpad mouseup-guard`）がパイプで保留の有無を受け取り、
+// パイプが閉じた（本体が終了した）時点で保留中だったら左ボタンのマウスアップを発行する。
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// 本体からガードに送る状態（1バイト）
+const (
+	guardReleased = '0' // 保留中のマウスアップなし
+	guardHeld     = '1' // マウスアップを保留中
+)
+
+// mouseUpGuard はガードプロセスへのパイプを表す。
+type mouseUpGuard struct {
+	w    *os.File
+	held bool // 最後に送った状態
+}
+
+// startMouseUpGuard はガードプロセスを起動する。
+// ガードはパイプの読み取り側を fd 3 で受け取る。端末の Ctrl+C で本体より先に終了しないよう、
+// 別のプロセスグループで動かす。
+func startMouseUpGuard() (*mouseUpGuard, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	cmd := exec.Command(exe, "mouseup-guard")
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{r}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		w.Close()
+		return nil, err
+	}
+	go cmd.Wait()
+	return &mouseUpGuard{w: w}, nil
+}
+
+// set は保留中のマウスアップの有無が変わったときにガードに知らせる。
+// ガードが終了していれば以降は何もしない。アクター goroutine から呼ぶこと。
+func (g *mouseUpGuard) set(held bool) {
+	if g == nil || g.w == nil || held == g.held {
+		return
+	}
+	g.held = held
+	b := byte(guardReleased)
+	if held {
+		b = guardHeld
+	}
+	if _, err := g.w.Write([]byte{b}); err != nil {
+		fmt.Fprintf(os.Stderr, "[guard] mouse-up guard exited: %v\n", err)
+		g.w.Close()
+		g.w = nil
+	}
+}
+
+// close は正常終了を知らせてパイプを閉じる。保留中のマウスアップを発行した後に呼ぶこと。
+func (g *mouseUpGuard) close() {
+	if g == nil || g.w == nil {
+		return
+	}
+	g.set(false)
+	if g.w != nil {
+		g.w.Close()
+		g.w = nil
+	}
+}
+
+// runMouseUpGuardCommand は `coastpad mouseup-guard` を実行する（startMouseUpGuard が起動する内部用）。
+// 本体が終了してパイプが閉じるまで状態を読み続け、最後の状態が保留中ならマウスアップを発行する。
+func runMouseUpGuardCommand([]string) error {
+	pipe := os.NewFile(3, "mouseup-guard")
+	if pipe == nil {
+		return errors.New("mouseup-guard is started by coastpad itself")
+	}
+	defer pipe.Close()
+
+	held := false
+	buf := make([]byte, 64)
+	for {
+		n, err := pipe.Read(buf)
+		if n > 0 {
+			held = buf[n-1] == guardHeld
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if held {
+		fmt.Fprintln(os.Stderr, "[guard] coastpad exited while holding the mouse button, releasing it")
+		postMouseUpAtCursor()
+	}
+	return nil
+}
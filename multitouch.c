@@ -1,11 +1,130 @@
 // multitouch.c: MultitouchSupport の C コールバックを
 // Go の goTouchCallback に中継する。C から Go の export 関数を
 // 直接コールバック登録できないため、この中継関数が必要。
+// あわせて、MultitouchSupport を dlopen して関数を解決し、機能の検出（capability.go）に使う。
+// フレームワークをリンクしないため、関数がなくなった macOS でも起動できる。
+#include <dlfcn.h>
+#include <pthread.h>
+#include <string.h>
 #include "multitouch.h"
 #include "_cgo_export.h"
 
+#define MT_FRAMEWORK_PATH "/System/Library/PrivateFrameworks/MultitouchSupport.framework/MultitouchSupport"
+
 // 戻り値の型・意味はプライベート API のため不明。慣例的に 0 を返す。
 int bridge_touch_callback(MTDeviceRef device, Finger *data, int dataNum, double timestamp, int frame) {
     goTouchCallback(device, data, dataNum, timestamp, frame);
     return 0;
 }
+
+// dlsym で解決した関数（見つからなければ NULL）
+static CFArrayRef (*fn_MTDeviceCreateList)(void);
+static void (*fn_MTRegisterContactFrameCallback)(MTDeviceRef, MTContactCallbackFunction);
+static void (*fn_MTUnregisterContactFrameCallback)(MTDeviceRef, MTContactCallbackFunction);
+static int (*fn_MTDeviceStart)(MTDeviceRef, int);
+static void (*fn_MTDeviceStop)(MTDeviceRef);
+static int (*fn_MTDeviceGetDeviceID)(MTDeviceRef, uint64_t *);
+static MTActuatorRef (*fn_MTActuatorCreateFromDeviceID)(uint64_t);
+static int (*fn_MTActuatorOpen)(MTActuatorRef);
+static int (*fn_MTActuatorClose)(MTActuatorRef);
+static int (*fn_MTActuatorActuate)(MTActuatorRef, int32_t, uint32_t, float, float);
+
+// 関数名と解決先のポインタ（capability.go の関数名の一覧と揃える）
+static const struct {
+    const char *name;
+    void **fn;
+} mt_symbols[] = {
+    {"MTDeviceCreateList", (void **)&fn_MTDeviceCreateList},
+    {"MTRegisterContactFrameCallback", (void **)&fn_MTRegisterContactFrameCallback},
+    {"MTUnregisterContactFrameCallback", (void **)&fn_MTUnregisterContactFrameCallback},
+    {"MTDeviceStart", (void **)&fn_MTDeviceStart},
+    {"MTDeviceStop", (void **)&fn_MTDeviceStop},
+    {"MTDeviceGetDeviceID", (void **)&fn_MTDeviceGetDeviceID},
+    {"MTActuatorCreateFromDeviceID", (void **)&fn_MTActuatorCreateFromDeviceID},
+    {"MTActuatorOpen", (void **)&fn_MTActuatorOpen},
+    {"MTActuatorClose", (void **)&fn_MTActuatorClose},
+    {"MTActuatorActuate", (void **)&fn_MTActuatorActuate},
+};
+
+static pthread_once_t mt_load_once = PTHREAD_ONCE_INIT;
+
+// フレームワークを開き、全ての関数を解決する（開けなければ全て NULL のまま）。
+static void mt_load_symbols(void) {
+    void *handle = dlopen(MT_FRAMEWORK_PATH, RTLD_LAZY | RTLD_LOCAL);
+    if (handle == NULL) {
+        return;
+    }
+    for (size_t i = 0; i < sizeof(mt_symbols) / sizeof(mt_symbols[0]); i++) {
+        *mt_symbols[i].fn = dlsym(handle, mt_symbols[i].name);
+    }
+}
+
+static void mt_load(void) {
+    pthread_once(&mt_load_once, mt_load_symbols);
+}
+
+int mt_has_symbol(const char *name) {
+    mt_load();
+    for (size_t i = 0; i < sizeof(mt_symbols) / sizeof(mt_symbols[0]); i++) {
+        if (strcmp(mt_symbols[i].name, name) == 0) {
+            return *mt_symbols[i].fn != NULL;
+        }
+    }
+    return 0;
+}
+
+CFArrayRef mt_device_create_list(void) {
+    mt_load();
+    return fn_MTDeviceCreateList ? fn_MTDeviceCreateList() : NULL;
+}
+
+void mt_register_contact_frame_callback(MTDeviceRef device, MTContactCallbackFunction callback) {
+    mt_load();
+    if (fn_MTRegisterContactFrameCallback) {
+        fn_MTRegisterContactFrameCallback(device, callback);
+    }
+}
+
+void mt_unregister_contact_frame_callback(MTDeviceRef device, MTContactCallbackFunction callback) {
+    mt_load();
+    if (fn_MTUnregisterContactFrameCallback) {
+        fn_MTUnregisterContactFrameCallback(device, callback);
+    }
+}
+
+int mt_device_start(MTDeviceRef device, int mode) {
+    mt_load();
+    return fn_MTDeviceStart ? fn_MTDeviceStart(device, mode) : -1;
+}
+
+void mt_device_stop(MTDeviceRef device) {
+    mt_load();
+    if (fn_MTDeviceStop) {
+        fn_MTDeviceStop(device);
+    }
+}
+
+int mt_device_get_device_id(MTDeviceRef device, uint64_t *id) {
+    mt_load();
+    return fn_MTDeviceGetDeviceID ? fn_MTDeviceGetDeviceID(device, id) : -1;
+}
+
+MTActuatorRef mt_actuator_create_from_device_id(uint64_t deviceID) {
+    mt_load();
+    return fn_MTActuatorCreateFromDeviceID ? fn_MTActuatorCreateFromDeviceID(deviceID) : NULL;
+}
+
+int mt_actuator_open(MTActuatorRef actuator) {
+    mt_load();
+    return fn_MTActuatorOpen ? fn_MTActuatorOpen(actuator) : -1;
+}
+
+int mt_actuator_close(MTActuatorRef actuator) {
+    mt_load();
+    return fn_MTActuatorClose ? fn_MTActuatorClose(actuator) : -1;
+}
+
+int mt_actuator_actuate(MTActuatorRef actuator, int32_t actuationID, uint32_t unknown1, float unknown2, float unknown3) {
+    mt_load();
+    return fn_MTActuatorActuate ? fn_MTActuatorActuate(actuator, actuationID, unknown1, unknown2, unknown3) : -1;
+}
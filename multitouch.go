@@ -3,39 +3,53 @@
 package main
 
 /*
-#cgo LDFLAGS: -F/System/Library/PrivateFrameworks -framework MultitouchSupport
+#cgo LDFLAGS: -framework CoreFoundation
 #include "multitouch.h"
 */
 import "C"
 import (
 	"fmt"
+	"os"
 	"sync"
+	"time"
 	"unsafe"
 )
 
+// MTDeviceStart のリトライ設定。
+// Bluetooth 接続の Magic Trackpad は再接続直後に MTDeviceStart が失敗することがある。
+// リトライはデバイスごとの goroutine で行い、他のデバイスの登録を待たせない。
+const (
+	deviceStartRetries    = 3
+	deviceStartRetryDelay = 200 * time.Millisecond
+	// deviceStartRefreshes はリトライしても開始できなかったときに、続けてデバイスの更新を依頼する回数の上限。
+	deviceStartRefreshes = 3
+)
+
 // MTDeviceRef は MultitouchSupport のデバイスハンドル（C の void*）。
 type MTDeviceRef = unsafe.Pointer
 
-// TouchDevices はタッチデバイスのリストとコールバック登録を管理する。
-type TouchDevices struct {
-	// mu は devs/list のスワップを保護する。RefreshDevices（IOKit RunLoop スレッド）と
-	// StopAll（メインゴルーチン）の並行アクセスを安全にするために必要。
-	mu   sync.Mutex
-	list C.CFArrayRef            // MTDeviceCreateList で取得した配列（デバイス参照の寿命を保持）
-	devs map[uintptr]MTDeviceRef // ポインタ値 → デバイス参照（差分検出用）
+// MTTouchDevices は MultitouchSupport のタッチデバイスのリストとコールバック登録を管理する。
+type MTTouchDevices struct {
+	// mu は devs/list のスワップと MTDeviceStart のリトライを保護する。RefreshDevices（IOKit RunLoop スレッド）、
+	// StopAll（メインゴルーチン）とリトライの goroutine の並行アクセスを安全にするために必要。
+	mu            sync.Mutex
+	list          C.CFArrayRef            // MTDeviceCreateList で取得した配列（デバイス参照の寿命を保持）
+	devs          map[uintptr]MTDeviceRef // ポインタ値 → デバイス参照（差分検出用）
+	gen           uint64                  // list を置き換えるたびに増やす（古いリストのデバイスへのリトライを止める）
+	startFailures int                     // 開始できずにデバイスの更新を依頼した回数（開始できれば 0 に戻す）
 }
 
-// NewTouchDevices は TouchDevices を初期化して返す。
-func NewTouchDevices() *TouchDevices {
-	return &TouchDevices{
+// NewMTTouchDevices は MTTouchDevices を初期化して返す。
+func NewMTTouchDevices() *MTTouchDevices {
+	return &MTTouchDevices{
 		devs: make(map[uintptr]MTDeviceRef),
 	}
 }
 
 // RefreshDevices は現在のデバイスリストを取得し、コールバックを再登録する。
-// Open からの初回呼び出しの後は、IOKit RunLoop スレッドからのみシリアルに呼ばれる。
-func (td *TouchDevices) RefreshDevices() {
-	newList := C.MTDeviceCreateList()
+// Open からの初回呼び出しの後は、App のデバイス更新 goroutine からのみシリアルに呼ばれる。
+func (td *MTTouchDevices) RefreshDevices() {
+	newList := C.mt_device_create_list()
 
 	// 新しいデバイスセットを構築
 	newDevs := make(map[uintptr]MTDeviceRef)
@@ -52,6 +66,8 @@ func (td *TouchDevices) RefreshDevices() {
 	oldList := td.list
 	td.devs = newDevs
 	td.list = newList
+	td.gen++
+	gen := td.gen
 	td.mu.Unlock()
 
 	// 旧デバイスのコールバック解除と停止（oldList が参照を保持中）
@@ -62,9 +78,11 @@ func (td *TouchDevices) RefreshDevices() {
 		C.CFRelease(C.CFTypeRef(oldList))
 	}
 
-	// 新デバイスのコールバック登録と開始
+	// 新デバイスのコールバック登録と開始（開始できなければデバイスごとにリトライする）
 	for _, dev := range newDevs {
-		registerTouchCallback(dev)
+		if !registerTouchCallback(dev) {
+			go td.retryStart(gen, dev)
+		}
 	}
 
 	prev, active := len(oldDevs), len(newDevs)
@@ -73,13 +91,29 @@ func (td *TouchDevices) RefreshDevices() {
 	}
 }
 
+// Count は監視中のデバイス数を返す。
+func (td *MTTouchDevices) Count() int {
+	td.mu.Lock()
+	defer td.mu.Unlock()
+	return len(td.devs)
+}
+
+// available は MultitouchSupport からデバイスリストを取得できたかを返す
+// （デバイスが0台でもリストが取れれば true。プライベート API が動かなければ false）。
+func (td *MTTouchDevices) available() bool {
+	td.mu.Lock()
+	defer td.mu.Unlock()
+	return td.list != 0
+}
+
 // StopAll は全デバイスのコールバックを解除し、監視を停止し、リストを解放する。
-func (td *TouchDevices) StopAll() {
+func (td *MTTouchDevices) StopAll() {
 	td.mu.Lock()
 	devs := td.devs
 	list := td.list
 	td.devs = nil
 	td.list = 0
+	td.gen++
 	td.mu.Unlock()
 
 	for _, dev := range devs {
@@ -90,45 +124,120 @@ func (td *TouchDevices) StopAll() {
 	}
 }
 
+// listDeviceIDs は現在接続されているタッチデバイスの ID 集合を返す。
+// デバイスの増減検出（ポーリング）用で、コールバック登録は行わない。
+func listDeviceIDs() map[uint64]struct{} {
+	ids := make(map[uint64]struct{})
+	if caps := multitouchCaps(); !caps.touch || !caps.deviceID {
+		return ids
+	}
+	list := C.mt_device_create_list()
+	if list == 0 {
+		return ids
+	}
+	defer C.CFRelease(C.CFTypeRef(list))
+
+	count := C.CFArrayGetCount(list)
+	for i := C.CFIndex(0); i < count; i++ {
+		dev := C.MTDeviceRef(C.CFArrayGetValueAtIndex(list, i))
+		var id C.uint64_t
+		if C.mt_device_get_device_id(dev, &id) == 0 {
+			ids[uint64(id)] = struct{}{}
+		}
+	}
+	return ids
+}
+
 // --- コールバック登録・解除 ---
 
-// registerTouchCallback はデバイスにタッチコールバックを登録して監視を開始する。
-func registerTouchCallback(dev MTDeviceRef) {
-	C.MTRegisterContactFrameCallback(C.MTDeviceRef(dev), C.MTContactCallbackFunction(C.bridge_touch_callback))
-	C.MTDeviceStart(C.MTDeviceRef(dev), 0)
+// registerTouchCallback はデバイスにタッチコールバックを登録して監視を開始する。開始できたかを返す。
+func registerTouchCallback(dev MTDeviceRef) bool {
+	C.mt_register_contact_frame_callback(C.MTDeviceRef(dev), C.MTContactCallbackFunction(C.bridge_touch_callback))
+	return C.mt_device_start(C.MTDeviceRef(dev), 0) == 0
+}
+
+// retryStart は MTDeviceStart が失敗したデバイス（接続直後など）の開始を、間隔を空けてリトライする。
+// gen のリストが置き換えられたら止める（デバイス参照は古いリストとともに解放される）。
+// 最後まで開始できなければデバイスを devs から外し、デバイスの更新を依頼して次の更新でやり直す。
+func (td *MTTouchDevices) retryStart(gen uint64, dev MTDeviceRef) {
+	var status C.int
+	for attempt := 0; attempt < deviceStartRetries; attempt++ {
+		time.Sleep(deviceStartRetryDelay)
+		td.mu.Lock()
+		if td.gen != gen {
+			td.mu.Unlock()
+			return
+		}
+		status = C.mt_device_start(C.MTDeviceRef(dev), 0)
+		if status == 0 {
+			td.startFailures = 0
+		}
+		td.mu.Unlock()
+		if status == 0 {
+			return
+		}
+	}
+
+	td.mu.Lock()
+	if td.gen != gen {
+		td.mu.Unlock()
+		return
+	}
+	unregisterTouchCallback(dev)
+	delete(td.devs, uintptr(dev))
+	td.startFailures++
+	refresh := td.startFailures <= deviceStartRefreshes
+	td.mu.Unlock()
+
+	fmt.Fprintf(os.Stderr, "[multitouch] MTDeviceStart failed after %d retries: %d\n", deviceStartRetries, status)
+	if refresh && app != nil {
+		app.onDeviceChanged()
+	}
 }
 
 // unregisterTouchCallback はデバイスのタッチコールバックを解除して監視を停止する。
 func unregisterTouchCallback(dev MTDeviceRef) {
-	C.MTUnregisterContactFrameCallback(C.MTDeviceRef(dev), C.MTContactCallbackFunction(C.bridge_touch_callback))
-	C.MTDeviceStop(C.MTDeviceRef(dev))
+	C.mt_unregister_contact_frame_callback(C.MTDeviceRef(dev), C.MTContactCallbackFunction(C.bridge_touch_callback))
+	C.mt_device_stop(C.MTDeviceRef(dev))
 }
 
 // --- タッチイベント処理 ---
 
 // goTouchCallback は bridge_touch_callback (C) から呼ばれる cgo export 関数。
-// タッチ中の指の本数を App.onTouchFrame に渡す。
+// タッチ中の指の本数とパッド上の位置・接触の大きさを、デバイスとともに App.onTouchFrame に渡す。
 //
 //export goTouchCallback
 func goTouchCallback(device MTDeviceRef, data *C.Finger, dataNum C.int, timestamp C.double, frame C.int) {
-	_, _ = device, frame
+	_ = frame
 	if app == nil {
 		return
 	}
-	n := countActiveFingers(data, int(dataNum))
-	app.onTouchFrame(n, float64(timestamp))
+	n, padX, padY, size := summarizeFingers(data, int(dataNum))
+	if n > 0 && app.haptics != nil {
+		app.haptics.noteDevice(device)
+	}
+	app.onTouchFrame(uintptr(device), n, padX, padY, size, float64(timestamp))
 }
 
 // タッチ中の state 値（multitouch.h のタッチ状態遷移を参照）
 const touchStateTouching = 4
 
-// countActiveFingers はタッチ中（state == touchStateTouching）の指の本数を返す。
-func countActiveFingers(data *C.Finger, count int) int {
-	n := 0
+// summarizeFingers はタッチ中（state == touchStateTouching）の指の本数と、
+// その正規化座標の重心（0〜1、原点は左下）、接触楕円の長軸の平均を返す。
+// タッチ中の指がなければ座標と大きさは 0。
+func summarizeFingers(data *C.Finger, count int) (n int, padX, padY, size float64) {
 	for _, f := range unsafe.Slice(data, count) {
 		if int(f.state) == touchStateTouching {
 			n++
+			padX += float64(f.normalized.position.x)
+			padY += float64(f.normalized.position.y)
+			size += float64(f.majorAxis)
 		}
 	}
-	return n
+	if n > 0 {
+		padX /= float64(n)
+		padY /= float64(n)
+		size /= float64(n)
+	}
+	return n, padX, padY, size
 }
@@ -46,14 +46,27 @@ typedef struct {
 typedef void *MTDeviceRef;
 typedef int (*MTContactCallbackFunction)(MTDeviceRef, Finger *, int, double, int);
 
-// MultitouchSupport extern 宣言
-extern CFArrayRef MTDeviceCreateList(void);
-extern void MTRegisterContactFrameCallback(MTDeviceRef, MTContactCallbackFunction);
-extern void MTUnregisterContactFrameCallback(MTDeviceRef, MTContactCallbackFunction);
-extern void MTDeviceStart(MTDeviceRef, int);
-extern void MTDeviceStop(MTDeviceRef);
+// MultitouchSupport の関数。フレームワークはリンクせず、multitouch.c が初回の呼び出しで
+// dlopen し、各関数を dlsym で関数ポインタに解決する。解決できなかった関数は何もせず失敗を返す
+// （ポインタ・ID を返す関数は NULL・0、状態を返す関数は -1）。
+CFArrayRef mt_device_create_list(void);
+void mt_register_contact_frame_callback(MTDeviceRef, MTContactCallbackFunction);
+void mt_unregister_contact_frame_callback(MTDeviceRef, MTContactCallbackFunction);
+int mt_device_start(MTDeviceRef, int); // 成功時 0
+void mt_device_stop(MTDeviceRef);
+int mt_device_get_device_id(MTDeviceRef, uint64_t *);
+
+// Force Touch トラックパッドのアクチュエータ（触覚フィードバック）
+typedef CFTypeRef MTActuatorRef;
+MTActuatorRef mt_actuator_create_from_device_id(uint64_t deviceID);
+int mt_actuator_open(MTActuatorRef);  // 成功時 0
+int mt_actuator_close(MTActuatorRef);
+int mt_actuator_actuate(MTActuatorRef, int32_t actuationID, uint32_t unknown1, float unknown2, float unknown3);
 
 // C→Go コールバックブリッジ
 int bridge_touch_callback(MTDeviceRef device, Finger *data, int dataNum, double timestamp, int frame);
 
+// name の関数を dlsym で解決できたかを返す（capability.go の機能の検出用）。
+int mt_has_symbol(const char *name);
+
 #endif
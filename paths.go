@@ -0,0 +1,26 @@
+// paths.go: coastpad が使うファイルの配置。
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// appDataDir は coastpad のデータディレクトリを返す。
+// macOS では ~/Library/Application Support/coastpad になる。
+func appDataDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "coastpad"), nil
+}
+
+// appDataPath はデータディレクトリ内のファイルパスを返す。
+func appDataPath(name string) (string, error) {
+	dir, err := appDataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
@@ -0,0 +1,118 @@
+// pause.go: 一時停止と再開。
+// 一時停止中はイベントの傍受（EventTap）と合成イベントの発行を止め、タッチフレームを無視する。
+// 制御コマンド pause / resume によるユーザーの一時停止と、全画面ゲームの検出（game.go）・
+// Mission Control の表示（missioncontrol.go）・
+// 集中モード（focus.go）・セッションの切り替え（session.go）・
+// アクセシビリティの権限の取り消し（permission.go）・画面共有での遠隔操作（remote.go）による自動の一時停止があり、
+// いずれかが有効な間は一時停止する。
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// updateSuspend は paused・gameActive・missionControl・focusPaused・sessionInactive・untrusted・remoteControlled から一時停止の状態を更新する。
+// 一時停止に入るときは進行中のコーストを終了して EventTap を止め、保留中のマウスアップを返す。
+// 返されたイベントは呼び出し側が releasePendingMouseUp すること。
+// アクター goroutine から呼ぶこと。
+func (a *App) updateSuspend() eventRef {
+	suspended := a.paused || a.gameActive || a.missionControl || a.focusPaused || a.sessionInactive || a.untrusted || a.remoteControlled
+	if suspended == a.suspended {
+		return 0
+	}
+	a.suspended = suspended
+	a.pauseEventTap(suspended)
+	if !suspended {
+		return 0
+	}
+	a.scroll = scrollState{}
+	a.isTouched = false
+	a.histLen = 0
+	return a.resetCoasting()
+}
+
+// SetPaused はユーザーによる一時停止を切り替える。
+func (a *App) SetPaused(paused bool) {
+	a.call(func() {
+		a.paused = paused
+		releasePendingMouseUp(a.updateSuspend())
+	})
+	if paused {
+		fmt.Println("[control] paused")
+	} else {
+		fmt.Println("[control] resumed")
+	}
+}
+
+// ctlPause は制御コマンド `pause` / `resume` を処理する。
+func ctlPause(paused bool) controlHandler {
+	return func(a *App, args []string) (any, error) {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("usage: pause|resume")
+		}
+		a.SetPaused(paused)
+		return a.Status(), nil
+	}
+}
+
+// SetCoastEnabled はカーソル慣性（drag=false）またはドラッグ慣性（drag=true）を個別にオン・オフする。
+// 一時停止と違い、イベントの傍受と他方の慣性は続ける。オフにした種類のコーストが進行中なら終了する。
+func (a *App) SetCoastEnabled(drag, enabled bool) {
+	a.call(func() {
+		coasting := a.vx != 0 || a.vy != 0 || a.holdingDrop()
+		if drag {
+			a.dragOff = !enabled
+			if !enabled && coasting && a.dragPhase == dragPhaseCoasting {
+				releasePendingMouseUp(a.resetCoasting())
+			}
+		} else {
+			a.cursorOff = !enabled
+			if !enabled && coasting && a.dragPhase == dragPhaseNone {
+				a.vx, a.vy = 0, 0
+			}
+		}
+	})
+	kind, state := "cursor", "on"
+	if drag {
+		kind = "drag"
+	}
+	if !enabled {
+		state = "off"
+	}
+	fmt.Printf("[control] %s coast %s\n", kind, state)
+}
+
+// ctlCoastToggle は制御コマンド `cursor on|off` / `drag on|off` を処理する。
+func ctlCoastToggle(drag bool) controlHandler {
+	return func(a *App, args []string) (any, error) {
+		if len(args) != 1 || (args[0] != "on" && args[0] != "off") {
+			if drag {
+				return nil, fmt.Errorf("usage: drag on|off")
+			}
+			return nil, fmt.Errorf("usage: cursor on|off")
+		}
+		a.SetCoastEnabled(drag, args[0] == "on")
+		return a.Status(), nil
+	}
+}
+
+// ctlGet は制御コマンド `get <name>` を処理し、パラメータの値だけを返す
+// （シェルスクリプトやショートカットから扱いやすいように）。
+func ctlGet(a *App, args []string) (any, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("usage: get <%s|preset|paused>", strings.Join(paramNames(), "|"))
+	}
+	switch args[0] {
+	case "preset":
+		return a.Preset().Preset, nil
+	case "paused":
+		return a.Status().Paused, nil
+	}
+	p := a.Preset().Params
+	f, ok := p.field(args[0])
+	if !ok {
+		return nil, fmt.Errorf("unknown parameter %q (available: %s, preset, paused)", args[0], strings.Join(paramNames(), ", "))
+	}
+	return *f, nil
+}
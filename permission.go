@@ -0,0 +1,58 @@
+// permission.go: 実行中のアクセシビリティの権限の取り消しの検出。
+// システム設定で coastpad のアクセシビリティの許可を外すと、EventTap は通知なしに止まり、
+// 合成イベントも届かなくなる（保留中のマウスアップが発行できずボタンが押されたままに見えることもある）。
+// 権限を定期的に確認し、取り消されている間は一時停止（縮退）して通知し、権限が戻ったら自動的に再開する。
+// 再開後、無効になった EventTap はウォッチドッグが作り直す。
+package main
+
+/*
+#cgo LDFLAGS: -framework ApplicationServices
+#include <ApplicationServices/ApplicationServices.h>
+*/
+import "C"
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// permissionCheckInterval はアクセシビリティの権限を確認する間隔。
+const permissionCheckInterval = 2 * time.Second
+
+// permissionMsg はアクセシビリティの権限の変化（trusted は許可されているか）。
+type permissionMsg struct {
+	trusted bool
+}
+
+// accessibilityTrusted は coastpad にアクセシビリティの権限があるかを返す。
+func accessibilityTrusted() bool {
+	return bool(C.AXIsProcessTrusted())
+}
+
+// watchPermission はアクセシビリティの権限を定期的に確認し、取り消されている間は一時停止する。
+// a.stop が閉じられるまでブロックする。
+func (a *App) watchPermission() {
+	ticker := time.NewTicker(permissionCheckInterval)
+	defer ticker.Stop()
+
+	trusted := true
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+			t := accessibilityTrusted()
+			if t == trusted {
+				continue
+			}
+			trusted = t
+			if trusted {
+				fmt.Println("[permission] accessibility permission granted again, resuming")
+			} else {
+				fmt.Fprintln(os.Stderr, "[permission] accessibility permission revoked, suspending until it is granted again")
+				a.alerts.alert(alertPermission, "Accessibility permission was removed. Coasting is suspended until it is granted again in System Settings.")
+			}
+			a.send(permissionMsg{trusted: trusted})
+		}
+	}
+}
@@ -0,0 +1,64 @@
+// phase.go: dragPhase の遷移表と状態の検証。
+// 機能追加で想定外の状態（例: ボタンが離されているのに pendingMouseUp が残ったまま
+// dragPhaseNone になる）が紛れ込まないよう、許可された遷移を表で定義して実行時に確認する。
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// dragPhaseTransitions は許可された dragPhase の遷移（遷移元 → 遷移先の集合）。
+// 同じフェーズへの遷移は常に許可する。
+var dragPhaseTransitions = map[dragPhase][]dragPhase{
+	// リリース時に速度があればドラッグ慣性を開始する
+	dragPhaseNone: {dragPhaseCoasting},
+	// 自然停止・マウスダウンで終了、再タッチで追従または判定保留へ
+	dragPhaseCoasting: {dragPhaseNone, dragPhaseFollowing, dragPhasePendingDecision},
+	// 複数指・リリースで終了、リリース時に速度があればドラッグ慣性を再開する
+	dragPhaseFollowing: {dragPhaseNone, dragPhaseCoasting},
+	// 移動・タイムアウト・リリースで終了、移動前の複数指で追従へ
+	dragPhasePendingDecision: {dragPhaseNone, dragPhaseFollowing},
+}
+
+// dragPhaseAllowed は from から to への遷移が許可されているかを返す。
+func dragPhaseAllowed(from, to dragPhase) bool {
+	if from == to {
+		return true
+	}
+	for _, p := range dragPhaseTransitions[from] {
+		if p == to {
+			return true
+		}
+	}
+	return false
+}
+
+// setDragPhase は dragPhase を遷移させる。許可されていない遷移はログに記録し、
+// 遷移後に validateDragState で状態をリセットさせる。
+// アクター goroutine から呼ぶこと。
+func (a *App) setDragPhase(next dragPhase) {
+	if !dragPhaseAllowed(a.dragPhase, next) {
+		fmt.Fprintf(os.Stderr, "[drag] illegal phase transition %s -> %s\n", a.dragPhase, next)
+		a.dragStateInvalid = true
+	}
+	a.dragPhase = next
+}
+
+// validateDragState はドラッグ状態の整合性を確認し、不正な遷移や矛盾した状態を検出したら
+// ドラッグ状態をリセットして保留中のマウスアップを返す。
+// 返されたイベントは呼び出し側が releasePendingMouseUp すること（ボタンの押しっぱなしを防ぐ）。
+// アクター goroutine から呼ぶこと。
+func (a *App) validateDragState() eventRef {
+	invalid := a.dragStateInvalid
+	if a.pendingMouseUp != 0 && a.dragPhase == dragPhaseNone && !a.isLeftButtonDown {
+		fmt.Fprintln(os.Stderr, "[drag] pending mouseUp held while no drag is in progress")
+		invalid = true
+	}
+	if !invalid {
+		return 0
+	}
+	a.dragStateInvalid = false
+	fmt.Fprintln(os.Stderr, "[drag] resetting drag state")
+	return a.resetCoasting()
+}
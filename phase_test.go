@@ -0,0 +1,83 @@
+// phase_test.go: dragPhase の遷移表（dragPhaseTransitions）と整合性の検査（validateDragState）のテスト。
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+var allDragPhases = []dragPhase{
+	dragPhaseNone,
+	dragPhaseCoasting,
+	dragPhaseFollowing,
+	dragPhasePendingDecision,
+}
+
+// TestSetDragPhase は全ての遷移について、dragPhaseTransitions にない遷移だけが不正とされることを確かめる。
+func TestSetDragPhase(t *testing.T) {
+	for _, from := range allDragPhases {
+		for _, to := range allDragPhases {
+			a := NewApp()
+			a.dragPhase = from
+			a.setDragPhase(to)
+			want := from == to || slices.Contains(dragPhaseTransitions[from], to)
+			if a.dragStateInvalid == want {
+				t.Errorf("%s -> %s: dragStateInvalid = %v, want %v", from, to, a.dragStateInvalid, !want)
+			}
+			if a.dragPhase != to {
+				t.Errorf("%s -> %s: dragPhase = %s", from, to, a.dragPhase)
+			}
+		}
+	}
+}
+
+func TestValidateDragState(t *testing.T) {
+	event := newMouseUpEvent(100, 100)
+	if event == 0 {
+		t.Fatal("failed to create a mouse-up event")
+	}
+	defer releaseEvent(event)
+
+	tests := []struct {
+		name        string
+		phase       dragPhase
+		buttonDown  bool
+		invalid     bool
+		wantRelease bool
+	}{
+		{name: "orphaned mouse-up", phase: dragPhaseNone, wantRelease: true},
+		{name: "illegal transition", phase: dragPhaseFollowing, buttonDown: true, invalid: true, wantRelease: true},
+		{name: "button still down", phase: dragPhaseNone, buttonDown: true},
+		{name: "drag coast", phase: dragPhaseCoasting, buttonDown: true},
+		{name: "following", phase: dragPhaseFollowing, buttonDown: true},
+		{name: "pending decision", phase: dragPhasePendingDecision, buttonDown: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewApp()
+			a.dragPhase = tt.phase
+			a.isLeftButtonDown = tt.buttonDown
+			a.dragStateInvalid = tt.invalid
+			a.pendingMouseUp = event
+			a.vx = 100
+
+			got := a.validateDragState()
+			if !tt.wantRelease {
+				if got != 0 || a.pendingMouseUp != event || a.dragPhase != tt.phase {
+					t.Errorf("state was reset: returned %v, pendingMouseUp = %v, dragPhase = %s", got, a.pendingMouseUp, a.dragPhase)
+				}
+				return
+			}
+			if got != event {
+				t.Errorf("returned %v, want %v", got, event)
+			}
+			if a.pendingMouseUp != 0 || a.dragPhase != dragPhaseNone || a.isLeftButtonDown || a.vx != 0 {
+				t.Errorf("not reset: pendingMouseUp = %v, dragPhase = %s, isLeftButtonDown = %v, vx = %.1f",
+					a.pendingMouseUp, a.dragPhase, a.isLeftButtonDown, a.vx)
+			}
+			if a.dragStateInvalid {
+				t.Error("dragStateInvalid was not cleared")
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+// power.c: IOPowerSources による電源の状態の取得。
+#include <IOKit/ps/IOPowerSources.h>
+#include <IOKit/ps/IOPSKeys.h>
+#include "power.h"
+
+int power_status(int *on_battery, int *percent) {
+    CFTypeRef info = IOPSCopyPowerSourcesInfo();
+    if (info == NULL) {
+        return 0;
+    }
+    CFArrayRef list = IOPSCopyPowerSourcesList(info);
+    if (list == NULL) {
+        CFRelease(info);
+        return 0;
+    }
+    int found = 0;
+    for (CFIndex i = 0; i < CFArrayGetCount(list) && !found; i++) {
+        CFDictionaryRef desc = IOPSGetPowerSourceDescription(info, CFArrayGetValueAtIndex(list, i));
+        if (desc == NULL) {
+            continue;
+        }
+        CFStringRef type = CFDictionaryGetValue(desc, CFSTR(kIOPSTypeKey));
+        if (type == NULL || !CFEqual(type, CFSTR(kIOPSInternalBatteryType))) {
+            continue;
+        }
+        int current = 0, capacity = 0;
+        CFNumberRef n = CFDictionaryGetValue(desc, CFSTR(kIOPSCurrentCapacityKey));
+        CFNumberRef m = CFDictionaryGetValue(desc, CFSTR(kIOPSMaxCapacityKey));
+        if (n == NULL || m == NULL ||
+            !CFNumberGetValue(n, kCFNumberIntType, &current) ||
+            !CFNumberGetValue(m, kCFNumberIntType, &capacity) || capacity <= 0) {
+            continue;
+        }
+        CFStringRef state = CFDictionaryGetValue(desc, CFSTR(kIOPSPowerSourceStateKey));
+        *on_battery = state != NULL && CFEqual(state, CFSTR(kIOPSBatteryPowerValue));
+        *percent = current * 100 / capacity;
+        found = 1;
+    }
+    CFRelease(list);
+    CFRelease(info);
+    return found;
+}
@@ -0,0 +1,107 @@
+// power.go: バッテリー駆動中の省電力モード（--battery-saver）。
+// バッテリー駆動で残量が指定した割合を下回ったら、コーストループの間隔を長くし、
+// コーストもスクロール慣性もない間は ticker を止める（常時の起床をなくす）。
+// 電源に接続するか残量が戻れば、元の滑らかさに戻す。
+package main
+
+/*
+#cgo LDFLAGS: -framework CoreFoundation -framework IOKit
+#include "power.h"
+*/
+import "C"
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// powerCheckInterval は電源の状態を確認する間隔。
+	powerCheckInterval = 30 * time.Second
+	// defaultPowerSaveInterval は省電力モードのコーストループの間隔のデフォルト（~30Hz）。
+	defaultPowerSaveInterval = 33 * time.Millisecond
+)
+
+// powerMsg は電源の状態の変化。
+type powerMsg struct {
+	onBattery bool
+	percent   int
+}
+
+// readPowerStatus はバッテリー駆動中かと残量（%）を返す。内蔵バッテリーがなければ ok=false を返す。
+func readPowerStatus() (onBattery bool, percent int, ok bool) {
+	var battery, pct C.int
+	if C.power_status(&battery, &pct) == 0 {
+		return false, 0, false
+	}
+	return battery != 0, int(pct), true
+}
+
+// watchPower は電源の状態を定期的に確認し、変化をアクターに送る。
+// a.stop が閉じられるまでブロックする。
+func (a *App) watchPower() {
+	ticker := time.NewTicker(powerCheckInterval)
+	defer ticker.Stop()
+
+	var last powerMsg
+	first := true
+	for {
+		onBattery, percent, ok := readPowerStatus()
+		if !ok {
+			return // デスクトップ機
+		}
+		if m := (powerMsg{onBattery: onBattery, percent: percent}); first || m != last {
+			first = false
+			last = m
+			a.send(m)
+		}
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// applyPower は電源の状態を反映し、省電力モードを切り替える。アクター goroutine から呼ぶこと。
+func (a *App) applyPower(m powerMsg) {
+	a.onBattery = m.onBattery
+	a.batteryPercent = m.percent
+	saving := m.onBattery && m.percent < a.batterySaverBelow
+	if saving == a.powerSave {
+		return
+	}
+	a.powerSave = saving
+	if saving {
+		fmt.Printf("[power] on battery at %d%%, power saving (loop interval %v)\n", m.percent, a.powerSaveInterval)
+	} else {
+		fmt.Println("[power] full smoothness restored")
+	}
+}
+
+// frameInterval は現在のコーストループの間隔を返す。
+// 熱の状態が serious 以上の間（thermal.go）は thermalLoopInterval より短くしない。
+// アクター goroutine から呼ぶこと。
+func (a *App) frameInterval() time.Duration {
+	d := a.loopInterval
+	if a.powerSave {
+		d = a.powerSaveInterval
+	}
+	if a.thermalThrottled {
+		d = max(d, thermalLoopInterval)
+	}
+	return d
+}
+
+// framesIdle はコーストループの ticker を止めてよいか（省電力モードか熱で負荷を下げている間で、
+// フレームを必要とする動きがない）を返す。アクター goroutine から呼ぶこと。
+func (a *App) framesIdle() bool {
+	return (a.powerSave || a.thermalThrottled) && a.vx == 0 && a.vy == 0 && !a.holdingDrop() && a.scroll.vx == 0 && a.scroll.vy == 0
+}
+
+// powerMode は制御コマンド status で表示する電源のモードを返す。
+func (a *App) powerMode() string {
+	if a.powerSave {
+		return "saving"
+	}
+	return "normal"
+}
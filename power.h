@@ -0,0 +1,9 @@
+// power.h: 電源の状態（バッテリー駆動か・残量）の取得。
+#ifndef POWER_H
+#define POWER_H
+
+// バッテリー駆動中なら *on_battery に 1、残量（%）を *percent に書き込む。
+// 内蔵バッテリーがない（デスクトップ）・取得できなければ 0 を返す。
+int power_status(int *on_battery, int *percent);
+
+#endif
@@ -0,0 +1,26 @@
+// pprof.go: プロファイリング用の pprof HTTP サーバー。
+// cgo 呼び出しの多いコーストループやコールバック経路を実行中にプロファイルするために使う。
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	_ "net/http/pprof" // DefaultServeMux に /debug/pprof/ を登録する
+	"os"
+)
+
+// startPprofServer は指定アドレスで待ち受けを開始し、pprof サーバーをバックグラウンドで動かす。
+func startPprofServer(addr string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := http.Serve(ln, nil); err != nil {
+			fmt.Fprintf(os.Stderr, "[pprof] server stopped: %v\n", err)
+		}
+	}()
+	fmt.Printf("pprof server listening on %s (/debug/pprof/)\n", ln.Addr())
+	return nil
+}
@@ -0,0 +1,166 @@
+// presets.go: 慣性パラメータと名前付きプリセット。
+// 物理定数を理解しなくても好みの摩擦感を選べるように、パラメータ一式に名前を付けて提供する。
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// coastParams は慣性の物理パラメータを表す。
+type coastParams struct {
+	DecayRate     float64 `json:"decay_rate"`      // 減衰係数 (1/sec)。大きいほど早く止まる
+	StopThreshold float64 `json:"stop_threshold"`  // 停止閾値 (px/sec)
+	MinFlickSpeed float64 `json:"min_flick_speed"` // コーストを開始する最低リリース速度 (px/sec)
+	VelocityGain  float64 `json:"velocity_gain"`   // リリース速度に掛ける倍率
+}
+
+// paramRange はパラメータの許容範囲を表す。
+type paramRange struct {
+	min, max float64
+}
+
+// paramRanges はパラメータ名（JSON 名）ごとの許容範囲。
+var paramRanges = map[string]paramRange{
+	"decay_rate":      {0.5, 30},
+	"stop_threshold":  {1, 200},
+	"min_flick_speed": {0, 3000},
+	"velocity_gain":   {0.1, 5},
+}
+
+// field はパラメータ名（JSON 名）に対応するフィールドへのポインタを返す。
+func (p *coastParams) field(name string) (*float64, bool) {
+	switch name {
+	case "decay_rate":
+		return &p.DecayRate, true
+	case "stop_threshold":
+		return &p.StopThreshold, true
+	case "min_flick_speed":
+		return &p.MinFlickSpeed, true
+	case "velocity_gain":
+		return &p.VelocityGain, true
+	}
+	return nil, false
+}
+
+// set はパラメータを範囲チェックしてから設定する。
+func (p *coastParams) set(name string, value float64) error {
+	f, ok := p.field(name)
+	if !ok {
+		return fmt.Errorf("unknown parameter %q (available: %s)", name, strings.Join(paramNames(), ", "))
+	}
+	r := paramRanges[name]
+	if value < r.min || value > r.max {
+		return fmt.Errorf("%s must be between %g and %g", name, r.min, r.max)
+	}
+	*f = value
+	return nil
+}
+
+// paramNames はパラメータ名をソートして返す。
+func paramNames() []string {
+	names := make([]string, 0, len(paramRanges))
+	for name := range paramRanges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+const defaultPresetName = "default"
+
+// presets は名前付きプリセットの一覧。
+var presets = map[string]coastParams{
+	// 氷の上のようによく滑る
+	"ice": {DecayRate: 2.5, StopThreshold: 5.0, MinFlickSpeed: 0, VelocityGain: 1.0},
+	// 標準（e^(-5t) で減衰）
+	"default": {DecayRate: 5.0, StopThreshold: 10.0, MinFlickSpeed: 0, VelocityGain: 1.0},
+	// カーペットの上のように重く、素早いフリックでのみ滑る
+	"carpet": {DecayRate: 9.0, StopThreshold: 20.0, MinFlickSpeed: 300, VelocityGain: 1.0},
+}
+
+// presetNames はプリセット名をソートして返す。
+func presetNames() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupPreset は名前からプリセットを取得する。
+func lookupPreset(name string) (coastParams, error) {
+	p, ok := presets[name]
+	if !ok {
+		return coastParams{}, fmt.Errorf("unknown preset %q (available: %s)", name, strings.Join(presetNames(), ", "))
+	}
+	return p, nil
+}
+
+// presetStatus は制御コマンド preset の結果を表す。
+type presetStatus struct {
+	Preset string      `json:"preset"` // 個別変更後は空
+	Params coastParams `json:"params"`
+}
+
+// ApplyPreset は名前付きプリセットを適用する。進行中のコーストにも次フレームから反映される。
+func (a *App) ApplyPreset(name string) error {
+	p, err := lookupPreset(name)
+	if err != nil {
+		return err
+	}
+	a.call(func() {
+		a.params = p
+		a.preset = name
+	})
+	return nil
+}
+
+// Preset は現在のプリセット名とパラメータを返す。
+func (a *App) Preset() (s presetStatus) {
+	a.call(func() { s = presetStatus{Preset: a.preset, Params: a.params} })
+	return s
+}
+
+// ctlPreset は制御コマンド `preset [name]` を処理する。
+// 引数なしなら現在の値を返し、名前を指定するとそのプリセットを適用する。
+func ctlPreset(a *App, args []string) (any, error) {
+	switch len(args) {
+	case 0:
+	case 1:
+		if err := a.ApplyPreset(args[0]); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("usage: preset [%s]", strings.Join(presetNames(), "|"))
+	}
+	return a.Preset(), nil
+}
+
+// SetParam はパラメータを1つ変更する。プリセットからの個別変更になるためプリセット名はクリアする。
+func (a *App) SetParam(name string, value float64) (err error) {
+	a.call(func() {
+		if err = a.params.set(name, value); err == nil {
+			a.preset = ""
+		}
+	})
+	return err
+}
+
+// ctlSet は制御コマンド `set <name> <value>` を処理する。
+func ctlSet(a *App, args []string) (any, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("usage: set <%s> <value>", strings.Join(paramNames(), "|"))
+	}
+	value, err := strconv.ParseFloat(args[1], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %q: %w", args[1], err)
+	}
+	if err := a.SetParam(args[0], value); err != nil {
+		return nil, err
+	}
+	return a.Preset(), nil
+}
@@ -0,0 +1,165 @@
+// releasefilter.go: リリース時にコーストを変更・取り消すスクリプトフック。
+// 指を離した時点の速度・位置・最前面のアプリをユーザーのスクリプトに渡し、
+// 返された速度でコーストを始める（または取り消す）。物理をプログラムで調整したい
+// パワーユーザー向け。スクリプトは Lua でも Python でも、標準入出力で JSON を扱えれば何でもよい。
+//
+// スクリプトは起動時に1回だけ起動して常駐させ、リリースごとに1行の JSON を標準入力に送り、
+// 1行の JSON を標準出力から受け取る:
+//
+//	→ {"id":1,"vx":1200,"vy":-300,"x":640,"y":400,"app":"com.apple.Safari","drag":false}
+//	← {"id":1,"vx":600,"vy":-150}   速度を変更する（省略した軸は変更しない）
+//	← {"id":1,"cancel":true}         コーストを取り消す
+//
+// 応答が releaseFilterTimeout 以内に届かなければ元の速度のまま続ける。
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// releaseFilterTimeout はスクリプトの応答を待つ時間。リリースの処理（アクター）を止めるため短くする。
+const releaseFilterTimeout = 20 * time.Millisecond
+
+// releaseFilterRequest はスクリプトに送るリリース時の状態。
+type releaseFilterRequest struct {
+	ID   uint64  `json:"id"`
+	VX   float64 `json:"vx"`
+	VY   float64 `json:"vy"`
+	X    float64 `json:"x"`
+	Y    float64 `json:"y"`
+	App  string  `json:"app"`
+	Drag bool    `json:"drag"`
+}
+
+// releaseFilterReply はスクリプトからの応答。
+type releaseFilterReply struct {
+	ID     uint64   `json:"id"`
+	VX     *float64 `json:"vx"`
+	VY     *float64 `json:"vy"`
+	Cancel bool     `json:"cancel"`
+}
+
+// releaseFilter は常駐するスクリプトのプロセスを管理する。
+type releaseFilter struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	replies chan releaseFilterReply // 標準出力から読んだ応答（読み取り goroutine が書き込む）
+	nextID  uint64
+}
+
+// startReleaseFilter はスクリプトを /bin/sh で起動する。
+func startReleaseFilter(command string) (*releaseFilter, error) {
+	cmd := exec.Command("/bin/sh", "-c", command)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	f := &releaseFilter{cmd: cmd, stdin: stdin, replies: make(chan releaseFilterReply, 1)}
+	go func() {
+		defer close(f.replies)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			var reply releaseFilterReply
+			if err := json.Unmarshal(scanner.Bytes(), &reply); err != nil {
+				fmt.Fprintf(os.Stderr, "[filter] invalid reply: %v\n", err)
+				continue
+			}
+			// 応答を待っていない（タイムアウト済み）場合は捨てる
+			select {
+			case f.replies <- reply:
+			default:
+			}
+		}
+	}()
+	return f, nil
+}
+
+// filter はリリース時の状態をスクリプトに送り、応答に応じた速度を返す。
+// 取り消された場合は速度 0 を返す。応答がない・スクリプトが終了している場合は元の速度を返す。
+// アクター goroutine から呼ぶこと。
+func (f *releaseFilter) filter(req releaseFilterRequest) (vx, vy float64) {
+	vx, vy = req.VX, req.VY
+
+	// タイムアウト後に届いた古い応答を捨てる
+	select {
+	case <-f.replies:
+	default:
+	}
+
+	f.nextID++
+	req.ID = f.nextID
+	data, err := json.Marshal(req)
+	if err != nil {
+		return vx, vy
+	}
+	if _, err := f.stdin.Write(append(data, '\n')); err != nil {
+		fmt.Fprintf(os.Stderr, "[filter] write failed: %v\n", err)
+		return vx, vy
+	}
+
+	timeout := time.NewTimer(releaseFilterTimeout)
+	defer timeout.Stop()
+	for {
+		select {
+		case reply, ok := <-f.replies:
+			if !ok {
+				return vx, vy
+			}
+			if reply.ID != req.ID {
+				continue
+			}
+			if reply.Cancel {
+				return 0, 0
+			}
+			if reply.VX != nil {
+				vx = *reply.VX
+			}
+			if reply.VY != nil {
+				vy = *reply.VY
+			}
+			return vx, vy
+		case <-timeout.C:
+			fmt.Fprintln(os.Stderr, "[filter] no reply in time, keeping the release velocity")
+			return vx, vy
+		}
+	}
+}
+
+// close はスクリプトの標準入力を閉じてプロセスを終了させる。
+// シェルの子プロセス（スクリプト本体）は標準入力の EOF で終了する。
+func (f *releaseFilter) close() {
+	f.stdin.Close()
+	f.cmd.Process.Kill()
+	f.cmd.Wait()
+}
+
+// filterRelease はリリース速度をスクリプトに渡して調整する。フィルタが無効か速度がなければ何もしない。
+// アクター goroutine から呼ぶこと。
+func (a *App) filterRelease(x, y float64) {
+	if a.releaseFilter == nil || (a.vx == 0 && a.vy == 0) {
+		return
+	}
+	a.vx, a.vy = a.releaseFilter.filter(releaseFilterRequest{
+		VX:   a.vx,
+		VY:   a.vy,
+		X:    x,
+		Y:    y,
+		App:  cachedFrontmostBundleID(),
+		Drag: a.isLeftButtonDown,
+	})
+}
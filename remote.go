@@ -0,0 +1,118 @@
+// remote.go: 画面共有・リモートデスクトップでの操作中の一時停止。
+// 画面共有（Apple Remote Desktop・VNC クライアントからの接続）で遠隔から操作されている間は、
+// 合成の慣性がリモートの操作者の絶対位置のイベントと取り合いになるため、一時停止する。
+// 画面共有中でもトラックパッドに触れれば手元の操作とみなして再開し、
+// 手元の操作が remoteLocalTimeout 途切れたら再び一時停止する。
+package main
+
+/*
+#cgo LDFLAGS: -framework CoreGraphics -framework CoreFoundation
+#include <CoreGraphics/CoreGraphics.h>
+
+// session_screen_shared は現在のセッションの画面が共有（遠隔操作）されていれば 1 を返す。
+// キーは非公開のため、取得できなければ 0 を返す（一時停止しない）。
+static int session_screen_shared(void) {
+    CFDictionaryRef dict = CGSessionCopyCurrentDictionary();
+    if (dict == NULL) {
+        return 0;
+    }
+    int shared = 0;
+    CFBooleanRef value = CFDictionaryGetValue(dict, CFSTR("CGSSessionScreenIsShared"));
+    if (value != NULL && CFGetTypeID(value) == CFBooleanGetTypeID()) {
+        shared = CFBooleanGetValue(value);
+    }
+    CFRelease(dict);
+    return shared;
+}
+*/
+import "C"
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// remoteCheckInterval は画面共有中かを確認する間隔。
+	remoteCheckInterval = 2 * time.Second
+
+	// remoteLocalTimeout は画面共有中に手元の操作が途切れてから再び一時停止するまでの時間（秒）。
+	remoteLocalTimeout = 10.0
+)
+
+// remoteMsg は画面共有の状態（shared は共有中か）。共有中は確認のたびに送る。
+type remoteMsg struct {
+	shared bool
+}
+
+// screenShared は自分のセッションの画面が共有されているかを返す。
+func screenShared() bool {
+	return C.session_screen_shared() != 0
+}
+
+// watchRemote は画面共有の状態を定期的に確認してアクターに送る。
+// 共有中は手元の操作が途切れたかを確かめるため、変化がなくても送る。
+// a.stop が閉じられるまでブロックする。
+func (a *App) watchRemote() {
+	ticker := time.NewTicker(remoteCheckInterval)
+	defer ticker.Stop()
+
+	shared := false
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+			s := screenShared()
+			if s == shared && !s {
+				continue
+			}
+			if s != shared {
+				if s {
+					fmt.Println("[remote] screen sharing started")
+				} else {
+					fmt.Println("[remote] screen sharing ended")
+				}
+			}
+			shared = s
+			a.send(remoteMsg{shared: shared})
+		}
+	}
+}
+
+// applyRemote は画面共有の状態を反映する。共有中で手元の操作が途切れていれば一時停止し、
+// 共有が終われば再開する。保留中のマウスアップを返す（releasePendingMouseUp すること）。
+// アクター goroutine から呼ぶこと。
+func (a *App) applyRemote(shared bool) eventRef {
+	if shared && !a.remoteShared {
+		// 共有の開始: 直前まで手元で操作していてもリモートの操作者を優先する
+		a.remoteLocalAt = 0
+	}
+	a.remoteShared = shared
+	controlled := shared && !a.isTouched && a.now()-a.remoteLocalAt >= remoteLocalTimeout
+	if controlled == a.remoteControlled {
+		return 0
+	}
+	a.remoteControlled = controlled
+	if controlled {
+		fmt.Println("[remote] screen is being controlled remotely, suspending")
+	} else if shared {
+		fmt.Println("[remote] local input during screen sharing, resuming")
+	} else {
+		fmt.Println("[remote] resuming")
+	}
+	return a.updateSuspend()
+}
+
+// noteLocalInput は画面共有中のトラックパッドのタッチを手元の操作として記録し、
+// 遠隔操作による一時停止中なら再開する。保留中のマウスアップを返す（applyRemote と同じ）。
+// アクター goroutine から呼ぶこと。
+func (a *App) noteLocalInput(fingerCount int) eventRef {
+	if !a.remoteShared || fingerCount == 0 {
+		return 0
+	}
+	a.remoteLocalAt = a.now()
+	if !a.remoteControlled {
+		return 0
+	}
+	return a.applyRemote(true)
+}
@@ -0,0 +1,17 @@
+// safedrop.go: ゴミ箱へのドロップの確認。
+// 投げたファイルが Dock のゴミ箱の上で止まった場合、そのまま mouseUp を解放すると
+// 意図せずゴミ箱へ捨ててしまう。ドラッグを保持したまま確認を待ち、
+// 1本指のタップ（判定保留中のリリース）でだけドロップする。
+// 指を動かすか複数指で触れた場合は追従モードへ移り、ドラッグを掴み直せる。
+package main
+
+import "fmt"
+
+// holdForDropConfirm はドラッグ慣性の停止位置でドロップの確認待ちに入る。
+// 速度はゼロのままドラッグフェーズを維持するため、コーストフレームは何も発行せず、
+// 次のタッチは通常どおりドラッグ判定保留へ移る（handleTouchDuringCoast）。
+// アクター goroutine から呼ぶこと。
+func (a *App) holdForDropConfirm() {
+	a.dropConfirm = true
+	fmt.Println("[drag] drag coast stopped on the Trash, tap to drop or move to keep dragging")
+}
@@ -0,0 +1,100 @@
+// scroll.go: スクロールイベントの処理。
+// 外付けマウスのスクロールホイール平滑化: 段階的な（非連続の）ホイールクリックを
+// EventTap で傍受して消費し、代わりにカーソル慣性と同じ指数減衰で滑らかに減速する
+// ピクセル単位のスクロールを発行する。
+// スクロールジェスチャー検出: 2本指スクロールのリリースをフリックと誤認しないよう、
+// タッチ中のスクロールフェーズ付きイベントを記録する。
+package main
+
+import "math"
+
+// scrollStepPixels はホイール1クリックあたりの総スクロール量 (px)。
+const scrollStepPixels = 40.0
+
+// scrollState はスクロール慣性の状態を表す。
+type scrollState struct {
+	vx, vy         float64 // スクロール速度 (px/sec)
+	accumX, accumY float64 // 端数デルタ蓄積
+}
+
+// scrollAction はスクロール慣性の1フレームで発行するスクロール量を表す。
+type scrollAction struct {
+	dx, dy  int
+	hasMove bool
+}
+
+// onScrollWheel は EventTap から段階的なホイール入力（行単位）で呼ばれ、処理をアクターに任せる。
+func (a *App) onScrollWheel(linesX, linesY int) {
+	if a.tapEngine != nil {
+		a.tapEngine.notify("scroll", linesX, linesY)
+		return
+	}
+	a.send(scrollWheelMsg{linesX: linesX, linesY: linesY})
+}
+
+// addScrollVelocity はホイール入力をスクロール速度に加算する。
+// 指数減衰での総移動量は v/k になるため、1クリックで scrollStepPixels 進むよう
+// 速度に step*k を加算する。逆方向の入力では慣性を打ち消してから加算する。
+// アクター goroutine から呼ぶこと。
+func (a *App) addScrollVelocity(linesX, linesY int) {
+	k := a.params.DecayRate
+	if linesY != 0 {
+		if (a.scroll.vy > 0) != (linesY > 0) {
+			a.scroll.vy = 0
+		}
+		a.scroll.vy += float64(linesY) * scrollStepPixels * k
+	}
+	if linesX != 0 {
+		if (a.scroll.vx > 0) != (linesX > 0) {
+			a.scroll.vx = 0
+		}
+		a.scroll.vx += float64(linesX) * scrollStepPixels * k
+	}
+}
+
+// prepareScrollFrame はスクロール慣性の1フレーム分を計算する。アクター goroutine から呼ぶこと。
+func (a *App) prepareScrollFrame(dt float64) scrollAction {
+	var action scrollAction
+	s := &a.scroll
+	if s.vx == 0 && s.vy == 0 {
+		return action
+	}
+
+	s.accumX += s.vx * dt
+	s.accumY += s.vy * dt
+	action.dx, action.dy = int(s.accumX), int(s.accumY)
+	s.accumX -= float64(action.dx)
+	s.accumY -= float64(action.dy)
+	action.hasMove = action.dx != 0 || action.dy != 0
+
+	factor := math.Exp(-a.params.DecayRate * dt)
+	s.vx *= factor
+	s.vy *= factor
+	if math.Hypot(s.vx, s.vy) < a.params.StopThreshold {
+		*s = scrollState{}
+	}
+	return action
+}
+
+// executeScrollFrame はスクロールイベントを発行する。
+func executeScrollFrame(action scrollAction) {
+	if action.hasMove {
+		postScrollPixels(action.dx, action.dy)
+	}
+}
+
+// onScrollPhase は EventTap からスクロールフェーズ付き（トラックパッドのジェスチャー）の
+// スクロールイベントで呼ばれる。現在のタッチがスクロールジェスチャーであることの記録はアクターが行う。
+func (a *App) onScrollPhase() {
+	if a.tapEngine != nil {
+		a.tapEngine.notify("scroll-phase")
+		return
+	}
+	a.send(scrollPhaseMsg{})
+}
+
+// isScrollGesture は現在のタッチが2本指以上のスクロールジェスチャーかを返す。
+// アクター goroutine から呼ぶこと。
+func (a *App) isScrollGesture() bool {
+	return a.touchScrolled && a.maxFingers >= 2
+}
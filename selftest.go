@@ -0,0 +1,205 @@
+// selftest.go: 実機での結合テスト（`coastpad selftest`）。
+// 仮想 HID タッチパッド（virtualhid.go）から台本どおりのタッチを入れ、HID バックエンド・状態機械・
+// イベントの発行（mouse.c）を通して、合成イベントが WindowServer に届いて実際にカーソルが動いたかを
+// カーソル位置から確かめる。
+//
+// 仮想デバイスの作成には entitlement が必要なため、selftest.entitlements で署名したテスト用のビルドでだけ実行できる。
+// macOS はデジタイザのタッチパッドではポインタを動かさないため、指の移動に伴うカーソルの移動は
+// トラックパッドと同じく mouseMoved の発行で再現する。
+// 実行中はトラックパッドとマウスに触れないこと。
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	selftestFrameInterval = 8 * time.Millisecond // 台本のタッチフレームの間隔（トラックパッドと同程度）
+	selftestSettle        = 300 * time.Millisecond
+	selftestCoastTimeout  = 5 * time.Second
+	selftestDeviceTimeout = 2 * time.Second // 仮想タッチパッドが HID バックエンドに見えるまでの待ち時間
+
+	// selftestStopTolerance は予測停止点と実際の停止位置の許容差（px）。
+	// 整数への丸めと、リリースから予測までに進んだフレーム分の誤差を見込む。
+	selftestStopTolerance = 3.0
+)
+
+// selftestCase は1つの台本と、その結果の確認を表す。
+type selftestCase struct {
+	name string
+	run  func(a *App, pad *virtualTouchpad, x, y float64) error
+}
+
+var selftestCases = []selftestCase{
+	{"flick coasts to the predicted stop point", selftestFlick},
+	{"touch during a coast catches the cursor", selftestCatch},
+}
+
+// runSelftestCommand は `coastpad selftest` を実行する。
+// 実行中の coastpad とは EventTap とタッチの受信が競合するため、停止してから実行すること。
+func runSelftestCommand(args []string) error {
+	if len(args) != 0 {
+		return errors.New("usage: coastpad selftest")
+	}
+	if pid, alive := readPID(); alive {
+		return fmt.Errorf("coastpad is running (pid %d), stop it first", pid)
+	}
+	screens := screenBounds()
+	if len(screens) == 0 {
+		return errors.New("no displays")
+	}
+	// 主ディスプレイの中央の左寄りから始める（右へのフリックが画面端に届かないように）
+	s := screens[0]
+	x0, y0 := s.minX+(s.maxX-s.minX)/4, (s.minY+s.maxY)/2
+
+	app = NewApp()
+	app.touchBackend = touchBackendHID
+	if err := app.Open(); err != nil {
+		return err
+	}
+	runDone := make(chan struct{})
+	go func() {
+		app.Run()
+		close(runDone)
+	}()
+	defer func() {
+		app.Stop()
+		<-runDone
+	}()
+
+	pad, err := startSelftestTouchpad(app)
+	if err != nil {
+		return err
+	}
+	defer pad.close()
+
+	fmt.Println("Running self-test, don't touch the trackpad or mouse...")
+	failed := 0
+	for _, tc := range selftestCases {
+		warpCursor(x0, y0)
+		reassociateMouse()
+		time.Sleep(selftestSettle)
+		if err := tc.run(app, pad, x0, y0); err != nil {
+			fmt.Printf("FAIL  %s: %v\n", tc.name, err)
+			failed++
+		} else {
+			fmt.Printf("ok    %s\n", tc.name)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d self-tests failed", failed, len(selftestCases))
+	}
+	return nil
+}
+
+// startSelftestTouchpad は仮想タッチパッドを作り、HID バックエンドのデバイスに加わるまで待つ。
+func startSelftestTouchpad(a *App) (*virtualTouchpad, error) {
+	before := a.touchDevices.Count()
+	pad, err := newVirtualTouchpad()
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(selftestDeviceTimeout)
+	for a.touchDevices.Count() <= before {
+		if time.Now().After(deadline) {
+			pad.close()
+			return nil, errors.New("the virtual touchpad did not appear in the HID event system")
+		}
+		time.Sleep(selftestFrameInterval)
+	}
+	return pad, nil
+}
+
+// selftestSwipe は1本指で右へ frames フレーム、1フレーム step px ずつ動かすタッチを流す。
+// 指の移動はトラックパッドと同じく mouseMoved で再現し、そのカーソル位置をエンジンが読む。
+func selftestSwipe(pad *virtualTouchpad, frames, step int) error {
+	for i := range frames {
+		postMouseMovedBy(step, 0)
+		if err := pad.touch(1, 0.3+0.02*float64(i), 0.5); err != nil {
+			return err
+		}
+		time.Sleep(selftestFrameInterval)
+	}
+	return nil
+}
+
+// selftestRelease は指を離す。
+func selftestRelease(pad *virtualTouchpad) error {
+	return pad.touch(0, 0, 0)
+}
+
+// selftestWaitStop はコーストが止まるまで待ち、止まった位置を返す。
+func selftestWaitStop(a *App) (x, y float64, err error) {
+	deadline := time.Now().Add(selftestCoastTimeout)
+	for a.Status().Coasting {
+		if time.Now().After(deadline) {
+			return 0, 0, errors.New("coast did not stop")
+		}
+		time.Sleep(selftestFrameInterval)
+	}
+	x, y, ok := getMouseLocation()
+	if !ok {
+		return 0, 0, errors.New("failed to read the cursor location")
+	}
+	return x, y, nil
+}
+
+// selftestFlick は右へのフリックでコーストが始まり、予測どおりの位置に止まるかを確かめる。
+func selftestFlick(a *App, pad *virtualTouchpad, x0, _ float64) error {
+	if err := selftestSwipe(pad, 10, 12); err != nil { // ~1500 px/sec
+		return err
+	}
+	if err := selftestRelease(pad); err != nil {
+		return err
+	}
+	time.Sleep(selftestFrameInterval) // リリースのレポートがエンジンに届くまで
+	p := a.Prediction()
+	if !p.Coasting {
+		return errors.New("release did not start a coast")
+	}
+	x, y, err := selftestWaitStop(a)
+	if err != nil {
+		return err
+	}
+	if d := math.Hypot(x-p.StopX, y-p.StopY); d > selftestStopTolerance {
+		return fmt.Errorf("stopped at (%.0f, %.0f), %.1f px from the predicted (%.0f, %.0f)", x, y, d, p.StopX, p.StopY)
+	}
+	if x-x0 < 120+50 {
+		return fmt.Errorf("cursor only moved %.0f px", x-x0)
+	}
+	return nil
+}
+
+// selftestCatch はコースト中に触れるとその場で止まる（以降カーソルが動かない）かを確かめる。
+func selftestCatch(a *App, pad *virtualTouchpad, _, _ float64) error {
+	if err := selftestSwipe(pad, 10, 12); err != nil {
+		return err
+	}
+	if err := selftestRelease(pad); err != nil {
+		return err
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := pad.touch(1, 0.5, 0.5); err != nil {
+		return err
+	}
+	time.Sleep(selftestFrameInterval)
+	if a.Status().Coasting {
+		return errors.New("touch did not stop the coast")
+	}
+	x0, y0, _ := getMouseLocation()
+	time.Sleep(selftestSettle)
+	if err := selftestRelease(pad); err != nil {
+		return err
+	}
+	x, y, err := selftestWaitStop(a)
+	if err != nil {
+		return err
+	}
+	if d := math.Hypot(x-x0, y-y0); d > 1 {
+		return fmt.Errorf("cursor kept moving %.0f px after the touch", d)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+// session.go: ログインセッションがコンソールにあるかの検出。
+// ファストユーザスイッチで別のユーザーに切り替わっている間やログイン画面の間は、
+// 合成イベントを自分のセッションに発行するのは誤りのため、一時停止する。
+package main
+
+/*
+#cgo LDFLAGS: -framework CoreGraphics -framework CoreFoundation
+#include <CoreGraphics/CoreGraphics.h>
+
+// session_on_console は現在のセッションがコンソール（画面）にあれば 1 を返す。
+// セッション情報を取得できなければ 1 を返す（一時停止しない）。
+static int session_on_console(void) {
+    CFDictionaryRef dict = CGSessionCopyCurrentDictionary();
+    if (dict == NULL) {
+        return 1;
+    }
+    int on = 1;
+    CFBooleanRef value = CFDictionaryGetValue(dict, kCGSessionOnConsoleKey);
+    if (value != NULL && CFGetTypeID(value) == CFBooleanGetTypeID()) {
+        on = CFBooleanGetValue(value);
+    }
+    CFRelease(dict);
+    return on;
+}
+*/
+import "C"
+import (
+	"fmt"
+	"time"
+)
+
+// sessionCheckInterval はセッションがコンソールにあるかを確認する間隔。
+const sessionCheckInterval = 2 * time.Second
+
+// sessionMsg はセッションの状態の変化（active はコンソールにあるか）。
+type sessionMsg struct {
+	active bool
+}
+
+// sessionOnConsole は自分のログインセッションがコンソールにあるかを返す。
+func sessionOnConsole() bool {
+	return C.session_on_console() != 0
+}
+
+// watchSession はセッションの状態を定期的に確認し、コンソールにない間は一時停止する。
+// a.stop が閉じられるまでブロックする。
+func (a *App) watchSession() {
+	ticker := time.NewTicker(sessionCheckInterval)
+	defer ticker.Stop()
+
+	active := true
+	for {
+		select {
+		case <-a.stop:
+			return
+		case <-ticker.C:
+			on := sessionOnConsole()
+			if on == active {
+				continue
+			}
+			active = on
+			if active {
+				fmt.Println("[session] session back on the console, resuming")
+			} else {
+				fmt.Println("[session] session left the console (user switch or login window), suspending")
+			}
+			a.send(sessionMsg{active: active})
+		}
+	}
+}
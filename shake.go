@@ -0,0 +1,37 @@
+// shake.go: 「シェイクしてマウスポインタを見つける」の誤作動の防止（--shake-guard）。
+// コースト中に捕まえて逆向きに投げ直すことを素早く繰り返すと、合成したカーソル移動が往復の揺さぶりになり、
+// macOS がポインタを一時的に拡大する。合成イベントをシェイクの検出から外す印はないため、
+// 短い間隔で向きを折り返すコーストが続いたら、それ以上の折り返しのコーストを開始しない。
+package main
+
+import "math"
+
+const (
+	// shakeGuardWindow は前のコーストの最後のフレームから、折り返しとして数える期間（秒）。
+	shakeGuardWindow = 0.6
+	// shakeGuardMaxReversals は続けて許す折り返しのコーストの数。これを超えたコーストは開始しない。
+	shakeGuardMaxReversals = 2
+	// shakeReverseMinAngle は前のコーストと逆向きとみなす角度の差の下限（度）。
+	shakeReverseMinAngle = 120.0
+)
+
+// guardShake はリリース時の速度が前のコーストと逆向きで、前のコーストの最後のフレームから
+// shakeGuardWindow 以内なら折り返しとして数え、shakeGuardMaxReversals 回を超えたら速度を 0 にする。
+// now はリリースの時刻。アクター goroutine から呼ぶこと。
+func (a *App) guardShake(now float64) {
+	if !a.shakeGuard || (a.vx == 0 && a.vy == 0) {
+		return
+	}
+	speed := math.Hypot(a.vx, a.vy)
+	cos := (a.vx*a.lastCoastDirX + a.vy*a.lastCoastDirY) / speed
+	reversed := a.lastCoastAt != 0 && now-a.lastCoastAt <= shakeGuardWindow &&
+		cos < math.Cos(shakeReverseMinAngle*math.Pi/180)
+	if !reversed {
+		a.shakeReversals = 0
+		return
+	}
+	a.shakeReversals++
+	if a.shakeReversals > shakeGuardMaxReversals {
+		a.vx, a.vy = 0, 0
+	}
+}
@@ -0,0 +1,259 @@
+// snap.c: アクセシビリティ API による UI 要素の検索（吸着モード・ドロップ先の判定）。
+#include "snap.h"
+
+// 応答しないアプリでコーストの終盤が遅れないよう、問い合わせのタイムアウトを短くする（秒）
+#define SNAP_MESSAGING_TIMEOUT 0.05
+
+// クリック可能とみなすロール
+static const char *clickable_roles[] = {
+    "AXButton", "AXCheckBox", "AXRadioButton", "AXPopUpButton", "AXMenuButton",
+    "AXLink", "AXMenuItem", "AXMenuBarItem", "AXDisclosureTriangle", "AXTab",
+};
+
+// has_role は要素のロールが roles のいずれかなら 1 を返す。
+static int has_role(AXUIElementRef element, const char **roles, size_t n) {
+    CFTypeRef role = NULL;
+    if (AXUIElementCopyAttributeValue(element, kAXRoleAttribute, &role) != kAXErrorSuccess || role == NULL) {
+        return 0;
+    }
+    int match = 0;
+    if (CFGetTypeID(role) == CFStringGetTypeID()) {
+        for (size_t i = 0; i < n && !match; i++) {
+            CFStringRef r = CFStringCreateWithCString(NULL, roles[i], kCFStringEncodingUTF8);
+            match = CFEqual(role, r);
+            CFRelease(r);
+        }
+    }
+    CFRelease(role);
+    return match;
+}
+
+static int is_clickable(AXUIElementRef element) {
+    return has_role(element, clickable_roles, sizeof(clickable_roles) / sizeof(clickable_roles[0]));
+}
+
+// is_directory は要素が表すファイル（AXURL 属性）がディレクトリなら 1 を返す。
+// Finder のアイコンやリストの項目は AXURL でファイルの場所を公開している。
+static int is_directory(AXUIElementRef element) {
+    CFTypeRef url = NULL;
+    if (AXUIElementCopyAttributeValue(element, kAXURLAttribute, &url) != kAXErrorSuccess || url == NULL) {
+        return 0;
+    }
+    int dir = CFGetTypeID(url) == CFURLGetTypeID() && CFURLHasDirectoryPath((CFURLRef)url);
+    CFRelease(url);
+    return dir;
+}
+
+static int copy_value(AXUIElementRef element, CFStringRef attr, AXValueType type, void *out) {
+    CFTypeRef value = NULL;
+    if (AXUIElementCopyAttributeValue(element, attr, &value) != kAXErrorSuccess || value == NULL) {
+        return 0;
+    }
+    int ok = AXValueGetValue((AXValueRef)value, type, out);
+    CFRelease(value);
+    return ok;
+}
+
+// copy_element_at は (x, y) にある UI 要素を返す（呼び出し側が CFRelease する）。
+static AXUIElementRef copy_element_at(double x, double y) {
+    AXUIElementRef system = AXUIElementCreateSystemWide();
+    if (system == NULL) {
+        return NULL;
+    }
+    AXUIElementSetMessagingTimeout(system, SNAP_MESSAGING_TIMEOUT);
+
+    AXUIElementRef element = NULL;
+    AXError err = AXUIElementCopyElementAtPosition(system, (float)x, (float)y, &element);
+    CFRelease(system);
+    if (err != kAXErrorSuccess) {
+        return NULL;
+    }
+    return element;
+}
+
+int snap_clickable_frame_at(double x, double y, CGRect *frame) {
+    AXUIElementRef element = copy_element_at(x, y);
+    if (element == NULL) {
+        return 0;
+    }
+
+    int ok = is_clickable(element) &&
+             copy_value(element, kAXPositionAttribute, kAXValueCGPointType, &frame->origin) &&
+             copy_value(element, kAXSizeAttribute, kAXValueCGSizeType, &frame->size);
+    CFRelease(element);
+    return ok;
+}
+
+// is_trash は要素が Dock のゴミ箱なら 1 を返す。
+static int is_trash(AXUIElementRef element) {
+    CFTypeRef subrole = NULL;
+    if (AXUIElementCopyAttributeValue(element, kAXSubroleAttribute, &subrole) != kAXErrorSuccess || subrole == NULL) {
+        return 0;
+    }
+    CFStringRef trash = CFStringCreateWithCString(NULL, "AXTrashDockItem", kCFStringEncodingUTF8);
+    int match = CFEqual(subrole, trash);
+    CFRelease(trash);
+    CFRelease(subrole);
+    return match;
+}
+
+int snap_drop_target_at(double x, double y) {
+    static const char *dock_roles[] = {"AXDockItem"};
+    AXUIElementRef element = copy_element_at(x, y);
+    if (element == NULL) {
+        return SNAP_DROP_NONE;
+    }
+    int kind = SNAP_DROP_NONE;
+    if (is_trash(element)) {
+        kind = SNAP_DROP_TRASH;
+    } else if (has_role(element, dock_roles, 1) || is_directory(element)) {
+        kind = SNAP_DROP_SPRING;
+    }
+    CFRelease(element);
+    return kind;
+}
+
+// copy_string は要素の文字列属性を buf に書き込む。値がなければ空文字にする。
+static void copy_string(AXUIElementRef element, CFStringRef attr, char *buf, int len) {
+    buf[0] = '\0';
+    CFTypeRef value = NULL;
+    if (AXUIElementCopyAttributeValue(element, attr, &value) != kAXErrorSuccess || value == NULL) {
+        return;
+    }
+    if (CFGetTypeID(value) == CFStringGetTypeID()) {
+        CFStringGetCString((CFStringRef)value, buf, len, kCFStringEncodingUTF8);
+    }
+    CFRelease(value);
+}
+
+int snap_window_at(double x, double y, char *title, int title_len, char *role, int role_len,
+                   char *subrole, int subrole_len) {
+    AXUIElementRef element = copy_element_at(x, y);
+    if (element == NULL) {
+        return 0;
+    }
+    // 要素を含むウインドウ（シートの中の要素ならシート）。ウインドウ自体（タイトルバー等）なら要素をそのまま使う
+    AXUIElementRef window = NULL;
+    if (AXUIElementCopyAttributeValue(element, kAXWindowAttribute, (CFTypeRef *)&window) != kAXErrorSuccess ||
+        window == NULL) {
+        window = (AXUIElementRef)CFRetain(element);
+    }
+    CFRelease(element);
+
+    copy_string(window, kAXTitleAttribute, title, title_len);
+    copy_string(window, kAXRoleAttribute, role, role_len);
+    copy_string(window, kAXSubroleAttribute, subrole, subrole_len);
+    CFRelease(window);
+    return 1;
+}
+
+int snap_text_selection_at(double x, double y) {
+    static const char *text_roles[] = {"AXTextArea", "AXTextField", "AXStaticText"};
+    static const char *focus_roles[] = {"AXTextArea", "AXTextField", "AXStaticText", "AXWebArea"};
+    AXUIElementRef element = copy_element_at(x, y);
+    if (element == NULL) {
+        return 0;
+    }
+    int text = has_role(element, text_roles, 3);
+    CFRelease(element);
+    if (!text) {
+        return 0;
+    }
+
+    // 文字の上でも、フォーカスが移らない（Finder のリストの名前等）なら項目のドラッグとみなす
+    AXUIElementRef system = AXUIElementCreateSystemWide();
+    if (system == NULL) {
+        return 0;
+    }
+    AXUIElementSetMessagingTimeout(system, SNAP_MESSAGING_TIMEOUT);
+    AXUIElementRef focused = NULL;
+    AXError err = AXUIElementCopyAttributeValue(system, kAXFocusedUIElementAttribute, (CFTypeRef *)&focused);
+    CFRelease(system);
+    if (err != kAXErrorSuccess || focused == NULL) {
+        return 0;
+    }
+    int selecting = has_role(focused, focus_roles, 4);
+    CFRelease(focused);
+    return selecting;
+}
+
+// titlebar_bottom はウインドウのタイトルバーの下端（CG 座標の y）を *bottom に返す。
+// 閉じるボタンとツールバーのうち下にあるものの下端を使う。どちらもない（枠のないウインドウ）なら 0 を返す。
+static int titlebar_bottom(AXUIElementRef window, double *bottom) {
+    int found = 0;
+    CGPoint origin;
+    CGSize size;
+
+    AXUIElementRef close = NULL;
+    if (AXUIElementCopyAttributeValue(window, kAXCloseButtonAttribute, (CFTypeRef *)&close) == kAXErrorSuccess &&
+        close != NULL) {
+        if (copy_value(close, kAXPositionAttribute, kAXValueCGPointType, &origin) &&
+            copy_value(close, kAXSizeAttribute, kAXValueCGSizeType, &size)) {
+            *bottom = origin.y + size.height;
+            found = 1;
+        }
+        CFRelease(close);
+    }
+
+    static const char *toolbar_roles[] = {"AXToolbar"};
+    CFArrayRef children = NULL;
+    if (AXUIElementCopyAttributeValue(window, kAXChildrenAttribute, (CFTypeRef *)&children) == kAXErrorSuccess &&
+        children != NULL) {
+        for (CFIndex i = 0; i < CFArrayGetCount(children); i++) {
+            AXUIElementRef child = (AXUIElementRef)CFArrayGetValueAtIndex(children, i);
+            if (has_role(child, toolbar_roles, 1) &&
+                copy_value(child, kAXPositionAttribute, kAXValueCGPointType, &origin) &&
+                copy_value(child, kAXSizeAttribute, kAXValueCGSizeType, &size)) {
+                double b = origin.y + size.height;
+                if (!found || b > *bottom) {
+                    *bottom = b;
+                }
+                found = 1;
+                break;
+            }
+        }
+        CFRelease(children);
+    }
+    return found;
+}
+
+int snap_titlebar_at(double x, double y) {
+    static const char *window_roles[] = {"AXWindow"};
+    static const char *toolbar_roles[] = {"AXToolbar"};
+    AXUIElementRef element = copy_element_at(x, y);
+    if (element == NULL) {
+        return 0;
+    }
+    // 統合されたツールバーの背景ではツールバーが返る
+    if (has_role(element, toolbar_roles, 1)) {
+        CFRelease(element);
+        return 1;
+    }
+    // タイトルバーの何もない所ではウインドウ自体が返る。ただし AX の子要素を持たない独自描画の内容
+    // （ゲーム・Java・一部の Electron のビュー等）の上でもウインドウ自体が返るため、
+    // ウインドウの上端（AXPosition）から閉じるボタン・ツールバーの下端までの帯の中だけタイトルバーとみなす
+    if (has_role(element, window_roles, 1)) {
+        CGPoint top;
+        double bottom;
+        int titlebar = copy_value(element, kAXPositionAttribute, kAXValueCGPointType, &top) &&
+                       titlebar_bottom(element, &bottom) && y >= top.y && y < bottom;
+        CFRelease(element);
+        return titlebar;
+    }
+
+    // タイトルの文字の上
+    int titlebar = 0;
+    AXUIElementRef window = NULL;
+    if (AXUIElementCopyAttributeValue(element, kAXWindowAttribute, (CFTypeRef *)&window) == kAXErrorSuccess &&
+        window != NULL) {
+        CFTypeRef title = NULL;
+        if (AXUIElementCopyAttributeValue(window, kAXTitleUIElementAttribute, &title) == kAXErrorSuccess &&
+            title != NULL) {
+            titlebar = CFEqual(title, element);
+            CFRelease(title);
+        }
+        CFRelease(window);
+    }
+    CFRelease(element);
+    return titlebar;
+}
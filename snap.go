@@ -0,0 +1,80 @@
+// snap.go: 吸着モード（実験的）。
+// コーストの終盤で、予測停止点の近くにあるクリック可能な UI 要素（ボタン等）を
+// アクセシビリティ API で探し、その中心に止まるよう軌道を緩やかに曲げる。
+// 大きな画面でボタンを狙うときに、カーソルを投げるだけで届くようにする（フィッツの法則の補助）。
+package main
+
+/*
+#cgo LDFLAGS: -framework ApplicationServices
+#include "snap.h"
+*/
+import "C"
+import "math"
+
+// 吸着の設定
+const (
+	snapSpeed  = 400.0 // この速さ (px/sec) を下回ったら吸着先を探す
+	snapRadius = 80.0  // 予測停止点からこの距離 (px) 以内の要素にだけ吸着する
+)
+
+// snapTargetMsg は吸着先の検索結果（要素の中心）。
+type snapTargetMsg struct {
+	x, y float64
+}
+
+// clickableCenterAt は (x, y) にあるクリック可能な UI 要素の中心を返す。
+// アクセシビリティ API はアプリとの IPC を伴うため、アクターの外で呼ぶこと。
+func clickableCenterAt(x, y float64) (cx, cy float64, ok bool) {
+	var frame C.CGRect
+	if C.snap_clickable_frame_at(C.double(x), C.double(y), &frame) == 0 {
+		return 0, 0, false
+	}
+	return float64(frame.origin.x + frame.size.width/2), float64(frame.origin.y + frame.size.height/2), true
+}
+
+// prepareSnapQuery は吸着先を探すべきかを判定し、探す位置（予測停止点）を返す。
+// 1回のコーストにつき1回だけ、通常コーストが snapSpeed を下回った時点で探す。
+// アクター goroutine から呼ぶこと。
+func (a *App) prepareSnapQuery() (x, y float64, ok bool) {
+	if !a.snapEnabled || a.snapRequested || a.dragPhase != dragPhaseNone {
+		return 0, 0, false
+	}
+	speed := math.Hypot(a.vx, a.vy)
+	if speed == 0 || speed > snapSpeed {
+		return 0, 0, false
+	}
+	a.snapRequested = true
+	x, y = a.predictStop()
+	return x, y, true
+}
+
+// querySnapTarget は吸着先を別 goroutine で探し、見つかればアクターに送る。
+func (a *App) querySnapTarget(x, y float64) {
+	go func() {
+		if cx, cy, ok := clickableCenterAt(x, y); ok {
+			a.send(snapTargetMsg{x: cx, y: cy})
+		}
+	}()
+}
+
+// applySnapTarget は予測停止点が (tx, ty) になるよう速度の向きと大きさを変える。
+// 停止までの移動量 d と初速 v の関係 |d| = (|v| - th)/k（predictStop 参照）から、
+// |v| = k|d| + th を d の向きに与える。検索中にコーストが終わっていれば何もしない。
+// アクター goroutine から呼ぶこと。
+func (a *App) applySnapTarget(tx, ty float64) {
+	if (a.vx == 0 && a.vy == 0) || a.dragPhase != dragPhaseNone {
+		return
+	}
+	sx, sy := a.predictStop()
+	if math.Hypot(tx-sx, ty-sy) > snapRadius {
+		return
+	}
+	dx, dy := tx-a.coastX, ty-a.coastY
+	dist := math.Hypot(dx, dy)
+	if dist < 1 {
+		return
+	}
+	speed := a.zoneDecayRate()*dist + a.params.StopThreshold
+	a.vx = dx / dist * speed
+	a.vy = dy / dist * speed
+}
@@ -0,0 +1,32 @@
+// snap.h: アクセシビリティ API による UI 要素の検索（吸着モード・ドロップ先の判定）。
+#ifndef SNAP_H
+#define SNAP_H
+
+#include <ApplicationServices/ApplicationServices.h>
+
+// (x, y) にあるクリック可能な UI 要素の矩形を *frame に返す。見つかった場合は 1 を返す。
+int snap_clickable_frame_at(double x, double y, CGRect *frame);
+
+// ドロップ先の種類
+enum {
+    SNAP_DROP_NONE = 0,   // 特になし
+    SNAP_DROP_SPRING = 1, // スプリングローディングの対象（Dock の項目・フォルダ）
+    SNAP_DROP_TRASH = 2,  // ゴミ箱（誤ってドロップすると危険）
+};
+
+// (x, y) にある UI 要素のドロップ先としての種類（SNAP_DROP_*）を返す。
+int snap_drop_target_at(double x, double y);
+
+// (x, y) にある UI 要素を含むウインドウのタイトル・ロール・サブロールを書き込む（ドラッグ慣性の除外）。
+// 要素が見つかった場合は 1 を返す。値のない属性は空文字にする。
+int snap_window_at(double x, double y, char *title, int title_len, char *role, int role_len,
+                   char *subrole, int subrole_len);
+
+// (x, y) からのドラッグが文字の選択らしければ 1 を返す（文字の要素の上で、フォーカスも文字の要素にある）。
+int snap_text_selection_at(double x, double y);
+
+// (x, y) がウインドウのタイトルバーの上なら 1 を返す。ツールバー・タイトルの文字の上か、
+// ウインドウ自体の上で、上端から閉じるボタン・ツールバーの下端までの帯の中ならタイトルバーとみなす。
+int snap_titlebar_at(double x, double y);
+
+#endif
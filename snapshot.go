@@ -0,0 +1,720 @@
+// snapshot.go: エンジンの状態のスナップショットと再生（不具合報告用）。
+// ドラッグ慣性の不具合は再現が難しいため、アクターが処理した直近のイベント（タッチフレーム・
+// マウスボタン・コーストフレーム等）と、その開始時点のエンジンの状態を常に記録しておく。
+// `coastpad ctl snapshot` で状態とイベントをファイルに書き出し、
+// `coastpad replay-snapshot <file>` で同じ状態から状態機械を再生して遷移を確認できる。
+//
+// 再生は状態遷移（prepareXxx）だけを行い、イベントの発行等の副作用は実行しない。
+// コースト開始時の画面バウンドの取得や、停止位置の UI 要素の問い合わせは再生時の環境で行うため、
+// 記録時と画面構成が異なると結果が変わることがある。
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// snapshotWindow はスナップショットに最低限含める直近のイベントの長さ（秒）。
+// 記録は snapshotWindow ごとに世代を切り替え、直前の世代の開始時の状態から書き出すため、
+// 実際には snapshotWindow〜2×snapshotWindow のイベントが含まれる。
+const snapshotWindow = 10.0
+
+// 記録するイベントの種類
+const (
+	recTouch          = "touch"
+	recMouseDown      = "mouse-down"
+	recOtherMouseDown = "other-mouse-down"
+	recMouseUp        = "mouse-up"
+	recFrame          = "frame" // コーストフレーム（ticker）
+	recKeyDown        = "key-down"
+	recScrollPhase    = "scroll-phase"
+	recSnapTarget     = "snap-target"
+	recSpringTarget   = "spring-target"
+	recGame           = "game"
+	recMissionControl = "mission-control"
+	recSession        = "session"
+	recPermission     = "permission"
+	recRemote         = "remote"
+	recDragWindow     = "drag-window"
+)
+
+// recordedEvent はアクターが処理した1つのイベントを表す。種類ごとに使うフィールドだけを設定する。
+type recordedEvent struct {
+	T          float64 `json:"t"` // 処理した時刻（monotonicSeconds）
+	Kind       string  `json:"kind"`
+	Device     uintptr `json:"device,omitempty"`
+	Fingers    int     `json:"fingers,omitempty"`
+	X          float64 `json:"x,omitempty"`
+	Y          float64 `json:"y,omitempty"`
+	PadX       float64 `json:"pad_x,omitempty"`
+	PadY       float64 `json:"pad_y,omitempty"`
+	Size       float64 `json:"size,omitempty"`
+	Timestamp  float64 `json:"timestamp,omitempty"`
+	ClickState int     `json:"click_state,omitempty"`
+	Flags      uint64  `json:"flags,omitempty"`
+	Target     int     `json:"target,omitempty"` // スプリングローディングの問い合わせ結果
+	Active     bool    `json:"active,omitempty"` // 全画面ゲーム・Mission Control・セッション・権限の状態、ウインドウの除外
+	Seq        uint64  `json:"seq,omitempty"`    // ウインドウの除外の対象のマウスダウン
+}
+
+// cursorSample はカーソル履歴の1点（cursorRecord の書き出し用）。
+type cursorSample struct {
+	X, Y, T float64
+}
+
+// screenSample はディスプレイの領域（displayRect の書き出し用）。
+type screenSample struct {
+	MinX, MinY, MaxX, MaxY, Scale float64
+}
+
+// engineState はエンジン（アクターが所有する状態と、起動時に決定する動作の設定）の書き出し用の表現。
+// 保留中のマウスアップはイベントそのものを書き出せないため、保留の有無だけを記録する。
+type engineState struct {
+	// タッチ
+	History        [historySize]cursorSample `json:"history"`
+	HistLen        int                       `json:"hist_len"`
+	IsTouched      bool                      `json:"is_touched"`
+	VX             float64                   `json:"vx"`
+	VY             float64                   `json:"vy"`
+	Omega          float64                   `json:"omega"`
+	PadX           float64                   `json:"pad_x"`
+	PadY           float64                   `json:"pad_y"`
+	MaxFingers     int                       `json:"max_fingers"`
+	ReleaseFingers int                       `json:"release_fingers"`
+	CursorScale    float64                   `json:"cursor_scale"`
+	ShakeReversals int                       `json:"shake_reversals"`
+	BrakeArmed     bool                      `json:"brake_armed"`
+	TouchStartT    float64                   `json:"touch_start_t"`
+	ContactPeak    float64                   `json:"contact_peak"`
+	ContactLast    float64                   `json:"contact_last"`
+	Braking        bool                      `json:"braking"`
+	TouchScrolled  bool                      `json:"touch_scrolled"`
+	DeviceFingers  map[uintptr]int           `json:"device_fingers"`
+	ActiveDevice   uintptr                   `json:"active_device"`
+
+	// ドラッグ慣性
+	IsLeftButtonDown   bool    `json:"is_left_button_down"`
+	ClickState         int     `json:"click_state"`
+	Flags              uint64  `json:"flags"`
+	DragPhase          string  `json:"drag_phase"`
+	WasMultiFingerDrag bool    `json:"was_multi_finger_drag"`
+	CoastX             float64 `json:"coast_x"`
+	CoastY             float64 `json:"coast_y"`
+	CoastT             float64 `json:"coast_t"`
+	FrameDeltaAvg      float64 `json:"frame_delta_avg"`
+	LastCoastAt        float64 `json:"last_coast_at"`
+	LastCoastDirX      float64 `json:"last_coast_dir_x"`
+	LastCoastDirY      float64 `json:"last_coast_dir_y"`
+	PendingSince       float64 `json:"pending_since"`
+	AccumX             float64 `json:"accum_x"`
+	AccumY             float64 `json:"accum_y"`
+	PendingMouseUp     bool    `json:"pending_mouse_up"`
+
+	// コースト中の付随状態
+	Screens          []screenSample `json:"screens"`
+	CoastScreenIdx   int            `json:"coast_screen_idx"`
+	HotEdgeFired     bool           `json:"hot_edge_fired"`
+	EdgeHold         string         `json:"edge_hold"`
+	EdgeHoldSince    float64        `json:"edge_hold_since"`
+	EdgeHoldSwitched bool           `json:"edge_hold_switched"`
+	EdgeHoldVX       float64        `json:"edge_hold_vx"`
+	SpringHold       bool           `json:"spring_hold"`
+	SpringUntil      float64        `json:"spring_until"`
+	DropSettling     bool           `json:"drop_settling"`
+	SettleUntil      float64        `json:"settle_until"`
+	DragMovedAt      float64        `json:"drag_moved_at"`
+	SpringJitter     int            `json:"spring_jitter"`
+	DropConfirm      bool           `json:"drop_confirm"`
+	SnapRequested    bool           `json:"snap_requested"`
+	LastKeyDown      float64        `json:"last_key_down"`
+	DeadUntil        float64        `json:"dead_until"`
+	SwallowMouseUp   bool           `json:"swallow_mouse_up"`
+	DragSeq          uint64         `json:"drag_seq"`
+	DragExcluded     bool           `json:"drag_excluded"`
+
+	// 一時停止
+	Paused          bool   `json:"paused"`
+	GameActive      bool   `json:"game_active"`
+	MissionControl  bool   `json:"mission_control"`
+	SessionInactive bool   `json:"session_inactive"`
+	Untrusted       bool   `json:"untrusted"`
+	FocusMode       string `json:"focus_mode"`
+	FocusPaused     bool   `json:"focus_paused"`
+	Suspended       bool   `json:"suspended"`
+	CursorOff       bool   `json:"cursor_off"`
+	DragOff         bool   `json:"drag_off"`
+
+	// 画面共有での遠隔操作
+	RemoteShared     bool    `json:"remote_shared"`
+	RemoteControlled bool    `json:"remote_controlled"`
+	RemoteLocalAt    float64 `json:"remote_local_at"`
+
+	// 摩擦の学習
+	AdaptiveFriction bool            `json:"adaptive_friction"`
+	Learned          learnedFriction `json:"learned"`
+
+	// 動作の設定
+	Params             coastParams     `json:"params"`
+	Preset             string          `json:"preset"`
+	Mode               string          `json:"mode"`
+	EdgeOnly           bool            `json:"edge_only"`
+	EdgeMargin         float64         `json:"edge_margin"`
+	IgnoreOtherDevices bool            `json:"ignore_other_devices"`
+	PendingTimeout     float64         `json:"pending_timeout"`
+	PendingUpPolicy    string          `json:"pending_mouseup"`
+	TypingSuppress     float64         `json:"typing_suppress"`
+	DragSpaceDwell     float64         `json:"drag_space_dwell"`
+	SpringDwell        float64         `json:"spring_dwell"`
+	SafeDrop           bool            `json:"safe_drop"`
+	DropSettle         float64         `json:"drop_settle"`
+	ClickThrough       bool            `json:"click_through"`
+	DeadTime           float64         `json:"dead_time"`
+	CurveGain          float64         `json:"curve_gain"`
+	QuantizeDirections int             `json:"quantize_directions"`
+	QuantizeTolerance  float64         `json:"quantize_tolerance"`
+	FlickBoost         float64         `json:"flick_boost"`
+	FlickBoostWindow   string          `json:"flick_boost_window"`
+	FingerGains        map[int]float64 `json:"finger_gains,omitempty"`
+	TrackingSpeedRef   float64         `json:"tracking_speed_ref"`
+	LargeCursor        bool            `json:"large_cursor"`
+	ShakeGuard         bool            `json:"shake_guard"`
+	BrakeFingers       int             `json:"brake_fingers"`
+	FlickIntent        float64         `json:"flick_intent"`
+	DragTitlebarOnly   bool            `json:"drag_titlebar_only"`
+	LoopInterval       string          `json:"loop_interval"`
+}
+
+// captureState は現在のエンジンの状態を書き出し用に複製する。アクター goroutine から呼ぶこと。
+func (a *App) captureState() engineState {
+	s := engineState{
+		HistLen:        a.histLen,
+		IsTouched:      a.isTouched,
+		VX:             a.vx,
+		VY:             a.vy,
+		Omega:          a.omega,
+		PadX:           a.padX,
+		PadY:           a.padY,
+		MaxFingers:     a.maxFingers,
+		ReleaseFingers: a.releaseFingers,
+		CursorScale:    a.cursorScale,
+		ShakeReversals: a.shakeReversals,
+		BrakeArmed:     a.brakeArmed,
+		TouchStartT:    a.touchStartT,
+		ContactPeak:    a.contactPeak,
+		ContactLast:    a.contactLast,
+		Braking:        a.braking,
+		TouchScrolled:  a.touchScrolled,
+		DeviceFingers:  make(map[uintptr]int, len(a.deviceFingers)),
+		ActiveDevice:   a.activeDevice,
+
+		IsLeftButtonDown:   a.isLeftButtonDown,
+		ClickState:         a.dragAttrs.clickState,
+		Flags:              a.dragAttrs.flags,
+		DragPhase:          a.dragPhase.String(),
+		WasMultiFingerDrag: a.wasMultiFingerDrag,
+		CoastX:             a.coastX,
+		CoastY:             a.coastY,
+		CoastT:             a.coastT,
+		FrameDeltaAvg:      a.frameDeltaAvg,
+		LastCoastAt:        a.lastCoastAt,
+		LastCoastDirX:      a.lastCoastDirX,
+		LastCoastDirY:      a.lastCoastDirY,
+		PendingSince:       a.pendingSince,
+		AccumX:             a.accumX,
+		AccumY:             a.accumY,
+		PendingMouseUp:     a.pendingMouseUp != 0,
+
+		CoastScreenIdx:   a.coastScreenIdx,
+		HotEdgeFired:     a.hotEdgeFired,
+		EdgeHold:         a.edgeHold.String(),
+		EdgeHoldSince:    a.edgeHoldSince,
+		EdgeHoldSwitched: a.edgeHoldSwitched,
+		EdgeHoldVX:       a.edgeHoldVX,
+		SpringHold:       a.springHold,
+		SpringUntil:      a.springUntil,
+		DropSettling:     a.dropSettling,
+		SettleUntil:      a.settleUntil,
+		DragMovedAt:      a.dragMovedAt,
+		SpringJitter:     a.springJitter,
+		DropConfirm:      a.dropConfirm,
+		SnapRequested:    a.snapRequested,
+		LastKeyDown:      a.lastKeyDown,
+		DeadUntil:        a.deadUntil,
+		SwallowMouseUp:   a.swallowMouseUp,
+		DragSeq:          a.dragSeq,
+		DragExcluded:     a.dragExcluded,
+
+		Paused:          a.paused,
+		GameActive:      a.gameActive,
+		MissionControl:  a.missionControl,
+		SessionInactive: a.sessionInactive,
+		Untrusted:       a.untrusted,
+		FocusMode:       a.focusMode.String(),
+		FocusPaused:     a.focusPaused,
+		Suspended:       a.suspended,
+		CursorOff:       a.cursorOff,
+		DragOff:         a.dragOff,
+
+		RemoteShared:     a.remoteShared,
+		RemoteControlled: a.remoteControlled,
+		RemoteLocalAt:    a.remoteLocalAt,
+
+		AdaptiveFriction: a.adaptiveFriction,
+		Learned:          a.learned,
+
+		Params:             a.params,
+		Preset:             a.preset,
+		Mode:               a.mode.String(),
+		EdgeOnly:           a.edgeOnly,
+		EdgeMargin:         a.edgeMargin,
+		IgnoreOtherDevices: a.ignoreOtherDevices,
+		PendingTimeout:     a.pendingTimeout,
+		PendingUpPolicy:    a.pendingUpPolicy,
+		TypingSuppress:     a.typingSuppress,
+		DragSpaceDwell:     a.dragSpaceDwell,
+		SpringDwell:        a.springDwell,
+		SafeDrop:           a.safeDrop,
+		DropSettle:         a.dropSettle,
+		ClickThrough:       a.clickThrough,
+		DeadTime:           a.deadTime,
+		CurveGain:          a.curveGain,
+		QuantizeDirections: a.quantizeDirections,
+		QuantizeTolerance:  a.quantizeTolerance,
+		FlickBoost:         a.flickBoost,
+		FlickBoostWindow:   a.flickBoostWindow.String(),
+		FingerGains:        a.fingerGains,
+		TrackingSpeedRef:   a.trackingSpeedRef,
+		LargeCursor:        a.largeCursor,
+		ShakeGuard:         a.shakeGuard,
+		BrakeFingers:       a.brakeFingers,
+		FlickIntent:        a.flickIntent,
+		DragTitlebarOnly:   a.dragTitlebarOnly,
+		LoopInterval:       a.loopInterval.String(),
+	}
+	for i, h := range a.history {
+		s.History[i] = cursorSample{X: h.x, Y: h.y, T: h.timestamp}
+	}
+	for device, n := range a.deviceFingers {
+		s.DeviceFingers[device] = n
+	}
+	for _, r := range a.screens {
+		s.Screens = append(s.Screens, screenSample{MinX: r.minX, MinY: r.minY, MaxX: r.maxX, MaxY: r.maxY, Scale: r.scale})
+	}
+	return s
+}
+
+// restoreState は書き出した状態をエンジンに戻す（再生用）。
+// 保留中のマウスアップは、コースト位置での合成のマウスアップで代用する。
+func (a *App) restoreState(s engineState) error {
+	phase, ok := parseDragPhase(s.DragPhase)
+	if !ok {
+		return fmt.Errorf("unknown drag phase %q", s.DragPhase)
+	}
+	mode, err := parseCoastMode(s.Mode)
+	if err != nil {
+		return err
+	}
+	focusMode, err := parseCoastMode(s.FocusMode)
+	if err != nil {
+		return err
+	}
+	loopInterval, err := time.ParseDuration(s.LoopInterval)
+	if err != nil {
+		return err
+	}
+	flickBoostWindow, err := time.ParseDuration(s.FlickBoostWindow)
+	if err != nil {
+		return err
+	}
+
+	for i, h := range s.History {
+		a.history[i] = cursorRecord{x: h.X, y: h.Y, timestamp: h.T}
+	}
+	a.histLen = s.HistLen
+	a.isTouched = s.IsTouched
+	a.vx, a.vy = s.VX, s.VY
+	a.omega = s.Omega
+	a.padX, a.padY = s.PadX, s.PadY
+	a.maxFingers = s.MaxFingers
+	a.releaseFingers = s.ReleaseFingers
+	a.cursorScale = s.CursorScale
+	a.shakeReversals = s.ShakeReversals
+	a.brakeArmed = s.BrakeArmed
+	a.touchStartT = s.TouchStartT
+	a.contactPeak = s.ContactPeak
+	a.contactLast = s.ContactLast
+	a.braking = s.Braking
+	a.touchScrolled = s.TouchScrolled
+	a.deviceFingers = make(map[uintptr]int, len(s.DeviceFingers))
+	for device, n := range s.DeviceFingers {
+		a.deviceFingers[device] = n
+	}
+	a.activeDevice = s.ActiveDevice
+
+	a.isLeftButtonDown = s.IsLeftButtonDown
+	a.dragAttrs = dragAttrs{clickState: s.ClickState, flags: s.Flags}
+	a.dragPhase = phase
+	a.wasMultiFingerDrag = s.WasMultiFingerDrag
+	a.coastX, a.coastY, a.coastT = s.CoastX, s.CoastY, s.CoastT
+	a.frameDeltaAvg = s.FrameDeltaAvg
+	a.lastCoastAt = s.LastCoastAt
+	a.lastCoastDirX, a.lastCoastDirY = s.LastCoastDirX, s.LastCoastDirY
+	a.pendingSince = s.PendingSince
+	a.accumX, a.accumY = s.AccumX, s.AccumY
+	if s.PendingMouseUp {
+		a.pendingMouseUp = newMouseUpEvent(s.CoastX, s.CoastY)
+	}
+
+	a.screens = a.screens[:0]
+	for _, r := range s.Screens {
+		a.screens = append(a.screens, displayRect{minX: r.MinX, minY: r.MinY, maxX: r.MaxX, maxY: r.MaxY, scale: r.Scale})
+	}
+	a.coastScreenIdx = s.CoastScreenIdx
+	a.hotEdgeFired = s.HotEdgeFired
+	a.edgeHold = screenEdgeNames[s.EdgeHold]
+	a.edgeHoldSince = s.EdgeHoldSince
+	a.edgeHoldSwitched = s.EdgeHoldSwitched
+	a.edgeHoldVX = s.EdgeHoldVX
+	a.springHold = s.SpringHold
+	a.springUntil = s.SpringUntil
+	a.dropSettling = s.DropSettling
+	a.settleUntil = s.SettleUntil
+	a.dragMovedAt = s.DragMovedAt
+	a.springJitter = s.SpringJitter
+	a.dropConfirm = s.DropConfirm
+	a.snapRequested = s.SnapRequested
+	a.lastKeyDown = s.LastKeyDown
+	a.deadUntil = s.DeadUntil
+	a.swallowMouseUp = s.SwallowMouseUp
+	a.dragSeq = s.DragSeq
+	a.dragExcluded = s.DragExcluded
+
+	a.paused = s.Paused
+	a.gameActive = s.GameActive
+	a.missionControl = s.MissionControl
+	a.sessionInactive = s.SessionInactive
+	a.untrusted = s.Untrusted
+	a.remoteShared = s.RemoteShared
+	a.remoteControlled = s.RemoteControlled
+	a.remoteLocalAt = s.RemoteLocalAt
+	a.adaptiveFriction = s.AdaptiveFriction
+	a.learned = s.Learned
+	a.focusMode = focusMode
+	a.focusPaused = s.FocusPaused
+	a.suspended = s.Suspended
+	a.cursorOff = s.CursorOff
+	a.dragOff = s.DragOff
+
+	a.params = s.Params
+	a.preset = s.Preset
+	a.mode = mode
+	a.edgeOnly = s.EdgeOnly
+	a.edgeMargin = s.EdgeMargin
+	a.ignoreOtherDevices = s.IgnoreOtherDevices
+	a.pendingTimeout = s.PendingTimeout
+	if s.PendingUpPolicy != "" {
+		a.pendingUpPolicy = s.PendingUpPolicy
+	}
+	a.typingSuppress = s.TypingSuppress
+	a.dragSpaceDwell = s.DragSpaceDwell
+	a.springDwell = s.SpringDwell
+	a.safeDrop = s.SafeDrop
+	a.dropSettle = s.DropSettle
+	a.clickThrough = s.ClickThrough
+	a.deadTime = s.DeadTime
+	a.curveGain = s.CurveGain
+	a.quantizeDirections = s.QuantizeDirections
+	a.quantizeTolerance = s.QuantizeTolerance
+	a.loopInterval = loopInterval
+	a.flickBoost = s.FlickBoost
+	a.flickBoostWindow = flickBoostWindow
+	a.fingerGains = s.FingerGains
+	a.trackingSpeedRef = s.TrackingSpeedRef
+	a.largeCursor = s.LargeCursor
+	a.shakeGuard = s.ShakeGuard
+	a.brakeFingers = s.BrakeFingers
+	a.flickIntent = s.FlickIntent
+	a.dragTitlebarOnly = s.DragTitlebarOnly
+	return nil
+}
+
+// eventRecorder はアクターが処理したイベントを、世代を切り替えながら記録する。
+// アクター goroutine だけが使う。
+type eventRecorder struct {
+	started    bool
+	since      float64         // 現在の世代の開始時刻
+	curState   engineState     // 現在の世代の開始時の状態
+	curEvents  []recordedEvent // 現在の世代のイベント
+	hasPrev    bool
+	prevState  engineState // 直前の世代の開始時の状態
+	prevEvents []recordedEvent
+}
+
+// record はイベントを記録する。イベントの処理前に呼ぶこと（世代の開始時の状態を正しく取るため）。
+// アクター goroutine から呼ぶこと。
+func (a *App) record(ev recordedEvent) {
+	r := &a.recorder
+	if !r.started || ev.T-r.since >= snapshotWindow {
+		if r.started {
+			r.prevState, r.curState = r.curState, engineState{}
+			r.prevEvents, r.curEvents = r.curEvents, r.prevEvents[:0]
+			r.hasPrev = true
+		}
+		r.curState = a.captureState()
+		r.since = ev.T
+		r.started = true
+	}
+	r.curEvents = append(r.curEvents, ev)
+}
+
+// recordMessage は inbox のメッセージのうち、状態機械の入力になるものを記録する。
+// アクター goroutine から呼ぶこと。
+func (a *App) recordMessage(msg any) {
+	ev := recordedEvent{T: monotonicSeconds()}
+	switch m := msg.(type) {
+	case touchFrameMsg:
+		ev.Kind = recTouch
+		ev.Device, ev.Fingers = m.device, m.fingerCount
+		ev.X, ev.Y, ev.PadX, ev.PadY, ev.Timestamp = m.x, m.y, m.padX, m.padY, m.timestamp
+		ev.Size = m.size
+	case mouseDownMsg:
+		ev.Kind = recMouseDown
+		ev.ClickState, ev.Flags = m.attrs.clickState, m.attrs.flags
+		ev.Active = m.menuBar
+	case otherMouseDownMsg:
+		ev.Kind = recOtherMouseDown
+	case mouseUpMsg:
+		ev.Kind = recMouseUp
+	case keyDownMsg:
+		ev.Kind = recKeyDown
+		ev.Timestamp = m.timestamp
+	case scrollPhaseMsg:
+		ev.Kind = recScrollPhase
+	case snapTargetMsg:
+		ev.Kind = recSnapTarget
+		ev.X, ev.Y = m.x, m.y
+	case springTargetMsg:
+		ev.Kind = recSpringTarget
+		ev.Target = int(m.target)
+	case gameMsg:
+		ev.Kind = recGame
+		ev.Active = m.active
+	case missionControlMsg:
+		ev.Kind = recMissionControl
+		ev.Active = m.active
+	case sessionMsg:
+		ev.Kind = recSession
+		ev.Active = m.active
+	case permissionMsg:
+		ev.Kind = recPermission
+		ev.Active = m.trusted
+	case remoteMsg:
+		ev.Kind = recRemote
+		ev.Active = m.shared
+	case dragWindowMsg:
+		ev.Kind = recDragWindow
+		ev.Seq = m.seq
+		ev.Active = m.excluded
+	default:
+		return
+	}
+	a.record(ev)
+}
+
+// engineSnapshot はスナップショットファイルの内容を表す。
+type engineSnapshot struct {
+	Taken  time.Time       `json:"taken"`
+	Start  engineState     `json:"start"`  // Events の最初のイベントの直前の状態
+	Events []recordedEvent `json:"events"` // 記録したイベント（古い順）
+	Final  engineState     `json:"final"`  // スナップショットを取った時点の状態
+}
+
+// Snapshot は現在の状態と記録したイベントのスナップショットを返す。
+func (a *App) Snapshot() (s engineSnapshot) {
+	a.call(func() {
+		r := &a.recorder
+		s = engineSnapshot{Taken: time.Now(), Final: a.captureState()}
+		switch {
+		case r.hasPrev:
+			s.Start = r.prevState
+			s.Events = append(append([]recordedEvent{}, r.prevEvents...), r.curEvents...)
+		case r.started:
+			s.Start = r.curState
+			s.Events = append([]recordedEvent{}, r.curEvents...)
+		default:
+			s.Start = s.Final
+		}
+	})
+	return s
+}
+
+// ctlSnapshot は制御コマンド `snapshot [path]` を処理し、スナップショットをファイルに書き出す。
+// パスを省略するとアプリのデータディレクトリに日時付きの名前で書き出す。
+func ctlSnapshot(a *App, args []string) (any, error) {
+	if len(args) > 1 {
+		return nil, fmt.Errorf("usage: snapshot [path]")
+	}
+	var path string
+	if len(args) == 1 {
+		path = args[0]
+	} else {
+		var err error
+		if path, err = appDataPath("snapshot-" + time.Now().Format("20060102-150405") + ".json"); err != nil {
+			return nil, err
+		}
+	}
+	s := a.Snapshot()
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return nil, err
+	}
+	fmt.Printf("[snapshot] wrote %d events to %s\n", len(s.Events), path)
+	return map[string]any{"path": path, "events": len(s.Events)}, nil
+}
+
+// replayEvent は記録したイベントを1つ状態機械に流す。副作用は実行せず、
+// 状態遷移で返されたマウスアップは発行せずに解放する。
+func (a *App) replayEvent(ev recordedEvent) error {
+	switch ev.Kind {
+	case recTouch:
+		discardEvent(a.noteLocalInput(ev.Fingers))
+		if a.suspended || !a.arbitrateTouch(ev.Device, ev.Fingers) {
+			return nil
+		}
+		action := a.prepareTouchFrame(ev.Fingers, ev.X, ev.Y, ev.PadX, ev.PadY, ev.Size, ev.Timestamp)
+		discardEvent(action.pending)
+	case recMouseDown:
+		action := a.prepareMouseDown(dragAttrs{clickState: ev.ClickState, flags: ev.Flags}, ev.Active)
+		discardEvent(action.pending)
+	case recOtherMouseDown:
+		discardEvent(a.prepareOtherMouseDown().pending)
+	case recMouseUp:
+		event := newMouseUpEvent(a.coastX, a.coastY)
+		a.prepareMouseUp(event)
+		discardEvent(event) // 保留した場合は prepareMouseUp が保持している
+	case recFrame:
+		discardEvent(a.prepareCoastFrame(ev.T).pending)
+	case recKeyDown:
+		a.lastKeyDown = ev.Timestamp
+	case recScrollPhase:
+		if a.isTouched {
+			a.touchScrolled = true
+		}
+	case recSnapTarget:
+		a.applySnapTarget(ev.X, ev.Y)
+	case recSpringTarget:
+		a.applySpringTarget(dropTarget(ev.Target))
+	case recGame:
+		a.gameActive = ev.Active
+		discardEvent(a.updateSuspend())
+	case recMissionControl:
+		a.missionControl = ev.Active
+		discardEvent(a.updateSuspend())
+	case recSession:
+		a.sessionInactive = !ev.Active
+		discardEvent(a.updateSuspend())
+	case recPermission:
+		a.untrusted = !ev.Active
+		discardEvent(a.updateSuspend())
+	case recRemote:
+		discardEvent(a.applyRemote(ev.Active))
+	case recDragWindow:
+		a.applyDragWindow(ev.Seq, ev.Active)
+	default:
+		return fmt.Errorf("unknown event kind %q", ev.Kind)
+	}
+	discardEvent(a.validateDragState())
+	return nil
+}
+
+// discardEvent はイベントを発行せずに解放する（0 なら何もしない）。
+func discardEvent(event eventRef) {
+	if event != 0 {
+		releaseEvent(event)
+	}
+}
+
+// runReplaySnapshotCommand は `coastpad replay-snapshot <file>` を実行する。
+// スナップショットの開始時の状態から記録したイベントを再生し、ドラッグのフェーズの遷移を表示して、
+// 最後の状態が記録時の状態と一致するかを確認する。
+func runReplaySnapshotCommand(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: coastpad replay-snapshot <file>")
+	}
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	var s engineSnapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("parse %s: %w", args[0], err)
+	}
+
+	a := NewApp()
+	// 再生中は問い合わせの結果をアクターに送らない（記録した結果を再生する）
+	a.actorDone = make(chan struct{})
+	close(a.actorDone)
+	if err := a.restoreState(s.Start); err != nil {
+		return err
+	}
+
+	fmt.Printf("Replaying %d events (taken %s)\n", len(s.Events), s.Taken.Format(time.RFC3339))
+	var t0, replayTime float64
+	if len(s.Events) > 0 {
+		t0 = s.Events[0].T
+	}
+	// 状態機械の時刻は再生中のイベントの記録時刻にする
+	a.now = func() float64 { return replayTime }
+	for _, ev := range s.Events {
+		replayTime = ev.T
+		before := a.dragPhase
+		if err := a.replayEvent(ev); err != nil {
+			return err
+		}
+		if a.dragPhase != before {
+			fmt.Printf("%8.3fs %-16s drag phase %s -> %s\n", ev.T-t0, ev.Kind, before, a.dragPhase)
+		}
+	}
+
+	diffs := diffEngineStates(s.Final, a.captureState())
+	if len(diffs) == 0 {
+		fmt.Println("Replay reached the recorded final state")
+		return nil
+	}
+	fmt.Println("Replay diverged from the recorded final state:")
+	for _, d := range diffs {
+		fmt.Println("  " + d)
+	}
+	return nil
+}
+
+// diffEngineStates は2つの状態の異なるフィールドを "name: want -> got" の形で返す。
+func diffEngineStates(want, got engineState) []string {
+	toMap := func(s engineState) map[string]any {
+		var m map[string]any
+		data, _ := json.Marshal(s)
+		json.Unmarshal(data, &m)
+		return m
+	}
+	wm, gm := toMap(want), toMap(got)
+	var diffs []string
+	for name, w := range wm {
+		if g := gm[name]; !reflect.DeepEqual(w, g) {
+			diffs = append(diffs, fmt.Sprintf("%s: %v -> %v", name, w, g))
+		}
+	}
+	sort.Strings(diffs)
+	return diffs
+}
@@ -0,0 +1,43 @@
+// sound.go: コーストの開始・終了とドラッグ解放時のサウンドフィードバック。
+// フリックが認識されたことを耳で確認したいユーザー向け。デフォルトは無効。
+package main
+
+/*
+#cgo LDFLAGS: -framework Cocoa
+#include <stdlib.h>
+#include "sound.h"
+*/
+import "C"
+import (
+	"fmt"
+	"os"
+	"unsafe"
+)
+
+// soundFeedback はイベントごとに鳴らすシステムサウンド名を保持する。空文字は無音。
+// 起動時に設定し、以後は変更しない。
+type soundFeedback struct {
+	coastStart  string // コースト開始時
+	coastEnd    string // コーストの自然停止時
+	dragRelease string // ドラッグ慣性の mouseUp 解放時
+}
+
+// playSound はシステムサウンドを再生する。name が空なら何もしない。
+func playSound(name string) {
+	if name == "" {
+		return
+	}
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	if C.sound_play(cname) == 0 {
+		fmt.Fprintf(os.Stderr, "[sound] system sound %q not found\n", name)
+	}
+}
+
+// dragReleaseFeedback はドラッグ慣性の mouseUp 解放時のフィードバック（触覚・サウンド）を行う。
+func (a *App) dragReleaseFeedback() {
+	if a.haptics != nil {
+		a.haptics.tick()
+	}
+	playSound(a.sounds.dragRelease)
+}
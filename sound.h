@@ -0,0 +1,9 @@
+// sound.h: システムサウンドの再生（NSSound）。
+#ifndef SOUND_H
+#define SOUND_H
+
+// 名前付きシステムサウンド（"Tink" など）を非同期で再生する。
+// 見つからない場合は 0 を返す。
+int sound_play(const char *name);
+
+#endif
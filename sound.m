@@ -0,0 +1,16 @@
+// sound.m: NSSound によるシステムサウンド再生。
+#import <Cocoa/Cocoa.h>
+#include "sound.h"
+
+int sound_play(const char *name) {
+    @autoreleasepool {
+        NSSound *sound = [NSSound soundNamed:[NSString stringWithUTF8String:name]];
+        if (sound == nil) {
+            return 0;
+        }
+        // 再生中の同じサウンドは先頭から鳴らし直す
+        [sound stop];
+        [sound play];
+        return 1;
+    }
+}
@@ -0,0 +1,50 @@
+// spaceswitch.go: ドラッグ慣性での操作スペースの切り替え。
+// macOS はウインドウを画面の左右端までドラッグして留めると隣の操作スペースへ移動するが、
+// ドラッグ慣性の合成ドラッグは端でクランプされて止まるため、この動作が発動しない。
+// そこで投げたウインドウが左右端にぶつかったら端で保持し、一定時間後に
+// スペース切り替えのキー操作を送ってから、切り替えの完了を待ってドロップする。
+package main
+
+// spaceSwitchSettle はスペース切り替えのキー操作を送ってからドロップするまでの時間（秒）。
+// 切り替えのアニメーション中にドロップすると、ウインドウが元のスペースに残ってしまう。
+const spaceSwitchSettle = 0.6
+
+// startEdgeHold はドラッグ慣性が左右端にぶつかったとき、端での保持を開始する。
+// 保持中は端に向かう速度を維持し（クランプで端に留まる）、減衰させない。
+// アクター goroutine から呼ぶこと。
+func (a *App) startEdgeHold(edge screenEdge, speed, now float64) {
+	if a.dragSpaceDwell <= 0 || a.edgeHold != edgeNone || (edge != edgeLeft && edge != edgeRight) {
+		return
+	}
+	a.edgeHold = edge
+	a.edgeHoldSince = now
+	a.edgeHoldSwitched = false
+	a.edgeHoldVX = speed
+	if edge == edgeLeft {
+		a.edgeHoldVX = -speed
+	}
+}
+
+// advanceEdgeHold は端での保持を進め、送るべきキー操作のアクション名と保持が終わったかを返す。
+// dragSpaceDwell の経過でスペース切り替えを送り、さらに spaceSwitchSettle 後に保持を終える。
+// アクター goroutine から呼ぶこと。
+func (a *App) advanceEdgeHold(now float64) (hotEdge string, done bool) {
+	a.vx = a.edgeHoldVX // クランプでゼロになった速度を戻し、端に押し付け続ける
+	elapsed := now - a.edgeHoldSince
+	if !a.edgeHoldSwitched {
+		if elapsed < a.dragSpaceDwell {
+			return "", false
+		}
+		a.edgeHoldSwitched = true
+		a.edgeHoldSince = now
+		if a.edgeHold == edgeLeft {
+			return "space-left", false
+		}
+		return "space-right", false
+	}
+	if elapsed < spaceSwitchSettle {
+		return "", false
+	}
+	a.edgeHold = edgeNone
+	return "", true
+}
@@ -0,0 +1,103 @@
+// spring.go: ドラッグ慣性でのスプリングローディング。
+// Finder のスプリングロードフォルダや Dock の項目は、ドラッグしたまま小刻みに動かしながら
+// 留まらないと開かない。投げた項目がフォルダや Dock の項目の上で止まったら、
+// 一定時間だけ小さな合成ドラッグを送り続けてから mouseUp を解放し、
+// 投げた項目でもフォルダへのドラッグ＆ドロップが効くようにする。
+// 停止位置の問い合わせは安全なドロップ（safedrop.go）のゴミ箱の判定も兼ねる。
+package main
+
+/*
+#include "snap.h"
+*/
+import "C"
+
+// springCheckTimeout はスプリングローディングの対象かの問い合わせを待つ時間（秒）。
+// この間に応答がなければ通常どおりドロップする。
+const springCheckTimeout = 0.3
+
+// dropTarget はドラッグ慣性の停止位置にある UI 要素のドロップ先としての種類。
+type dropTarget int
+
+const (
+	dropTargetNone   dropTarget = C.SNAP_DROP_NONE   // 特になし
+	dropTargetSpring dropTarget = C.SNAP_DROP_SPRING // スプリングローディングの対象（Dock の項目・フォルダ）
+	dropTargetTrash  dropTarget = C.SNAP_DROP_TRASH  // ゴミ箱
+)
+
+// springTargetMsg は停止位置の問い合わせ結果。
+type springTargetMsg struct {
+	target dropTarget
+}
+
+// dropTargetAt は (x, y) にある UI 要素のドロップ先としての種類を返す。
+// アクセシビリティ API はアプリとの IPC を伴うため、アクターの外で呼ぶこと。
+func dropTargetAt(x, y float64) dropTarget {
+	return dropTarget(C.snap_drop_target_at(C.double(x), C.double(y)))
+}
+
+// startSpringHold はドラッグ慣性の自然停止時に、ドロップを保留して停止位置の問い合わせを始める。
+// 保持を始めた場合は true を返す。
+// アクター goroutine から呼ぶこと。
+func (a *App) startSpringHold(now float64) bool {
+	if a.springDwell <= 0 && !a.safeDrop {
+		return false
+	}
+	a.springHold = true
+	a.springUntil = now + springCheckTimeout
+	a.springJitter = 0
+	x, y := a.coastX, a.coastY
+	go func() {
+		a.send(springTargetMsg{target: dropTargetAt(x, y)})
+	}()
+	return true
+}
+
+// applySpringTarget は問い合わせ結果に応じて保持の期限を決める。
+// 対象なら springDwell の間保持し、対象でなければ次のフレームでドロップする。
+// 安全なドロップが有効でゴミ箱の上なら、ドロップの確認待ちに移る。
+// アクター goroutine から呼ぶこと。
+func (a *App) applySpringTarget(target dropTarget) {
+	if !a.springHold {
+		return
+	}
+	if target == dropTargetTrash && a.safeDrop {
+		a.springHold = false
+		a.holdForDropConfirm()
+		return
+	}
+	now := a.now()
+	if target != dropTargetNone && a.springDwell > 0 {
+		a.springUntil = now + a.springDwell
+	} else {
+		a.springUntil = now
+	}
+}
+
+// prepareSpringHold は保持中の1フレームを計算する。
+// 期限までは停止位置で 1px の往復ドラッグを送り、期限が来たらドラッグを終了する
+// （静定待ちが有効なら、往復ドラッグの後に静定待ちへ移る）。
+// アクター goroutine から呼ぶこと。
+func (a *App) prepareSpringHold(now float64) coastAction {
+	var action coastAction
+	if now >= a.springUntil {
+		a.springHold = false
+		if a.startDropSettle(now) {
+			action.hud = a.currentHUDFrame()
+			return action
+		}
+		action.dragX = a.coastX
+		action.dragY = a.coastY
+		action.coastEnded = true
+		a.queueHook(&action.hooks, hookDragEnd)
+		action.pending = a.resetCoasting()
+		return action
+	}
+	next := 1 - a.springJitter
+	action.dragX = a.coastX + float64(next)
+	action.dragY = a.coastY
+	action.dragDx = next - a.springJitter
+	action.isDragCoasting = true
+	a.dragMovedAt = now
+	a.springJitter = next
+	return action
+}
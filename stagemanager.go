@@ -0,0 +1,92 @@
+// stagemanager.go: ステージマネージャとの互換モード（--stage-manager）。
+// ステージマネージャの有効中に画面端のストリップ（最近使ったアプリの一覧）へウインドウを投げ込むと、
+// ドロップが取り消されてウインドウが元の位置に戻るなど、意図しない動作になる。
+// 互換モードではドラッグ慣性を短くし、速いままストリップに入るドラッグ慣性はストリップの手前で止めて、
+// ストリップ内ではほぼ止まった（ゆっくり入った）ときだけマウスアップを発行する。
+package main
+
+/*
+#cgo LDFLAGS: -framework CoreFoundation
+#include <stdlib.h>
+#include "defaults.h"
+*/
+import "C"
+import (
+	"math"
+	"unsafe"
+)
+
+const (
+	// stageStripWidth は画面端からのストリップの幅（pt）。ストリップの表示幅は公開されていないため概算。
+	stageStripWidth = 200.0
+	// stageDragVelocityScale はステージマネージャの有効中のドラッグ慣性の初速の倍率。
+	stageDragVelocityScale = 0.6
+	// stageStripEntrySpeed はストリップに入ってよいドラッグ慣性の速さの上限 (px/sec)。
+	stageStripEntrySpeed = 150.0
+)
+
+// stageStrip はドラッグ慣性の開始時に確認したステージマネージャの状態を表す。
+type stageStrip struct {
+	active bool // ステージマネージャが有効か
+	right  bool // ストリップが右端にあるか（Dock が左にあると右端に出る）
+}
+
+// readStageStrip はステージマネージャの設定（com.apple.WindowManager）と Dock の位置を読む。
+func readStageStrip() stageStrip {
+	var strip stageStrip
+	wm := C.CString("com.apple.WindowManager")
+	defer C.free(unsafe.Pointer(wm))
+	key := C.CString("GloballyEnabled")
+	defer C.free(unsafe.Pointer(key))
+	C.defaults_sync(wm)
+	var enabled C.int
+	if C.defaults_get_bool(wm, key, &enabled) == 0 || enabled == 0 {
+		return strip
+	}
+	strip.active = true
+
+	dock := C.CString("com.apple.dock")
+	defer C.free(unsafe.Pointer(dock))
+	orientation := C.CString("orientation")
+	defer C.free(unsafe.Pointer(orientation))
+	C.defaults_sync(dock)
+	var buf [16]C.char
+	if C.defaults_get_string(dock, orientation, &buf[0], C.int(len(buf))) != 0 {
+		strip.right = C.GoString(&buf[0]) == "left"
+	}
+	return strip
+}
+
+// startStageDrag はドラッグ慣性の開始時にステージマネージャの状態を確認し、有効なら初速を抑える。
+// 設定の読み込みは CFPreferences の単純なクエリのため、ドラッグ慣性の開始時に1回だけ状態遷移中に行う。
+// アクター goroutine から呼ぶこと。
+func (a *App) startStageDrag() {
+	a.stage = stageStrip{}
+	if !a.stageManagerCompat {
+		return
+	}
+	a.stage = readStageStrip()
+	if a.stage.active {
+		a.vx *= stageDragVelocityScale
+		a.vy *= stageDragVelocityScale
+	}
+}
+
+// avoidStageStrip は速いままストリップに入ろうとするドラッグ慣性を、ストリップの境界で横方向に止める。
+// prevX は今フレームの移動前の位置。画面端を越えた場合も止められるよう、クランプ（clampToScreen）の前に呼ぶこと。
+// アクター goroutine から呼ぶこと。
+func (a *App) avoidStageStrip(prevX float64) {
+	if !a.stage.active || len(a.screens) == 0 || math.Hypot(a.vx, a.vy) <= stageStripEntrySpeed {
+		return
+	}
+	s := a.screens[a.coastScreenIdx]
+	if a.stage.right {
+		if edge := s.maxX - stageStripWidth; prevX <= edge && a.coastX > edge {
+			a.coastX, a.vx = edge, 0
+		}
+	} else {
+		if edge := s.minX + stageStripWidth; prevX >= edge && a.coastX < edge {
+			a.coastX, a.vx = edge, 0
+		}
+	}
+}
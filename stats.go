@@ -0,0 +1,148 @@
+// stats.go: 使用統計の集計と永続化。
+// セッション中のフリック回数・コースト距離などを記録し、終了時に表示する。
+// オプトインで統計ファイルへ累積保存し、`coastpad stats` で表示できる。
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// coastStats はコーストの使用統計を表す。
+type coastStats struct {
+	Flicks        int     `json:"flicks"`         // コーストが発生したフリック回数
+	DragCoasts    int     `json:"drag_coasts"`    // うちドラッグ慣性の回数
+	TotalDistance float64 `json:"total_distance"` // コーストでの総移動距離 (px)
+	LongestThrow  float64 `json:"longest_throw"`  // 1回のコーストでの最長移動距離 (px)
+
+	current float64 // 進行中のコーストの移動距離（永続化しない）
+}
+
+// startCoast はコースト開始を記録する。
+func (s *coastStats) startCoast(isDrag bool) {
+	s.Flicks++
+	if isDrag {
+		s.DragCoasts++
+	}
+	s.current = 0
+}
+
+// addDistance はコーストフレームの移動距離を加算する。
+func (s *coastStats) addDistance(d float64) {
+	s.TotalDistance += d
+	s.current += d
+	if s.current > s.LongestThrow {
+		s.LongestThrow = s.current
+	}
+}
+
+// merge は別の統計を累積する。
+func (s *coastStats) merge(o coastStats) {
+	s.Flicks += o.Flicks
+	s.DragCoasts += o.DragCoasts
+	s.TotalDistance += o.TotalDistance
+	if o.LongestThrow > s.LongestThrow {
+		s.LongestThrow = o.LongestThrow
+	}
+}
+
+// print は統計を人間向けの形式で出力する。
+func (s *coastStats) print(w io.Writer) {
+	fmt.Fprintf(w, "Flicks:         %d\n", s.Flicks)
+	fmt.Fprintf(w, "Drag coasts:    %d\n", s.DragCoasts)
+	fmt.Fprintf(w, "Total distance: %.0f px\n", s.TotalDistance)
+	fmt.Fprintf(w, "Longest throw:  %.0f px\n", s.LongestThrow)
+}
+
+// statsFileName はデータディレクトリ内の統計ファイルの名前。
+const statsFileName = "stats.json"
+
+// loadStats は統計ファイルを読み込む。ファイルが存在しない場合はゼロ値を返す。
+func loadStats(path string) (coastStats, error) {
+	var s coastStats
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// saveStats はセッションの統計を統計ファイルへ累積保存する。
+func saveStats(path string, session coastStats) error {
+	total, err := loadStats(path)
+	if err != nil {
+		return err
+	}
+	total.merge(session)
+
+	data, err := json.MarshalIndent(total, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// persistStats はセッションの統計をデフォルトの統計ファイルへ累積保存する。
+func persistStats(stats coastStats) error {
+	path, err := appDataPath(statsFileName)
+	if err != nil {
+		return err
+	}
+	return saveStats(path, stats)
+}
+
+// Stats はセッション中の統計のコピーを返す。
+func (a *App) Stats() (s coastStats) {
+	a.call(func() { s = a.stats })
+	return s
+}
+
+// runStatsCommand は `coastpad stats` を実行し、累積統計を表示する。
+// 統計ファイルへの保存は終了時のため、実行中ならそのセッションの統計も制御ソケットから読んで表示する。
+func runStatsCommand() error {
+	path, err := appDataPath(statsFileName)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stat(path)
+	saved := !errors.Is(err, fs.ErrNotExist)
+	if saved {
+		s, err := loadStats(path)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Stats file: %s\n", path)
+		s.print(os.Stdout)
+	}
+
+	var session coastStats
+	result, err := sendControl("stats")
+	if err == nil {
+		err = json.Unmarshal(result, &session)
+	}
+	switch {
+	case err == nil:
+		if saved {
+			fmt.Println()
+		}
+		fmt.Println("Current session (not saved yet):")
+		session.print(os.Stdout)
+	case !saved:
+		fmt.Println("No stats recorded yet. Run coastpad with --save-stats to record them.")
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+// swipefriction.go: 4本指の横スワイプによる摩擦の調整（--swipe-friction）。
+// 4本指で左右にスワイプすると減衰率を1段階ずつ上げ下げし、新しい値をログに出す。
+// 設定ファイルや制御コマンドを使わずに、実際にフリックしながら滑り心地を合わせられる。
+// macOS の「フルスクリーンアプリケーション間をスワイプ」を4本指にしている場合は競合するため、3本指にしておくこと。
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// swipeFrictionFingers はスワイプとみなす指の本数。
+	swipeFrictionFingers = 4
+	// swipeFrictionDistance はスワイプとみなす横の移動量（パッドの幅に対する割合）。
+	swipeFrictionDistance = 0.25
+	// swipeFrictionStep は1回のスワイプで減衰率に掛ける（割る）倍率。
+	swipeFrictionStep = 1.15
+)
+
+// trackSwipeFriction は4本指のタッチ中のパッド上の位置を記録する。
+// アクター goroutine から呼ぶこと。
+func (a *App) trackSwipeFriction(fingerCount int, padX, padY float64) {
+	if !a.swipeFriction || fingerCount != swipeFrictionFingers {
+		return
+	}
+	if !a.swipeTracking {
+		a.swipeTracking = true
+		a.swipeStartX, a.swipeStartY = padX, padY
+	}
+	a.swipeEndX, a.swipeEndY = padX, padY
+}
+
+// finishSwipeFriction はリリース時に4本指の横スワイプだったかを判定し、そうなら減衰率を調整する。
+// 右へのスワイプで摩擦を増やし（早く止まる）、左へのスワイプで減らす（よく滑る）。
+// アクター goroutine から呼ぶこと。
+func (a *App) finishSwipeFriction() {
+	if !a.swipeTracking {
+		return
+	}
+	a.swipeTracking = false
+	dx, dy := a.swipeEndX-a.swipeStartX, a.swipeEndY-a.swipeStartY
+	if math.Abs(dx) < swipeFrictionDistance || math.Abs(dx) < 2*math.Abs(dy) {
+		return
+	}
+	r := paramRanges["decay_rate"]
+	decay := a.params.DecayRate * swipeFrictionStep
+	if dx < 0 {
+		decay = a.params.DecayRate / swipeFrictionStep
+	}
+	decay = math.Max(r.min, math.Min(decay, r.max))
+	if decay == a.params.DecayRate {
+		return
+	}
+	a.params.DecayRate = decay
+	a.preset = ""
+	fmt.Printf("[swipe] decay_rate %.2f\n", decay)
+}
@@ -0,0 +1,694 @@
+// taphelper.go: EventTap と保留中のマウスアップを補助プロセスに分ける（--tap-helper）。
+// 補助プロセス（`coastpad tap-helper`）が EventTap を作り、傍受したマウスボタンのイベントを保留するか・
+// 先に発行するかをパイプでエンジン（本体）に問い合わせる。保留したマウスアップは補助プロセスが持ち、
+// 発行もエンジンの指示で補助プロセスが行う。エンジンはマウスアップの複製で状態機械を進める。
+//
+// マルチタッチのプライベート API 等、cgo の多いエンジンがクラッシュしても、補助プロセスはパイプが閉じた時点で
+// 保留中のマウスアップを発行してから終了するため、投げたウインドウのボタンが押されたままにならない
+// （エンジンは launchd の KeepAlive 等で再起動する）。補助プロセスが終了した場合は、エンジンが複製を発行し、
+// 補助プロセスを再起動する。2つのプロセスには別々のサンドボックス・hardened runtime の設定を適用できる。
+//
+// プロトコル（1行1メッセージ、空白区切り。<event> は CGEventCreateData の base64、<seq> は問い合わせの番号）:
+//
+//	補助プロセス → エンジン（補助プロセスの標準出力）
+//	ready                     EventTap を作成した（起動時に1回）
+//	down <seq> <event>        左ボタンのマウスダウン（返答を待つ）
+//	up <seq> <event>          左ボタンのマウスアップ（返答を待つ）
+//	other <seq>               右・その他のボタンのマウスダウン（返答を待つ）
+//	scroll <linesX> <linesY>  平滑化のために消費したホイール入力
+//	scroll-phase              スクロールフェーズ付きのスクロール
+//	key                       キー入力
+//
+//	エンジン → 補助プロセス（補助プロセスの標準入力）
+//	reply <seq> <swallow> <clickThrough> <x> <y> none|release|discard  down への返答（保留中のマウスアップの扱い）
+//	reply <seq> hold|pass <x> <y> <clickState> <flags>                 up への返答（位置と属性を書き換えてから扱う）
+//	reply <seq> none|release                                           other への返答
+//	release <seq>             保留中のマウスアップ（up の seq）を発行する
+//	release-at <seq> <x> <y>  位置を直して発行する
+//	end <seq> <x> <y>         位置を直して発行し、カーソルをワープする（endDragSession）
+//	pause 0|1                 EventTap を再開・一時停止する
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// tapHelperReplyTimeout は補助プロセスがエンジンの返答を待つ時間。過ぎたらイベントをそのまま通す。
+	// EventTap のコールバックが長く止まると tap が無効化されるため、それより十分短くする。
+	tapHelperReplyTimeout = 250 * time.Millisecond
+	// tapHelperRestartDelay は補助プロセスが終了してから再起動するまでの待ち時間。
+	tapHelperRestartDelay = time.Second
+	// tapHelperStopTimeout は終了を依頼してから補助プロセスを強制終了するまでの待ち時間。
+	tapHelperStopTimeout = 2 * time.Second
+)
+
+// tapHelperLink は保留中のマウスアップの発行を指示する補助プロセス（--tap-helper のエンジン側、使わなければ nil）。
+// mouse.go の発行関数が参照する。起動時に決定する。
+var tapHelperLink *tapHelperProcess
+
+// --- エンジン側 ---
+
+// tapHelperProcess は補助プロセスを起動・監視し、問い合わせにアクターの判断で答える。
+type tapHelperProcess struct {
+	app *App
+
+	mu      sync.Mutex // 以下と標準入力への書き込みの保護（監視 goroutine とアクターが並行する）
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	stopped bool
+	paused  bool // 最後に指示した一時停止（再起動した補助プロセスにも伝える）
+
+	// 補助プロセスが持つマウスアップの複製。発行関数に渡された複製がこれらなら、発行を補助プロセスに指示する
+	inflight    eventRef // 返答前の up の複製（アクターが返答前に発行を決めた場合に使う）
+	inflightSeq uint64
+	held        eventRef // 保留中のマウスアップの複製（アクターの pendingMouseUp）
+	heldSeq     uint64
+
+	stop     chan struct{}
+	done     chan struct{} // 監視 goroutine の終了通知
+	stopOnce sync.Once
+}
+
+// startTapHelper は補助プロセスを起動し、EventTap の作成を待ってから監視を開始する。
+func startTapHelper(a *App) (*tapHelperProcess, error) {
+	h := &tapHelperProcess{app: a, stop: make(chan struct{}), done: make(chan struct{})}
+	sc, err := h.spawn()
+	if err != nil {
+		return nil, err
+	}
+	go h.supervise(sc)
+	return h, nil
+}
+
+// tapHelperArgs は補助プロセスのコマンドライン引数を返す。EventTap に関わる設定だけを渡す。
+func (a *App) tapHelperArgs() []string {
+	args := []string{"tap-helper", "-mode=" + a.mode.String(), "-tap-location=" + a.tapLocation, "-tap-options=" + a.tapOptions}
+	for _, opt := range []struct {
+		on   bool
+		flag string
+	}{
+		{a.smoothScroll, "-smooth-scroll"},
+		{a.typingSuppress > 0, "-keys"},
+		{a.karabinerCompat, "-karabiner"},
+		{a.conservativeTap, "-conservative"},
+		{a.alerts.enabled, "-notify"},
+	} {
+		if opt.on {
+			args = append(args, opt.flag)
+		}
+	}
+	return args
+}
+
+// spawn は補助プロセスを起動し、ready の報告まで読む。
+// 端末の Ctrl+C で本体より先に終了しないよう、別のプロセスグループで動かす。
+func (h *tapHelperProcess) spawn() (*bufio.Scanner, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(exe, h.app.tapHelperArgs()...)
+	cmd.Stderr = os.Stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	if h.stopped {
+		h.mu.Unlock()
+		stdin.Close()
+		cmd.Wait()
+		return nil, errors.New("tap helper stopped")
+	}
+	h.cmd, h.stdin = cmd, stdin
+	if h.paused {
+		h.writeLocked("pause", 1)
+	}
+	h.mu.Unlock()
+
+	sc := bufio.NewScanner(stdout)
+	if sc.Scan() && sc.Text() == "ready" {
+		return sc, nil
+	}
+	h.wait()
+	return nil, errors.New("tap helper exited during startup")
+}
+
+// supervise は補助プロセスの問い合わせに答え続け、終了したら再起動する。stopTapHelper まで実行する。
+// 補助プロセスが持っていたマウスアップは失われるため、以降はアクターの持つ複製をエンジンが発行する。
+func (h *tapHelperProcess) supervise(sc *bufio.Scanner) {
+	defer close(h.done)
+
+	for {
+		for sc.Scan() {
+			h.handleLine(sc.Text())
+		}
+		h.wait()
+		select {
+		case <-h.stop:
+			return
+		default:
+		}
+		fmt.Fprintln(os.Stderr, "[tap-helper] tap helper exited, restarting")
+
+		for {
+			select {
+			case <-h.stop:
+				return
+			case <-time.After(tapHelperRestartDelay):
+			}
+			var err error
+			if sc, err = h.spawn(); err == nil {
+				break
+			}
+			fmt.Fprintf(os.Stderr, "[tap-helper] failed to restart tap helper: %v\n", err)
+		}
+	}
+}
+
+// handleLine は補助プロセスの1行を処理する。
+func (h *tapHelperProcess) handleLine(line string) {
+	f := strings.Fields(line)
+	if len(f) == 0 {
+		return
+	}
+	switch {
+	case (f[0] == "down" || f[0] == "up") && len(f) == 3:
+		seq, err1 := strconv.ParseUint(f[1], 10, 64)
+		data, err2 := base64.StdEncoding.DecodeString(f[2])
+		event := eventFromData(data)
+		if err := errors.Join(err1, err2); err != nil || event == 0 {
+			fmt.Fprintf(os.Stderr, "[tap-helper] invalid %s from tap helper: %v\n", f[0], err)
+			return
+		}
+		defer releaseEvent(event)
+		if f[0] == "down" {
+			h.handleMouseDown(seq, event)
+		} else {
+			h.handleMouseUp(seq, event)
+		}
+	case f[0] == "other" && len(f) == 2:
+		seq, err := strconv.ParseUint(f[1], 10, 64)
+		if err != nil {
+			return
+		}
+		action, _ := h.app.askOtherMouseDown()
+		h.replyPending(seq, action.pending, false)
+	case f[0] == "scroll" && len(f) == 3:
+		linesX, err1 := strconv.Atoi(f[1])
+		linesY, err2 := strconv.Atoi(f[2])
+		if err1 == nil && err2 == nil {
+			h.app.onScrollWheel(linesX, linesY)
+		}
+	case f[0] == "scroll-phase":
+		h.app.onScrollPhase()
+	case f[0] == "key":
+		h.app.onKeyDown()
+	}
+}
+
+// handleMouseDown はマウスダウンの問い合わせに答える。
+// アクターが返した保留中のマウスアップ（複製）は、補助プロセスに本物を発行・破棄させて解放する。
+func (h *tapHelperProcess) handleMouseDown(seq uint64, event eventRef) {
+	action, _ := h.app.askMouseDown(event)
+	x, y := action.clickX, action.clickY
+	h.replyPending(seq, action.pending, action.discard, flag01(action.swallow), flag01(action.clickThrough), x, y)
+}
+
+// replyPending は保留中のマウスアップの扱いを最後の項目にした返答を送り、複製を解放する。
+// 補助プロセスの持たない複製（再起動前の補助プロセスが保留したもの）は、破棄しないならエンジンが発行する。
+func (h *tapHelperProcess) replyPending(seq uint64, pending eventRef, discard bool, fields ...any) {
+	h.mu.Lock()
+	held := pending != 0 && pending == h.held
+	decision := "none"
+	switch {
+	case held && discard:
+		decision = "discard"
+	case held:
+		decision = "release"
+	}
+	if held {
+		h.held = 0
+	}
+	h.writeLocked("reply", append(append([]any{seq}, fields...), decision)...)
+	h.mu.Unlock()
+	switch {
+	case pending == 0:
+	case held || discard:
+		releaseEvent(pending)
+	default:
+		releasePendingMouseUp(pending)
+	}
+}
+
+// handleMouseUp はマウスアップの問い合わせに答える。保留するならアクターの持つ複製を held として覚える。
+// アクターが返答の前に発行を決めた場合（release が返答より先に届く）は、補助プロセスが受け取った順に処理する。
+func (h *tapHelperProcess) handleMouseUp(seq uint64, event eventRef) {
+	h.mu.Lock()
+	h.inflight, h.inflightSeq = event, seq
+	h.mu.Unlock()
+
+	held := h.app.handleMouseUp(event)
+	x, y := eventLocation(event)
+	attrs := eventDragAttrs(event)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if held && h.inflight == event {
+		h.held, h.heldSeq = event, seq
+	}
+	h.inflight = 0
+	decision := "pass"
+	if held {
+		decision = "hold"
+	}
+	h.writeLocked("reply", seq, decision, x, y, attrs.clickState, attrs.flags)
+}
+
+// release は event が補助プロセスの持つマウスアップの複製なら、発行を補助プロセスに指示して複製を解放し、true を返す。
+// 補助プロセスが終了していれば false を返し、呼び出し側が複製を発行する。
+func (h *tapHelperProcess) release(event eventRef, cmd string, xy ...float64) bool {
+	if h == nil || event == 0 {
+		return false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var seq uint64
+	switch event {
+	case h.held:
+		seq = h.heldSeq
+	case h.inflight:
+		seq = h.inflightSeq
+	default:
+		return false
+	}
+	fields := []any{seq}
+	for _, v := range xy {
+		fields = append(fields, v)
+	}
+	if !h.writeLocked(cmd, fields...) {
+		return false
+	}
+	if event == h.held {
+		h.held = 0
+	} else {
+		h.inflight = 0
+	}
+	releaseEvent(event)
+	return true
+}
+
+// pause は補助プロセスの EventTap を一時停止（または再開）する。
+func (h *tapHelperProcess) pause(paused bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.paused = paused
+	h.writeLocked("pause", flag01(paused))
+}
+
+// writeLocked は補助プロセスに1行を書く。書けなければ false を返す。mu を保持して呼ぶこと。
+func (h *tapHelperProcess) writeLocked(kind string, fields ...any) bool {
+	if h.stdin == nil {
+		return false
+	}
+	_, err := fmt.Fprintln(h.stdin, append([]any{kind}, fields...)...)
+	return err == nil
+}
+
+// wait は終了した補助プロセスを回収する。補助プロセスの持っていたマウスアップは以降エンジンが発行する。
+func (h *tapHelperProcess) wait() {
+	h.mu.Lock()
+	cmd := h.cmd
+	h.cmd, h.stdin = nil, nil
+	h.held, h.inflight = 0, 0
+	h.mu.Unlock()
+	if cmd != nil {
+		cmd.Wait()
+	}
+}
+
+// stopTapHelper は補助プロセスを終了させ、監視 goroutine の終了を待つ。
+// アクターの終了後（保留中のマウスアップを発行し終えてから）呼ぶこと。
+// 補助プロセスが応答しなければ tapHelperStopTimeout 後に強制終了する。
+func (h *tapHelperProcess) stopTapHelper() {
+	h.stopOnce.Do(func() {
+		close(h.stop)
+		h.mu.Lock()
+		h.stopped = true
+		cmd := h.cmd
+		if h.stdin != nil {
+			h.stdin.Close()
+		}
+		h.mu.Unlock()
+
+		select {
+		case <-h.done:
+		case <-time.After(tapHelperStopTimeout):
+			if cmd != nil {
+				cmd.Process.Kill()
+			}
+			<-h.done
+		}
+	})
+}
+
+// flag01 は bool をプロトコルの 0/1 にする。
+func flag01(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// --- 補助プロセス側 ---
+
+// tapEngineLink は補助プロセスからエンジンへの問い合わせと、保留中のマウスアップを持つ。
+type tapEngineLink struct {
+	mu      sync.Mutex // 以下と標準出力への書き込みの保護（tap のコールバックと標準入力の読み取りが並行する）
+	out     *bufio.Writer
+	seq     uint64
+	waiting uint64   // 返答を待っている問い合わせ（0 ならなし）
+	early   []string // 返答より先に届いた、waiting のマウスアップへの指示
+	held    eventRef // 保留中のマウスアップ（保持している）
+	heldSeq uint64
+
+	replies chan []string // waiting への返答（reply 以降の項目）
+	closed  chan struct{} // エンジンとのパイプが閉じた
+}
+
+func newTapEngineLink(w io.Writer) *tapEngineLink {
+	return &tapEngineLink{
+		out:     bufio.NewWriter(w),
+		replies: make(chan []string, 1),
+		closed:  make(chan struct{}),
+	}
+}
+
+// notify は返答の要らない1行をエンジンに送る。
+func (l *tapEngineLink) notify(kind string, args ...int) {
+	fields := make([]any, len(args))
+	for i, v := range args {
+		fields[i] = v
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.writeLocked(kind, fields...)
+}
+
+// writeLocked はエンジンに1行を書く。mu を保持して呼ぶこと。
+func (l *tapEngineLink) writeLocked(kind string, fields ...any) error {
+	fmt.Fprintln(l.out, append([]any{kind}, fields...)...)
+	return l.out.Flush()
+}
+
+// request はイベントをエンジンに送って返答を待つ。返答の seq 以降の項目を返す。
+// 返答が tapHelperReplyTimeout 以内に来ない・エンジンが終了した場合は ok=false を返す。
+// 返答を受け取ったら finish を呼ぶこと。tap のコールバック（1スレッド）から呼ぶ。
+func (l *tapEngineLink) request(kind string, event eventRef) (seq uint64, reply []string, ok bool) {
+	l.mu.Lock()
+	l.seq++
+	seq = l.seq
+	l.waiting, l.early = seq, nil
+	select {
+	case <-l.replies: // 待ちきれなかった前の返答（run が捨てる前に入ったもの）
+	default:
+	}
+	fields := []any{seq}
+	if event != 0 {
+		fields = append(fields, base64.StdEncoding.EncodeToString(eventData(event)))
+	}
+	err := l.writeLocked(kind, fields...)
+	l.mu.Unlock()
+
+	if err == nil {
+		timer := time.NewTimer(tapHelperReplyTimeout)
+		defer timer.Stop()
+		for !ok {
+			select {
+			case r := <-l.replies:
+				if n, err := strconv.ParseUint(r[0], 10, 64); err == nil && n == seq {
+					reply, ok = r[1:], true
+				}
+			case <-timer.C:
+				fmt.Fprintf(os.Stderr, "[tap-helper] no reply to %s from the engine, passing it through\n", kind)
+				err = errors.New("timeout")
+			case <-l.closed:
+				err = errors.New("closed")
+			}
+			if err != nil {
+				break
+			}
+		}
+	}
+	if !ok {
+		l.finish(0)
+	}
+	return seq, reply, ok
+}
+
+// finish は問い合わせを終え、hold が 0 でなければ保留中のマウスアップにする（保持する）。
+// 返答より先に発行の指示が届いていれば、保留せずにその指示を返す（なければ nil）。
+func (l *tapEngineLink) finish(hold eventRef) (early []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if hold != 0 {
+		retainEvent(hold)
+		if l.held != 0 {
+			// エンジンは新しいマウスアップを保留するとき、前のマウスアップを破棄する（prepareMouseUp）
+			releaseEvent(l.held)
+			l.held = 0
+		}
+		if early = l.early; early == nil {
+			l.held, l.heldSeq = hold, l.waiting
+		}
+	}
+	l.waiting, l.early = 0, nil
+	return early
+}
+
+// takeHeld は保留中のマウスアップを取り出す（なければ 0）。呼び出し側が発行・解放すること。
+func (l *tapEngineLink) takeHeld() eventRef {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	event := l.held
+	l.held = 0
+	return event
+}
+
+// mouseDown はマウスダウンの扱いをエンジンに問い合わせる。
+// 返答が保留中のマウスアップの発行・破棄なら、持っているマウスアップを pending に入れて返す。
+func (l *tapEngineLink) mouseDown(event eventRef) (mouseDownAction, bool) {
+	var action mouseDownAction
+	_, r, ok := l.request("down", event)
+	if !ok {
+		return action, false
+	}
+	l.finish(0)
+	if len(r) != 5 {
+		return action, false
+	}
+	action.swallow = r[0] == "1"
+	action.clickThrough = r[1] == "1"
+	action.clickX, _ = strconv.ParseFloat(r[2], 64)
+	action.clickY, _ = strconv.ParseFloat(r[3], 64)
+	if r[4] != "none" {
+		action.pending = l.takeHeld()
+		action.discard = r[4] == "discard" && action.pending != 0
+	}
+	return action, true
+}
+
+// otherMouseDown は左以外のボタンのマウスダウンの扱いをエンジンに問い合わせる。
+func (l *tapEngineLink) otherMouseDown() (mouseDownAction, bool) {
+	var action mouseDownAction
+	_, r, ok := l.request("other", 0)
+	if !ok {
+		return action, false
+	}
+	l.finish(0)
+	if len(r) == 1 && r[0] == "release" {
+		action.pending = l.takeHeld()
+	}
+	return action, true
+}
+
+// mouseUp はマウスアップを保留するかをエンジンに問い合わせ、保留するなら持っておく。
+// 位置と属性はエンジンの書き換え（クリックスルー・ドラッグの属性）に合わせる。消費した場合は true を返す。
+func (l *tapEngineLink) mouseUp(event eventRef) bool {
+	_, r, ok := l.request("up", event)
+	if !ok {
+		return false
+	}
+	if len(r) != 5 {
+		l.finish(0)
+		return false
+	}
+	x, err1 := strconv.ParseFloat(r[1], 64)
+	y, err2 := strconv.ParseFloat(r[2], 64)
+	clickState, err3 := strconv.Atoi(r[3])
+	flags, err4 := strconv.ParseUint(r[4], 10, 64)
+	if errors.Join(err1, err2, err3, err4) != nil {
+		l.finish(0)
+		return false
+	}
+	setEventLocation(event, x, y)
+	if r[0] != "hold" {
+		l.finish(0)
+		return false
+	}
+	applyDragAttrs(event, dragAttrs{clickState: clickState, flags: flags})
+	if early := l.finish(event); early != nil {
+		// 返答の前に発行が決まっていた（保留した直後にドラッグが終わった）
+		l.execute(early, event)
+	}
+	return true
+}
+
+// run はエンジンからの返答と指示を標準入力から読む。パイプが閉じる（エンジンが終了する）まで実行する。
+func (l *tapEngineLink) run(r io.Reader) {
+	defer close(l.closed)
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if len(f) < 2 {
+			continue
+		}
+		switch f[0] {
+		case "reply":
+			// 待ちきれなかった問い合わせへの返答は捨てる
+			seq, err := strconv.ParseUint(f[1], 10, 64)
+			l.mu.Lock()
+			if err == nil && seq == l.waiting {
+				select {
+				case l.replies <- f[1:]:
+				default:
+				}
+			}
+			l.mu.Unlock()
+		case "release", "release-at", "end":
+			seq, err := strconv.ParseUint(f[1], 10, 64)
+			if err != nil {
+				continue
+			}
+			l.mu.Lock()
+			switch {
+			case l.held != 0 && l.heldSeq == seq:
+				event := l.held
+				l.held = 0
+				l.mu.Unlock()
+				l.execute(f, event)
+			case l.waiting == seq:
+				l.early = f
+				l.mu.Unlock()
+			default:
+				// 返答が間に合わずに通したマウスアップ、または破棄済み
+				l.mu.Unlock()
+			}
+		case "pause":
+			app.pauseEventTap(f[1] == "1")
+		}
+	}
+}
+
+// execute は保留中のマウスアップ event への指示（release / release-at / end）を実行し、event を解放する。
+func (l *tapEngineLink) execute(f []string, event eventRef) {
+	var x, y float64
+	var err error
+	if len(f) == 4 {
+		x, err = strconv.ParseFloat(f[2], 64)
+		if err == nil {
+			y, err = strconv.ParseFloat(f[3], 64)
+		}
+	}
+	switch {
+	case f[0] == "release" || err != nil || len(f) != 4:
+		releasePendingMouseUp(event)
+	case f[0] == "release-at":
+		releasePendingMouseUpAt(event, x, y)
+	default:
+		endDragSession(event, x, y)
+	}
+}
+
+// runTapHelperCommand は `coastpad tap-helper` を実行する（--tap-helper でエンジンが起動する内部用）。
+// EventTap を作って ready を報告し、標準入力が閉じる（エンジンが終了する）まで傍受を続ける。
+// 終了時に保留中のマウスアップがあれば発行する。
+func runTapHelperCommand(args []string) error {
+	fs := flag.NewFlagSet("tap-helper", flag.ContinueOnError)
+	mode := fs.String("mode", "both", "")
+	tapLocation := fs.String("tap-location", tapLocationSession, "")
+	tapOptions := fs.String("tap-options", tapOptionsAuto, "")
+	smoothScroll := fs.Bool("smooth-scroll", false, "")
+	keys := fs.Bool("keys", false, "")
+	karabiner := fs.Bool("karabiner", false, "")
+	conservative := fs.Bool("conservative", false, "")
+	notify := fs.Bool("notify", false, "")
+	if err := fs.Parse(args); err != nil {
+		return errors.New("tap-helper is started by coastpad itself (--tap-helper)")
+	}
+	m, err := parseCoastMode(*mode)
+	if err != nil {
+		return err
+	}
+
+	app = NewApp()
+	app.mode = m
+	app.tapLocation = *tapLocation
+	app.tapOptions = *tapOptions
+	app.smoothScroll = *smoothScroll
+	if *keys {
+		// キー入力を傍受してエンジンに知らせる（抑制する時間はエンジンが判定する）
+		app.typingSuppress = 1
+	}
+	if *karabiner {
+		app.karabinerCompat = true
+		postAtSessionLevel()
+	}
+	if *conservative {
+		app.conservativeTap = true
+		annotateSynthetic = true
+	}
+	app.alerts.enabled = *notify
+	link := newTapEngineLink(os.Stdout)
+	app.tapEngine = link
+
+	if err := app.startEventTap(); err != nil {
+		return err
+	}
+	app.watchdogDone = make(chan struct{})
+	go app.watchEventTap()
+	link.notify("ready")
+
+	link.run(os.Stdin)
+
+	close(app.stop)
+	<-app.watchdogDone
+	app.stopEventTap()
+	if event := link.takeHeld(); event != 0 {
+		fmt.Fprintln(os.Stderr, "[tap-helper] engine exited while holding a mouse-up, releasing it")
+		releasePendingMouseUp(event)
+	}
+	return nil
+}
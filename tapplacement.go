@@ -0,0 +1,94 @@
+// tapplacement.go: EventTap の挿入位置とオプションの指定（--tap-location, --tap-options）。
+// 他のユーティリティやリモートデスクトップのツールとの相性は、どの位置の tap がどの順にイベントを見るかで決まる。
+// HID レベル（kCGHIDEventTap）の tap はセッションレベルの tap より先に、注釈付きセッション
+// （kCGAnnotatedSessionEventTap）の tap は送り先のアプリが決まった後にイベントを見る。
+// 実際の位置は `coastpad doctor` で確かめられる。
+package main
+
+/*
+#cgo LDFLAGS: -framework CoreGraphics
+#include <CoreGraphics/CoreGraphics.h>
+*/
+import "C"
+import "fmt"
+
+// EventTap の挿入位置（--tap-location）
+const (
+	tapLocationSession   = "session"
+	tapLocationHID       = "hid"
+	tapLocationAnnotated = "annotated"
+)
+
+// EventTap のオプション（--tap-options）
+const (
+	tapOptionsAuto       = "auto"        // イベントを書き換えないとき（--mode cursor で平滑化なし）だけ監視専用にする
+	tapOptionsDefault    = "default"     // 常にイベントを書き換えられる tap にする
+	tapOptionsListenOnly = "listen-only" // 常に監視専用の tap にする
+)
+
+// checkTapPlacement は --tap-location と --tap-options の値を確かめる。
+// 監視専用の tap ではマウスアップの保留とスクロールの平滑化ができないため、
+// --mode cursor かつ --smooth-scroll なしのときだけ許す。
+func checkTapPlacement(location, options string, mode coastMode, smoothScroll bool) error {
+	switch location {
+	case tapLocationSession, tapLocationHID, tapLocationAnnotated:
+	default:
+		return fmt.Errorf("invalid --tap-location %q (want session, hid or annotated)", location)
+	}
+	switch options {
+	case tapOptionsAuto, tapOptionsDefault:
+	case tapOptionsListenOnly:
+		if mode != modeCursor || smoothScroll {
+			return fmt.Errorf("--tap-options listen-only needs --mode cursor without --smooth-scroll (drags and scrolling rewrite events)")
+		}
+	default:
+		return fmt.Errorf("invalid --tap-options %q (want auto, default or listen-only)", options)
+	}
+	return nil
+}
+
+// eventTapLocation は EventTap を挿入する位置を返す。
+func (a *App) eventTapLocation() C.CGEventTapLocation {
+	switch a.tapLocation {
+	case tapLocationHID:
+		return C.kCGHIDEventTap
+	case tapLocationAnnotated:
+		return C.kCGAnnotatedSessionEventTap
+	default:
+		return C.kCGSessionEventTap
+	}
+}
+
+// eventTapOptions は自動で選んだ options を --tap-options の指定で上書きする。
+func (a *App) eventTapOptions(auto C.CGEventTapOptions) C.CGEventTapOptions {
+	switch a.tapOptions {
+	case tapOptionsDefault:
+		return C.kCGEventTapOptionDefault
+	case tapOptionsListenOnly:
+		return C.kCGEventTapOptionListenOnly
+	default:
+		return auto
+	}
+}
+
+// tapLocationName は CGEventTapLocation の表示名を返す。
+func tapLocationName(location uint32) string {
+	switch location {
+	case C.kCGHIDEventTap:
+		return tapLocationHID
+	case C.kCGSessionEventTap:
+		return tapLocationSession
+	case C.kCGAnnotatedSessionEventTap:
+		return tapLocationAnnotated
+	default:
+		return fmt.Sprintf("location %d", location)
+	}
+}
+
+// tapOptionsName は CGEventTapOptions の表示名を返す。
+func tapOptionsName(options uint32) string {
+	if options == C.kCGEventTapOptionListenOnly {
+		return tapOptionsListenOnly
+	}
+	return tapOptionsDefault
+}
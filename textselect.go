@@ -0,0 +1,23 @@
+// textselect.go: 文字の選択のドラッグでのドラッグ慣性の無効化（デフォルト、--text-drag-coast で有効）。
+// 文字を選択するドラッグに慣性を付けると、ほぼ必ず選択しすぎる。
+// マウスダウンの位置が文字の要素の上で、フォーカスも文字の要素に移っていれば文字の選択とみなし、
+// ドラッグ慣性を付けない（結果は dragexclude.go のウインドウの除外と同じ経路でアクターに送る）。
+package main
+
+/*
+#cgo LDFLAGS: -framework ApplicationServices
+#include "snap.h"
+*/
+import "C"
+import "time"
+
+// textSelectionFocusDelay はマウスダウンからフォーカスを確かめるまでの待ち時間。
+// クリックを処理したアプリがフォーカスを移すのを待つ。
+const textSelectionFocusDelay = 30 * time.Millisecond
+
+// isTextSelectionAt は (x, y) からのドラッグが文字の選択らしいかを返す。
+// アクセシビリティ API はアプリとの IPC を伴い、フォーカスの移動も待つため、アクターの外で呼ぶこと。
+func isTextSelectionAt(x, y float64) bool {
+	time.Sleep(textSelectionFocusDelay)
+	return C.snap_text_selection_at(C.double(x), C.double(y)) != 0
+}
@@ -0,0 +1,95 @@
+// thermal.go: 熱の状態への適応（--thermal-adapt）。
+// ファンのない機種では、熱の状態が serious 以上になると macOS が性能を絞る。
+// その間はコーストループの間隔を延ばし、HUD の描画を省いて、coastpad が発熱を後押ししないようにする。
+// 状態が戻れば元の滑らかさに戻す。
+package main
+
+/*
+#cgo LDFLAGS: -framework Foundation
+#include "thermal.h"
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 熱の状態（NSProcessInfoThermalState）
+const (
+	thermalNominal  = 0
+	thermalFair     = 1
+	thermalSerious  = 2
+	thermalCritical = 3
+)
+
+// thermalLoopInterval は熱の状態が serious 以上の間のコーストループの間隔の下限（~30Hz）。
+const thermalLoopInterval = 33 * time.Millisecond
+
+// thermalMsg は熱の状態の変化。
+type thermalMsg struct {
+	state int
+}
+
+// thermalStateName は熱の状態の名前を返す。
+func thermalStateName(state int) string {
+	switch state {
+	case thermalNominal:
+		return "nominal"
+	case thermalFair:
+		return "fair"
+	case thermalSerious:
+		return "serious"
+	case thermalCritical:
+		return "critical"
+	}
+	return "unknown"
+}
+
+// startThermalObserver は熱の状態の変化の監視を開始し、現在の状態をアクターに送る。
+func (a *App) startThermalObserver() error {
+	if C.thermal_observe_start() == 0 {
+		return errors.New("failed to observe thermal state changes")
+	}
+	// 監視の開始前の状態を取りこぼさないよう、現在の値を送る
+	a.send(thermalMsg{state: int(C.thermal_state())})
+	return nil
+}
+
+// stopThermalObserver は熱の状態の変化の監視を停止する。
+func stopThermalObserver() {
+	C.thermal_observe_stop()
+}
+
+// goThermalStateChanged は thermal_observe_start の通知ブロック (Objective-C) から呼ばれる cgo export 関数。
+//
+//export goThermalStateChanged
+func goThermalStateChanged(state C.int) {
+	if app != nil {
+		app.send(thermalMsg{state: int(state)})
+	}
+}
+
+// applyThermal は熱の状態を反映し、serious 以上なら負荷を下げる。アクター goroutine から呼ぶこと。
+func (a *App) applyThermal(state int) {
+	a.thermalState = state
+	throttled := state >= thermalSerious
+	if throttled == a.thermalThrottled {
+		return
+	}
+	a.thermalThrottled = throttled
+	if throttled {
+		fmt.Printf("[thermal] thermal state %s, reducing the loop rate and skipping the HUD\n", thermalStateName(state))
+	} else {
+		fmt.Printf("[thermal] thermal state %s, full smoothness restored\n", thermalStateName(state))
+	}
+}
+
+// thermalStatus は制御コマンド status で表示する熱の状態を返す（無効時は空）。
+// アクター goroutine から呼ぶこと。
+func (a *App) thermalStatus() string {
+	if !a.thermalAdapt {
+		return ""
+	}
+	return thermalStateName(a.thermalState)
+}
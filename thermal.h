@@ -0,0 +1,15 @@
+// thermal.h: 熱の状態（NSProcessInfo.thermalState）の取得と変化の監視。
+#ifndef THERMAL_H
+#define THERMAL_H
+
+// 現在の熱の状態を返す（0: nominal, 1: fair, 2: serious, 3: critical）。
+int thermal_state(void);
+
+// 熱の状態の変化の通知（NSProcessInfoThermalStateDidChangeNotification）の監視を開始する。
+// 通知は投稿したスレッドで受け取り、goThermalStateChanged に渡す。失敗すると 0 を返す。
+int thermal_observe_start(void);
+
+// 熱の状態の変化の監視を停止する。
+void thermal_observe_stop(void);
+
+#endif
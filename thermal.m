@@ -0,0 +1,37 @@
+// thermal.m: 熱の状態の取得と、変化の通知の監視。
+#import <Foundation/Foundation.h>
+#include "thermal.h"
+#include "_cgo_export.h"
+
+int thermal_state(void) {
+    return (int)[NSProcessInfo processInfo].thermalState;
+}
+
+static id observer = nil;
+
+int thermal_observe_start(void) {
+    @autoreleasepool {
+        // queue:nil のため、ブロックは通知を投稿したスレッドで実行される（RunLoop は不要）
+        observer = [[NSNotificationCenter defaultCenter]
+            addObserverForName:NSProcessInfoThermalStateDidChangeNotification
+                        object:nil
+                         queue:nil
+                    usingBlock:^(NSNotification *note) {
+            goThermalStateChanged(thermal_state());
+        }];
+        if (observer == nil) {
+            return 0;
+        }
+        [observer retain];
+        return 1;
+    }
+}
+
+void thermal_observe_stop(void) {
+    if (observer == nil) {
+        return;
+    }
+    [[NSNotificationCenter defaultCenter] removeObserver:observer];
+    [observer release];
+    observer = nil;
+}
@@ -0,0 +1,26 @@
+// titlebar.go: ドラッグ慣性をウインドウのタイトルバーのドラッグに限る（--drag-titlebar-only）。
+// ドラッグ慣性はマウスアップを保留してドラッグを続けさせる仕組みのため、どのアプリのどんなドラッグにも影響しうる。
+// ウインドウを投げられれば十分な場合のために、マウスダウンの位置がタイトルバーだと確かめられたドラッグにだけ
+// 慣性を付ける。確かめるまでは除外（dragexclude.go）として扱い、確かめられなければ除外のままにする。
+package main
+
+/*
+#cgo LDFLAGS: -framework ApplicationServices
+#include "snap.h"
+*/
+import "C"
+
+// isTitlebarAt は (x, y) がウインドウのタイトルバーの上かを返す。
+// AX の子要素を持たない独自描画の内容の上でもウインドウ自体が返るため、ウインドウ自体の場合は
+// 閉じるボタン・ツールバーの下端より上にあるときだけタイトルバーとみなす（snap.c）。
+// アクセシビリティ API はアプリとの IPC を伴うため、アクターの外で呼ぶこと。
+func isTitlebarAt(x, y float64) bool {
+	return C.snap_titlebar_at(C.double(x), C.double(y)) != 0
+}
+
+// holdsUnconfirmedDrag はタイトルバーだと確かめられていないドラッグかを返す。
+// そのドラッグでは複数指ドラッグのリリースを待つためのマウスアップの保留もしない。
+// アクター goroutine から呼ぶこと。
+func (a *App) holdsUnconfirmedDrag() bool {
+	return a.dragTitlebarOnly && a.dragExcluded
+}
@@ -2,7 +2,11 @@
 // MultitouchSupport コールバックから呼ばれるタッチ/リリースのフレーム処理。
 package main
 
-import "math"
+import (
+	"fmt"
+	"math"
+	"os"
+)
 
 // onTouchFrame はマルチタッチコールバックから呼ばれる。
 // タッチ中はカーソル履歴を記録し、リリース時に直近2点から速度を算出する。
@@ -10,44 +14,94 @@ import "math"
 // ドラッグ追従: コースト中に複数指で再タッチするとドラッグ追従モードへ移行する。
 // mouseDragged でウィンドウを追従させ、リリース時に速度があれば
 // ドラッグ慣性を再開する。1本指のみの場合はドラッグを終了する。
-func (a *App) onTouchFrame(fingerCount int, timestamp float64) {
-	// cgo 呼び出し（getMouseLocation）を mutex 外で実行
+// カーソル位置の取得（cgo 呼び出し）はコールバック側で行い、処理はアクターに任せる。
+// device はフレームを送ったデバイス（MTDeviceRef のポインタ値）で、複数デバイスの調停に使う。
+// size は接触楕円の長軸の平均（バックエンドが取得できなければ 0）。
+func (a *App) onTouchFrame(device uintptr, fingerCount int, padX, padY, size, timestamp float64) {
 	x, y, ok := getMouseLocation()
 	if !ok {
 		return
 	}
-
-	action := a.prepareTouchFrame(fingerCount, x, y, timestamp)
-	a.executeTouchFrame(action)
+	a.send(touchFrameMsg{device: device, fingerCount: fingerCount, x: x, y: y, padX: padX, padY: padY, size: size, timestamp: timestamp})
 }
 
 // touchAction はタッチフレームで実行するアクションを表す。
-// prepareTouchFrame が mutex 内で準備し、executeTouchAction が mutex 外で実行する。
+// prepareTouchFrame が状態遷移とともに準備し、executeTouchFrame が副作用（cgo 呼び出し）を実行する。
 type touchAction struct {
-	warpX, warpY       float64  // ドラッグ追従開始時のワープ先
-	needWarp           bool     // カーソルワープが必要か
-	syncX, syncY       float64  // ドラッグ追従のイベント位置
-	syncDx, syncDy     int      // ドラッグ追従の整数デルタ
-	needDragSync       bool     // ドラッグイベントの発行が必要か
-	releaseX, releaseY float64  // ドラッグ終了時の位置
-	needDragEnd        bool     // ドラッグセッションの終了が必要か（ワープ付き）
-	needMouseUpOnly    bool     // mouseUp のみ発行（カーソルワープなし）
-	pending            eventRef // 解放するマウスアップ
+	warpX, warpY       float64     // ドラッグ追従開始時のワープ先
+	needWarp           bool        // カーソルワープが必要か
+	syncX, syncY       float64     // ドラッグ追従のイベント位置
+	syncDx, syncDy     int         // ドラッグ追従の整数デルタ
+	needDragSync       bool        // ドラッグイベントの発行が必要か
+	releaseX, releaseY float64     // ドラッグ終了時の位置
+	needDragEnd        bool        // ドラッグセッションの終了が必要か（ワープ付き）
+	needMouseUpOnly    bool        // mouseUp のみ発行（カーソルワープなし）
+	pending            eventRef    // 解放するマウスアップ
+	coastStarted       bool        // リリースでコーストが開始されたか
+	abLabel            string      // A/B モードでコーストを開始した場合のセットラベル（ログ用）
+	hooks              []hookEvent // 実行するシェルフック
 }
 
-// prepareTouchFrame は mutex 内でタッチフレームの状態を計算する。
-func (a *App) prepareTouchFrame(fingerCount int, x, y, timestamp float64) touchAction {
-	a.mu.Lock()
-	defer a.mu.Unlock()
+// arbitrateTouch はデバイスごとのタッチ状態を更新し、フレームをエンジンに渡すかを返す。
+// 内蔵トラックパッドと外付けの Magic Trackpad を併用すると両方のフレームが交互に届き、
+// そのまま1つの状態に流すと指の本数が入れ替わってしまう。そこで最後にタッチを開始した
+// デバイスを採用し、そのデバイスのフレームだけをエンジンに渡す。
+// 採用中のデバイスがタッチしていなければ、タッチ中の他のデバイスに引き継ぐ。
+// ignoreOtherDevices が有効なら、コースト中は開始したデバイス以外のタッチを無視する
+// （外付けパッドの使用中に内蔵パッドに触れてしまってもコーストを止めない）。
+// アクター goroutine から呼ぶこと。
+func (a *App) arbitrateTouch(device uintptr, fingerCount int) bool {
+	prev := a.deviceFingers[device]
+	if fingerCount > 0 {
+		a.deviceFingers[device] = fingerCount
+	} else {
+		delete(a.deviceFingers, device)
+	}
+	if a.ignoreOtherDevices && (a.vx != 0 || a.vy != 0) && device != a.activeDevice {
+		return false
+	}
+	_, activeTouching := a.deviceFingers[a.activeDevice]
+	if fingerCount > 0 && (prev == 0 || !activeTouching) {
+		a.activeDevice = device
+	}
+	return device == a.activeDevice
+}
 
+// prepareTouchFrame はタッチフレームの状態を計算する。
+// x, y はカーソル位置、padX, padY はパッド上の指の正規化座標、size は接触の大きさ（不明なら 0）。
+// アクター goroutine から呼ぶこと。
+func (a *App) prepareTouchFrame(fingerCount int, x, y, padX, padY, size, timestamp float64) touchAction {
 	var action touchAction
 	isTouched := fingerCount > 0
 
 	if isTouched {
-		action = a.handleTouch(fingerCount, x, y, timestamp)
+		if !a.isTouched {
+			// タッチ開始: ジェスチャー追跡をリセットする
+			a.maxFingers = 0
+			a.touchScrolled = false
+			a.armClickThrough(timestamp)
+			a.armBrake()
+			a.startFlickIntent(timestamp)
+		}
+		a.trackClickThrough(x, y)
+		a.trackDeadTime(x, y)
+		a.maxFingers = max(a.maxFingers, fingerCount)
+		a.releaseFingers = fingerCount
+		a.padX, a.padY = padX, padY
+		a.trackSwipeFriction(fingerCount, padX, padY)
+		a.trackContactSize(size)
+		if brake, ok := a.checkBrake(fingerCount); ok {
+			action = brake
+		} else {
+			action = a.handleTouch(fingerCount, x, y, timestamp)
+		}
+		if a.vx != 0 || a.vy != 0 {
+			a.coastEnd = coastEndCaught
+		}
 		a.vx = 0
 		a.vy = 0
 	} else if a.isTouched {
+		a.finishSwipeFriction()
 		action = a.handleRelease(x, y)
 	}
 
@@ -56,7 +110,7 @@ func (a *App) prepareTouchFrame(fingerCount int, x, y, timestamp float64) touchA
 }
 
 // handleTouch はタッチ中のフレームを処理する。dragPhase に応じてサブメソッドへ振り分ける。
-// mu をロックした状態で呼ぶこと。
+// アクター goroutine から呼ぶこと。
 func (a *App) handleTouch(fingerCount int, x, y, timestamp float64) touchAction {
 	// 複数指ドラッグを追跡する（1本指減少時の終了判定に使用）
 	if a.isLeftButtonDown && fingerCount > 1 {
@@ -65,6 +119,8 @@ func (a *App) handleTouch(fingerCount int, x, y, timestamp float64) touchAction
 
 	switch a.dragPhase {
 	case dragPhaseCoasting:
+		a.springHold = false
+		a.dropSettling = false
 		return a.handleTouchDuringCoast(fingerCount, x, y, timestamp)
 	case dragPhasePendingDecision:
 		return a.handleTouchDuringPending(fingerCount, x, y, timestamp)
@@ -75,7 +131,7 @@ func (a *App) handleTouch(fingerCount int, x, y, timestamp float64) touchAction
 
 // handleTouchDuringCoast はコースト中の再タッチを処理する。
 // 慣性を停止し、指の本数に応じてドラッグ追従モードか判定保留モードへ移行する。
-// mu をロックした状態で呼ぶこと。
+// アクター goroutine から呼ぶこと。
 func (a *App) handleTouchDuringCoast(fingerCount int, x, y, timestamp float64) touchAction {
 	var action touchAction
 	a.accumX = 0
@@ -87,13 +143,15 @@ func (a *App) handleTouchDuringCoast(fingerCount int, x, y, timestamp float64) t
 		action.warpX = a.coastX
 		action.warpY = a.coastY
 		action.needWarp = true
-		a.dragPhase = dragPhaseFollowing
+		a.setDragPhase(dragPhaseFollowing)
+		a.dropConfirm = false
 		a.recordCursor(a.coastX, a.coastY, timestamp)
 	} else {
 		// 1本指 → ドラッグ判定を保留する。カーソルはワープしない。
 		// 後続フレームで移動を検出したらドラッグを終了し、
 		// 移動前に複数指になったら追従モードへ移行する。
-		a.dragPhase = dragPhasePendingDecision
+		a.setDragPhase(dragPhasePendingDecision)
+		a.pendingSince = timestamp
 		a.recordCursor(x, y, timestamp)
 	}
 
@@ -102,34 +160,40 @@ func (a *App) handleTouchDuringCoast(fingerCount int, x, y, timestamp float64) t
 
 // handleTouchDuringPending はドラッグ判定保留中の処理を行う。
 // 移動か複数指かで、ドラッグ終了 / 追従モード移行 / 継続待機を判定する。
-// mu をロックした状態で呼ぶこと。
+// 1本指のまま静止してタイムアウトした場合もドラッグを終了する
+// （指を数秒置いたままにするのは、ドラッグを続けたい意図ではないため）。
+// ゴミ箱へのドロップの確認待ちでは、タイムアウトせず、移動してもドロップせずに追従モードへ移る
+// （ドロップするのはタップで確認したときだけ）。
+// アクター goroutine から呼ぶこと。
 func (a *App) handleTouchDuringPending(fingerCount int, x, y, timestamp float64) touchAction {
 	var action touchAction
 	hasMoved := math.Abs(x-a.coastX) > dragFollowMovementThreshold ||
 		math.Abs(y-a.coastY) > dragFollowMovementThreshold
+	timedOut := !a.dropConfirm && a.pendingTimeout > 0 && timestamp-a.pendingSince >= a.pendingTimeout
 
-	if !hasMoved && fingerCount == 1 {
+	if !hasMoved && fingerCount == 1 && !timedOut {
 		// 判定中（1本指、移動なし）→ カーソル位置を記録のみ
 		a.recordCursor(x, y, timestamp)
-	} else if !hasMoved {
-		// 移動前に複数指検出 → ドラッグ追従モードへ
+	} else if (!hasMoved && fingerCount > 1) || a.dropConfirm {
+		// 移動前に複数指検出、または確認待ちでの移動 → ドラッグ追従モードへ
+		a.dropConfirm = false
 		action.warpX = a.coastX
 		action.warpY = a.coastY
 		action.needWarp = true
-		a.dragPhase = dragPhaseFollowing
+		a.setDragPhase(dragPhaseFollowing)
 		a.accumX = 0
 		a.accumY = 0
 		a.histLen = 0
 		a.recordCursor(a.coastX, a.coastY, timestamp)
 	} else {
-		// 移動検出 → コースト位置で mouseUp を発行しドラッグを終了する
+		// 移動検出またはタイムアウト → コースト位置で mouseUp を発行しドラッグを終了する
 		action.releaseX = a.coastX
 		action.releaseY = a.coastY
 		action.needMouseUpOnly = true
 		action.pending = a.pendingMouseUp
 		a.pendingMouseUp = 0
 		a.isLeftButtonDown = false
-		a.dragPhase = dragPhaseNone
+		a.setDragPhase(dragPhaseNone)
 		a.recordCursor(x, y, timestamp)
 	}
 
@@ -138,7 +202,7 @@ func (a *App) handleTouchDuringPending(fingerCount int, x, y, timestamp float64)
 
 // handleTouchDefault は通常のタッチ処理を行う。
 // 複数指→1本指減少によるドラッグ終了と、ドラッグ追従中のイベント発行を処理する。
-// mu をロックした状態で呼ぶこと。
+// アクター goroutine から呼ぶこと。
 func (a *App) handleTouchDefault(fingerCount int, x, y, timestamp float64) touchAction {
 	var action touchAction
 
@@ -149,7 +213,7 @@ func (a *App) handleTouchDefault(fingerCount int, x, y, timestamp float64) touch
 		action.needMouseUpOnly = true
 		action.pending = a.pendingMouseUp
 		a.pendingMouseUp = 0
-		a.dragPhase = dragPhaseNone
+		a.setDragPhase(dragPhaseNone)
 		a.isLeftButtonDown = false
 		a.wasMultiFingerDrag = false
 		a.recordCursor(x, y, timestamp)
@@ -173,11 +237,47 @@ func (a *App) handleTouchDefault(fingerCount int, x, y, timestamp float64) touch
 }
 
 // handleRelease はリリースエッジ（タッチ→非タッチ遷移）を処理する。
-// mu をロックした状態で呼ぶこと。
+// アクター goroutine から呼ぶこと。
 func (a *App) handleRelease(x, y float64) touchAction {
 	var action touchAction
 	a.vx, a.vy = a.calcReleaseVelocity()
+	a.normalizeTrackingSpeed()
+	a.omega = a.releaseOmega()
+	a.coastT = a.lastSampleTime()
+	a.classifyFlick()
 	a.histLen = 0
+	if math.Hypot(a.vx, a.vy) < a.params.MinFlickSpeed {
+		// フリックとみなさない遅いリリースでは慣性を発生させない
+		a.vx, a.vy = 0, 0
+	}
+	if a.edgeOnly && !a.releasedNearPadEdge() {
+		// パッド端でのみ慣性を発生させるモードでは、パッド中央でのリリースは通常動作にする
+		a.vx, a.vy = 0, 0
+	}
+	if !a.isLeftButtonDown && a.typingSuppressed(a.now()) {
+		// タイピング直後にトラックパッドをかすめた場合はカーソル慣性を発生させない。
+		// ボタンを押したままのドラッグは意図的な操作なので抑制しない。
+		a.vx, a.vy = 0, 0
+	}
+	if a.isScrollGesture() {
+		// 2本指スクロールは macOS がスクロールの慣性を生成するため、
+		// 指を離す際のカーソル移動を慣性にしない
+		a.vx, a.vy = 0, 0
+	}
+	a.releaseBrake()
+	a.vx *= a.params.VelocityGain
+	a.vy *= a.params.VelocityGain
+	a.applyLargeCursor()
+	a.applyFingerGain()
+	a.quantizeDirection()
+	a.applyFlickBoost(a.coastT)
+	a.guardShake(a.coastT)
+	a.filterRelease(x, y)
+	if (a.focusMode == modeCursor || a.dragOff || a.dragExcluded) && a.isLeftButtonDown {
+		// 集中モードでカーソル慣性のみに制限中・ドラッグ慣性をオフにしている間・
+		// 除外したウインドウのドラッグ（dragexclude.go）ではドラッグ慣性を開始しない
+		a.vx, a.vy = 0, 0
+	}
 
 	switch a.dragPhase {
 	case dragPhasePendingDecision:
@@ -186,6 +286,11 @@ func (a *App) handleRelease(x, y float64) touchAction {
 		action = a.releaseDefault(x, y)
 	}
 
+	// ドラッグ慣性のみのモード（集中モードでの制限・カーソル慣性のオフを含む）では通常コーストを開始しない
+	if (a.mode == modeDrag || a.focusMode == modeDrag || a.cursorOff) && a.dragPhase != dragPhaseCoasting {
+		a.vx, a.vy = 0, 0
+	}
+
 	// 通常コーストが開始される場合、位置追跡と画面バウンドを初期化する。
 	// ドラッグコーストは releaseDefault 内で別途初期化済み。
 	if (a.vx != 0 || a.vy != 0) && a.dragPhase == dragPhaseNone {
@@ -193,6 +298,20 @@ func (a *App) handleRelease(x, y float64) touchAction {
 		a.coastY = y
 		a.cacheScreenBounds()
 	}
+	if a.vx != 0 || a.vy != 0 {
+		a.coastStartSpeed = math.Hypot(a.vx, a.vy)
+		a.noteCoastDirection()
+		a.frameDeltaAvg = 0
+		a.traj = coastTrajectory{}
+		a.stats.startCoast(a.dragPhase == dragPhaseCoasting)
+		a.startCoastRecord()
+		a.snapRequested = false
+		a.hotEdgeFired = false
+		a.edgeHold = edgeNone
+		action.coastStarted = true
+		action.abLabel = a.abLabel()
+		a.queueHook(&action.hooks, hookCoastStart)
+	}
 
 	return action
 }
@@ -201,22 +320,26 @@ func (a *App) handleRelease(x, y float64) touchAction {
 // コースト位置で mouseUp を発行してドラッグを終了する。
 // カーソルはユーザーの現在位置にあるのでワープしない。
 // 速度があれば通常の慣性として適用される。
-// mu をロックした状態で呼ぶこと。
+// アクター goroutine から呼ぶこと。
 func (a *App) releaseDuringPending() touchAction {
 	var action touchAction
+	if a.dropConfirm {
+		fmt.Fprintln(os.Stderr, "[drag] drop on the Trash confirmed")
+		a.dropConfirm = false
+	}
 	action.releaseX = a.coastX
 	action.releaseY = a.coastY
 	action.needMouseUpOnly = true
 	action.pending = a.pendingMouseUp
 	a.pendingMouseUp = 0
 	a.isLeftButtonDown = false
-	a.dragPhase = dragPhaseNone
+	a.setDragPhase(dragPhaseNone)
 	return action
 }
 
 // releaseDefault は通常のリリース処理を行う。
 // ドラッグ慣性の開始、または保留マウスアップの解放を処理する。
-// mu をロックした状態で呼ぶこと。
+// アクター goroutine から呼ぶこと。
 func (a *App) releaseDefault(x, y float64) touchAction {
 	var action touchAction
 
@@ -226,8 +349,9 @@ func (a *App) releaseDefault(x, y float64) touchAction {
 		a.coastY = y
 		a.accumX = 0
 		a.accumY = 0
-		a.dragPhase = dragPhaseCoasting
+		a.setDragPhase(dragPhaseCoasting)
 		a.cacheScreenBounds()
+		a.startStageDrag()
 	} else if a.pendingMouseUp != 0 {
 		// 速度なし、保留マウスアップがあれば現在位置で解放する。
 		// releasePendingMouseUp（位置修正なし）だとイベントの元のキャプチャ位置
@@ -242,13 +366,24 @@ func (a *App) releaseDefault(x, y float64) touchAction {
 	return action
 }
 
+// releasedNearPadEdge は最後にタッチしていた位置がパッド端から edgeMargin 以内かを返す。
+// 指がパッドの端に達した（パッドが足りなくなった）リリースかどうかの判定に使う。
+// アクター goroutine から呼ぶこと。
+func (a *App) releasedNearPadEdge() bool {
+	d := min(a.padX, 1-a.padX, a.padY, 1-a.padY)
+	return d < a.edgeMargin
+}
+
 // executeTouchFrame はタッチアクションに基づき cgo 呼び出しを実行する。
 func (a *App) executeTouchFrame(action touchAction) {
 	if action.needWarp {
-		syncCursorViaDrag(action.warpX, action.warpY)
+		syncCursorViaDrag(action.warpX, action.warpY, a.dragAttrs)
 	}
 	if action.needDragSync {
-		postSyntheticDrag(action.syncX, action.syncY, action.syncDx, action.syncDy)
+		postSyntheticDrag(action.syncX, action.syncY, action.syncDx, action.syncDy, a.dragAttrs)
+	}
+	if (action.needDragEnd || action.needMouseUpOnly) && action.pending != 0 {
+		a.dragReleaseFeedback()
 	}
 	if action.needDragEnd {
 		endDragSession(action.pending, action.releaseX, action.releaseY)
@@ -259,27 +394,43 @@ func (a *App) executeTouchFrame(action touchAction) {
 		action.pending = 0
 	}
 	releasePendingMouseUp(action.pending)
+	if action.coastStarted {
+		playSound(a.sounds.coastStart)
+	}
+	if action.abLabel != "" {
+		fmt.Fprintf(os.Stderr, "[ab] coast with set %s\n", action.abLabel)
+	}
+	a.runHooks(action.hooks)
 }
 
-// recordCursor はカーソル位置を履歴に追加する（直近2点を保持）。
-// mu をロックした状態で呼ぶこと。
+// recordCursor はカーソル位置を履歴に追加する（直近 historySize 点を保持）。
+// アクター goroutine から呼ぶこと。
 func (a *App) recordCursor(x, y, timestamp float64) {
-	if a.histLen < 2 {
+	if a.histLen < historySize {
 		a.history[a.histLen] = cursorRecord{x, y, timestamp}
 		a.histLen++
 	} else {
-		a.history[0] = a.history[1]
-		a.history[1] = cursorRecord{x, y, timestamp}
+		copy(a.history[:], a.history[1:])
+		a.history[historySize-1] = cursorRecord{x, y, timestamp}
+	}
+}
+
+// lastSampleTime は履歴の最新点のタイムスタンプを返す。履歴がなければ 0 を返す。
+// アクター goroutine から呼ぶこと。
+func (a *App) lastSampleTime() float64 {
+	if a.histLen == 0 {
+		return 0
 	}
+	return a.history[a.histLen-1].timestamp
 }
 
 // calcReleaseVelocity は履歴の直近2点からリリース時の速度を算出する。
-// mu をロックした状態で呼ぶこと。
+// アクター goroutine から呼ぶこと。
 func (a *App) calcReleaseVelocity() (vx, vy float64) {
 	if a.histLen < 2 {
 		return 0, 0
 	}
-	prev, curr := a.history[0], a.history[1]
+	prev, curr := a.history[a.histLen-2], a.history[a.histLen-1]
 	dt := curr.timestamp - prev.timestamp
 	if dt < minTimeDelta {
 		return 0, 0
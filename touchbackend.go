@@ -0,0 +1,97 @@
+// touchbackend.go: タッチバックエンドの選択。
+// タッチフレームの取得元として MultitouchSupport（multitouch.go）、
+// IOHIDEventSystemClient（hidtouch.go）、NSEvent のグローバルモニタ（gesture.go）があり、
+// --touch-backend で選ぶ。
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// TouchDevices はタッチデバイスの監視を表す。どのバックエンドも受信したフレームを App.onTouchFrame に渡す。
+type TouchDevices interface {
+	// RefreshDevices はデバイスの接続・切断を反映する。
+	RefreshDevices()
+	// Count は監視中のデバイス数を返す。
+	Count() int
+	// StopAll は全デバイスの監視を停止する。
+	StopAll()
+}
+
+// タッチバックエンドの名前
+const (
+	touchBackendAuto       = "auto"       // MultitouchSupport を使い、使えなければ HID、NSEvent の順に切り替える
+	touchBackendMultitouch = "multitouch" // MultitouchSupport
+	touchBackendHID        = "hid"        // IOHIDEventSystemClient
+	touchBackendNSEvent    = "nsevent"    // NSEvent のグローバルモニタ（タッチの近似）
+)
+
+// touchBackendNames はフラグのヘルプとエラー用のバックエンド名の一覧。
+var touchBackendNames = []string{touchBackendAuto, touchBackendMultitouch, touchBackendHID, touchBackendNSEvent}
+
+// openTouchDevices は指定したバックエンドでタッチデバイスを検出し、監視を開始する。
+// ignoreVirtual が true なら Karabiner-Elements の仮想デバイスを除外する（HID バックエンドのみ。
+// MultitouchSupport は仮想デバイスを列挙しない）。
+func openTouchDevices(backend string, ignoreVirtual bool) (TouchDevices, error) {
+	switch backend {
+	case touchBackendMultitouch:
+		if !multitouchCaps().touch {
+			return nil, fmt.Errorf("MultitouchSupport touch API unavailable on macOS %s (try --touch-backend hid)", macOSVersion())
+		}
+		td := NewMTTouchDevices()
+		td.RefreshDevices()
+		return td, nil
+	case touchBackendHID:
+		hd, err := NewHIDTouchDevices(ignoreVirtual)
+		if err != nil {
+			return nil, err
+		}
+		hd.RefreshDevices()
+		return hd, nil
+	case touchBackendNSEvent:
+		return NewGestureTouchDevices(), nil
+	case touchBackendAuto:
+		// MultitouchSupport の関数がない・起動時の検出で受信を開始できなかった場合は使わない（capability.go）
+		caps := multitouchCaps()
+		var td *MTTouchDevices
+		if caps.touch {
+			td = NewMTTouchDevices()
+			td.RefreshDevices()
+			if td.Count() > 0 && caps.started {
+				return td, nil
+			}
+		}
+		// MultitouchSupport でデバイスを登録できない（API の変更・トラックパッドなし）場合は HID を試す
+		hd, err := NewHIDTouchDevices(ignoreVirtual)
+		if err != nil {
+			if td != nil && td.available() {
+				return td, nil
+			}
+			// どちらのプライベート API も使えない → タッチを近似する（トラックパッドがないだけなら使わない）
+			fmt.Fprintf(os.Stderr, "[touch] MultitouchSupport and HID unavailable (%v), approximating touches with NSEvent\n", err)
+			if td != nil {
+				td.StopAll()
+			}
+			return NewGestureTouchDevices(), nil
+		}
+		hd.RefreshDevices()
+		if hd.Count() == 0 && td != nil {
+			hd.StopAll()
+			return td, nil
+		}
+		fmt.Fprintln(os.Stderr, "[touch] no usable MultitouchSupport devices, using the HID backend")
+		if td != nil {
+			td.StopAll()
+		}
+		return hd, nil
+	}
+	return nil, fmt.Errorf("unknown touch backend %q (available: %s)", backend, strings.Join(touchBackendNames, ", "))
+}
+
+// needsMainLoop はタッチバックエンドがメインスレッドの NSApplication の RunLoop を必要とするかを返す。
+func (a *App) needsMainLoop() bool {
+	_, ok := a.touchDevices.(*GestureTouchDevices)
+	return ok
+}
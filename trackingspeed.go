@@ -0,0 +1,46 @@
+// trackingspeed.go: システムの軌跡の速さに対するリリース速度の正規化（--normalize-tracking-speed）。
+// システム設定の「軌跡の速さ」を上げるとカーソルの移動量が大きくなり、同じフリックでもコーストが長くなる。
+// リリース速度を基準の軌跡の速さでの値に換算し、設定した滑り心地が軌跡の速さによらず同じになるようにする。
+package main
+
+/*
+#cgo LDFLAGS: -framework CoreFoundation
+#include <stdlib.h>
+#include "defaults.h"
+*/
+import "C"
+import "unsafe"
+
+const (
+	// trackingSpeedKey はトラックパッドの軌跡の速さ（0〜3）のキー（全アプリ共通のドメイン）。
+	trackingSpeedKey = "com.apple.trackpad.scaling"
+	// minTrackingSpeed は換算に使う軌跡の速さの下限。最も遅い設定（0）で速度が発散しないようにする。
+	minTrackingSpeed = 0.125
+)
+
+// readTrackingSpeed はトラックパッドの軌跡の速さを読む。設定がなければ ok=false を返す。
+func readTrackingSpeed() (speed float64, ok bool) {
+	key := C.CString(trackingSpeedKey)
+	defer C.free(unsafe.Pointer(key))
+	var v C.double
+	if C.defaults_get_global_double(key, &v) == 0 {
+		return 0, false
+	}
+	return float64(v), true
+}
+
+// normalizeTrackingSpeed はリリース速度を基準の軌跡の速さ（a.trackingSpeedRef）での値に換算する。
+// 設定の読み込みは CFPreferences の単純なクエリのため、リリース時に1回だけ状態遷移中に行う。
+// アクター goroutine から呼ぶこと。
+func (a *App) normalizeTrackingSpeed() {
+	if a.trackingSpeedRef <= 0 {
+		return
+	}
+	speed, ok := readTrackingSpeed()
+	if !ok {
+		return
+	}
+	scale := a.trackingSpeedRef / max(speed, minTrackingSpeed)
+	a.vx *= scale
+	a.vy *= scale
+}
@@ -0,0 +1,173 @@
+// trajectory.go: コーストの軌跡の閉形式。
+// 指数減衰のコーストは、開始位置 p0・初速 v0 から経過時間 t の位置と速度が
+// p(t) = p0 + v0(1 - e^(-kt))/k、v(t) = v0·e^(-kt) と閉形式で求まる。
+// フレームごとに速度を積分する代わりにこの式を標本化するため、フレームの間隔や分割の仕方
+// （ticker の揺らぎ・ループの周波数）によらず、同じフリックは同じ軌跡をたどって同じ位置に止まる。
+// 画面端でのクランプ・バー上の追加減衰・吸着等で位置や速度が変わった場合は、その状態から軌跡を引き直す。
+//
+// 曲がるコースト（curve.go）では速度を角速度 ω で回転させる。速度を複素数とみなすと
+// v(t) = v0·e^((-k+iω)t)、p(t) = p0 + v0(e^((-k+iω)t) - 1)/(-k+iω) とやはり閉形式で求まり、
+// 速さは ω によらないため停止時刻も変わらない。
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+)
+
+// coastTrajectory は1本の減衰軌跡と、その上の現在の経過時間を表す。
+type coastTrajectory struct {
+	x0, y0   float64 // 軌跡の開始位置
+	vx0, vy0 float64 // 開始時の速度 (px/sec)
+	k        float64 // 減衰率 (1/sec)
+	w        float64 // 角速度 (rad/sec、0 なら直進)
+	stopT    float64 // 速さが停止閾値を下回る時刻（開始からの秒、減衰しなければ +Inf）
+	t        float64 // 開始からの経過時間
+
+	// 最後に標本化した状態。コーストの状態がこれと異なれば、外から変更されたとみなして引き直す
+	x, y, vx, vy float64
+	valid        bool
+}
+
+// newCoastTrajectory は位置 (x, y)・速度 (vx, vy) から始まる軌跡を作る。
+// k は減衰率、w は角速度、th は停止閾値 (px/sec)。
+func newCoastTrajectory(x, y, vx, vy, k, w, th float64) coastTrajectory {
+	tr := coastTrajectory{x0: x, y0: y, vx0: vx, vy0: vy, k: k, w: w, stopT: math.Inf(1),
+		x: x, y: y, vx: vx, vy: vy, valid: true}
+	if speed := math.Hypot(vx, vy); speed <= th {
+		tr.stopT = 0
+	} else if k > 0 && th > 0 {
+		tr.stopT = math.Log(speed/th) / k
+	}
+	return tr
+}
+
+// at は開始から t 秒後の位置と速度を返す。
+func (tr *coastTrajectory) at(t float64) (x, y, vx, vy float64) {
+	if tr.w != 0 {
+		s := complex(-tr.k, tr.w)
+		e := cmplx.Exp(s * complex(t, 0))
+		v0 := complex(tr.vx0, tr.vy0)
+		v := v0 * e
+		d := v0 * (e - 1) / s
+		return tr.x0 + real(d), tr.y0 + imag(d), real(v), imag(v)
+	}
+	if tr.k <= 0 {
+		return tr.x0 + tr.vx0*t, tr.y0 + tr.vy0*t, tr.vx0, tr.vy0
+	}
+	// 1 - e^(-kt) は t が小さいと桁落ちするため expm1 で求める
+	decay := math.Exp(-tr.k * t)
+	dist := -math.Expm1(-tr.k*t) / tr.k
+	return tr.x0 + tr.vx0*dist, tr.y0 + tr.vy0*dist, tr.vx0 * decay, tr.vy0 * decay
+}
+
+// follows はコーストの状態が最後に標本化した状態のままかを返す。
+func (tr *coastTrajectory) follows(x, y, vx, vy float64) bool {
+	return tr.valid && x == tr.x && y == tr.y && vx == tr.vx && vy == tr.vy
+}
+
+// advance は経過時間を dt 進めて、その時点の位置と速度を返す。
+// 停止時刻を過ぎたら停止位置と速度ゼロを返す（停止までの移動量はフレームの間隔によらない）。
+func (tr *coastTrajectory) advance(dt float64) (x, y, vx, vy float64) {
+	tr.t += dt
+	if tr.t >= tr.stopT {
+		x, y, _, _ = tr.at(tr.stopT)
+		vx, vy = 0, 0
+	} else {
+		x, y, vx, vy = tr.at(tr.t)
+	}
+	tr.x, tr.y, tr.vx, tr.vy = x, y, vx, vy
+	return x, y, vx, vy
+}
+
+// advanceCoast はコースト位置と速度を dt 秒進める。
+// 端での保持中（スペース切り替え待ち）は減衰させずに等速で進める。
+// アクター goroutine から呼ぶこと。
+func (a *App) advanceCoast(dt float64) {
+	if a.edgeHold != edgeNone {
+		a.coastX += a.vx * dt
+		a.coastY += a.vy * dt
+		a.traj.valid = false
+		return
+	}
+	// 摩擦の領域（frictionzone.go）に出入りして減衰率が変わった場合も引き直す
+	k := a.zoneDecayRate()
+	if !a.traj.follows(a.coastX, a.coastY, a.vx, a.vy) || a.traj.k != k || a.traj.w != a.omega {
+		a.traj = newCoastTrajectory(a.coastX, a.coastY, a.vx, a.vy, k, a.omega, a.params.StopThreshold)
+	}
+	a.coastX, a.coastY, a.vx, a.vy = a.traj.advance(dt)
+}
+
+// stop は軌跡の停止位置を返す。減衰しない（停止時刻が無限の）軌跡では ok=false を返す。
+func (tr *coastTrajectory) stop() (x, y float64, ok bool) {
+	if math.IsInf(tr.stopT, 1) {
+		return 0, 0, false
+	}
+	x, y, _, _ = tr.at(tr.stopT)
+	return x, y, true
+}
+
+// coastPrediction は現在のコーストの停止予測を表す（制御コマンド predict の結果）。
+type coastPrediction struct {
+	Coasting  bool    `json:"coasting"`
+	Drag      bool    `json:"drag"` // ドラッグ慣性か
+	X         float64 `json:"x"`    // 現在のコースト位置
+	Y         float64 `json:"y"`
+	StopX     float64 `json:"stop_x"` // 予測停止点（画面端でのクランプ前）
+	StopY     float64 `json:"stop_y"`
+	Remaining float64 `json:"remaining"`      // 停止までの残り時間（秒）
+	Edge      string  `json:"edge,omitempty"` // 予測停止点が画面外なら、ぶつかる画面端
+}
+
+// Prediction は現在のコーストの停止予測を返す。コースト中でなければ Coasting=false を返す。
+func (a *App) Prediction() (p coastPrediction) {
+	a.call(func() {
+		p = a.predictCoast()
+	})
+	return p
+}
+
+// predictCoast は現在の位置と速度から引いた軌跡で停止を予測する。
+// 減衰率は advanceCoast と同じく現在位置の摩擦の領域とポインタの大きさを含めた値（zoneDecayRate）を使う。
+// 軌跡がこの先で別の摩擦の領域やバー（bars.go）に入って減衰率が変わることは予測できないため、
+// その場合は実際の停止点とずれる（領域に入った時点で予測し直せば一致する）。
+// 停止点が最後にいたディスプレイの外なら、ぶつかる端を Edge に入れる（ホットエッジ等の事前準備用）。
+// アクター goroutine から呼ぶこと。
+func (a *App) predictCoast() coastPrediction {
+	p := coastPrediction{X: a.coastX, Y: a.coastY, StopX: a.coastX, StopY: a.coastY}
+	if a.vx == 0 && a.vy == 0 {
+		return p
+	}
+	tr := newCoastTrajectory(a.coastX, a.coastY, a.vx, a.vy, a.zoneDecayRate(), a.omega, a.params.StopThreshold)
+	x, y, ok := tr.stop()
+	if !ok {
+		return p
+	}
+	p.Coasting = true
+	p.Drag = a.dragPhase == dragPhaseCoasting
+	p.StopX, p.StopY = x, y
+	p.Remaining = tr.stopT
+	if a.coastScreenIdx < len(a.screens) {
+		s := a.screens[a.coastScreenIdx]
+		switch {
+		case x < s.minX:
+			p.Edge = edgeLeft.String()
+		case x > s.maxX:
+			p.Edge = edgeRight.String()
+		case y < s.minY:
+			p.Edge = edgeTop.String()
+		case y > s.maxY:
+			p.Edge = edgeBottom.String()
+		}
+	}
+	return p
+}
+
+// ctlPredict は制御コマンド `predict` を処理する。
+func ctlPredict(a *App, args []string) (any, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("usage: predict")
+	}
+	return a.Prediction(), nil
+}
@@ -0,0 +1,108 @@
+// trajectory_test.go: 減衰軌跡（coastTrajectory）の停止点と、停止予測（predictCoast）のテスト。
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// trajectoryTolerance は停止点の比較の許容誤差 (px)。
+const trajectoryTolerance = 1e-6
+
+// TestCoastTrajectoryStop は stop() の停止点が、advance で停止まで進めた位置と一致することを確かめる。
+// フレームの間隔によらず同じ位置に止まることも確かめる。
+func TestCoastTrajectoryStop(t *testing.T) {
+	tests := []struct {
+		name   string
+		vx, vy float64
+		k, w   float64
+	}{
+		{name: "straight", vx: 1500, vy: -400, k: 5},
+		{name: "slow decay", vx: -300, vy: 900, k: 1.5},
+		{name: "curved", vx: 2000, vy: 0, k: 4, w: 3},
+		{name: "curved backwards", vx: -800, vy: -800, k: 6, w: -8},
+	}
+	for _, tt := range tests {
+		for _, dt := range []float64{1.0 / 60, 1.0 / 120, 0.037} {
+			tr := newCoastTrajectory(100, 200, tt.vx, tt.vy, tt.k, tt.w, 10)
+			wantX, wantY, ok := tr.stop()
+			if !ok {
+				t.Fatalf("%s: stop() not ok", tt.name)
+			}
+			var x, y, vx, vy float64
+			frames := 0
+			for vx, vy = tt.vx, tt.vy; vx != 0 || vy != 0; frames++ {
+				if frames > 10000 {
+					t.Fatalf("%s (dt %.4f): did not stop", tt.name, dt)
+				}
+				x, y, vx, vy = tr.advance(dt)
+			}
+			if math.Abs(x-wantX) > trajectoryTolerance || math.Abs(y-wantY) > trajectoryTolerance {
+				t.Errorf("%s (dt %.4f): advanced to (%.6f, %.6f), stop() = (%.6f, %.6f)", tt.name, dt, x, y, wantX, wantY)
+			}
+		}
+	}
+}
+
+func TestCoastTrajectoryStopEdgeCases(t *testing.T) {
+	// 減衰しない軌跡は止まらない
+	undamped := newCoastTrajectory(0, 0, 100, 0, 0, 0, 10)
+	if _, _, ok := undamped.stop(); ok {
+		t.Error("undamped trajectory: stop() ok")
+	}
+	// 停止閾値以下の速度ではその場で止まる
+	tr := newCoastTrajectory(50, 60, 5, 5, 5, 0, 10)
+	if x, y, ok := tr.stop(); !ok || x != 50 || y != 60 {
+		t.Errorf("below threshold: stop() = (%.1f, %.1f, %v), want (50, 60, true)", x, y, ok)
+	}
+}
+
+// TestPredictCoastEdge は停止点がディスプレイの外に出る場合に、ぶつかる端が分類されることを確かめる。
+func TestPredictCoastEdge(t *testing.T) {
+	tests := []struct {
+		name     string
+		vx, vy   float64
+		wantEdge string
+	}{
+		{name: "inside", vx: 300, vy: 200, wantEdge: ""},
+		{name: "left", vx: -5000, vy: 100, wantEdge: "left"},
+		{name: "right", vx: 5000, vy: -100, wantEdge: "right"},
+		{name: "top", vx: 100, vy: -5000, wantEdge: "top"},
+		{name: "bottom", vx: -100, vy: 5000, wantEdge: "bottom"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewApp()
+			a.screens = []displayRect{{minX: 0, minY: 0, maxX: 1000, maxY: 800, scale: 1}}
+			a.coastScreenIdx = 0
+			a.coastX, a.coastY = 500, 400
+			a.vx, a.vy = tt.vx, tt.vy
+
+			p := a.predictCoast()
+			if !p.Coasting || p.Drag {
+				t.Fatalf("Coasting = %v, Drag = %v", p.Coasting, p.Drag)
+			}
+			if p.Edge != tt.wantEdge {
+				t.Errorf("Edge = %q, want %q (stop at %.1f, %.1f)", p.Edge, tt.wantEdge, p.StopX, p.StopY)
+			}
+			// 予測はコーストを advanceCoast で停止まで進めた位置と一致する（クランプ前）
+			for frames := 0; a.vx != 0 || a.vy != 0; frames++ {
+				if frames > 10000 {
+					t.Fatal("coast did not stop")
+				}
+				a.advanceCoast(a.loopInterval.Seconds())
+			}
+			if math.Abs(p.StopX-a.coastX) > trajectoryTolerance || math.Abs(p.StopY-a.coastY) > trajectoryTolerance {
+				t.Errorf("predicted (%.6f, %.6f), stopped at (%.6f, %.6f)", p.StopX, p.StopY, a.coastX, a.coastY)
+			}
+		})
+	}
+}
+
+func TestPredictCoastIdle(t *testing.T) {
+	a := NewApp()
+	a.coastX, a.coastY = 10, 20
+	if p := a.predictCoast(); p.Coasting || p.StopX != 10 || p.StopY != 20 || p.Edge != "" {
+		t.Errorf("idle prediction = %+v", p)
+	}
+}
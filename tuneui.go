@@ -0,0 +1,215 @@
+// tuneui.go: 慣性パラメータのライブ調整用 Web UI。
+// 慣性の感触は実際に動かさないと分からないため、スライダーで値を変えると
+// 実行中のエンジンに即座に反映されるページを localhost で提供する。
+// 変更は制御ソケットと同じコマンド（preset / set）として処理する。
+//
+// ブラウザで開いた他のサイトから値を書き換えられないよう、待ち受けはループバックに限り、
+// Host がループバックでないリクエスト（DNS リバインディング）と、
+// 同じオリジン以外からの変更のリクエスト（Origin・Sec-Fetch-Site で判定）を拒否する。
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// tuneUIShutdownTimeout は終了時に処理中のリクエストを待つ時間。
+const tuneUIShutdownTimeout = 2 * time.Second
+
+// startTuneUI は指定アドレスでライブ調整 UI の待ち受けを開始する。待ち受けは App.Stop で閉じる。
+// addr のホストはループバック（127.0.0.1、::1、localhost）でなければならない。
+func startTuneUI(a *App, addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if !isLoopbackHost(host) {
+		return fmt.Errorf("tuning UI must listen on a loopback address (e.g. 127.0.0.1:7070), not %q", addr)
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, tuneUIPage)
+	})
+	mux.HandleFunc("GET /api/params", func(w http.ResponseWriter, r *http.Request) {
+		writeTuneResponse(w, dispatchControl(a, []string{"preset"}))
+	})
+	mux.HandleFunc("POST /api/set", func(w http.ResponseWriter, r *http.Request) {
+		writeTuneResponse(w, dispatchControl(a, []string{"set", r.FormValue("name"), r.FormValue("value")}))
+	})
+	mux.HandleFunc("POST /api/preset", func(w http.ResponseWriter, r *http.Request) {
+		writeTuneResponse(w, dispatchControl(a, []string{"preset", r.FormValue("name")}))
+	})
+
+	srv := &http.Server{Handler: guardTuneRequest(mux)}
+	a.tuneUI = srv
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintf(os.Stderr, "[tune] server stopped: %v\n", err)
+		}
+	}()
+	fmt.Printf("Tuning UI: http://%s/\n", ln.Addr())
+	return nil
+}
+
+// stopTuneUI はライブ調整 UI の待ち受けを閉じ、処理中のリクエストを待つ（開始していなければ何もしない）。
+// リクエストはアクターに問い合わせるため、アクターの終了前に呼ぶこと。
+func (a *App) stopTuneUI() {
+	if a.tuneUI == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), tuneUIShutdownTimeout)
+	defer cancel()
+	if err := a.tuneUI.Shutdown(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "[tune] failed to shut down: %v\n", err)
+	}
+}
+
+// isLoopbackHost は host（ポートなし）がループバックのアドレスか localhost かを返す。空なら false。
+func isLoopbackHost(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
+
+// guardTuneRequest は他のサイトからのリクエストを拒否する。
+// Host がループバックでなければ（DNS リバインディングで別の名前から届いた）拒否し、
+// GET 以外では Origin が自分自身でないか、Sec-Fetch-Site が same-origin でなければ拒否する。
+// どちらのヘッダもないリクエスト（curl 等のブラウザ以外）は通す。
+func guardTuneRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if !isLoopbackHost(host) {
+			http.Error(w, "forbidden host", http.StatusForbidden)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			if origin := r.Header.Get("Origin"); origin != "" && origin != "http://"+r.Host {
+				http.Error(w, "cross-origin request", http.StatusForbidden)
+				return
+			}
+			if site := r.Header.Get("Sec-Fetch-Site"); site != "" && site != "same-origin" {
+				http.Error(w, "cross-site request", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeTuneResponse は制御コマンドのレスポンスを JSON で返す。
+func writeTuneResponse(w http.ResponseWriter, resp controlResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	if !resp.OK {
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// tuneUIPage はライブ調整 UI の HTML。スライダーの範囲は paramRanges から埋め込む。
+var tuneUIPage = strings.NewReplacer(
+	"{{RANGES}}", tuneUIRangesJSON(),
+	"{{PRESETS}}", tuneUIPresetsJSON(),
+).Replace(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>CoastPad tuning</title>
+<style>
+body { font: 14px -apple-system, sans-serif; max-width: 520px; margin: 2em auto; }
+label { display: block; margin-top: 1.2em; }
+input[type=range] { width: 100%; }
+.value { float: right; font-variant-numeric: tabular-nums; }
+#error { color: #c00; }
+</style>
+</head>
+<body>
+<h1>CoastPad tuning</h1>
+<p>Preset: <select id="preset"></select> <span id="current"></span></p>
+<div id="sliders"></div>
+<p id="error"></p>
+<script>
+const ranges = {{RANGES}};
+const presets = {{PRESETS}};
+const sliders = document.getElementById("sliders");
+const errorEl = document.getElementById("error");
+const presetEl = document.getElementById("preset");
+
+function render(result) {
+  document.getElementById("current").textContent = result.preset || "(custom)";
+  presetEl.value = result.preset || "";
+  for (const [name, value] of Object.entries(result.params)) {
+    const input = document.getElementById(name);
+    if (input) {
+      input.value = value;
+      document.getElementById(name + "-value").textContent = value;
+    }
+  }
+}
+
+async function call(method, path, body) {
+  const res = await fetch(path, { method, body: body && new URLSearchParams(body) });
+  const resp = await res.json();
+  errorEl.textContent = resp.ok ? "" : resp.error;
+  if (resp.ok) render(resp.result);
+}
+
+for (const name of presets) {
+  presetEl.add(new Option(name, name));
+}
+presetEl.add(new Option("(custom)", ""));
+presetEl.onchange = () => presetEl.value && call("POST", "/api/preset", { name: presetEl.value });
+
+for (const [name, r] of Object.entries(ranges)) {
+  const label = document.createElement("label");
+  label.innerHTML = name + ' <span class="value" id="' + name + '-value"></span>';
+  const input = document.createElement("input");
+  Object.assign(input, { type: "range", id: name, min: r.min, max: r.max, step: r.step });
+  input.oninput = () => call("POST", "/api/set", { name, value: input.value });
+  label.appendChild(input);
+  sliders.appendChild(label);
+}
+
+call("GET", "/api/params");
+</script>
+</body>
+</html>
+`)
+
+// tuneUIRangesJSON はスライダー設定（範囲と刻み幅）を JSON で返す。
+func tuneUIRangesJSON() string {
+	type slider struct {
+		Min  float64 `json:"min"`
+		Max  float64 `json:"max"`
+		Step float64 `json:"step"`
+	}
+	sliders := make(map[string]slider, len(paramRanges))
+	for name, r := range paramRanges {
+		sliders[name] = slider{Min: r.min, Max: r.max, Step: (r.max - r.min) / 200}
+	}
+	data, _ := json.Marshal(sliders)
+	return string(data)
+}
+
+// tuneUIPresetsJSON はプリセット名の一覧を JSON で返す。
+func tuneUIPresetsJSON() string {
+	data, _ := json.Marshal(presetNames())
+	return string(data)
+}
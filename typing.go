@@ -0,0 +1,24 @@
+// typing.go: タイピング中のカーソル慣性の抑制。
+// macOS の「入力中はトラックパッドを無視」と同様に、キー入力の直後に手のひらや親指が
+// トラックパッドをかすめても、カーソルが文章の途中へ飛んでいかないようにする。
+package main
+
+// keyDownMsg は EventTap からのキー入力。
+type keyDownMsg struct {
+	timestamp float64 // monotonicSeconds の受信時刻
+}
+
+// onKeyDown は EventTap からのキー入力で呼ばれる。時刻の記録はアクターが行う。
+func (a *App) onKeyDown() {
+	if a.tapEngine != nil {
+		a.tapEngine.notify("key")
+		return
+	}
+	a.send(keyDownMsg{timestamp: monotonicSeconds()})
+}
+
+// typingSuppressed は最後のキー入力から typingSuppress 以内で、カーソル慣性を抑制すべきかを返す。
+// アクター goroutine から呼ぶこと。
+func (a *App) typingSuppressed(now float64) bool {
+	return a.typingSuppress > 0 && a.lastKeyDown > 0 && now-a.lastKeyDown < a.typingSuppress
+}
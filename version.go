@@ -0,0 +1,157 @@
+// version.go: バージョン・ビルド情報の表示と更新の確認（`coastpad version`）。
+// プライベートフレームワーク（MultitouchSupport 等）に依存するため、macOS の更新で動かなくなることがある。
+// 不具合の報告や、使っている macOS に対応したリリースかの確認のために、ビルドの出所を表示する。
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ビルド時に -ldflags で埋め込む。例:
+//
+//	go build -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// 埋め込まれていなければ、go build / go install が記録したモジュール・VCS の情報を使う。
+var (
+	version   = ""
+	commit    = ""
+	buildDate = ""
+)
+
+const (
+	// latestReleaseURL は最新リリースを問い合わせる GitHub API の URL。
+	latestReleaseURL = "https://api.github.com/repos/nobmurakita/coastpad/releases/latest"
+
+	updateCheckTimeout = 10 * time.Second
+)
+
+// buildInfo はビルドの出所を表す。
+type buildInfo struct {
+	version   string
+	commit    string
+	buildDate string
+	modified  bool // 未コミットの変更を含むビルドか
+}
+
+// currentBuildInfo は埋め込まれた（なければ Go が記録した）ビルド情報を返す。
+func currentBuildInfo() buildInfo {
+	bi := buildInfo{version: version, commit: commit, buildDate: buildDate}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if bi.version == "" && info.Main.Version != "(devel)" {
+			bi.version = info.Main.Version // go install ...@vX.Y.Z
+		}
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if bi.commit == "" {
+					bi.commit = s.Value
+				}
+			case "vcs.time":
+				if bi.buildDate == "" {
+					bi.buildDate = s.Value
+				}
+			case "vcs.modified":
+				bi.modified = s.Value == "true"
+			}
+		}
+	}
+	if bi.version == "" {
+		bi.version = "devel"
+	}
+	return bi
+}
+
+// macOSVersion は実行中の macOS のバージョン（例: 14.5）を返す。
+func macOSVersion() string {
+	v, err := syscall.Sysctl("kern.osproductversion")
+	if err != nil {
+		return "unknown"
+	}
+	return v
+}
+
+// runVersionCommand は `coastpad version [--check-update]` を実行する。
+// 更新の確認はネットワークに問い合わせるため、--check-update を指定したときだけ行う。
+func runVersionCommand(args []string) error {
+	checkUpdate := false
+	switch {
+	case len(args) == 0:
+	case len(args) == 1 && args[0] == "--check-update":
+		checkUpdate = true
+	default:
+		return errors.New("usage: coastpad version [--check-update]")
+	}
+
+	bi := currentBuildInfo()
+	fmt.Printf("coastpad %s\n", bi.version)
+	if bi.commit != "" {
+		dirty := ""
+		if bi.modified {
+			dirty = " (modified)"
+		}
+		fmt.Printf("  commit:  %s%s\n", bi.commit, dirty)
+	}
+	if bi.buildDate != "" {
+		fmt.Printf("  built:   %s\n", bi.buildDate)
+	}
+	fmt.Printf("  go:      %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Printf("  macOS:   %s\n", macOSVersion())
+	fmt.Printf("  multitouch: %s\n", multitouchCaps().describe())
+
+	if !checkUpdate {
+		return nil
+	}
+	rel, err := fetchLatestRelease()
+	if err != nil {
+		return fmt.Errorf("check for updates: %w", err)
+	}
+	if rel.TagName == bi.version {
+		fmt.Println("Up to date.")
+		return nil
+	}
+	fmt.Printf("Latest release: %s (%s)\n", rel.TagName, rel.HTMLURL)
+	fmt.Println("See the release notes for the macOS versions it supports.")
+	return nil
+}
+
+// githubRelease は GitHub API のリリースのうち使う項目。
+type githubRelease struct {
+	TagName string `json:"tag_name"`
+	HTMLURL string `json:"html_url"`
+}
+
+// fetchLatestRelease は GitHub から最新リリースを取得する。
+func fetchLatestRelease() (githubRelease, error) {
+	var rel githubRelease
+	req, err := http.NewRequest(http.MethodGet, latestReleaseURL, nil)
+	if err != nil {
+		return rel, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "coastpad/"+strings.TrimPrefix(currentBuildInfo().version, "v"))
+
+	client := &http.Client{Timeout: updateCheckTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return rel, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return rel, fmt.Errorf("GitHub API returned %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&rel); err != nil {
+		return rel, fmt.Errorf("parse release: %w", err)
+	}
+	if rel.TagName == "" {
+		return rel, errors.New("no release found")
+	}
+	return rel, nil
+}
@@ -0,0 +1,104 @@
+// virtualhid.c: 仮想 HID タッチパッドの作成と入力レポートの送信。
+// HID Usage Tables の Digitizer ページの TouchPad（Precision Touchpad と同じ構成の最小限）を記述し、
+// IOHIDEventDriver がデジタイザイベント（指ごとの子イベント付き）に変換するようにする。
+// HID バックエンド（hidtouch.c）は PrimaryUsagePage/PrimaryUsage でこのデバイスを選ぶ。
+#include <math.h>
+#include "virtualhid.h"
+
+#define VHID_REPORT_ID 1
+#define VHID_LOGICAL_MAX 4095
+
+// 指1本分のコレクション（Tip Switch・Contact Identifier・X・Y の 6 バイト）
+#define VHID_FINGER \
+    0x09, 0x22,             /*   Usage (Finger) */                   \
+    0xA1, 0x02,             /*   Collection (Logical) */             \
+    0x09, 0x42,             /*     Usage (Tip Switch) */             \
+    0x15, 0x00,             /*     Logical Minimum (0) */            \
+    0x25, 0x01,             /*     Logical Maximum (1) */            \
+    0x75, 0x01,             /*     Report Size (1) */                \
+    0x95, 0x01,             /*     Report Count (1) */               \
+    0x81, 0x02,             /*     Input (Data, Var, Abs) */         \
+    0x95, 0x07,             /*     Report Count (7) */               \
+    0x81, 0x03,             /*     Input (Const) */                  \
+    0x09, 0x51,             /*     Usage (Contact Identifier) */     \
+    0x75, 0x08,             /*     Report Size (8) */                \
+    0x95, 0x01,             /*     Report Count (1) */               \
+    0x26, 0xFF, 0x00,       /*     Logical Maximum (255) */          \
+    0x81, 0x02,             /*     Input (Data, Var, Abs) */         \
+    0x05, 0x01,             /*     Usage Page (Generic Desktop) */   \
+    0x26, 0xFF, 0x0F,       /*     Logical Maximum (4095) */         \
+    0x75, 0x10,             /*     Report Size (16) */               \
+    0x55, 0x0E,             /*     Unit Exponent (-2) */             \
+    0x65, 0x11,             /*     Unit (cm) */                      \
+    0x35, 0x00,             /*     Physical Minimum (0) */           \
+    0x46, 0xE8, 0x03,       /*     Physical Maximum (1000) */        \
+    0x09, 0x30,             /*     Usage (X) */                      \
+    0x09, 0x31,             /*     Usage (Y) */                      \
+    0x95, 0x02,             /*     Report Count (2) */               \
+    0x81, 0x02,             /*     Input (Data, Var, Abs) */         \
+    0x45, 0x00,             /*     Physical Maximum (0) */           \
+    0x55, 0x00,             /*     Unit Exponent (0) */              \
+    0x65, 0x00,             /*     Unit (None) */                    \
+    0x05, 0x0D,             /*     Usage Page (Digitizer) */         \
+    0xC0                    /*   End Collection */
+
+static const uint8_t report_descriptor[] = {
+    0x05, 0x0D,             // Usage Page (Digitizer)
+    0x09, 0x05,             // Usage (Touch Pad)
+    0xA1, 0x01,             // Collection (Application)
+    0x85, VHID_REPORT_ID,   //   Report ID
+    VHID_FINGER,
+    VHID_FINGER,
+    0x09, 0x54,             //   Usage (Contact Count)
+    0x75, 0x08,             //   Report Size (8)
+    0x95, 0x01,             //   Report Count (1)
+    0x25, 0x7F,             //   Logical Maximum (127)
+    0x81, 0x02,             //   Input (Data, Var, Abs)
+    0xC0,                   // End Collection
+};
+
+static void set_int(CFMutableDictionaryRef dict, CFStringRef key, int v) {
+    CFNumberRef n = CFNumberCreate(kCFAllocatorDefault, kCFNumberIntType, &v);
+    CFDictionarySetValue(dict, key, n);
+    CFRelease(n);
+}
+
+IOHIDUserDeviceRef vhid_touchpad_create(void) {
+    CFMutableDictionaryRef props = CFDictionaryCreateMutable(kCFAllocatorDefault, 0,
+                                                             &kCFTypeDictionaryKeyCallBacks, &kCFTypeDictionaryValueCallBacks);
+    CFDataRef descriptor = CFDataCreate(kCFAllocatorDefault, report_descriptor, sizeof(report_descriptor));
+    CFDictionarySetValue(props, CFSTR("ReportDescriptor"), descriptor);
+    CFRelease(descriptor);
+    CFDictionarySetValue(props, CFSTR("Product"), CFSTR("coastpad selftest touchpad"));
+    CFDictionarySetValue(props, CFSTR("Transport"), CFSTR("Virtual"));
+    set_int(props, CFSTR("VendorID"), 0);
+    set_int(props, CFSTR("ProductID"), 0);
+    set_int(props, CFSTR("PrimaryUsagePage"), 0x0D);
+    set_int(props, CFSTR("PrimaryUsage"), 0x05);
+
+    IOHIDUserDeviceRef device = IOHIDUserDeviceCreate(kCFAllocatorDefault, props);
+    CFRelease(props);
+    return device;
+}
+
+IOReturn vhid_touchpad_report(IOHIDUserDeviceRef device, int fingers, double x, double y) {
+    uint8_t report[1 + 6 * VHID_MAX_FINGERS + 1] = {VHID_REPORT_ID};
+    if (fingers > VHID_MAX_FINGERS) {
+        fingers = VHID_MAX_FINGERS;
+    }
+    for (int i = 0; i < fingers; i++) {
+        // 2本目以降の指は少し右に並べる（重心は (x, y) の近くに保つ）
+        double fx = x + 0.02 * i;
+        uint16_t lx = (uint16_t)(fmin(fmax(fx, 0), 1) * VHID_LOGICAL_MAX);
+        uint16_t ly = (uint16_t)(fmin(fmax(y, 0), 1) * VHID_LOGICAL_MAX);
+        uint8_t *f = &report[1 + 6 * i];
+        f[0] = 1; // Tip Switch
+        f[1] = (uint8_t)i;
+        f[2] = lx & 0xFF;
+        f[3] = lx >> 8;
+        f[4] = ly & 0xFF;
+        f[5] = ly >> 8;
+    }
+    report[sizeof(report) - 1] = (uint8_t)fingers;
+    return IOHIDUserDeviceHandleReport(device, report, sizeof(report));
+}
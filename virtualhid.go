@@ -0,0 +1,59 @@
+// virtualhid.go: 結合テスト（selftest.go）用の仮想 HID タッチパッド。
+// IOHIDUserDevice でデジタイザのタッチパッドを作り、台本どおりのタッチを入力レポートとして送る。
+// レポートは IOHIDEventDriver・HID イベントシステムを経て HID バックエンド（hidtouch.go）に届くため、
+// 実際のトラックパッドと同じ経路でエンジンにタッチが入る。
+//
+// 仮想デバイスの作成には com.apple.developer.hid.virtual.device の entitlement が必要で、
+// selftest.entitlements で署名したテスト用のビルドでだけ作れる（README の「実機での動作確認」）。
+package main
+
+/*
+#cgo LDFLAGS: -framework CoreFoundation -framework IOKit
+#include "virtualhid.h"
+*/
+import "C"
+import (
+	"errors"
+	"fmt"
+)
+
+// virtualTouchpadFingers は仮想タッチパッドが同時に報告できる指の本数。
+const virtualTouchpadFingers = C.VHID_MAX_FINGERS
+
+// errNoVirtualHID は仮想デバイスを作れなかった（entitlement のないビルド）ことを表す。
+var errNoVirtualHID = errors.New("can't create a virtual HID device: sign a test build with selftest.entitlements (com.apple.developer.hid.virtual.device)")
+
+// virtualTouchpad は仮想 HID タッチパッドを表す。
+type virtualTouchpad struct {
+	device C.IOHIDUserDeviceRef
+}
+
+// newVirtualTouchpad は仮想タッチパッドを作成する。
+func newVirtualTouchpad() (*virtualTouchpad, error) {
+	device := C.vhid_touchpad_create()
+	if device == nil {
+		return nil, errNoVirtualHID
+	}
+	return &virtualTouchpad{device: device}, nil
+}
+
+// touch は fingers 本の指が (x, y)（パッド上の位置、0〜1、左下原点）に触れている入力レポートを送る。
+// fingers が 0 なら全ての指を離す。
+func (p *virtualTouchpad) touch(fingers int, x, y float64) error {
+	if fingers > virtualTouchpadFingers {
+		return fmt.Errorf("the virtual touchpad reports at most %d fingers", virtualTouchpadFingers)
+	}
+	// デジタイザの Y は上が原点（hidtouch.c で左下原点に戻される）
+	if ret := C.vhid_touchpad_report(p.device, C.int(fingers), C.double(x), C.double(1-y)); ret != 0 {
+		return fmt.Errorf("IOHIDUserDeviceHandleReport failed (0x%x)", uint32(ret))
+	}
+	return nil
+}
+
+// close は仮想タッチパッドを取り外す。
+func (p *virtualTouchpad) close() {
+	if p.device != nil {
+		C.CFRelease(C.CFTypeRef(p.device))
+		p.device = nil
+	}
+}
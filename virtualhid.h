@@ -0,0 +1,23 @@
+// virtualhid.h: 結合テスト用の仮想 HID タッチパッド（IOHIDUserDevice）。
+#ifndef VIRTUALHID_H
+#define VIRTUALHID_H
+
+#include <CoreFoundation/CoreFoundation.h>
+#include <IOKit/IOReturn.h>
+
+typedef struct __IOHIDUserDevice *IOHIDUserDeviceRef;
+
+// IOHIDUserDevice extern 宣言（作成には com.apple.developer.hid.virtual.device の entitlement が必要）
+extern IOHIDUserDeviceRef IOHIDUserDeviceCreate(CFAllocatorRef allocator, CFDictionaryRef properties);
+extern IOReturn IOHIDUserDeviceHandleReport(IOHIDUserDeviceRef device, const uint8_t *report, CFIndex reportLength);
+
+// 仮想タッチパッドが同時に報告できる指の本数。
+#define VHID_MAX_FINGERS 2
+
+// デジタイザの TouchPad として振る舞う仮想デバイスを作成する。作成できなければ NULL を返す。
+IOHIDUserDeviceRef vhid_touchpad_create(void);
+
+// fingers 本の指が (x, y)（0〜1、左上原点）付近に触れている入力レポートを送る。fingers が 0 なら全ての指を離す。
+IOReturn vhid_touchpad_report(IOHIDUserDeviceRef device, int fingers, double x, double y);
+
+#endif